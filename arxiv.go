@@ -0,0 +1,357 @@
+package irdmtools
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	// Caltech Library Packages
+	"github.com/caltechlibrary/simplified"
+)
+
+// ArxivAuthor is an author of an arXiv preprint.
+type ArxivAuthor struct {
+	Name         string   `xml:"name" json:"name,omitempty"`
+	Affiliations []string `xml:"http://arxiv.org/schemas/atom affiliation" json:"affiliations,omitempty"`
+}
+
+// ArxivCategory is an arXiv subject category, e.g. "astro-ph.GA".
+type ArxivCategory struct {
+	Term string `xml:"term,attr" json:"term,omitempty"`
+}
+
+// ArxivLink is a link of an arXiv entry, e.g. to the PDF.
+type ArxivLink struct {
+	Href  string `xml:"href,attr" json:"href,omitempty"`
+	Rel   string `xml:"rel,attr" json:"rel,omitempty"`
+	Title string `xml:"title,attr" json:"title,omitempty"`
+	Type  string `xml:"type,attr" json:"type,omitempty"`
+}
+
+// ArxivEntry is an entry of the arXiv API's Atom feed, see
+// https://info.arxiv.org/help/api/user-manual.html
+type ArxivEntry struct {
+	// ID is the abstract URL including the version,
+	// e.g. "http://arxiv.org/abs/2312.07215v2"
+	ID string `xml:"id" json:"id,omitempty"`
+	// Published is when the first version was submitted
+	Published string `xml:"published" json:"published,omitempty"`
+	// Updated is when the current version was submitted
+	Updated         string           `xml:"updated" json:"updated,omitempty"`
+	Title           string           `xml:"title" json:"title,omitempty"`
+	Summary         string           `xml:"summary" json:"summary,omitempty"`
+	Authors         []*ArxivAuthor   `xml:"author" json:"authors,omitempty"`
+	Links           []*ArxivLink     `xml:"link" json:"links,omitempty"`
+	DOI             string           `xml:"http://arxiv.org/schemas/atom doi" json:"doi,omitempty"`
+	JournalRef      string           `xml:"http://arxiv.org/schemas/atom journal_ref" json:"journal_ref,omitempty"`
+	Comment         string           `xml:"http://arxiv.org/schemas/atom comment" json:"comment,omitempty"`
+	PrimaryCategory *ArxivCategory   `xml:"http://arxiv.org/schemas/atom primary_category" json:"primary_category,omitempty"`
+	Categories      []*ArxivCategory `xml:"category" json:"categories,omitempty"`
+}
+
+type arxivFeed struct {
+	XMLName xml.Name      `xml:"http://www.w3.org/2005/Atom feed"`
+	Entries []*ArxivEntry `xml:"entry"`
+}
+
+var (
+	// reArxivID matches new (e.g. 2312.07215v2) and old style
+	// (e.g. hep-th/9901001v1) arXiv ids with an optional version.
+	reArxivID = regexp.MustCompile(`(?i)^([a-z\-]+(\.[a-z]{2})?/\d{7}|\d{4}\.\d{4,5})(v\d+)?$`)
+)
+
+// ArxivID returns the arXiv id, without version, for an arXiv id
+// (e.g. "arXiv:2312.07215"), arXiv DOI (e.g. "10.48550/arXiv.2312.07215")
+// or abstract URL. It returns false for other identifiers.
+func ArxivID(id string) (string, bool) {
+	id = strings.TrimSpace(id)
+	lower := strings.ToLower(id)
+	for _, prefix := range []string{
+		"https://doi.org/10.48550/arxiv.", "10.48550/arxiv.", "arxiv:",
+		"https://arxiv.org/abs/", "http://arxiv.org/abs/",
+	} {
+		if strings.HasPrefix(lower, prefix) {
+			m := reArxivID.FindStringSubmatch(id[len(prefix):])
+			if m == nil {
+				return "", false
+			}
+			return m[1], true
+		}
+	}
+	return "", false
+}
+
+// arxivVersion returns the arXiv id and version, e.g. "2312.07215" and
+// "v2", from an entry's id.
+func arxivVersion(entryID string) (string, string) {
+	s := entryID
+	if i := strings.Index(s, "/abs/"); i >= 0 {
+		s = s[i+5:]
+	}
+	if m := reArxivID.FindStringSubmatch(s); m != nil {
+		return m[1], strings.ToLower(m[3])
+	}
+	return s, ""
+}
+
+// ParseArxivAtom reads an arXiv API Atom feed, e.g. a saved response from
+// http://export.arxiv.org/api/query?id_list=2312.07215, returning the
+// entries it contains.
+//
+// ```
+// src, _ := os.ReadFile("arxiv-2312.07215.xml")
+// entries, err := ParseArxivAtom(src)
+// if err != nil {
+//     // ... handle error ...
+// }
+// rec, err := CrosswalkArxivEntry(cfg, entries[0], options)
+// ```
+func ParseArxivAtom(src []byte) ([]*ArxivEntry, error) {
+	feed := new(arxivFeed)
+	if err := xml.Unmarshal(src, feed); err != nil {
+		return nil, err
+	}
+	entries := []*ArxivEntry{}
+	for _, entry := range feed.Entries {
+		// NOTE: The API reports errors as an entry
+		if strings.Contains(entry.ID, "/api/errors") {
+			return nil, fmt.Errorf("arxiv: %s", strings.TrimSpace(entry.Summary))
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// queryArxiv calls the arXiv API for an arXiv id.
+func queryArxiv(arxivID string) ([]byte, error) {
+	queryAPI := "https://export.arxiv.org/api/query"
+	client := &http.Client{}
+	req, err := http.NewRequest("GET", queryAPI, nil)
+	if err != nil {
+		return nil, err
+	}
+	q := req.URL.Query()
+	q.Set("id_list", arxivID)
+	req.URL.RawQuery = q.Encode()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s", queryAPI, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// arxivAtomJSON checks an arXiv API response for an error entry and
+// returns the Atom XML as a JSON string, the cache holds JSON. Error
+// responses are returned as errors so they aren't cached.
+func arxivAtomJSON(src []byte) ([]byte, error) {
+	if _, err := ParseArxivAtom(src); err != nil {
+		return nil, err
+	}
+	return JSONMarshal(string(src))
+}
+
+// QueryArxivEntry retrieves the entry for an arXiv id, DOI or abstract
+// URL from the arXiv API. Responses are read from and saved to cfg.Cache
+// when set.
+//
+// ```
+// entry, err := QueryArxivEntry(cfg, "arXiv:2312.07215")
+// if err != nil {
+//     // ... handle error ...
+// }
+// rec, err := CrosswalkArxivEntry(cfg, entry, options)
+// ```
+func QueryArxivEntry(cfg *Config, id string) (*ArxivEntry, error) {
+	arxivID, ok := ArxivID(id)
+	if !ok {
+		return nil, fmt.Errorf("%q is not an arXiv id", id)
+	}
+	src, err := cfg.Cache.Fetch("arxiv", arxivID, func() ([]byte, error) {
+		src, err := queryArxiv(arxivID)
+		if err != nil {
+			return nil, err
+		}
+		return arxivAtomJSON(src)
+	})
+	if err != nil {
+		return nil, err
+	}
+	atom := ""
+	if err := JSONUnmarshal(src, &atom); err != nil {
+		return nil, err
+	}
+	entries, err := ParseArxivAtom([]byte(atom))
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%q not found in arXiv", id)
+	}
+	if cfg.Debug {
+		src, _ := JSONMarshalIndent(entries[0], "", "    ")
+		fmt.Fprintf(os.Stderr, "arxiv entry JSON:\n\n%s\n\n", src)
+	}
+	return entries[0], nil
+}
+
+// normalizeText collapses the line breaks and indenting of Atom text.
+func normalizeText(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// getEntryCreators maps the authors. arXiv gives names as written so the
+// last word is taken as the family name. Affiliations are matched with
+// the ROR data dump index when available.
+func getEntryCreators(cfg *Config, entry *ArxivEntry) []*simplified.Creator {
+	creators := []*simplified.Creator{}
+	for _, author := range entry.Authors {
+		name := normalizeText(author.Name)
+		if name == "" {
+			continue
+		}
+		po := &simplified.PersonOrOrg{
+			Type: "personal",
+		}
+		if i := strings.LastIndex(name, " "); i > 0 {
+			po.GivenName, po.FamilyName = name[0:i], name[i+1:]
+			po.Name = fmt.Sprintf("%s, %s", po.FamilyName, po.GivenName)
+		} else {
+			po.FamilyName, po.Name = name, name
+		}
+		creator := &simplified.Creator{
+			PersonOrOrg: po,
+			Role:        makeSimpleRole("author"),
+		}
+		for _, affiliationName := range author.Affiliations {
+			affiliation := &simplified.Affiliation{Name: normalizeText(affiliationName)}
+			if cfg.Ror != nil {
+				if ror, ok := cfg.Ror.Resolve(affiliation.Name); ok {
+					affiliation = &simplified.Affiliation{ID: ror}
+				}
+			}
+			if !creator.HasAffiliation(affiliation) {
+				creator.Affiliations = append(creator.Affiliations, affiliation)
+			}
+		}
+		creators = append(creators, creator)
+	}
+	return creators
+}
+
+// getEntrySubjects returns the categories as subjects, primary category
+// first.
+func getEntrySubjects(entry *ArxivEntry) []*simplified.Subject {
+	subjects := []*simplified.Subject{}
+	categories := entry.Categories
+	if entry.PrimaryCategory != nil {
+		categories = append([]*ArxivCategory{entry.PrimaryCategory}, categories...)
+	}
+	for _, category := range categories {
+		subject := &simplified.Subject{Subject: strings.TrimSpace(category.Term)}
+		if subject.Subject != "" && !isDuplicateSubject(subject, subjects) {
+			subjects = append(subjects, subject)
+		}
+	}
+	return subjects
+}
+
+// getEntryDates maps the first and current versions to submitted and
+// updated dates. The Atom API only reports these two versions.
+func getEntryDates(entry *ArxivEntry) []*simplified.DateType {
+	dates := []*simplified.DateType{}
+	_, version := arxivVersion(entry.ID)
+	if entry.Published != "" {
+		dates = append(dates, dateTypeFromTimestamp("submitted", entry.Published, "arXiv v1"))
+	}
+	if entry.Updated != "" && version != "" && version != "v1" {
+		dates = append(dates, dateTypeFromTimestamp("updated", entry.Updated, "arXiv "+version))
+	}
+	return dates
+}
+
+// CrosswalkArxivEntry takes an entry from the arXiv API and maps the
+// fields into a simplified Record. The record gets the arXiv DOI, the
+// versions as dates, the categories as subjects and the DOI of the
+// published version as a related identifier. The journal reference and
+// comments become additional descriptions.
+func CrosswalkArxivEntry(cfg *Config, entry *ArxivEntry, options *Doi2RdmOptions) (*simplified.Record, error) {
+	if entry == nil {
+		return nil, fmt.Errorf("arxiv entry not populated")
+	}
+	arxivID, version := arxivVersion(entry.ID)
+	rec := new(simplified.Record)
+	rec.Metadata = new(simplified.Metadata)
+	if err := SetResourceType(rec, "preprint", options.ResourceTypes); err != nil {
+		return nil, err
+	}
+	if err := SetDOI(rec, "10.48550/arXiv."+arxivID); err != nil {
+		return nil, err
+	}
+	if value := normalizeText(entry.Title); value != "" {
+		if err := SetTitle(rec, value); err != nil {
+			return nil, err
+		}
+	}
+	if value := normalizeText(entry.Summary); value != "" {
+		if err := SetDescription(rec, value); err != nil {
+			return nil, err
+		}
+	}
+	if values := getEntryCreators(cfg, entry); len(values) > 0 {
+		if err := SetCreators(rec, values); err != nil {
+			return nil, err
+		}
+	}
+	if err := SetPublisher(rec, "arXiv"); err != nil {
+		return nil, err
+	}
+	AddIdentifier(rec, "arxiv", "arXiv:"+arxivID)
+	rec.Metadata.Version = version
+	if values := getEntrySubjects(entry); len(values) > 0 {
+		if err := AddSubjects(rec, values); err != nil {
+			return nil, err
+		}
+	}
+	for _, dt := range getEntryDates(entry) {
+		if err := AddDate(rec, dt); err != nil {
+			return nil, err
+		}
+	}
+	if len(entry.Published) >= 10 {
+		if err := SetPublicationDate(rec, entry.Published[0:10]); err != nil {
+			return nil, err
+		}
+	}
+	// NOTE: the preprint is a previous version of the published article
+	if value := strings.TrimSpace(entry.DOI); value != "" {
+		if err := AddRelatedIdentifier(rec, "doi", "ispreviousversionof", value); err != nil {
+			return nil, err
+		}
+	}
+	if value := normalizeText(entry.JournalRef); value != "" {
+		rec.Metadata.AdditionalDescriptions = append(rec.Metadata.AdditionalDescriptions, &simplified.Description{
+			Type: &simplified.Type{
+				ID: "additional",
+			},
+			Description: "Journal reference: " + value,
+		})
+	}
+	if value := normalizeText(entry.Comment); value != "" {
+		rec.Metadata.AdditionalDescriptions = append(rec.Metadata.AdditionalDescriptions, &simplified.Description{
+			Type: &simplified.Type{
+				ID: "additional",
+			},
+			Description: "Comments: " + value,
+		})
+	}
+	cfg.People.EnrichCreators(rec)
+	return rec, nil
+}
@@ -0,0 +1,166 @@
+package irdmtools
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestArxivID(t *testing.T) {
+	for id, expected := range map[string]string{
+		"arXiv:2312.07215":                          "2312.07215",
+		"arxiv:2312.07215v2":                        "2312.07215",
+		"10.48550/arXiv.2312.07215":                 "2312.07215",
+		"https://doi.org/10.48550/arXiv.2312.07215": "2312.07215",
+		"https://arxiv.org/abs/2312.07215v3":        "2312.07215",
+		"arXiv:hep-th/9901001v1":                    "hep-th/9901001",
+		"arXiv:math.GT/0309136":                     "math.GT/0309136",
+	} {
+		if got, ok := ArxivID(id); !ok || got != expected {
+			t.Errorf("expected %q for %q, got %q, %t", expected, id, got, ok)
+		}
+	}
+	for _, id := range []string{"10.1021/acsami.7b15651", "arXiv:not-an-id", ""} {
+		if got, ok := ArxivID(id); ok {
+			t.Errorf("expected %q not to be an arXiv id, got %q", id, got)
+		}
+	}
+}
+
+func TestParseArxivAtom(t *testing.T) {
+	entry, err := readArxivFile(path.Join("testdata", "arxiv", "arxiv-2312.07215.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.ID != "http://arxiv.org/abs/2312.07215v2" || entry.DOI != "10.3847/1538-4357/ad0001" || entry.JournalRef != "ApJ 961, 42 (2024)" {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+	if len(entry.Authors) != 2 || len(entry.Authors[1].Affiliations) != 2 {
+		t.Errorf("unexpected authors %+v", entry.Authors)
+	}
+	if entry.PrimaryCategory == nil || entry.PrimaryCategory.Term != "astro-ph.GA" || len(entry.Categories) != 2 {
+		t.Errorf("unexpected categories %+v %+v", entry.PrimaryCategory, entry.Categories)
+	}
+	src, err := os.ReadFile(path.Join("testdata", "arxiv", "arxiv-error.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseArxivAtom(src); err == nil || !strings.Contains(err.Error(), "incorrect id format") {
+		t.Errorf("expected the API error, got %v", err)
+	}
+}
+
+func TestCrosswalkArxivEntry(t *testing.T) {
+	entry, err := readArxivFile(path.Join("testdata", "arxiv", "arxiv-2312.07215.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := LoadRorDump(path.Join("testdata", "ror", "ror-data.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	options, _ := LoadDoi2RdmOptions("", false)
+	rec, err := CrosswalkArxivEntry(&Config{Ror: idx}, entry, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := rec.Metadata
+	if m.Title != "A Survey of Star Formation in Nearby Galaxies" || m.Description != "We survey star formation in nearby galaxies using infrared observations." {
+		t.Errorf("unexpected title or description %q, %q", m.Title, m.Description)
+	}
+	if m.ResourceType["id"] != "publication-preprint" || m.Version != "v2" || m.PublicationDate != "2023-12-12" {
+		t.Errorf("unexpected resource type, version or publication date %+v", m)
+	}
+	if rec.ExternalPIDs["doi"] == nil || rec.ExternalPIDs["doi"].Identifier != "10.48550/arXiv.2312.07215" {
+		t.Errorf("expected the arXiv DOI, got %+v", rec.ExternalPIDs)
+	}
+	if len(m.Dates) != 2 || m.Dates[0].Date != "2023-12-12" || m.Dates[0].Type.ID != "submitted" ||
+		m.Dates[1].Date != "2024-01-15" || m.Dates[1].Type.ID != "updated" || m.Dates[1].Description != "arXiv v2" {
+		t.Errorf("unexpected version dates %+v", m.Dates)
+	}
+	subjects := []string{}
+	for _, subject := range m.Subjects {
+		subjects = append(subjects, subject.Subject)
+	}
+	if strings.Join(subjects, ",") != "astro-ph.GA,astro-ph.SR" {
+		t.Errorf("unexpected subjects %q", subjects)
+	}
+	if len(m.RelatedIdentifiers) != 1 || m.RelatedIdentifiers[0].Identifier != "10.3847/1538-4357/ad0001" || m.RelatedIdentifiers[0].RelationType.ID != "ispreviousversionof" {
+		t.Errorf("expected the published DOI as a related identifier, got %+v", m.RelatedIdentifiers)
+	}
+	if len(m.AdditionalDescriptions) != 2 || m.AdditionalDescriptions[0].Description != "Journal reference: ApJ 961, 42 (2024)" {
+		t.Errorf("unexpected additional descriptions %+v", m.AdditionalDescriptions)
+	}
+	if len(m.Creators) != 2 {
+		t.Fatalf("expected two creators, got %d", len(m.Creators))
+	}
+	doe, roe := m.Creators[0], m.Creators[1]
+	if doe.PersonOrOrg.FamilyName != "Doe" || doe.PersonOrOrg.GivenName != "Jane" || doe.Affiliations[0].ID != "05dxps055" {
+		t.Errorf("unexpected creator %+v %+v", doe.PersonOrOrg, doe.Affiliations)
+	}
+	if len(roe.Affiliations) != 2 || roe.Affiliations[0].ID != "027k65916" || roe.Affiliations[1].Name != "Unseen University" {
+		t.Errorf("unexpected affiliations %+v", roe.Affiliations)
+	}
+}
+
+func TestArxivOffline(t *testing.T) {
+	cache, err := OpenResponseCache(path.Join(t.TempDir(), "api_cache.ds"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+	src, err := os.ReadFile(path.Join("testdata", "arxiv", "arxiv-2312.07215.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	atom, _ := JSONMarshal(string(src))
+	if err := cache.Put("arxiv", "2312.07215", atom); err != nil {
+		t.Fatal(err)
+	}
+	cache.Offline = true
+	options, _ := LoadDoi2RdmOptions("", false)
+	batch, err := newDoiBatch(&Config{Cache: cache}, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Combined mode uses arXiv for arXiv ids
+	for _, id := range []string{"arXiv:2312.07215", "10.48550/arXiv.2312.07215v2"} {
+		rec, err := batch.getRecord("", id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.Metadata.Version != "v2" {
+			t.Errorf("expected the arXiv record for %q, got %+v", id, rec.Metadata)
+		}
+	}
+	if _, err := batch.getRecord("", "arXiv:2401.00001"); err == nil || !strings.Contains(err.Error(), "arxiv:") || !strings.Contains(err.Error(), "datacite:") {
+		t.Errorf("expected arXiv and DataCite errors for an uncached id, got %v", err)
+	}
+}
+
+func TestArxivErrorNotCached(t *testing.T) {
+	cache, err := OpenResponseCache(path.Join(t.TempDir(), "api_cache.ds"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+	for fName, cached := range map[string]bool{
+		"arxiv-error.xml":      false,
+		"arxiv-2312.07215.xml": true,
+	} {
+		src, err := os.ReadFile(path.Join("testdata", "arxiv", fName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = cache.Fetch("arxiv", fName, func() ([]byte, error) {
+			return arxivAtomJSON(src)
+		})
+		if cached != (err == nil) {
+			t.Errorf("%s, unexpected error %v", fName, err)
+		}
+		if _, ok := cache.Get("arxiv", fName); ok != cached {
+			t.Errorf("%s, expected cached %t, got %t", fName, cached, ok)
+		}
+	}
+}
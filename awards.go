@@ -0,0 +1,320 @@
+package irdmtools
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// AwardTerm is a term of the awards vocabulary, a grant or contract
+// and its funder.
+type AwardTerm struct {
+	ID      string            `json:"id" yaml:"id"`
+	Title   map[string]string `json:"title,omitempty" yaml:"title,omitempty"`
+	Number  string            `json:"number" yaml:"number"`
+	Acronym string            `json:"acronym,omitempty" yaml:"acronym,omitempty"`
+	Funder  map[string]string `json:"funder" yaml:"funder"`
+}
+
+// awardSource holds the columns of an awards CSV file. Either the
+// funder and number are given or they are found from the prime or
+// funding source columns of the Caltech awards export.
+type awardSource struct {
+	ID           string
+	Title        string
+	Number       string
+	Funder       string
+	Acronym      string
+	PrimeSource  string
+	PrimeNumber  string
+	OracleSource string
+	OracleNumber string
+}
+
+// mapAwardField maps a column of the awards CSV file to an award
+// source. Other columns are ignored.
+func mapAwardField(award *awardSource, key string, val string) error {
+	val = strings.TrimSpace(val)
+	switch key {
+	case "id", "Award #":
+		award.ID = val
+	case "title", "Award Full Name":
+		award.Title = val
+	case "number":
+		award.Number = val
+	case "funder", "funder_id", "ror":
+		award.Funder = val
+	case "acronym":
+		award.Acronym = val
+	case "Prime Funding Source":
+		award.PrimeSource = val
+	case "Prime Agreement #":
+		award.PrimeNumber = val
+	case "Oracle Funding Source Name":
+		award.OracleSource = val
+	case "Funding Src Award #":
+		award.OracleNumber = val
+	}
+	return nil
+}
+
+// isPlaceholderNumber returns true for the award numbers used when the
+// number isn't known.
+func isPlaceholderNumber(number string) bool {
+	switch strings.ToLower(number) {
+	case "", "unknown", "n/a", "na", "none", "tbd":
+		return true
+	}
+	return false
+}
+
+// ValidAwardNumber returns true if number can be used as an award
+// number, it needs a letter or digit and no control characters.
+func ValidAwardNumber(number string) bool {
+	hasAlnum := false
+	for _, r := range number {
+		if unicode.IsControl(r) {
+			return false
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			hasAlnum = true
+		}
+	}
+	return hasAlnum
+}
+
+// normalizeROR returns the ROR id without the "https://ror.org/" prefix
+// in lower case.
+func normalizeROR(id string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(id), "https://ror.org/"))
+}
+
+// toAwardTerm returns the award term for the source, funder names are
+// resolved with funders. It returns nil if the award has no number.
+func (award *awardSource) toAwardTerm(funders map[string]string) (*AwardTerm, error) {
+	number, funder, funderName := award.Number, award.Funder, ""
+	if award.PrimeSource != "" {
+		funderName = award.PrimeSource
+		if number == "" {
+			number = award.PrimeNumber
+		}
+	} else {
+		funderName = award.OracleSource
+		if number == "" {
+			number = award.OracleNumber
+		}
+	}
+	if isPlaceholderNumber(number) {
+		return nil, nil
+	}
+	if !ValidAwardNumber(number) {
+		return nil, fmt.Errorf("invalid award number %q", number)
+	}
+	if funder == "" {
+		if funderName == "" {
+			return nil, fmt.Errorf("missing funder for award %q", number)
+		}
+		ror, ok := funders[funderName]
+		if !ok {
+			return nil, fmt.Errorf("no funder mapping for %q", funderName)
+		}
+		funder = ror
+	}
+	funder = normalizeROR(funder)
+	if !ValidROR(funder) {
+		return nil, fmt.Errorf("invalid ROR funder id %q", funder)
+	}
+	term := &AwardTerm{
+		ID:      award.ID,
+		Number:  number,
+		Acronym: award.Acronym,
+		Funder:  map[string]string{"id": funder},
+	}
+	if term.ID == "" {
+		// NOTE: RDM uses the funder and number as the id of an award
+		// from a funder's vocabulary.
+		term.ID = funder + "::" + number
+	}
+	if award.Title != "" {
+		term.Title = map[string]string{"en": award.Title}
+	}
+	return term, nil
+}
+
+// ReadFunderNames reads a CSV file, or a JSON array if isCSV is false,
+// with "name" and "ror" columns mapping funder names used in an awards
+// file to ROR ids.
+func ReadFunderNames(src []byte, isCSV bool) (map[string]string, error) {
+	rows, err := readVocabularyRows(src, isCSV)
+	if err != nil {
+		return nil, err
+	}
+	funders := map[string]string{}
+	err = mapVocabularyRows(rows, func(row *vocabularyRow) error {
+		name, ror := strings.TrimSpace(row.Values["name"]), normalizeROR(row.Values["ror"])
+		if name == "" {
+			return fmt.Errorf("missing name")
+		}
+		if !ValidROR(ror) {
+			return fmt.Errorf("invalid ROR id %q for %q", ror, name)
+		}
+		funders[name] = ror
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return funders, nil
+}
+
+// AwardsToVocabulary maps the rows of an awards CSV file, or a JSON
+// array if isCSV is false, to the awards vocabulary sorted by id. Funder
+// names are mapped to ROR ids with funders, DefaultFunderNames is used
+// if funders is nil. Awards without a number are skipped. Rows that
+// can't be mapped are logged and counted, if there are any an error is
+// returned.
+func AwardsToVocabulary(src []byte, isCSV bool, funders map[string]string) ([]*AwardTerm, error) {
+	if funders == nil {
+		funders = DefaultFunderNames
+	}
+	rows, err := readVocabularyRows(src, isCSV)
+	if err != nil {
+		return nil, err
+	}
+	terms := map[string]interface{}{}
+	err = mapVocabularyRows(rows, func(row *vocabularyRow) error {
+		award := new(awardSource)
+		if err := row.mapColumns(func(key string, val string) error {
+			return mapAwardField(award, key, val)
+		}); err != nil {
+			return err
+		}
+		term, err := award.toAwardTerm(funders)
+		if err != nil || term == nil {
+			return err
+		}
+		return uniqueTerms(terms, term.ID, term)
+	})
+	if err != nil {
+		return nil, err
+	}
+	awards := []*AwardTerm{}
+	for _, term := range terms {
+		awards = append(awards, term.(*AwardTerm))
+	}
+	sort.Slice(awards, func(i, j int) bool {
+		return awards[i].ID < awards[j].ID
+	})
+	return awards, nil
+}
+
+// DefaultFunderNames maps the funding source names used in the Caltech
+// awards export to ROR ids.
+var DefaultFunderNames = map[string]string{
+	"AIR FORCE AP":             "006gmme17",
+	"AIR FORCE CR":             "006gmme17",
+	"AIR FORCE":                "006gmme17",
+	"ZZ DO NOT USE -AIR FORCE": "006gmme17",
+	"Asian Office of Aerospace Research and Development": "011e9bt93",
+	"DOD CR": "0447fe631",
+	"DOD AP": "0447fe631",
+	"ZZ - DO NOT USE - DEPARTMENT OF DEFENSE": "0447fe631",
+	"ARMY CR":                "00afsp483",
+	"ARMY AP":                "00afsp483",
+	"ARMY":                   "00afsp483",
+	"ZZ - DO NOT USE - ARMY": "00afsp483",
+	"ONR AP":                 "00rk2pe57",
+	"ONR CR 1":               "00rk2pe57",
+	"DARPA":                  "02caytj08",
+	"DEFENSE ADVANCED RESEARCH PROJECT AGENCY": "02caytj08",
+	"SNWS": "000ztjy10",
+	"Naval Air Warfare Center Aircraft Division - Lakehurst": "03ar0mv07",
+	"NAVAL COMMAND":                                      "03ar0mv07",
+	"NAVY":                                               "03ar0mv07",
+	"FLEET AND INDUSTRIAL SUPPLY CENTER":                 "03ar0mv07",
+	"Naval Research Laboratory":                          "04d23a975",
+	"SPACE AND NAVAL WARFARE SYSTEM":                     "000ztjy10",
+	"Department of Homeland Security":                    "00jyr0d86",
+	"Defense Threat Reduction Agency":                    "04tz64554",
+	"NSA":                                                "0047bvr32",
+	"NATIONAL SECURITY AGENCY":                           "0047bvr32",
+	"US Army Medical Research Command":                   "03cd02q50",
+	"AA - DO NOT USE - US Army Medical Research Command": "03cd02q50",
+	"NIST":                                 "05xpvk416",
+	"NOAA":                                 "02z5nhe81",
+	"USGS":                                 "035a68863",
+	"DOE CR":                               "01bj3aw27",
+	"DOE LC":                               "01bj3aw27",
+	"Department of Energy Pittsburgh":      "01bj3aw27",
+	"DEPARTMENT OF ENERGY, IL":             "01bj3aw27",
+	"Department of Energy Oak Ridge":       "01bj3aw27",
+	"DEPARTMENT OF ENERGY":                 "01bj3aw27",
+	"Department of Energy Pittsburgh-ARRA": "01bj3aw27",
+	"SANDIA NATIONAL LABORATORIES":         "01apwpt12",
+	"Federal Highway Administration":       "0473rr271",
+	"FAA":                                  "05q0y0j38",
+	"EPA":                                  "03tns0030",
+	"UNITED STATES ENVIRONMENTAL PROTECTION AGENCY": "03tns0030",
+	"EPA LC":                                "03tns0030",
+	"NASA Stennis":                          "027ka1x80",
+	"NASA/Johnson Space Center":             "027ka1x80",
+	"NASA Ames":                             "027ka1x80",
+	"ZZ - NASA HEADQUARTERS - DO NOT USE":   "027ka1x80",
+	"NASA Kennedy":                          "027ka1x80",
+	"NASA":                                  "027ka1x80",
+	"NASA GLENN":                            "027ka1x80",
+	"NASA LANGLEY":                          "027ka1x80",
+	"NASA GODDARD LC":                       "027ka1x80",
+	"NASA GODDARD CR":                       "027ka1x80",
+	"NASA GODDARD":                          "027ka1x80",
+	"NASA MARSHALL":                         "027ka1x80",
+	"NASA NSSC":                             "027ka1x80",
+	"NASA HOUSTON":                          "027ka1x80",
+	"NASA HEADQUARTERS":                     "027ka1x80",
+	"NASA AMES":                             "027ka1x80",
+	"NASA SPECIAL":                          "027ka1x80",
+	"NASA Johnson":                          "027ka1x80",
+	"NASA WASHINGTON":                       "027ka1x80",
+	"SMITHSONIAN":                           "01pp8nd67",
+	"NSF":                                   "021nxhr62",
+	"NATIONAL SCIENCE FOUNDATION LIGO":      "021nxhr62",
+	"NATIONAL SCIENCE FOUNDATION ARRA":      "021nxhr62",
+	"NATIONAL SCIENCE FOUNDATION LIGO ARRA": "021nxhr62",
+	"NATIONAL SCIENCE FOUNDATION":           "021nxhr62",
+	"National Science Foundation":           "021nxhr62",
+	"NSF-ARRA":                              "021nxhr62",
+	"JPL":                                   "027k65916",
+	"NIH":                                   "01cwqze88",
+	"NATIONAL INSTITUTES OF HEALTH":         "01cwqze88",
+	"NATIONAL INSTITUTES OF HEALTH ARRA":    "01cwqze88",
+	"ASPR/BARDA":                            "029y69023",
+	"NIH LC":                                "01cwqze88",
+	"NIH CR":                                "01cwqze88",
+	"USAID":                                 "01n6e6j62",
+	"Homeland Security Advanced Research Projects Agency": "00jyr0d86",
+	"Homeland Security Adv Research Projects Agency":      "00jyr0d86",
+	"Centers for Disease Control and Prevention":          "042twtr12",
+	"USDA": "01na82s61",
+	"UNITED STATES DEPARTMENT OF AGRICULTURE":                 "01na82s61",
+	"National Historical Publications and Records Commission": "032214n64",
+	"ZZ - DO NOT USE NATIONAL ENDOWMENT FOR THE HUMANITIES":   "02vdm1p28",
+	"Department of State":                                     "03vvynj75",
+	"U.S. Naval Observatory":                                  "048s2rn92",
+	"Department of Justice":                                   "02916qm60",
+	"FEMA":                                                    "01g9x3v85",
+	"Microelectronics Advanced Research Corporation":          "047z4n946",
+	"USNRC":                                           "03nhmbj89",
+	"FOOD AND DRUG ADMINISTRATION":                    "034xvzb47",
+	"Photonic Systems, Inc.":                          "016s82z56",
+	"National Geospatial-Intelligence Agency":         "02k4pxv54",
+	"Department of the Interior":                      "03v0pmy70",
+	"NATIONAL INSTITUTE OF STANDARDS AND TECHNOLOGY":  "05xpvk416",
+	"NATIONAL OCEANIC AND ATMOSPHERIC ADMINISTRATION": "02z5nhe81",
+	"DEPARTMENT OF EDUCATION":                         "05nne8c43",
+	"UNITED STATES GEOLOGICAL SURVEY":                 "035a68863",
+	"United States Geological Survey-ARRA":            "035a68863",
+	"United States Geological Survey":                 "035a68863",
+	"US GEOLOGICAL SURVEY":                            "035a68863",
+	"United States Bureau of Reclamation":             "00ezrrm21",
+}
@@ -0,0 +1,338 @@
+package irdmtools
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// bibtexTypes maps CSL types (see CSLType) to BibTeX entry types.
+// Patents use the biblatex @patent type.
+var bibtexTypes = map[string]string{
+	"article-journal":  "article",
+	"article":          "unpublished",
+	"book":             "book",
+	"chapter":          "incollection",
+	"paper-conference": "inproceedings",
+	"report":           "techreport",
+	"thesis":           "phdthesis",
+	"patent":           "patent",
+	"periodical":       "periodical",
+}
+
+// latinFold maps accented Latin letters to ASCII for BibTeX keys.
+var latinFold = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ą': "a",
+	'æ': "ae", 'ç': "c", 'ć': "c", 'č': "c", 'ď': "d", 'ð': "d", 'đ': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ę': "e", 'ě': "e",
+	'ğ': "g", 'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'ı': "i",
+	'ł': "l", 'ñ': "n", 'ń': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ő': "o",
+	'œ': "oe", 'ř': "r", 'ś': "s", 'š': "s", 'ş': "s", 'ß': "ss", 'ť': "t", 'þ': "th",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u", 'ű': "u",
+	'ý': "y", 'ÿ': "y", 'ź': "z", 'ż': "z", 'ž': "z",
+}
+
+// bibtexStopWords are skipped when choosing the title word of a key.
+var bibtexStopWords = map[string]bool{
+	"a": true, "an": true, "the": true, "on": true, "of": true, "in": true,
+	"and": true, "for": true, "to": true, "with": true, "from": true, "at": true,
+}
+
+// asciiWord folds s to lower case ASCII letters and digits.
+func asciiWord(s string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			sb.WriteRune(r)
+		default:
+			if folded, ok := latinFold[r]; ok {
+				sb.WriteString(folded)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// citationYear returns the four digit year of the citation's
+// publication date, or the thesis year.
+func citationYear(cite *Citation) string {
+	for _, s := range []string{cite.PublicationDate, cite.ThesisYear} {
+		if len(s) >= 4 && strings.Trim(s[0:4], "0123456789") == "" {
+			return s[0:4]
+		}
+	}
+	return ""
+}
+
+// firstAgentName returns the folded family name, or first word of an
+// organization's name, of the citation's first author or editor.
+func firstAgentName(cite *Citation) string {
+	for _, agents := range [][]*CitationAgent{cite.Author, cite.Editor} {
+		for _, agent := range agents {
+			if agent == nil {
+				continue
+			}
+			name := ""
+			if agent.FamilyName != "" {
+				name = asciiWord(agent.NonDroppingParticle + agent.FamilyName)
+			} else if fields := strings.Fields(agent.Literal); len(fields) > 0 {
+				name = asciiWord(fields[0])
+			}
+			if name != "" {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// bibtexKeyBase returns the key for a citation before collisions are
+// resolved, first author family name, year and first significant word of
+// the title, e.g. "doe2023quantum".
+func bibtexKeyBase(cite *Citation) string {
+	name := firstAgentName(cite)
+	word := ""
+	for _, field := range strings.Fields(cite.Title) {
+		if w := asciiWord(field); w != "" && !bibtexStopWords[w] {
+			word = w
+			break
+		}
+	}
+	key := name + citationYear(cite) + word
+	if key == "" {
+		key = asciiWord(cite.ID)
+	}
+	if key == "" {
+		key = "cite"
+	}
+	return key
+}
+
+// BibTeXKeys returns a map of citation id to BibTeX key. Keys are stable
+// for a given set of citations: when several citations share a key they
+// are ordered by id, the first keeps the key and the others are given
+// the suffixes "a", "b", "c", ...
+func BibTeXKeys(citations []*Citation) map[string]string {
+	groups := map[string][]string{}
+	for _, cite := range citations {
+		base := bibtexKeyBase(cite)
+		groups[base] = append(groups[base], cite.ID)
+	}
+	keys := map[string]string{}
+	used := map[string]bool{}
+	bases := []string{}
+	for base := range groups {
+		bases = append(bases, base)
+		used[base] = true
+	}
+	sort.Strings(bases)
+	for _, base := range bases {
+		ids := groups[base]
+		sort.Strings(ids)
+		keys[ids[0]] = base
+		n := 0
+		for _, id := range ids[1:] {
+			key := ""
+			for key == "" || used[key] {
+				key = base + keySuffix(n)
+				n++
+			}
+			used[key] = true
+			keys[id] = key
+		}
+	}
+	return keys
+}
+
+// keySuffix returns "a" ... "z", "aa", "ab", ... for n = 0, 1, ...
+func keySuffix(n int) string {
+	suffix := ""
+	for n >= 0 {
+		suffix = string(rune('a'+n%26)) + suffix
+		n = n/26 - 1
+	}
+	return suffix
+}
+
+// latexEscaper escapes the LaTeX special characters.
+var latexEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`$`, `\$`,
+	`&`, `\&`,
+	`#`, `\#`,
+	`^`, `\^{}`,
+	`_`, `\_`,
+	`%`, `\%`,
+	`~`, `\~{}`,
+)
+
+// EscapeLaTeX escapes the characters in s that have special meaning in
+// LaTeX so the text prints as is.
+func EscapeLaTeX(s string) string {
+	return latexEscaper.Replace(s)
+}
+
+// bibtexNames formats agents as a BibTeX name list. Organizations are
+// wrapped in braces so they aren't parsed as a person's name.
+func bibtexNames(agents []*CitationAgent) string {
+	names := []string{}
+	for _, agent := range agents {
+		if agent == nil {
+			continue
+		}
+		switch {
+		case agent.FamilyName != "":
+			family := agent.FamilyName
+			if agent.NonDroppingParticle != "" {
+				family = agent.NonDroppingParticle + " " + family
+			}
+			parts := []string{EscapeLaTeX(family)}
+			if agent.Suffix != "" {
+				parts = append(parts, EscapeLaTeX(agent.Suffix))
+			}
+			if agent.LivedName != "" {
+				parts = append(parts, EscapeLaTeX(agent.LivedName))
+			}
+			names = append(names, strings.Join(parts, ", "))
+		case agent.Literal != "":
+			names = append(names, "{"+EscapeLaTeX(agent.Literal)+"}")
+		}
+	}
+	return strings.Join(names, " and ")
+}
+
+// bibtexPages uses an en dash, "--", between page numbers.
+func bibtexPages(pages string) string {
+	if strings.Contains(pages, "--") {
+		return pages
+	}
+	return strings.Replace(pages, "-", "--", 1)
+}
+
+// bibtexThesisType returns the BibTeX thesis entry type for the degree.
+func bibtexThesisType(cite *Citation) string {
+	degree := strings.ToLower(cite.ThesisDegree + " " + cite.ThesisType)
+	if strings.Contains(degree, "master") || strings.Contains(degree, "senior") {
+		return "mastersthesis"
+	}
+	for _, field := range strings.Fields(strings.NewReplacer(".", "", "_", " ").Replace(degree)) {
+		switch field {
+		case "ms", "ma", "msc", "meng", "mba":
+			return "mastersthesis"
+		}
+	}
+	return "phdthesis"
+}
+
+// ToBibTeX returns the citation as a BibTeX entry using key.
+//
+// ```
+// keys := BibTeXKeys(citations)
+// for _, cite := range citations {
+//    fmt.Printf("%s\n", cite.ToBibTeX(keys[cite.ID]))
+// }
+// ```
+func (cite *Citation) ToBibTeX(key string) string {
+	entryType, ok := bibtexTypes[cite.cslType()]
+	if !ok {
+		entryType = "misc"
+	}
+	if entryType == "phdthesis" {
+		entryType = bibtexThesisType(cite)
+	}
+	fields := [][2]string{}
+	add := func(name string, val string, escape bool) {
+		val = strings.TrimSpace(val)
+		if val == "" {
+			return
+		}
+		if escape {
+			val = EscapeLaTeX(val)
+		}
+		fields = append(fields, [2]string{name, val})
+	}
+	if names := bibtexNames(cite.Author); names != "" {
+		fields = append(fields, [2]string{"author", names})
+	}
+	if names := bibtexNames(cite.Editor); names != "" {
+		fields = append(fields, [2]string{"editor", names})
+	}
+	if names := bibtexNames(cite.Translator); names != "" {
+		fields = append(fields, [2]string{"translator", names})
+	}
+	add("title", cite.Title, true)
+	switch entryType {
+	case "article":
+		add("journal", cite.Publication, true)
+	case "incollection", "inproceedings":
+		if cite.BookTitle != "" {
+			add("booktitle", cite.BookTitle, true)
+		} else {
+			add("booktitle", cite.Publication, true)
+		}
+	}
+	add("year", citationYear(cite), false)
+	if dt := parseCSLDate(cite.PublicationDate); dt != nil && len(dt.DateParts) > 0 && len(dt.DateParts[0]) > 1 {
+		add("month", fmt.Sprintf("%d", dt.DateParts[0][1]), false)
+	}
+	add("volume", cite.Volume, true)
+	switch entryType {
+	case "techreport":
+		add("institution", cite.Publisher, true)
+		add("number", cite.SeriesNumber, true)
+	case "phdthesis", "mastersthesis":
+		add("school", cite.Publisher, true)
+		add("type", cite.ThesisType, true)
+	case "patent":
+		add("number", cite.PatentNumber, true)
+		add("holder", cite.PatentAssignee, true)
+		if cite.PatentApplication != "" {
+			add("note", "Application "+cite.PatentApplication, true)
+		}
+	default:
+		add("number", cite.Issue, true)
+		add("publisher", cite.Publisher, true)
+	}
+	if entryType != "techreport" {
+		add("series", cite.Series, true)
+	}
+	add("chapter", cite.Chapters, true)
+	add("pages", bibtexPages(cite.Pages), true)
+	add("edition", cite.Edition, true)
+	add("address", cite.PlaceOfPublication, true)
+	add("isbn", cite.ISBN, true)
+	add("issn", cite.ISSN, true)
+	add("doi", strings.TrimPrefix(cite.DOI, "https://doi.org/"), false)
+	add("url", cite.CiteUsingURL, false)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "@%s{%s", entryType, key)
+	for _, field := range fields {
+		fmt.Fprintf(&sb, ",\n  %s = {%s}", field[0], field[1])
+	}
+	sb.WriteString("\n}\n")
+	return sb.String()
+}
+
+// WriteBibTeX writes the citations as BibTeX entries with keys from
+// BibTeXKeys.
+func WriteBibTeX(out io.Writer, citations []*Citation) error {
+	keys := BibTeXKeys(citations)
+	for i, cite := range citations {
+		if i > 0 {
+			if _, err := fmt.Fprintln(out); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprint(out, cite.ToBibTeX(keys[cite.ID])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package irdmtools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBibTeXKeys(t *testing.T) {
+	doe := []*CitationAgent{{FamilyName: "Doé", LivedName: "Jane"}}
+	citations := []*Citation{
+		{ID: "authors:3", Author: doe, Title: "The Quantum Thing", PublicationDate: "2023-05"},
+		{ID: "authors:1", Author: doe, Title: "Quantum things", PublicationDate: "2023"},
+		{ID: "authors:2", Author: doe, Title: "A quantum", PublicationDate: "2023-01-02"},
+		{ID: "authors:4", Author: doe, Title: "Quantum", PublicationDate: "2023", Publication: "a"},
+		{ID: "authors:5", Author: doe, Title: "Quantuma", PublicationDate: "2023"},
+		{ID: "authors:6", Title: "", PublicationDate: ""},
+	}
+	expected := map[string]string{
+		"authors:1": "doe2023quantum",
+		"authors:2": "doe2023quantumb",
+		"authors:3": "doe2023quantumc",
+		"authors:4": "doe2023quantumd",
+		"authors:5": "doe2023quantuma",
+		"authors:6": "authors6",
+	}
+	keys := BibTeXKeys(citations)
+	for id, key := range expected {
+		if keys[id] != key {
+			t.Errorf("expected key %q for %s, got %q", key, id, keys[id])
+		}
+	}
+	// Keys don't depend on the order of the citations
+	reversed := []*Citation{}
+	for i := len(citations) - 1; i >= 0; i-- {
+		reversed = append(reversed, citations[i])
+	}
+	for id, key := range BibTeXKeys(reversed) {
+		if keys[id] != key {
+			t.Errorf("expected stable key %q for %s, got %q", keys[id], id, key)
+		}
+	}
+}
+
+func TestEscapeLaTeX(t *testing.T) {
+	s := EscapeLaTeX(`50% of $x_1$ & {y} #2 ~ a^b \ c`)
+	expected := `50\% of \$x\_1\$ \& \{y\} \#2 \~{} a\^{}b \textbackslash{} c`
+	if s != expected {
+		t.Errorf("expected %q, got %q", expected, s)
+	}
+}
+
+func TestCitationToBibTeX(t *testing.T) {
+	cite := &Citation{
+		ID:              "thesis:1",
+		Type:            "thesis",
+		Title:           "Flow & Heat",
+		Author:          []*CitationAgent{{FamilyName: "Doe", LivedName: "Jane", Suffix: "Jr."}},
+		PublicationDate: "1998-06",
+		Publisher:       "California Institute of Technology",
+		ThesisDegree:    "Ph.D.",
+		ThesisType:      "phd",
+		Pages:           "1-120",
+		DOI:             "https://doi.org/10.7907/abc",
+	}
+	src := cite.ToBibTeX("doe1998flow")
+	for _, expected := range []string{
+		"@phdthesis{doe1998flow,",
+		"author = {Doe, Jr., Jane}",
+		`title = {Flow \& Heat}`,
+		"year = {1998}",
+		"month = {6}",
+		"school = {California Institute of Technology}",
+		"pages = {1--120}",
+		"doi = {10.7907/abc}",
+	} {
+		if !strings.Contains(src, expected) {
+			t.Errorf("expected %q in\n%s", expected, src)
+		}
+	}
+	cite.ThesisDegree, cite.ThesisType = "M.S.", "masters"
+	if src := cite.ToBibTeX("doe1998flow"); !strings.HasPrefix(src, "@mastersthesis{") {
+		t.Errorf("expected @mastersthesis, got\n%s", src)
+	}
+
+	cite = &Citation{
+		ID:                "patent:1",
+		Type:              "patent",
+		Title:             "Widget",
+		Author:            []*CitationAgent{{Literal: "Caltech"}},
+		PatentNumber:      "US 1234567",
+		PatentAssignee:    "California Institute of Technology",
+		PatentApplication: "US 12/345",
+	}
+	src = cite.ToBibTeX("caltechwidget")
+	for _, expected := range []string{
+		"@patent{caltechwidget,",
+		"author = {{Caltech}}",
+		"number = {US 1234567}",
+		"holder = {California Institute of Technology}",
+		"note = {Application US 12/345}",
+	} {
+		if !strings.Contains(src, expected) {
+			t.Errorf("expected %q in\n%s", expected, src)
+		}
+	}
+
+	cite = &Citation{
+		ID:           "report:1",
+		Type:         "publication-technicalnote",
+		Title:        "Report",
+		Publisher:    "JPL",
+		Series:       "JPL Technical Report",
+		SeriesNumber: "32-1",
+	}
+	src = cite.ToBibTeX("report")
+	for _, expected := range []string{"@techreport{report,", "institution = {JPL}", "number = {32-1}"} {
+		if !strings.Contains(src, expected) {
+			t.Errorf("expected %q in\n%s", expected, src)
+		}
+	}
+}
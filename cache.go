@@ -0,0 +1,161 @@
+package irdmtools
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/dataset/v2"
+)
+
+// ErrOffline is returned by a ResponseCache in offline mode when a
+// response isn't in the cache.
+var ErrOffline = errors.New("not cached, offline mode")
+
+// CachedResponse is a response stored in a ResponseCache.
+type CachedResponse struct {
+	// Service is the API the response came from, e.g. "crossref", "datacite", "ror"
+	Service string `json:"service"`
+	// Query is the DOI or query string sent to the service
+	Query string `json:"query"`
+	// Fetched is when the response was retrieved
+	Fetched time.Time `json:"fetched"`
+	// Src holds the response body
+	Src json.RawMessage `json:"src"`
+}
+
+// ResponseCache is an on-disk cache of API responses (e.g. CrossRef works,
+// DataCite DOI and ROR queries) stored in a dataset collection. Responses
+// are keyed by a hash of the service and query. A nil *ResponseCache
+// always calls the service.
+type ResponseCache struct {
+	// CName is the name of the dataset collection holding the cache
+	CName string
+	// TTL is how long a cached response is used, zero means forever
+	TTL time.Duration
+	// Offline only serves responses from the cache
+	Offline bool
+	// Refresh ignores cached responses, replacing them with new ones
+	Refresh bool
+
+	c *dataset.Collection
+	// requests counts the responses retrieved from a service
+	requests int
+}
+
+// OpenResponseCache opens the cache's dataset collection creating it if
+// needed.
+//
+// ```
+// cache, err := OpenResponseCache("api_cache.ds", 30 * 24 * time.Hour)
+// if err != nil {
+//     // ... handle error ...
+// }
+// defer cache.Close()
+// app.Cfg.Cache = cache
+// ```
+func OpenResponseCache(cName string, ttl time.Duration) (*ResponseCache, error) {
+	var (
+		c   *dataset.Collection
+		err error
+	)
+	if _, err = os.Stat(cName); os.IsNotExist(err) {
+		c, err = dataset.Init(cName, "sqlite://collection.db")
+	} else {
+		c, err = dataset.Open(cName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cache %q, %s", cName, err)
+	}
+	return &ResponseCache{CName: cName, TTL: ttl, c: c}, nil
+}
+
+// Close closes the cache's dataset collection.
+func (cache *ResponseCache) Close() error {
+	if cache == nil || cache.c == nil {
+		return nil
+	}
+	return cache.c.Close()
+}
+
+// cacheKey returns the key for a service and query. DOI are case
+// insensitive so the query is lower cased.
+func cacheKey(service string, query string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(service+"\t"+strings.ToLower(strings.TrimSpace(query)))))
+}
+
+// Get returns the cached response for service and query. Responses older
+// than TTL aren't returned. In Refresh mode nothing is returned.
+func (cache *ResponseCache) Get(service string, query string) ([]byte, bool) {
+	if cache == nil || cache.c == nil || cache.Refresh {
+		return nil, false
+	}
+	key := cacheKey(service, query)
+	if !cache.c.HasKey(key) {
+		return nil, false
+	}
+	obj := new(CachedResponse)
+	if err := cache.c.ReadObject(key, obj); err != nil {
+		return nil, false
+	}
+	if cache.TTL > 0 && time.Since(obj.Fetched) > cache.TTL {
+		return nil, false
+	}
+	return obj.Src, true
+}
+
+// Put stores a response for service and query. src must be JSON.
+func (cache *ResponseCache) Put(service string, query string, src []byte) error {
+	if cache == nil || cache.c == nil {
+		return nil
+	}
+	if !json.Valid(src) {
+		return fmt.Errorf("%s response for %q is not JSON", service, query)
+	}
+	key := cacheKey(service, query)
+	obj := &CachedResponse{
+		Service: service,
+		Query:   query,
+		Fetched: time.Now(),
+		Src:     json.RawMessage(src),
+	}
+	if cache.c.HasKey(key) {
+		return cache.c.UpdateObject(key, obj)
+	}
+	return cache.c.CreateObject(key, obj)
+}
+
+// Fetch returns the cached response for service and query, otherwise it
+// calls fn and caches the result. In Offline mode fn is never called and
+// ErrOffline is returned for responses not in the cache.
+//
+// ```
+// src, err := cache.Fetch("crossref", doi, func() ([]byte, error) {
+//     return client.WorksJSON(doi)
+// })
+// ```
+func (cache *ResponseCache) Fetch(service string, query string, fn func() ([]byte, error)) ([]byte, error) {
+	if src, ok := cache.Get(service, query); ok {
+		return src, nil
+	}
+	if cache != nil && cache.Offline {
+		return nil, fmt.Errorf("%s %q %w", service, query, ErrOffline)
+	}
+	if cache != nil {
+		cache.requests++
+	}
+	src, err := fn()
+	if err != nil {
+		return nil, err
+	}
+	if err := cache.Put(service, query, src); err != nil {
+		log.Printf("failed to cache %s %q, %s", service, query, err)
+	}
+	return src, nil
+}
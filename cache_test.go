@@ -0,0 +1,111 @@
+package irdmtools
+
+import (
+	"errors"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestResponseCache(t *testing.T) {
+	cache, err := OpenResponseCache(path.Join(t.TempDir(), "api_cache.ds"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+	calls := 0
+	fn := func() ([]byte, error) {
+		calls++
+		return []byte(`{"status":"ok"}`), nil
+	}
+	for i := 0; i < 2; i++ {
+		src, err := cache.Fetch("crossref", "10.1000/Test.1", fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := map[string]interface{}{}
+		if err := JSONUnmarshal(src, &m); err != nil || m["status"] != "ok" {
+			t.Errorf("unexpected response %s, %v", src, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected one call to the service, got %d", calls)
+	}
+	// DOI are case insensitive
+	if _, ok := cache.Get("crossref", "10.1000/test.1"); !ok {
+		t.Errorf("expected cached response for lower case DOI")
+	}
+	if _, ok := cache.Get("datacite", "10.1000/test.1"); ok {
+		t.Errorf("expected cache to be keyed by service")
+	}
+
+	cache.Refresh = true
+	if _, err := cache.Fetch("crossref", "10.1000/test.1", fn); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("expected refresh to call the service, got %d calls", calls)
+	}
+	cache.Refresh = false
+
+	cache.Offline = true
+	if _, err := cache.Fetch("crossref", "10.1000/test.2", fn); !errors.Is(err, ErrOffline) {
+		t.Errorf("expected ErrOffline, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected offline mode to not call the service, got %d calls", calls)
+	}
+	cache.Offline = false
+
+	cache.TTL = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	if _, ok := cache.Get("crossref", "10.1000/test.1"); ok {
+		t.Errorf("expected expired response to be ignored")
+	}
+}
+
+func TestOfflineLookups(t *testing.T) {
+	cache, err := OpenResponseCache(path.Join(t.TempDir(), "api_cache.ds"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+	doi := "10.1000/test.1"
+	if err := cache.Put("crossref", doi, []byte(`{
+    "status": "ok",
+    "message-type": "work",
+    "message": {
+        "DOI": "10.1000/test.1",
+        "type": "journal-article",
+        "title": [ "A cached work" ]
+    }
+}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Put("ror", "100000001", []byte(`{
+    "number_of_results": 1,
+    "items": [ { "id": "https://ror.org/021nxhr62" } ]
+}`)); err != nil {
+		t.Fatal(err)
+	}
+	cache.Offline = true
+	cfg := new(Config)
+	cfg.Cache = cache
+	options, err := LoadDoi2RdmOptions("", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	work, err := QueryCrossRefWork(cfg, doi, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if work.Message == nil || len(work.Message.Title) != 1 || work.Message.Title[0] != "A cached work" {
+		t.Errorf("unexpected work from cache, %+v", work.Message)
+	}
+	if ror, ok := lookupROR(cache, "100000001", true); !ok || ror != "021nxhr62" {
+		t.Errorf("expected ROR 021nxhr62, got %q, %t", ror, ok)
+	}
+	if _, err := QueryDataCiteObject(cfg, doi, options); !errors.Is(err, ErrOffline) {
+		t.Errorf("expected ErrOffline for DataCite, got %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package irdmtools
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// CheckpointFinished marks an id as successfully harvested
+	CheckpointFinished = "finished"
+	// CheckpointFailed marks an id as having failed to harvest
+	CheckpointFailed = "failed"
+)
+
+// CheckpointEntry is a single line in a checkpoint journal.
+type CheckpointEntry struct {
+	// ID is the record id processed
+	ID string `json:"id"`
+	// Status is either "finished" or "failed"
+	Status string `json:"status"`
+	// Error holds the reason for the failure
+	Error string `json:"error,omitempty"`
+	// Updated is the time the entry was written
+	Updated string `json:"updated"`
+}
+
+// Checkpoint is a journal of the ids processed by a harvest run. It
+// is stored as JSON lines next to the dataset collection so a run that
+// dies partway through can be resumed. The last entry for an id wins.
+type Checkpoint struct {
+	// Name is the path to the journal file
+	Name string
+
+	entries map[string]*CheckpointEntry
+	fp      *os.File
+	mu      sync.Mutex
+}
+
+// CheckpointName returns the journal file name for a dataset collection,
+// e.g. "authors.ds" has the journal "authors.ds.checkpoint.jsonl".
+func CheckpointName(cName string) string {
+	return strings.TrimSuffix(cName, "/") + ".checkpoint.jsonl"
+}
+
+// OpenCheckpoint opens the checkpoint journal for a dataset collection.
+// If resume is true the existing journal is read and new entries are
+// appended to it, otherwise a new journal is started.
+//
+// ```
+// cp, err := OpenCheckpoint(cfg.CName, true)
+// if err != nil {
+//    // ... handle error ...
+// }
+// defer cp.Close()
+// ids = cp.Pending(ids)
+// ```
+func OpenCheckpoint(cName string, resume bool) (*Checkpoint, error) {
+	cp := &Checkpoint{
+		Name:    CheckpointName(cName),
+		entries: map[string]*CheckpointEntry{},
+	}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if resume {
+		if err := cp.load(); err != nil {
+			return nil, err
+		}
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	fp, err := os.OpenFile(cp.Name, flags, 0664)
+	if err != nil {
+		return nil, err
+	}
+	cp.fp = fp
+	return cp, nil
+}
+
+// load reads an existing journal. A missing journal is not an error.
+func (cp *Checkpoint) load() error {
+	src, err := os.ReadFile(cp.Name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for i := 1; scanner.Scan(); i++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		entry := new(CheckpointEntry)
+		if err := JSONUnmarshal(line, &entry); err != nil {
+			// NOTE: a crash can leave a partial last line, skip it.
+			fmt.Fprintf(os.Stderr, "WARNING: skipping line %d of %s, %s\n", i, cp.Name, err)
+			continue
+		}
+		cp.entries[entry.ID] = entry
+	}
+	return scanner.Err()
+}
+
+// record appends an entry to the journal.
+func (cp *Checkpoint) record(id string, status string, reason error) error {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	entry := &CheckpointEntry{
+		ID:      id,
+		Status:  status,
+		Error:   errorToString(reason),
+		Updated: time.Now().Format(time.RFC3339),
+	}
+	cp.entries[id] = entry
+	src, err := JSONMarshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := cp.fp.Write(append(src, '\n')); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Finished records id as successfully processed.
+func (cp *Checkpoint) Finished(id string) error {
+	return cp.record(id, CheckpointFinished, nil)
+}
+
+// Failed records id as failed along with the reason.
+func (cp *Checkpoint) Failed(id string, reason error) error {
+	return cp.record(id, CheckpointFailed, reason)
+}
+
+// IsFinished returns true if id was successfully processed.
+func (cp *Checkpoint) IsFinished(id string) bool {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	if entry, ok := cp.entries[id]; ok {
+		return entry.Status == CheckpointFinished
+	}
+	return false
+}
+
+// Failures returns the entries of ids that failed in the journal.
+func (cp *Checkpoint) Failures() []*CheckpointEntry {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	failures := []*CheckpointEntry{}
+	for _, entry := range cp.entries {
+		if entry.Status == CheckpointFailed {
+			failures = append(failures, entry)
+		}
+	}
+	return failures
+}
+
+// Pending returns the ids that have not finished, i.e. failed ids and
+// ids not yet in the journal. The order of ids is preserved.
+func (cp *Checkpoint) Pending(ids []string) []string {
+	pending := []string{}
+	for _, id := range ids {
+		if !cp.IsFinished(id) {
+			pending = append(pending, id)
+		}
+	}
+	return pending
+}
+
+// Close closes the journal file.
+func (cp *Checkpoint) Close() error {
+	if cp.fp != nil {
+		return cp.fp.Close()
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package irdmtools
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestCheckpoint(t *testing.T) {
+	cName := path.Join(t.TempDir(), "checkpoint_test.ds")
+	ids := []string{"1", "2", "3", "4"}
+	cp, err := OpenCheckpoint(cName, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp.Finished("1")
+	cp.Failed("2", fmt.Errorf("not found"))
+	cp.Finished("3")
+	if err := cp.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Resume should skip finished ids and keep the failed and pending ones.
+	cp, err = OpenCheckpoint(cName, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending := cp.Pending(ids)
+	if strings.Join(pending, ",") != "2,4" {
+		t.Errorf("expected pending ids 2,4, got %+v", pending)
+	}
+	failures := cp.Failures()
+	if len(failures) != 1 || failures[0].ID != "2" || failures[0].Error != "not found" {
+		t.Errorf("expected id 2 to have failed with a reason, got %+v", failures)
+	}
+	cp.Finished("2")
+	cp.Close()
+
+	cp, err = OpenCheckpoint(cName, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending = cp.Pending(ids)
+	if strings.Join(pending, ",") != "4" {
+		t.Errorf("expected pending ids 4, got %+v", pending)
+	}
+	cp.Close()
+
+	// Starting without resume begins a new journal.
+	cp, err = OpenCheckpoint(cName, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cp.Close()
+	if pending = cp.Pending(ids); len(pending) != len(ids) {
+		t.Errorf("expected a new journal, got pending %+v", pending)
+	}
+}
@@ -167,6 +167,12 @@ type Citation struct {
 
 	// Patent Number
 	PatentNumber string `json:"patent_number,omitempty" xml:"patent_number,omitempty" yaml:"patent_number,omitempty"`
+
+	// Formatted holds pre-rendered citations keyed by CSL style name, e.g. "apa", see CitationFormatter
+	Formatted map[string]string `json:"formatted,omitempty" xml:"formatted,omitempty" yaml:"formatted,omitempty"`
+
+	// Sources lists the repository records a canonical citation was merged from, see DedupeCitations
+	Sources []*CitationSource `json:"sources,omitempty" xml:"sources,omitempty" yaml:"sources,omitempty"`
 }
 
 // CitationIdentifier is a minimal object to identify a type of identifier, e.g. ISBN, ISSN, ROR, ORCID, etc.
@@ -315,30 +321,33 @@ func (cite *Citation) CrosswalkRecord(prefix string, citeUsingURL string, repoUR
 	// DataCite doesn't provide some commonly used fields. These are getting mapped
 	// into the CustomFields namespace in the object.
 	if rec.CustomFields != nil {
-		if journalInfo, ok := rec.CustomFields["journal:journal"].(map[string]string); ok {
-			if publication, ok := journalInfo["title"]; ok {
+		if journalInfo, ok := rec.CustomFields["journal:journal"].(map[string]interface{}); ok {
+			if publication, ok := journalInfo["title"].(string); ok {
 				// map publication from simplified record
 				// FIXME: DataCite/RDM do not provide an explicit publication field for some reason.
 				// We use a custom field, `.custom_fields["journal:joural"].title` to identify publishers ...
 				cite.Publication = publication
 			}
 			// map series/number from simplified record
-			if series, ok := journalInfo["series"]; ok {
+			if series, ok := journalInfo["series"].(string); ok {
 				cite.Series = series
 			}
-			if seriesNumber, ok := journalInfo["number"]; ok {
+			if seriesNumber, ok := journalInfo["number"].(string); ok {
 				cite.SeriesNumber = seriesNumber
 			}
 			// map volume/issue from simplified record
-			if volume, ok := journalInfo["volume"]; ok {
+			if volume, ok := journalInfo["volume"].(string); ok {
 				cite.Volume = volume
 			}
-			if issue, ok := journalInfo["issue"]; ok {
+			if issue, ok := journalInfo["issue"].(string); ok {
 				cite.Issue = issue
 			}
-			if pages, ok := journalInfo["pages"]; ok {
+			if pages, ok := journalInfo["pages"].(string); ok {
 				cite.Pages = pages
 			}
+			if issn, ok := journalInfo["issn"].(string); ok {
+				cite.ISSN = issn
+			}
 		}
 		if imprintInfo, ok := rec.CustomFields["imprint:imprint"].(map[string]interface{}); ok {
 			if title, ok := imprintInfo["title"].(string); ok {
@@ -0,0 +1,103 @@
+package irdmtools
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/dataset/v2"
+)
+
+// HasAgent returns true if the clpid or ORCID belongs to one of the
+// citation's authors or editors. Empty values aren't matched.
+func (cite *Citation) HasAgent(clpid string, orcid string) bool {
+	for _, agents := range [][]*CitationAgent{cite.Author, cite.Editor} {
+		for _, agent := range agents {
+			if agent == nil {
+				continue
+			}
+			if clpid != "" && agent.CLpid == clpid {
+				return true
+			}
+			if orcid != "" && strings.HasSuffix(agent.ORCID, orcid) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReadCitations reads citations from a dataset collection. If keys is
+// empty all the citations are read. If clpid or orcid is set only the
+// citations with a matching author or editor are returned (see
+// HasAgent). Citations are returned in key order.
+//
+// ```
+// citations, err := ReadCitations("citation.ds", nil, "Doe-J", "")
+// if err != nil {
+//    // ... handle error ...
+// }
+// WriteBibTeX(os.Stdout, citations)
+// ```
+func ReadCitations(cName string, keys []string, clpid string, orcid string) ([]*Citation, error) {
+	c, err := dataset.Open(cName)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	if len(keys) == 0 {
+		keys, err = c.Keys()
+		if err != nil {
+			return nil, err
+		}
+	}
+	keys = append([]string{}, keys...)
+	sort.Strings(keys)
+	citations := []*Citation{}
+	for _, key := range keys {
+		cite := new(Citation)
+		if err := c.ReadObject(key, cite); err != nil {
+			log.Printf("failed to read citation %s, %s", key, err)
+			continue
+		}
+		if cite.ID == "" {
+			cite.ID = key
+		}
+		if (clpid != "" || orcid != "") && !cite.HasAgent(clpid, orcid) {
+			continue
+		}
+		citations = append(citations, cite)
+	}
+	return citations, nil
+}
+
+// RunCitationDSToBibliography writes the citations in a dataset
+// collection as BibTeX or RIS. args holds the collection name optionally
+// followed by the keys to write.
+func RunCitationDSToBibliography(in io.Reader, out io.Writer, eout io.Writer, args []string, format string, clpid string, orcid string) int {
+	if len(args) < 1 {
+		fmt.Fprintf(eout, "missing citation collection name\n")
+		return 1
+	}
+	citations, err := ReadCitations(args[0], args[1:], clpid, orcid)
+	if err != nil {
+		fmt.Fprintf(eout, "%s\n", err)
+		return 1
+	}
+	switch strings.ToLower(format) {
+	case "", "bib", "bibtex":
+		err = WriteBibTeX(out, citations)
+	case "ris":
+		err = WriteRIS(out, citations)
+	default:
+		err = fmt.Errorf("%q is not a supported format, use bibtex or ris", format)
+	}
+	if err != nil {
+		fmt.Fprintf(eout, "%s\n", err)
+		return 1
+	}
+	return 0 // OK
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+
+	// Caltech Library
+	"github.com/caltechlibrary/irdmtools"
+
+	// 3rd Party Libraries
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	helpText = `%{app_name}(1) irdmtools user manual | version {version} {release_hash}
+% R. S. Doiel
+% {release_date}
+
+# NAME
+
+{app_name}
+
+# SYNOPSIS
+
+{app_name} [OPTIONS] < INPUT_CSV_FILE > OUTPUT_VOC_YAML_FILE
+
+# DESCRIPTION
+
+{app_name} converts a CSV file of awards (grants and contracts) to a
+YAML vocabulary file suitable for import into Invenio-RDM. The CSV file
+needs "number" and "funder" columns, the funder being a ROR id, with
+optional "id", "title" and "acronym" columns. The Caltech awards export
+is also understood, the "Award #" column is the id and the number and
+funder come from the "Prime Agreement #" and "Prime Funding Source"
+columns or, without a prime funding source, the "Funding Src Award #"
+and "Oracle Funding Source Name" columns. Funding source names are
+mapped to ROR ids, see -funders. Other columns are ignored.
+
+Funder ROR ids are checked for a valid checksum and award numbers must
+contain a letter or digit. Awards without a number (e.g. "unknown") are
+skipped. An award without an id gets the id FUNDER::NUMBER.
+
+The awards are sorted by id so converting an updated CSV file only
+changes the awards that changed. Rows that can't be converted are
+reported, if there are any nothing is written and {app_name} exits
+with an error.
+
+# OPTIONS
+
+-help
+: display help
+
+-license
+: display license
+
+-version
+: display version
+
+-i
+: Read input from file
+
+-o
+: Write output to file
+
+-csv
+: (default: true) Input is in csv format, otherwise a JSON array of
+objects
+
+-funders
+: Read the funding source names from a CSV file with "name" and "ror"
+columns, they are added to (or replace) the built in names
+
+# EXAMPLES
+
+~~~shell
+    {app_name} < awards.csv >awards-vocabulary.yaml
+
+    {app_name} -funders funders.csv awards.csv awards-vocabulary.yaml
+~~~
+
+`
+)
+
+func main() {
+	var (
+		err         error
+		inputFName  string
+		outputFName string
+
+		showHelp    bool
+		showVersion bool
+		showLicense bool
+
+		inputIsCSV   bool
+		fundersFName string
+	)
+	appName := path.Base(os.Args[0])
+	version := irdmtools.Version
+	releaseDate := irdmtools.ReleaseDate
+	releaseHash := irdmtools.ReleaseHash
+	fmtHelp := irdmtools.FmtHelp
+
+	in := os.Stdin
+	out := os.Stdout
+	eout := os.Stderr
+
+	flag.BoolVar(&showHelp, "help", false, "display help text")
+	flag.BoolVar(&showVersion, "version", false, "display version")
+	flag.BoolVar(&showLicense, "license", false, "display license")
+	flag.StringVar(&inputFName, "i", "", "input filename")
+	flag.StringVar(&outputFName, "o", "", "output filename")
+	flag.BoolVar(&inputIsCSV, "csv", true, "input is CSV format")
+	flag.StringVar(&fundersFName, "funders", "", "read funding source names and ROR ids from CSV file")
+	flag.Parse()
+	args := flag.Args()
+	if showHelp {
+		fmt.Fprintf(out, "%s\n", fmtHelp(helpText, appName, version, releaseDate, releaseHash))
+		os.Exit(0)
+	}
+	if showLicense {
+		fmt.Fprintf(out, "%s\n", irdmtools.LicenseText)
+		os.Exit(0)
+	}
+	if showVersion {
+		fmt.Fprintf(out, "%s %s %s\n", appName, version, releaseHash)
+		os.Exit(0)
+	}
+	if (len(args) > 0) && (inputFName == "") {
+		inputFName = args[0]
+	}
+	if (len(args) > 1) && (outputFName == "") {
+		outputFName = args[1]
+	}
+	if (inputFName != "") && (inputFName != "-") {
+		in, err = os.Open(inputFName)
+		if err != nil {
+			fmt.Fprintf(eout, "%s\n", err)
+			os.Exit(1)
+		}
+		defer in.Close()
+	}
+	funders := map[string]string{}
+	for name, ror := range irdmtools.DefaultFunderNames {
+		funders[name] = ror
+	}
+	if fundersFName != "" {
+		src, err := ioutil.ReadFile(fundersFName)
+		if err != nil {
+			fmt.Fprintf(eout, "%s\n", err)
+			os.Exit(1)
+		}
+		names, err := irdmtools.ReadFunderNames(src, true)
+		if err != nil {
+			fmt.Fprintf(eout, "%s, %s\n", fundersFName, err)
+			os.Exit(1)
+		}
+		for name, ror := range names {
+			funders[name] = ror
+		}
+	}
+	src, err := ioutil.ReadAll(in)
+	if err != nil {
+		fmt.Fprintf(eout, "%s\n", err)
+		os.Exit(1)
+	}
+	awards, err := irdmtools.AwardsToVocabulary(src, inputIsCSV, funders)
+	if err != nil {
+		fmt.Fprintf(eout, "%s\n", err)
+		os.Exit(1)
+	}
+	src, err = yaml.Marshal(awards)
+	if err != nil {
+		fmt.Fprintf(eout, "%s\n", err)
+		os.Exit(1)
+	}
+	// NOTE: the output file is only created once the awards are mapped
+	// so a failed run doesn't clobber a good vocabulary.
+	if (outputFName != "") && (outputFName != "-") {
+		out, err = os.Create(outputFName)
+		if err != nil {
+			fmt.Fprintf(eout, "%s\n", err)
+			os.Exit(1)
+		}
+		defer out.Close()
+	}
+	fmt.Fprintf(out, "%s", src)
+}
@@ -0,0 +1,198 @@
+// citations2bib is a command line program that will write a citations dataset collection as BibTeX or RIS
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+// @author Tom Morrell, <tmorrell@caltech.edu>
+//
+// Copyright (c) 2024, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/irdmtools"
+)
+
+var (
+	helpText = `%{app_name}(1) irdmtools user manual | version {version} {release_hash}
+% R. S. Doiel and Tom Morrell
+% {release_date}
+
+# NAME
+
+{app_name}
+
+# SYNOPSIS
+
+{app_name} [OPTIONS] CITATION_DS [KEY ...]
+
+# DESCRIPTION
+
+{app_name} is a Caltech Library oriented command line application
+that writes the citations in a citations dataset collection (e.g.
+one created by ep3ds2citations or rdmds2citations) as BibTeX or RIS.
+It can write the whole collection, the citations for a list of keys
+or the citations with an author or editor matching a clpid or ORCID.
+
+Citations are written in key order. BibTeX keys are formed from the
+first author's family name, the year and the first significant word
+of the title (e.g. "doe2023quantum"). When citations share a key they
+are ordered by id and a letter is added to all but the first (e.g.
+"doe2023quantuma"), so the keys are stable from run to run for the
+same citations. LaTeX special characters are escaped.
+
+Theses are written as @phdthesis or @mastersthesis, technical reports
+as @techreport and patents as biblatex @patent entries.
+
+# OPTIONS
+
+-help
+: display help
+
+-license
+: display license
+
+-version
+: display version
+
+-format FORMAT
+: output format, "bibtex" (the default) or "ris"
+
+-keys FILENAME
+: read the keys to write, one per line, from a file or standard
+input (use filename as "-")
+
+-clpid CLPID
+: only write citations with an author or editor with this clpid
+
+-orcid ORCID
+: only write citations with an author or editor with this ORCID
+
+# EXAMPLE
+
+Write all the citations in "citation.ds" as BibTeX.
+
+~~~shell
+{app_name} citation.ds >citations.bib
+~~~
+
+Write the citations for an author as RIS.
+
+~~~shell
+{app_name} -format ris -clpid Doe-J citation.ds >Doe-J.ris
+{app_name} -format ris -orcid 0000-0002-1825-0097 citation.ds >Doe-J.ris
+~~~
+
+Write the citations for a list of keys.
+
+~~~shell
+{app_name} -keys keys.txt citation.ds >citations.bib
+~~~
+
+`
+)
+
+// getKeyList will read in a list if keys one per line and return an array of keys.
+func getKeyList(keysFName string) ([]string, error) {
+	var err error
+	in := os.Stdin
+	if keysFName != "-" {
+		in, err = os.Open(keysFName)
+		if err != nil {
+			return nil, err
+		}
+		defer in.Close()
+	}
+	keys := []string{}
+	r := bufio.NewReader(in)
+	for {
+		s, err := r.ReadString('\n')
+		if s = strings.TrimSpace(s); s != "" {
+			keys = append(keys, s)
+		}
+		if err != nil {
+			break
+		}
+	}
+	return keys, nil
+}
+
+func main() {
+	appName := path.Base(os.Args[0])
+	// NOTE: The following are set when version.go is generated
+	version := irdmtools.Version
+	releaseDate := irdmtools.ReleaseDate
+	releaseHash := irdmtools.ReleaseHash
+	fmtHelp := irdmtools.FmtHelp
+
+	showHelp, showVersion, showLicense := false, false, false
+	format, keysFName, clpid, orcid := "bibtex", "", "", ""
+	flag.BoolVar(&showHelp, "help", false, "display help")
+	flag.BoolVar(&showVersion, "version", false, "display version")
+	flag.BoolVar(&showLicense, "license", false, "display license")
+	flag.StringVar(&format, "format", format, "output format, bibtex or ris")
+	flag.StringVar(&keysFName, "keys", keysFName, "read keys from a file or standard input")
+	flag.StringVar(&clpid, "clpid", clpid, "only write citations with an author or editor with this clpid")
+	flag.StringVar(&orcid, "orcid", orcid, "only write citations with an author or editor with this ORCID")
+
+	flag.Parse()
+	args := flag.Args()
+
+	in := os.Stdin
+	out := os.Stdout
+	eout := os.Stderr
+
+	if showHelp {
+		fmt.Fprintf(out, "%s\n", fmtHelp(helpText, appName, version, releaseDate, releaseHash))
+		os.Exit(0)
+	}
+	if showVersion {
+		fmt.Fprintf(out, "%s %s %s\n", appName, version, releaseHash)
+		os.Exit(0)
+	}
+	if showLicense {
+		fmt.Fprintf(out, "%s\n", irdmtools.LicenseText)
+		os.Exit(0)
+	}
+	if keysFName != "" {
+		keys, err := getKeyList(keysFName)
+		if err != nil {
+			fmt.Fprintf(eout, "%s\n", err)
+			os.Exit(1)
+		}
+		args = append(args, keys...)
+	}
+	os.Exit(irdmtools.RunCitationDSToBibliography(in, out, eout, args, format, clpid, orcid))
+}
@@ -0,0 +1,162 @@
+// dedupecitations is a command line program that will merge duplicate citations from a citations dataset collection into canonical citations
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+// @author Tom Morrell, <tmorrell@caltech.edu>
+//
+// Copyright (c) 2024, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/irdmtools"
+)
+
+var (
+	helpText = `%{app_name}(1) irdmtools user manual | version {version} {release_hash}
+% R. S. Doiel and Tom Morrell
+% {release_date}
+
+# NAME
+
+{app_name}
+
+# SYNOPSIS
+
+{app_name} [OPTIONS] CITATION_DS CANONICAL_DS
+
+# DESCRIPTION
+
+{app_name} is a Caltech Library oriented command line application
+that finds citations describing the same work in a citations dataset
+collection, e.g. a thesis harvested from both CaltechAUTHORS and
+CaltechTHESIS, and writes a merged "canonical" citation for each work
+to another dataset collection.
+
+Citations are clustered by DOI, then ISBN, then by normalized title,
+year and first author. The canonical citation is taken from the first
+preferred collection, then one with a DOI, then the most complete
+citation. Fields it lacks are filled in from the other citations. Its
+"sources" field lists the id, collection and collection id of every
+citation it was merged from. Citations without duplicates are written
+with themselves as their source.
+
+Matches that look doubtful are reported for review,
+
+- citations sharing a DOI but not their titles (merged)
+- citations sharing an ISBN but not their titles, e.g. chapters of a
+  book (not merged)
+- citations with the same title, year and first author but different
+  types (not merged)
+- citations with the same title and first author a year apart (not
+  merged)
+- citations with similar titles, the same year and first author (not
+  merged)
+
+CITATION_DS is the dataset collection holding the citations.
+
+CANONICAL_DS is the dataset collection where the canonical citations
+are written.
+
+# OPTIONS
+
+-help
+: display help
+
+-license
+: display license
+
+-version
+: display version
+
+-prefer COLLECTIONS
+: a comma separated list of collections to take the canonical citation
+from in order of preference, e.g. "caltechauthors,caltechthesis"
+
+-report FILENAME
+: write the matches to review as a JSON array to FILENAME (use "-"
+for standard output)
+
+# EXAMPLE
+
+Merge the duplicate citations in "citation.ds" into "canonical.ds"
+preferring the CaltechAUTHORS citations and write the matches to
+review to "dedupe-report.json".
+
+~~~shell
+dataset init canonical.ds
+{app_name} -prefer caltechauthors,caltechthesis,caltechdata \
+           -report dedupe-report.json citation.ds canonical.ds
+~~~
+
+`
+)
+
+func main() {
+	appName := path.Base(os.Args[0])
+	// NOTE: The following are set when version.go is generated
+	version := irdmtools.Version
+	releaseDate := irdmtools.ReleaseDate
+	releaseHash := irdmtools.ReleaseHash
+	fmtHelp := irdmtools.FmtHelp
+
+	showHelp, showVersion, showLicense := false, false, false
+	prefer, reportFName := "", ""
+	flag.BoolVar(&showHelp, "help", false, "display help")
+	flag.BoolVar(&showVersion, "version", false, "display version")
+	flag.BoolVar(&showLicense, "license", false, "display license")
+	flag.StringVar(&prefer, "prefer", prefer, "collections to take the canonical citation from in order of preference (comma separated)")
+	flag.StringVar(&reportFName, "report", reportFName, "write the matches to review to a file or standard out (use \"-\")")
+
+	flag.Parse()
+	args := flag.Args()
+
+	in := os.Stdin
+	out := os.Stdout
+	eout := os.Stderr
+
+	if showHelp {
+		fmt.Fprintf(out, "%s\n", fmtHelp(helpText, appName, version, releaseDate, releaseHash))
+		os.Exit(0)
+	}
+	if showVersion {
+		fmt.Fprintf(out, "%s %s %s\n", appName, version, releaseHash)
+		os.Exit(0)
+	}
+	if showLicense {
+		fmt.Fprintf(out, "%s\n", irdmtools.LicenseText)
+		os.Exit(0)
+	}
+	os.Exit(irdmtools.RunDedupeCitations(in, out, eout, args, prefer, reportFName))
+}
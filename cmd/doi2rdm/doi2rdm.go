@@ -38,6 +38,8 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"strings"
+	"time"
 
 	// Caltech Library packages
 	"github.com/caltechlibrary/irdmtools"
@@ -54,7 +56,11 @@ var (
 
 # SYNOPSIS
 
-{app_name} [OPTIONS] [OPTIONS_YAML] [crossref|datacite] DOI
+{app_name} [OPTIONS] [OPTIONS_YAML] [crossref|datacite|merge|pubmed|arxiv] DOI
+
+{app_name} [OPTIONS] -batch DOI_LIST [OPTIONS_YAML] [crossref|datacite|merge|pubmed|arxiv]
+
+{app_name} [OPTIONS] -rdm-diff RECORD_ID [OPTIONS_YAML] [crossref|datacite|merge|pubmed|arxiv] [DOI]
 
 # DESCRIPTION
 
@@ -64,10 +70,78 @@ JSON document suitable for import into Invenio RDM. The DOI can be
 in either their canonical form or URL form (e.g. "10.1021/acsami.7b15651" or
 "https://doi.org/10.1021/acsami.7b15651").
 
+By default {app_name} tries CrossRef then DataCite. With "merge" the DOI
+is retrieved from both and the records are combined field by field.
+The `+"`"+`merge_precedence`+"`"+` rules in OPTIONS_YAML set which source is used
+first for each field (e.g. the abstract from DataCite, funding from
+CrossRef). The `+"`"+`-provenance`+"`"+` option writes which source each field
+came from to a JSON sidecar file, JSON lines in batch mode.
+
+With "pubmed" the metadata is retrieved from PubMed via Europe PMC,
+<https://europepmc.org>. The DOI can also be a PMID (e.g. "pmid:31452104")
+or PMCID (e.g. "PMC6711234"). The record includes the MeSH headings as
+subjects, the PMID and PMCID as identifiers and the grants as funding.
+With "arxiv" the metadata is retrieved from the arXiv API for an arXiv
+id (e.g. "arXiv:2312.07215"), arXiv DOI or abstract URL. The versions
+become submitted and updated dates, the categories subjects and the DOI
+of the published version a related identifier. By default arXiv ids are
+retrieved from arXiv then DataCite.
+
+Setting `+"`"+`enrich_pubmed`+"`"+` in OPTIONS_YAML adds the abstract (if missing),
+MeSH subjects, PMID, PMCID and grants (if there is no funding) from
+PubMed to records retrieved from CrossRef or DataCite.
+
 If a DOI is retrieve the exit code will be zero. If a DOI is not found
 the exit code with be ENOENT (2) else another non-zero exit code will be
 returned depending on the problem.
 
+In batch mode {app_name} reads a list of DOI, one per line, from a file
+or standard input. Blank lines and lines starting with "#" are skipped.
+The options YAML is read once for the whole batch. Each record is
+written as a line of JSON (JSONL) or, with the `+"`"+`-dataset`+"`"+` option,
+stored in a dataset collection using the DOI as key. Requests are spaced
+to respect the CrossRef and DataCite rate limits and progress is logged
+each minute. DOI that couldn't be retrieved are written to an error log,
+one per line as the DOI, a tab and the error message. If any DOI fail
+the exit code will be ENOENT (2).
+
+With the `+"`"+`-cache`+"`"+` option the CrossRef, DataCite and ROR responses are
+saved in a dataset collection and reused until they are older than the
+cache's time to live. This makes re-running a crosswalk after changing
+the YAML options fast and reproducible. The `+"`"+`-offline`+"`"+` option only uses
+the cache, `+"`"+`-refresh`+"`"+` retrieves new responses and updates the cache.
+
+With the `+"`"+`-rdm-diff`+"`"+` option {app_name} compares a record in RDM with
+its DOI metadata, showing what would change if the record was refreshed
+from CrossRef or DataCite. The record is read from RDM's Postgres
+database when RDM_DB_HOST and RDM_DB_USER are set otherwise from the
+RDM API using RDM_URL and RDMTOK. The DOI defaults to the record's DOI.
+The first argument is the options YAML file only if it ends in ".yaml" or
+".yml" or is an existing file, it can be left out. Only the fields in the DOI metadata are compared. The `+"`"+`-stage`+"`"+` option
+copies the listed fields from the DOI metadata into a draft of the RDM
+record for a curator to review and publish. Fields are named as in the
+`+"`"+`merge_precedence`+"`"+` rules, e.g. "metadata.funding" or
+"custom_fields.journal:journal".
+
+With the `+"`"+`-ror-dump`+"`"+` option affiliation and funder names are matched
+against a local copy of the ROR data dump, <https://ror.readme.io/docs/data-dump>,
+instead of querying the ROR API. Each match has a confidence between
+0.0 and 1.0. Matches below the threshold don't get a ROR id, they are
+written to the ROR report for review as tab delimited lines of name,
+confidence, best matching ROR id and organization name.
+
+With the `+"`"+`-people`+"`"+` option creators are matched to the Caltech people
+list, the CSV file people2vocabulary reads. A creator matches a person by
+ORCID or, if the creator has a Caltech affiliation, by family and given
+name. Matched creators get the person's clpid and ORCID. The
+`+"`"+`-orcid-data`+"`"+` option reads records from the ORCID public data file, an
+XML file or a directory of them. A creator whose ORCID isn't in the
+people list but whose ORCID record shows Caltech employment is matched
+by the name in the ORCID record. Uncertain matches, e.g. only initials
+match or more than one person matches, are left alone and written to the
+people report as tab delimited lines of DOI, creator, ORCID, how they
+matched, the candidate clpids and a note.
+
 # OPTIONS_YAML
 
 {app_name} can use an YAML options file to set the behavior of the
@@ -89,11 +163,68 @@ migrating content from CrossRef to RDM.
 : display version
 
 -diff JSON_FILENAME
-: compare the JSON_FILENAME contents with record generated from CrossRef or DataCite works record
+: compare JSON_FILENAME, a saved service response, with the current record. With "crossref" or "datacite" it holds a CrossRef or DataCite works response, with "pubmed" a Europe PMC JSON or PubMed XML response, with "arxiv" an arXiv API Atom response. By default and with "merge" it can be any of these, the source is detected from its contents. When enriching from PubMed the saved response is enriched too before comparing
+
+-provenance FILENAME
+: when merging write the source of each field to FILENAME
 
 -show-yaml
 : This will display the default YAML configuration file. You can save this and customize to suit your needs.
 
+-batch DOI_LIST
+: read the DOI to retrieve from DOI_LIST, one per line, use "-" to read from standard input
+
+-dataset C_NAME
+: in batch mode store the records in the dataset collection C_NAME instead of writing JSON lines to standard output
+
+-errors FILENAME
+: in batch mode write the DOI that failed and their errors to FILENAME instead of standard error
+
+-cache C_NAME
+: save and reuse CrossRef, DataCite and ROR responses in the dataset collection C_NAME, it is created if needed
+
+-cache-ttl DURATION
+: how long cached responses are used, e.g. "24h", zero means forever (default 720h)
+
+-offline
+: only use responses in the cache, requires -cache
+
+-refresh
+: ignore responses in the cache and replace them with new ones, requires -cache
+
+-rdm-diff RECORD_ID
+: compare the RDM record RECORD_ID with the metadata retrieved for its DOI
+
+-stage FIELDS
+: with -rdm-diff copy the comma separated list of fields from the DOI metadata into a draft of the RDM record
+
+-config
+: provide a path to an alternate configuration file (e.g. "irdmtools.json") for accessing RDM
+
+-ror-dump FILENAME
+: match affiliations and funders using the ROR data dump (JSON or zip file)
+
+-ror-threshold NUMBER
+: the confidence needed to use a ROR match (default 0.85)
+
+-ror-report FILENAME
+: write the ROR matches below the threshold to FILENAME instead of standard error
+
+-people FILENAME
+: add clpid and ORCID to creators matching the people CSV file. Creators
+without an ORCID are matched by name only when they have a Caltech
+affiliation, a Caltech or JPL ROR id or a name like "California Institute
+of Technology". With -people or -ror-dump CrossRef affiliations without a
+publisher ROR id keep their name, with -ror-dump the name is resolved to
+a ROR id when it matches. Without either they are dropped.
+
+-orcid-data PATH
+: with -people use the ORCID public data file records (XML file or
+directory), only the records with Caltech employment are kept
+
+-people-report FILENAME
+: write the creators needing review to FILENAME instead of standard error
+
 # EXAMPLES
 
 Save the default YAML options to a file. You can customize this to match your
@@ -119,16 +250,105 @@ the current metadata retrieved from CrossRef or DataCite.
 	{app_name} -diff article.json options.yaml "10.1021/acsami.7b15651"
 ~~~
 
-Example getting metadata for an arXiv record from DataCite
+Merge the CrossRef and DataCite metadata for a DOI saving the source
+of each field in "article-provenance.json".
+
+~~~
+	{app_name} -provenance article-provenance.json options.yaml merge \
+	    "10.1021/acsami.7b15651" >article.json
+~~~
+
+Show what would change if RDM record "qez01-2309a" was refreshed from
+its DOI then stage the funding and journal information into a draft.
+
+~~~
+	{app_name} -rdm-diff qez01-2309a options.yaml
+	{app_name} -rdm-diff qez01-2309a \
+	    -stage metadata.funding,custom_fields.journal:journal options.yaml
+~~~
+
+Example getting the PubMed metadata, including MeSH subjects and
+grants, for a PMID.
+
+~~~
+	{app_name} options.yaml pubmed "pmid:31452104" >article.json
+~~~
+
+Example getting metadata for an arXiv record from arXiv, falling back
+to DataCite
 
 ~~~
 	{app_name} options.yaml "arXiv:2312.07215"
 ~~~
 
+Stage a faculty member's publication list, "publications.txt", into
+a dataset collection called "staged.ds" logging the DOI that failed
+to "errors.log".
+
+~~~
+	dataset init staged.ds
+	{app_name} -batch publications.txt -dataset staged.ds \
+	    -errors errors.log options.yaml
+~~~
+
+Stage the list again after changing "options.yaml" using only the cached
+responses from the previous run.
+
+~~~
+	{app_name} -cache api_cache.ds -batch publications.txt options.yaml >records.jsonl
+	{app_name} -cache api_cache.ds -offline -batch publications.txt options.yaml >records.jsonl
+~~~
+
+Match affiliations and funders with the ROR data dump saving the names
+that need review in "ror-review.tsv".
+
+~~~
+	{app_name} -ror-dump v1.50-2024-07-29-ror-data.zip \
+	    -ror-report ror-review.tsv -batch publications.txt options.yaml >records.jsonl
+~~~
+
+Add clpid and ORCID to the creators matching "people.csv", saving the
+creators that need review in "people-review.tsv".
+
+~~~
+	{app_name} -people people.csv -orcid-data ORCID_2024_10_summaries \
+	    -people-report people-review.tsv -batch publications.txt options.yaml >records.jsonl
+~~~
+
+Read the DOI list from standard input and write JSON lines using CrossRef only.
+
+~~~
+	cat publications.txt | {app_name} -batch - options.yaml crossref >records.jsonl
+~~~
+
 `
 )
 
 
+// isDataSource returns true if arg names a DOI metadata source.
+func isDataSource(arg string) bool {
+	switch arg {
+	case "crossref", "datacite", "merge", "pubmed", "arxiv":
+		return true
+	}
+	return false
+}
+
+// isOptionsFile returns true if arg is an options YAML file rather than
+// a data source or DOI, i.e. it has a .yaml or .yml extension or is an
+// existing file.
+func isOptionsFile(arg string) bool {
+	if isDataSource(arg) || strings.HasPrefix(arg, "10.") || strings.Contains(arg, "doi.org/") {
+		return false
+	}
+	ext := strings.ToLower(path.Ext(arg))
+	if ext == ".yaml" || ext == ".yml" {
+		return true
+	}
+	info, err := os.Stat(arg)
+	return err == nil && !info.IsDir()
+}
+
 func main() {
 	appName := path.Base(os.Args[0])
 	// NOTE: the following are set when version.go is generated
@@ -139,13 +359,35 @@ func main() {
 
 	showHelp, showVersion, showLicense := false, false, false
 	debug, showYAML := false, false
-	diffFName := ""
+	configFName, diffFName := "", ""
+	rdmDiff, stageFields := "", ""
+	batchFName, cName, errorFName, provenanceFName := "", "", "", ""
+	cacheName, cacheTTL, offline, refresh := "", 720*time.Hour, false, false
+	rorDump, rorReport, rorThreshold := "", "", irdmtools.DefaultRorThreshold
+	peopleFName, orcidData, peopleReport := "", "", ""
 	flag.BoolVar(&showHelp, "help", false, "display help")
 	flag.BoolVar(&showVersion, "version", false, "display version")
 	flag.BoolVar(&showLicense, "license", false, "display license")
 	flag.BoolVar(&showYAML, "show-yaml", false, "display the YAML configuration")
-	flag.StringVar(&diffFName, "diff", diffFName, "compare the JSON file with the current record generated from CrossRef")
+	flag.StringVar(&diffFName, "diff", diffFName, "compare a saved CrossRef, DataCite, PubMed or arXiv response with the current record")
 	flag.BoolVar(&debug, "debug", debug, "display additional info to stderr")
+	flag.StringVar(&configFName, "config", configFName, "use a config file")
+	flag.StringVar(&rdmDiff, "rdm-diff", rdmDiff, "compare an RDM record with its DOI metadata")
+	flag.StringVar(&stageFields, "stage", stageFields, "with -rdm-diff stage the comma separated fields into a draft")
+	flag.StringVar(&batchFName, "batch", batchFName, "read a list of DOI, one per line, from file (use \"-\" for stdin)")
+	flag.StringVar(&cName, "dataset", cName, "in batch mode store records in a dataset collection")
+	flag.StringVar(&errorFName, "errors", errorFName, "in batch mode write failed DOI and errors to file")
+	flag.StringVar(&provenanceFName, "provenance", provenanceFName, "when merging write the source of each field to file")
+	flag.StringVar(&cacheName, "cache", cacheName, "save and reuse API responses in a dataset collection")
+	flag.DurationVar(&cacheTTL, "cache-ttl", cacheTTL, "how long cached responses are used, zero means forever")
+	flag.BoolVar(&offline, "offline", offline, "only use cached responses")
+	flag.BoolVar(&refresh, "refresh", refresh, "replace cached responses")
+	flag.StringVar(&rorDump, "ror-dump", rorDump, "match affiliations and funders using the ROR data dump")
+	flag.Float64Var(&rorThreshold, "ror-threshold", rorThreshold, "confidence needed to use a ROR match")
+	flag.StringVar(&rorReport, "ror-report", rorReport, "write ROR matches below the threshold to file")
+	flag.StringVar(&peopleFName, "people", peopleFName, "add clpid and ORCID to creators matching the people CSV file")
+	flag.StringVar(&orcidData, "orcid-data", orcidData, "with -people use ORCID public data file records")
+	flag.StringVar(&peopleReport, "people-report", peopleReport, "write creators needing review to file")
 	flag.Parse()
 	args := flag.Args()
 
@@ -173,16 +415,142 @@ func main() {
 	// Create a appity object
 	app := new(irdmtools.Doi2Rdm)
 	app.Cfg = new(irdmtools.Config)
+	if rdmDiff != "" {
+		// Comparing with RDM needs access to RDM
+		if err := app.Configure(configFName, "", debug); err != nil {
+			fmt.Fprintf(eout, "%s\n", err)
+			os.Exit(1)
+		}
+	} else if stageFields != "" {
+		fmt.Fprintln(eout, "-stage requires -rdm-diff")
+		os.Exit(1)
+	}
 	if debug {
 		app.Cfg.Debug = true
 	} else {
 		app.Cfg.Debug = false
 	}
 
+	if (offline || refresh) && cacheName == "" {
+		fmt.Fprintln(eout, "-offline and -refresh require -cache")
+		os.Exit(1)
+	}
+	if offline && refresh {
+		fmt.Fprintln(eout, "-offline and -refresh can't be combined")
+		os.Exit(1)
+	}
+	if cacheName != "" {
+		cache, err := irdmtools.OpenResponseCache(cacheName, cacheTTL)
+		if err != nil {
+			fmt.Fprintf(eout, "%s\n", err)
+			os.Exit(1)
+		}
+		cache.Offline, cache.Refresh = offline, refresh
+		app.Cfg.Cache = cache
+	}
+	if rorDump != "" {
+		idx, err := irdmtools.LoadRorDump(rorDump)
+		if err != nil {
+			fmt.Fprintf(eout, "%s\n", err)
+			os.Exit(1)
+		}
+		idx.Threshold = rorThreshold
+		app.Cfg.Ror = idx
+	}
+	if orcidData != "" && peopleFName == "" {
+		fmt.Fprintln(eout, "-orcid-data requires -people")
+		os.Exit(1)
+	}
+	if peopleFName != "" {
+		idx, err := irdmtools.LoadPeopleCSV(peopleFName)
+		if err != nil {
+			fmt.Fprintf(eout, "%s\n", err)
+			os.Exit(1)
+		}
+		if orcidData != "" {
+			records, err := irdmtools.LoadOrcidRecords(orcidData)
+			if err != nil {
+				fmt.Fprintf(eout, "%s\n", err)
+				os.Exit(1)
+			}
+			idx.AddOrcidRecords(records)
+		}
+		app.Cfg.People = idx
+	}
+	// exit closes the cache and database and writes the ROR and people reports before exiting
+	exit := func(exitCode int) {
+		app.Cfg.Cache.Close()
+		app.CloseDB()
+		if app.Cfg.Ror != nil && rorReport != "" {
+			if fp, err := os.Create(rorReport); err != nil {
+				fmt.Fprintf(eout, "%s\n", err)
+			} else {
+				if err := irdmtools.WriteRorReport(fp, app.Cfg.Ror.Reported); err != nil {
+					fmt.Fprintf(eout, "%s\n", err)
+				}
+				fp.Close()
+			}
+		} else if app.Cfg.Ror != nil {
+			irdmtools.WriteRorReport(eout, app.Cfg.Ror.Reported)
+		}
+		if app.Cfg.People != nil && peopleReport != "" {
+			if fp, err := os.Create(peopleReport); err != nil {
+				fmt.Fprintf(eout, "%s\n", err)
+			} else {
+				if err := irdmtools.WritePeopleReport(fp, app.Cfg.People.Reported); err != nil {
+					fmt.Fprintf(eout, "%s\n", err)
+				}
+				fp.Close()
+			}
+		} else if app.Cfg.People != nil {
+			irdmtools.WritePeopleReport(eout, app.Cfg.People.Reported)
+		}
+		os.Exit(exitCode)
+	}
+
 	optionsFName, dataSource, doi := "", "", ""
+	if batchFName != "" {
+		if len(args) > 0 {
+			optionsFName = args[0]
+		}
+		if len(args) > 1 {
+			dataSource = args[1]
+		}
+		if exitCode, err := app.RunBatch(in, out, eout, optionsFName, dataSource, batchFName, cName, errorFName, provenanceFName); err != nil {
+			fmt.Fprintf(eout, "%s\n", err)
+			exit(exitCode)
+		}
+		exit(0)
+	}
+	if rdmDiff != "" {
+		// args are [OPTIONS_YAML] [crossref|datacite|merge|pubmed|arxiv] [DOI]
+		for i, arg := range args {
+			switch {
+			case i == 0 && isOptionsFile(arg):
+				optionsFName = arg
+			case dataSource == "" && doi == "" && isDataSource(arg):
+				dataSource = arg
+			default:
+				doi = arg
+			}
+		}
+		fields := []string{}
+		if stageFields != "" {
+			fields = strings.Split(stageFields, ",")
+		}
+		if err := app.OpenDB(); err != nil {
+			fmt.Fprintf(eout, "%s\n", err)
+			exit(irdmtools.ENOEXEC)
+		}
+		if exitCode, err := app.RunRdmDiff(in, out, eout, optionsFName, dataSource, rdmDiff, doi, fields); err != nil {
+			fmt.Fprintf(eout, "%s\n", err)
+			exit(exitCode)
+		}
+		exit(0)
+	}
 	if len(args) < 1 {
 		fmt.Fprintln(eout, "expected a least a single DOI on the command line")
-		os.Exit(1)
+		exit(1)
 	} else if len(args) == 1 {
 		optionsFName, dataSource, doi = "", "", args[0]
 	} else if len(args) == 2 {
@@ -196,17 +564,33 @@ func main() {
 		case "crossref":
 			if exitCode, err := app.RunCrossRefToRdm(in, out, eout, optionsFName, doi, diffFName); err != nil {
 				fmt.Fprintf(eout, "%s\n", err)
-				os.Exit(exitCode)
+				exit(exitCode)
 			}
 		case "datacite":
 			if exitCode, err := app.RunDataCiteToRdm(in, out, eout, optionsFName, doi, diffFName); err != nil {
 				fmt.Fprintf(eout, "%s\n", err)
-				os.Exit(exitCode)
+				exit(exitCode)
+			}
+		case "arxiv":
+			if exitCode, err := app.RunArxivToRdm(in, out, eout, optionsFName, doi, diffFName); err != nil {
+				fmt.Fprintf(eout, "%s\n", err)
+				exit(exitCode)
+			}
+		case "pubmed":
+			if exitCode, err := app.RunPubMedToRdm(in, out, eout, optionsFName, doi, diffFName); err != nil {
+				fmt.Fprintf(eout, "%s\n", err)
+				exit(exitCode)
+			}
+		case "merge":
+			if exitCode, err := app.RunDoiToRdmMerged(in, out, eout, optionsFName, doi, diffFName, provenanceFName); err != nil {
+				fmt.Fprintf(eout, "%s\n", err)
+				exit(exitCode)
 			}
 		default:
 			if exitCode, err := app.RunDoiToRdmCombined(in, out, eout, optionsFName, doi, diffFName); err != nil {
 				fmt.Fprintf(eout, "%s\n", err)
-				os.Exit(exitCode)
+				exit(exitCode)
 			}
 	}
+	exit(0)
 }
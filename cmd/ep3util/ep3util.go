@@ -144,6 +144,16 @@ with one of the options, e.g. `+"`"+`-all`+"`"+`, you can skip providing the KEY
 -as-citations
 : This harvests the record into a minimal citation form similar to citeproc
 
+-workers N
+: The number of records to retrieve concurrently when harvesting from the
+EPrints MySQL database, writes to the collection remain serialized.
+Defaults to one.
+
+-resume
+: Skip the ids finished by a previous harvest and retry the failed and
+pending ones. Each harvest records its progress in a checkpoint journal
+next to the collection, e.g. "C_NAME.checkpoint.jsonl".
+
 # ACTION_PARAMETERS
 
 Action parameters are the specific optional or required parameters need to complete an aciton.
@@ -56,6 +56,8 @@ var (
 
 {app_name} [OPTIONS] [EPRINT_HOST] EPRINT_ID
 
+{app_name} [OPTIONS] migrate [EPRINT_ID ...]
+
 # DESCRIPTION
 
 {app_name} is a Caltech Library oriented command line application
@@ -72,6 +74,22 @@ customized resource type and person role mapping for the content
 you harvest. This will allow you to be substantially closer to the
 final record form needed to crosswalk EPrints data into Invenio RDM.
 
+The "migrate" mode moves EPrint records into published RDM records.
+Each EPrint is crosswalked, created in RDM and its files attached. EPrint
+documents with different security settings become versions of the record,
+in the order internal, validuser (campus only) and public with a metadata
+only version if there are no public files. The first version is sent to
+the RDM_COMMUNITY_ID community and accepted, later versions are published.
+Each EPrint's id, RDM record id and status ("migrated", "exists", "deleted"
+or "failed") is appended to "migrated_records.csv". EPrints already
+logged as migrated, or found in RDM by their eprintid or resolverid, are
+skipped so a migration can be re-run safely. An EPrint that failed after
+its RDM record was created is resumed from its last published version
+using the RDM record id in the log. Files are copied from
+EPRINT_ARCHIVES_PATH/REPO_ID/documents/DIR where DIR is the eprint's
+dir, otherwise they are retrieved from their URL with the EPrints user
+and password.
+
 # ENVIRONMENT
 
 Environment variables can be set at the shell level or in a ".env" file.
@@ -85,6 +103,25 @@ EPRINT_PASSWORD
 EPRINT_HOST
 : The hostname of the EPrints service
 
+EPRINT_ARCHIVES_PATH
+: (used with migrate) The path to the EPrints archives directory holding
+the REPO_ID directory. If it is not on the local file system the files
+are copied from EPRINT_HOST with scp. If not set the files are
+retrieved using their EPrints URL.
+
+REPO_ID
+: (used with migrate and EPRINT_ARCHIVES_PATH) The EPrints repository
+id, e.g. caltechauthors
+
+RDM_URL
+: (used with migrate) The URL of the RDM instance
+
+RDMTOK
+: (used with migrate) The token used to access the RDM API
+
+RDM_COMMUNITY_ID
+: (used with migrate) The community migrated records are submitted to
+
 
 # OPTIONS
 
@@ -103,10 +140,42 @@ EPRINT_HOST
 -harvest DATASET_NAME
 : Harvest content to a dataset collection rather than standard out
 
+-fixup RULES
+: apply the fixup rules to records after they are crosswalked. RULES is
+"all" or a comma separated list of rule names. The rules are applied in
+the order below.
+
+  drop-empty-identifiers
+  : remove identifiers missing a scheme or value from the record, related
+  identifiers and people
+  normalize-doi
+  : trim doi.org URLs and "doi:" prefixes from DOI and remove related
+  identifiers matching the record's DOI
+  normalize-issn
+  : format the journal and identifier ISSN as NNNN-NNNN
+  person-names
+  : set a missing name to "family, given" for creators and contributors
+  dedupe-creators
+  : merge creators and contributors repeated with the same ORCID, clpid
+  or name
+  title-types
+  : set missing additional title types to "alternative-title"
+  contributor-roles
+  : map contributor roles not in the roles vocabulary to "other"
+  award-titles
+  : add an empty title to awards without one
+  vocabulary-ids
+  : report resource type, role, title, description, date and relation
+  type ids not in the RDM vocabularies, the record is not output
+
 -id-list ID_FILE_LIST
 : (used with harvest) Retrieve records based on the ids in a file,
 one line per id.
 
+-migration-log FILENAME
+: (used with migrate) the CSV file logging the eprintid, rdm_id and
+status of each EPrint migrated. Defaults to "migrated_records.csv".
+
 -resource-map FILENAME
 : use this comma delimited resource map from EPrints to RDM resource types.
 The resource map file is a comma delimited file without a header row.
@@ -172,6 +241,15 @@ dataset init eprints.ds
 
 At this point you would be ready to improve the records in
 eprints.ds before migrating them into Invenio RDM.
+
+Migrate the EPrints listed in "1890s-eprints.txt" into RDM. The RDM and
+EPrints access is configured in the environment. Re-running the command
+picks up where a previous run left off.
+
+~~~
+{app_name} -id-list 1890s-eprints.txt -resource-map resource_types.csv \
+      -contributor-map contributor_types.csv -fixup all migrate
+~~~
 `
 )
 
@@ -185,8 +263,9 @@ func main() {
 
 	showHelp, showVersion, showLicense := false, false, false
 	allIds, debug := false, false
-	idList, cName, configFName := "", "", ""
+	idList, cName, configFName, fixup := "", "", "", ""
 	resourceTypesFName, contributorTypesFName := "", ""
+	migrationLog := irdmtools.MigratedRecordsCSV
 	flag.BoolVar(&showHelp, "help", false, "display help")
 	flag.BoolVar(&showVersion, "version", false, "display version")
 	flag.BoolVar(&showLicense, "license", false, "display license")
@@ -197,6 +276,8 @@ func main() {
 	flag.StringVar(&resourceTypesFName, "resource-map", resourceTypesFName, "use this file to map resource types from EPrints to Invenio RDM")
 	flag.StringVar(&contributorTypesFName, "contributor-map", contributorTypesFName, "use this file to map contributor types from EPrints to Invenio RDM")
 	flag.StringVar(&configFName, "config", configFName, "user config file")
+	flag.StringVar(&fixup, "fixup", fixup, "apply fixup rules (comma separated or all) to crosswalked records")
+	flag.StringVar(&migrationLog, "migration-log", migrationLog, "(used with migrate) CSV file logging eprintid, rdm_id and status")
 	flag.Parse()
 	args := flag.Args()
 
@@ -224,6 +305,21 @@ func main() {
 		fmt.Fprintf(os.Stderr, "%s\n", err)
 		os.Exit(1)
 	}
+	if fixup != "" {
+		fixups, err := irdmtools.NewFixups(fixup)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+		app.Fixups = fixups
+	}
+	if len(args) > 0 && args[0] == "migrate" {
+		if err := app.RunMigrate(args[1:], idList, resourceTypesFName, contributorTypesFName, migrationLog); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
 	if (allIds || idList != "") && eprintHostname == "" {
 		if len(args) != 1 {
 			fmt.Fprintf(os.Stderr, "expected an EPrint hostname with either -all-ids or -ids-list and -harvest options")
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+
+	// Caltech Library
+	"github.com/caltechlibrary/irdmtools"
+
+	// 3rd Party Libraries
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	helpText = `%{app_name}(1) irdmtools user manual | version {version} {release_hash}
+% R. S. Doiel
+% {release_date}
+
+# NAME
+
+{app_name}
+
+# SYNOPSIS
+
+{app_name} [OPTIONS] < INPUT_CSV_FILE > OUTPUT_VOC_YAML_FILE
+
+# DESCRIPTION
+
+{app_name} converts a CSV file of local groups to a YAML vocabulary
+file suitable for import into Invenio-RDM. The CSV file needs a "name"
+column (or "title") and usually a "key" column (or "id"). A group without
+a key gets an id made from its name, e.g. "Division of Biology" becomes
+"division-of-biology". Other columns are ignored.
+
+The groups are sorted by id so converting an updated CSV file only
+changes the groups that changed. Rows that can't be converted are
+reported, if there are any nothing is written and {app_name} exits
+with an error.
+
+# OPTIONS
+
+-help
+: display help
+
+-license
+: display license
+
+-version
+: display version
+
+-i
+: Read input from file
+
+-o
+: Write output to file
+
+-csv
+: (default: true) Input is in csv format, otherwise a JSON array of
+objects
+
+# EXAMPLES
+
+~~~shell
+    {app_name} < groups.csv >groups-vocabulary.yaml
+
+    {app_name} -csv=false groups.json groups-vocabulary.yaml
+~~~
+
+`
+)
+
+func main() {
+	var (
+		err         error
+		inputFName  string
+		outputFName string
+
+		showHelp    bool
+		showVersion bool
+		showLicense bool
+
+		inputIsCSV bool
+	)
+	appName := path.Base(os.Args[0])
+	version := irdmtools.Version
+	releaseDate := irdmtools.ReleaseDate
+	releaseHash := irdmtools.ReleaseHash
+	fmtHelp := irdmtools.FmtHelp
+
+	in := os.Stdin
+	out := os.Stdout
+	eout := os.Stderr
+
+	flag.BoolVar(&showHelp, "help", false, "display help text")
+	flag.BoolVar(&showVersion, "version", false, "display version")
+	flag.BoolVar(&showLicense, "license", false, "display license")
+	flag.StringVar(&inputFName, "i", "", "input filename")
+	flag.StringVar(&outputFName, "o", "", "output filename")
+	flag.BoolVar(&inputIsCSV, "csv", true, "input is CSV format")
+	flag.Parse()
+	args := flag.Args()
+	if showHelp {
+		fmt.Fprintf(out, "%s\n", fmtHelp(helpText, appName, version, releaseDate, releaseHash))
+		os.Exit(0)
+	}
+	if showLicense {
+		fmt.Fprintf(out, "%s\n", irdmtools.LicenseText)
+		os.Exit(0)
+	}
+	if showVersion {
+		fmt.Fprintf(out, "%s %s %s\n", appName, version, releaseHash)
+		os.Exit(0)
+	}
+	if (len(args) > 0) && (inputFName == "") {
+		inputFName = args[0]
+	}
+	if (len(args) > 1) && (outputFName == "") {
+		outputFName = args[1]
+	}
+	if (inputFName != "") && (inputFName != "-") {
+		in, err = os.Open(inputFName)
+		if err != nil {
+			fmt.Fprintf(eout, "%s\n", err)
+			os.Exit(1)
+		}
+		defer in.Close()
+	}
+	src, err := ioutil.ReadAll(in)
+	if err != nil {
+		fmt.Fprintf(eout, "%s\n", err)
+		os.Exit(1)
+	}
+	groups, err := irdmtools.GroupsToVocabulary(src, inputIsCSV)
+	if err != nil {
+		fmt.Fprintf(eout, "%s\n", err)
+		os.Exit(1)
+	}
+	src, err = yaml.Marshal(groups)
+	if err != nil {
+		fmt.Fprintf(eout, "%s\n", err)
+		os.Exit(1)
+	}
+	// NOTE: the output file is only created once the groups are mapped
+	// so a failed run doesn't clobber a good vocabulary.
+	if (outputFName != "") && (outputFName != "-") {
+		out, err = os.Create(outputFName)
+		if err != nil {
+			fmt.Fprintf(eout, "%s\n", err)
+			os.Exit(1)
+		}
+		defer out.Close()
+	}
+	fmt.Fprintf(out, "%s", src)
+}
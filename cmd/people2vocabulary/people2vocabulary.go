@@ -2,13 +2,10 @@ package main
 
 import (
 	"bytes"
-	"encoding/csv"
 	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
 	"io/ioutil"
-	"log"
 	"os"
 	"path"
 
@@ -79,79 +76,6 @@ func fmtYAML(o interface{}) string {
 	return fmt.Sprintf("%s", src)
 }
 
-func mapField(person *simplified.Person, key string, val string) error {
-	if val == "" {
-		// NOTE: An empty value isn't an error, we just don't map it.
-		return nil
-	}
-	switch key {
-	case "family_name":
-		person.Family = val
-	case "given_name":
-		person.Given = val
-	case "clpid":
-		identifier := new(simplified.Identifier)
-		identifier.Scheme = "clpid"
-		identifier.Identifier = val
-		person.Identifiers = append(person.Identifiers, identifier)
-	case "cl_people_id":
-		identifier := new(simplified.Identifier)
-		identifier.Scheme = "clpid"
-		identifier.Identifier = val
-		person.Identifiers = append(person.Identifiers, identifier)
-	case "thesis_id":
-	case "advisor_id":
-	case "authors_id":
-	case "archivesspace_id":
-	case "directory_id":
-	case "viaf_id":
-	case "lcnaf":
-	case "isni":
-		identifier := new(simplified.Identifier)
-		identifier.Scheme = key
-		identifier.Identifier = val
-		person.Identifiers = append(person.Identifiers, identifier)
-	case "wikidata":
-	case "snac":
-	case "orcid":
-		identifier := new(simplified.Identifier)
-		identifier.Scheme = key
-		identifier.Identifier = val
-		person.Identifiers = append(person.Identifiers, identifier)
-	case "image":
-	case "educated_at":
-	case "caltech":
-		affiliation := new(simplified.Affiliation)
-		affiliation.ID = "05dxps055"
-		affiliation.Name = "Caltech"
-		person.Affiliations = append(person.Affiliations, affiliation)
-	case "jpl":
-		affiliation := new(simplified.Affiliation)
-		affiliation.ID = "027k65916"
-		affiliation.Name = "JPL"
-		person.Affiliations = append(person.Affiliations, affiliation)
-	case "faculty":
-	case "alumn":
-	case "status":
-	case "directory_person_type":
-	case "title":
-	case "bio":
-	case "division":
-	case "authors_count":
-	case "thesis_count":
-	case "data_count":
-	case "advisor_count":
-	case "editor_count":
-	case "updated":
-	default:
-		return fmt.Errorf("not know how to map %q <- %q", key, val)
-	}
-	if person.Name == "" && person.Given != "" && person.Family != "" {
-		person.Name = fmt.Sprintf("%s, %s", person.Family, person.Given)
-	}
-	return nil
-}
-
 func main() {
 	var (
 		err    error
@@ -227,53 +151,9 @@ func main() {
 	if inputIsCSV {
 		//NOTE: spreadsheet conversion process will filter out none
 		// RDM identifiers when producing the YAML.
-		r := csv.NewReader(bytes.NewBuffer(src))
-		fields := []string{}
-		rowNo := 0
-		e := 0
-		// NOTE:This is the Caltech affiliation, needed by clsRules
-		// where this list of of Caltech people (from feeds).
-		caltech := new(simplified.Affiliation)
-		caltech.ID = "05dxps055"
-		caltech.Name = "Caltech"
-		for {
-			cells, err := r.Read()
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				log.Printf("row %d error, %s", rowNo, err)
-				e += 1
-				break
-			}
-			if rowNo == 0 {
-				fields = cells[:]
-			} else {
-				person := new(simplified.Person)
-				if len(cells) > len(fields) {
-					log.Printf("row %d error, too many columns", rowNo)
-					e += 1
-					break
-				}
-				for colNo, val := range cells {
-					key := fields[colNo]
-					if err := mapField(person, key, val); err != nil {
-						log.Printf("row %d error, %s", rowNo, err)
-						e += 1
-					}
-				}
-				if clRules {
-					// Check if Caltech affiliation is asserted
-					if ! person.HasAffiliation(caltech) {
-						person.Affiliations = append(person.Affiliations, caltech)
-					}
-				}
-
-				peopleList = append(peopleList, person)
-			}
-			rowNo++
-		}
-		if e > 0 {
+		peopleList, err = irdmtools.ReadPeopleCSV(bytes.NewBuffer(src), clRules)
+		if err != nil {
+			fmt.Fprintf(eout, "%s\n", err)
 			os.Exit(1)
 		}
 		src, err = yaml.Marshal(peopleList)
@@ -112,6 +112,11 @@ specified by C_NAME.
 -latest
 : only convert record(s) if latest version.
 
+-resume
+: when used with -harvest skip the ids finished in a previous run, as
+recorded in the checkpoint journal next to the collection, retrying
+the failed and pending ids.
+
 # EXAMPLE
 
 Example generating a EPRINT JSON document from RDM would use the following
@@ -163,7 +168,7 @@ func main() {
 	releaseDate := irdmtools.ReleaseDate
 	releaseHash := irdmtools.ReleaseHash
 	fmtHelp := irdmtools.FmtHelp
-	latestVersions := false
+	latestVersions, resume := false, false
 
 	showHelp, showVersion, showLicense := false, false, false
 	configFName, debug, asXML := "", false, false
@@ -178,6 +183,7 @@ func main() {
 	flag.StringVar(&cName, "harvest", cName, "harvest JSON eprint records into the dataset collection.")
 	flag.BoolVar(&pipeline, "pipeline", pipeline, "read from standard input, crosswalk and write to standard out")
 	flag.BoolVar(&latestVersions, "latest", latestVersions, "only convert record if the latest version")
+	flag.BoolVar(&resume, "resume", resume, "resume a harvest skipping ids finished in the checkpoint journal")
 
 	flag.Parse()
 	rdmids := flag.Args()
@@ -215,6 +221,7 @@ func main() {
 		os.Exit(1)
 	}
 	if cName != "" {
+		app.Cfg.Resume = resume
 		if err := app.RunHarvest(os.Stdin, os.Stdout, os.Stderr, cName, rdmids, latestVersions); err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", err)
 			os.Exit(1)
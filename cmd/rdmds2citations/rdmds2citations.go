@@ -104,6 +104,24 @@ prefix keys with "authors:"
 -host
 : Set the hostname of base url to for reference records (e.g. authors.library.caltech.edu). Can also be set via the environment as RDM_URL.
 
+-csl FILENAME
+: Also write the citations processed as a CSL-JSON array to FILENAME
+(use "-" for standard output). CSL-JSON can be read by citeproc
+processors and imported into Zotero. The resource types are mapped to
+CSL types (e.g. "publication-article" to "article-journal"), the
+publication date to "issued" and the time of the run to "accessed".
+The "accessed" date is only in the CSL-JSON, it isn't stored with the
+citations.
+
+-style FILENAMES
+: Render each citation with the CSL styles (a comma separated list of
+".csl" files, e.g. "styles/apa.csl,styles/ieee.csl") and store them in
+the citation's "formatted" field keyed by style name (e.g. "apa").
+
+-style-format FORMAT
+: The format of the rendered citations, "html" (the default),
+"markdown" or "text".
+
 # ENVIRONMENT 
 
 Some settings can be picked from the environment.
@@ -126,6 +144,24 @@ collection using prefixes and the source repository ids.
 		   data.ds citations.ds zzj7r-61978
 ~~~
 
+Write the CSL-JSON for the citations as they are added to the citation
+collection.
+
+~~~shell
+{app_name} -prefix authors -host authors.library.caltech.edu \
+           -keys authors-keys.txt -csl authors-csl.json \
+           authors.ds citations.ds
+~~~
+
+Store the citations formatted in APA and IEEE styles as HTML in the
+"formatted" field of each citation.
+
+~~~shell
+{app_name} -prefix authors -host authors.library.caltech.edu \
+           -style styles/apa.csl,styles/ieee.csl \
+           authors.ds citations.ds k3tpc-ga970
+~~~
+
 `
 )
 
@@ -191,7 +227,8 @@ func main() {
 	fmtHelp := irdmtools.FmtHelp
 
 	showHelp, showVersion, showLicense := false, false, false
-	idsFName, keysFName, repoHost, prefix := "", "", "", ""
+	idsFName, keysFName, repoHost, prefix, cslFName := "", "", "", "", ""
+	styleFNames, styleFormat := "", "html"
 	flag.BoolVar(&showHelp, "help", false, "display help")
 	flag.BoolVar(&showVersion, "version", false, "display version")
 	flag.BoolVar(&showLicense, "license", false, "display license")
@@ -199,6 +236,9 @@ func main() {
 	flag.StringVar(&keysFName, "keys", keysFName, "read keys from a file or standard input")
 	flag.StringVar(&prefix, "prefix", prefix, "apply this prefix to keys being imported before saving")
 	flag.StringVar(&repoHost, "host", repoHost, "repository hostname, used to form URL to original record")
+	flag.StringVar(&cslFName, "csl", cslFName, "also write the citations as CSL-JSON to a file or standard out (use \"-\")")
+	flag.StringVar(&styleFNames, "style", styleFNames, "render citations with these CSL style files (comma separated)")
+	flag.StringVar(&styleFormat, "style-format", styleFormat, "format of rendered citations, html, markdown or text")
 
 	flag.Parse()
 	args := flag.Args()
@@ -237,5 +277,5 @@ func main() {
    			os.Exit(1)
 		}
 	}
-	os.Exit(irdmtools.RunRdmDSToCitationDS(in, out, eout, args, repoHost, prefix, dsIds))
+	os.Exit(irdmtools.RunRdmDSToCitationDS(in, out, eout, args, repoHost, prefix, dsIds, cslFName, styleFNames, styleFormat))
 }
@@ -127,6 +127,38 @@ get_all_ids
 : Returns a list of all repository record ids latest versions. The method
 requires Postgres database access.
 
+query_ids FILTER
+: Returns a list of latest version record ids whose JSON matches FILTER.
+FILTER is one or more comparisons of a field to a value joined by "and",
+"or", "not" and parentheses. A field is a dotted path into the record,
+e.g. metadata.publication_date or custom_fields.journal:journal.issn,
+arrays along the path are searched. The operators are =, !=, <, <=, >,
+>=, ~ (case insensitive regular expression) and "exists" which takes no
+value. Values containing spaces or operator characters must be quoted.
+Quote the whole FILTER for your shell. The JSON array returned can be
+used with harvest or patch_records. Requires Postgres access.
+
+setup_reindex_queue
+: Creates the irdmtools_reindex_queue table used by get_reindex_ids and
+reindex_drafts. Run it once, as a Postgres user allowed to create tables,
+before drafts are edited directly in Postgres.
+
+get_reindex_ids [-clear]
+: Draft edits made directly in Postgres (update_draft, set_access,
+set_version, set_publication_date, set_files_enable) are not seen by
+RDM's search index until reindexed. Each edit bumps the draft's
+revision_id and queues it in the irdmtools_reindex_queue table (see
+setup_reindex_queue). This returns the queued record ids as a JSON array, with `+"`"+`-clear`+"`"+`
+the returned ids are removed from the queue. Requires Postgres access.
+
+reindex_drafts
+: Drains the reindex queue. Each queued draft is read and updated
+unchanged through the RDM API so RDM validates it and updates its
+search index. Drafts that fail stay queued. Returns the record ids
+reindexed as a JSON array. Run it after a batch of update_draft,
+put_record, patch_records or link_versions edits. Requires Postgres
+access.
+
 get_all_stale_ids
 : Returns a list of public record ids that are NOT the latest version of the
 records, useful when prune a dataset collection of stale RDM records.
@@ -191,6 +223,30 @@ new_record [FILENAME]
 : Create a new record from JSON source. If FILENAME is set then json source
 is read from FILENAME otherwise it reads from standard input.
 
+put_record [FILENAME]
+: Create or update a record from JSON source. If FILENAME is set then json
+source is read from FILENAME otherwise it reads from standard input. An
+existing record is found by the EPrints "resolverid" or "eprintid" in
+.metadata.identifiers or, if neither matches, by its DOI. A DOI match with
+different EPrints identifiers is not the same record. If one is found a draft is created, the metadata, custom fields, access and pids
+are merged into it and it is published. If the match is a draft that was
+never published the draft is updated. Otherwise a new record is created as
+with new_record. If more than one record matches nothing is changed and an
+error is reported. If Postgres is configured it is used to find drafts.
+
+validate [-vocabularies YAML_FILE] [FILENAME]
+: Check the vocabulary ids in a record's JSON source, e.g. resource type,
+creator and contributor roles, title, description, date and relation
+types, licenses and subjects, before it is submitted with new_record or
+put_record. If FILENAME is set the JSON source is read from FILENAME
+otherwise it reads from standard input. The vocabularies are read from
+YAML_FILE if set, otherwise from the vocabularies_metadata table if
+Postgres is configured, otherwise the RDM defaults are used. YAML_FILE maps
+vocabulary names (e.g. "resourcetypes") to a list of ids or to a
+"data-file" as in irdm/vocabularies.yaml. Every violation is written out as
+a JSON array of path, vocabulary and value. The exit status is non-zero if
+there are violations.
+
 new_version RECORD_ID
 : This will create a new version of the record. RECORD_ID is required.
 NOTE: When you create a new version .metadata.publication_date and 
@@ -207,15 +263,22 @@ RECORD_ID is required.
 
 new_draft RECORD_ID
 : Create a new draft for an existing record. RECORD_ID is required. 
+If Postgres is configured and a draft already exists it is returned
+from the database.
 
-get_draft RECORD_ID
+get_draft [-pg] RECORD_ID
 : Retrieve an existing draft record for RECORD_ID. RECORD_ID is required.
-If draft of RECORD_ID does not exist you will see a 404 error.
+If draft of RECORD_ID does not exist you will see a 404 error. With
+`+"`"+`-pg`+"`"+` the draft is read as stored in Postgres, without the links
+and parent added by the RDM API.
 
 update_draft RECORD_ID [FILENAME]
 : Update a draft record. RECORD_ID is required. FILENAME is optional, if
 one is provided the JSON document is used to update RDM, otherwise standard
-input is used to get the JSON required to do the update.
+input is used to get the JSON required to do the update. If Postgres is
+configured the draft's vocabulary ids are checked, the draft's metadata,
+custom_fields, access and pids are replaced directly in the database and
+the draft is queued for reindex_drafts.
 
 set_files_enable RECORD_ID true|false
 : This will flip the files.enabled value to true and update the draft.
@@ -260,10 +323,51 @@ set_access RECORD_ID ACCESS_TYPE ACCESS_VALUE
 required. ACCESS_TYPE is required and can be either "record" or "files".
 ACCESS_VALUE is required and can be "restricted" or "public".
 
-harvest KEY_JSON
+harvest [-workers N] [-resume] KEY_JSON
 : harvest takes a JSON file containing a list of keys and harvests each record
 into the dataset collection indicated by the environment variable C_NAME.
-
+The `+"`"+`-workers`+"`"+` option sets the number of records retrieved concurrently
+from Postgres, writes to the collection remain serialized. Defaults to one.
+Each run records finished and failed ids (with the reason) in a checkpoint
+journal next to the collection, e.g. "C_NAME.checkpoint.jsonl". The
+`+"`"+`-resume`+"`"+` option skips the finished ids and retries the failed and
+pending ones.
+
+
+sync [-mark-deleted] [START]
+: sync brings the dataset collection indicated by the environment variable
+C_NAME up to date. It retrieves the records updated since the last sync,
+the high-water mark stored in "C_NAME.sync.json", and removes the
+collection keys of records that have been deleted, tombstoned or
+restricted. With `+"`"+`-mark-deleted`+"`"+` those records are kept with a
+tombstone instead. START (e.g. 2023-01-01) overrides the stored mark,
+on the first run without START all records are synced. Requires Postgres
+access.
+
+patch_records [-dry-run] [-publish] KEY_JSON PATCH_FILE
+: patch_records applies the changes in PATCH_FILE to each record listed
+in the JSON file KEY_JSON. PATCH_FILE holds either a JSON Patch (RFC 6902),
+an array of operations with paths like "/metadata/title", or a JSON Merge
+Patch (RFC 7396), an object merged into the record. A new draft is created
+for each record, patched and updated. With `+"`"+`-publish`+"`"+` the draft is
+then published. With `+"`"+`-dry-run`+"`"+` no drafts are created, the changes
+to each record, or to its pending draft if it has one, are written out as
+a JSON array of record id and diff.
+
+link_versions [-apply] [-publish] [-cache C_NAME] [-cache-ttl DURATION] [KEY_JSON]
+: link_versions checks preprint records with CrossRef. When CrossRef
+reports the preprint's DOI "is-preprint-of" a published DOI the preprint
+gets an "ispreviousversionof" related identifier for the published DOI and,
+if the published version is in RDM, that record gets an "isversionof"
+related identifier for the preprint DOI. Records already listing the DOI
+are skipped. The preprints are listed in the JSON file KEY_JSON, without
+it the records with the resource type publication-preprint are checked,
+this requires Postgres access. Without Postgres access the records are
+read with the RDM API. By default the proposed links are written
+out as a JSON array with the diff of each record. With `+"`"+`-apply`+"`"+` a
+draft of each record is updated, with `+"`"+`-publish`+"`"+` the draft is then
+published. With `+"`"+`-cache`+"`"+` CrossRef responses are saved in and reused
+from a dataset collection for the `+"`"+`-cache-ttl`+"`"+` (default 720h).
 
 get_endpoint PATH
 : Perform a GET to the end point indicated by PATH. PATH is required.
@@ -313,6 +417,27 @@ Get a list of all Invenio-RDM record ids.
 {app_name} get_all_ids
 ~~~
 
+Get the ids of the 2020 articles in a journal and harvest them.
+
+~~~
+{app_name} query_ids 'custom_fields.journal:journal.issn = "0035-8711"
+  and metadata.publication_date >= 2020 and metadata.publication_date < 2021' >ids.json
+{app_name} harvest ids.json
+~~~
+
+Keep the collection in C_NAME up to date, e.g. from a nightly cron job.
+
+~~~
+{app_name} sync
+~~~
+
+Check a crosswalked record against the vocabularies before creating it.
+
+~~~
+{app_name} validate -vocabularies irdm/vocabularies.yaml article.json && \
+  {app_name} new_record article.json
+~~~
+
 Get a specific Invenio-RDM record. Record is validated
 against irdmtool model.
 
@@ -93,12 +93,31 @@ type Config struct {
 	EPrintDbPassword string `json:"eprint_db_password,omitempty" yaml:"eprint_db_password,omitempty"`
 	EPrintBaseURL string`json:"eprint_base_url,omitempty" yaml:"eprint_base_url,omitempty"`
 
+	// Workers holds the number of concurrent workers used when harvesting
+	// records. If zero or less a single worker is used.
+	Workers int `json:"workers,omitempty" yaml:"workers,omitempty"`
+	// Resume is set true to skip the ids a previous harvest finished
+	// as recorded in the collection's checkpoint journal.
+	Resume bool `json:"-" yaml:"-"`
+
+	// Cache holds the response cache used for CrossRef, DataCite and
+	// ROR lookups. If nil the services are always queried.
+	Cache *ResponseCache `json:"-" yaml:"-"`
+	// Ror holds a local index of the ROR data dump used to match
+	// affiliations and funders. If nil the ROR API is queried for funders.
+	Ror *RorIndex `json:"-" yaml:"-"`
+	// People holds the Caltech people list used to add clpid and ORCID
+	// to creators. If nil creators aren't enriched.
+	People *PeopleIndex `json:"-" yaml:"-"`
 
 	// rl holds rate limiter data for throttling API requests
 	rl *RateLimit
 
 	// pgDb holds a Postgres connection
 	pgDB *sql.DB
+	// vocabularies holds the vocabularies drafts edited in Postgres
+	// are validated with, they are loaded on first use.
+	vocabularies Vocabularies
 	myDB *sql.DB
 }
 
@@ -118,6 +137,15 @@ func NewConfig() *Config {
 	return cfg
 }
 
+// harvestWorkers returns the number of workers to use when harvesting,
+// at least one.
+func (cfg *Config) harvestWorkers() int {
+	if cfg.Workers < 1 {
+		return 1
+	}
+	return cfg.Workers
+}
+
 // MakeDSN will return the value set for cfg.InvenioDSN or set and return it if
 // enough data is provided in the config.
 func (cfg *Config) MakeDSN() string {
@@ -186,6 +214,9 @@ func (cfg *Config) LoadEnv(prefix string) error {
 	if token := os.Getenv(prefixVar("RDMTOK", prefix)); token != "" && cfg.InvenioToken == "" {
 		cfg.InvenioToken = token
 	}
+	if communityID := os.Getenv(prefixVar("RDM_COMMUNITY_ID", prefix)); communityID != "" && cfg.InvenioCommunityID == "" {
+		cfg.InvenioCommunityID = communityID
+	}
 	if cName := os.Getenv(prefixVar("C_NAME", prefix)); cName != "" && cfg.CName == "" {
 		cfg.CName = cName
 	}
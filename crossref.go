@@ -19,10 +19,26 @@ func QueryCrossRefWork(cfg *Config, doi string, options *Doi2RdmOptions) (*cross
 	if err != nil {
 		return nil, err
 	}
-	works, err := client.Works(doi)
+	return queryCrossRefWork(client, cfg, doi)
+}
+
+// queryCrossRefWork retrieves a works record using an existing client, e.g.
+// when processing a batch of DOI. Responses are read from and saved to
+// cfg.Cache when set.
+func queryCrossRefWork(client *crossrefapi.CrossRefClient, cfg *Config, doi string) (*crossrefapi.Works, error) {
+	src, err := cfg.Cache.Fetch("crossref", doi, func() ([]byte, error) {
+		return client.WorksJSON(doi)
+	})
 	if err != nil {
 		return nil, err
 	}
+	if len(src) == 0 {
+		return nil, fmt.Errorf("no data returned for %q", doi)
+	}
+	works := new(crossrefapi.Works)
+	if err := JSONUnmarshal(src, &works); err != nil {
+		return nil, err
+	}
 	if cfg.Debug {
 		src, _ := JSONMarshalIndent(works, "", "    ")
 		fmt.Fprintf(os.Stderr, "works JSON:\n\n%s\n\n", src)
@@ -49,10 +65,11 @@ func getWorksTitles(work *crossrefapi.Works) []string {
 	return []string{}
 }
 
-// getWorksAbstract retrieves the abstract from the CrossRef Works
+// getWorksAbstract retrieves the abstract from the CrossRef Works, the
+// JATS markup is converted to HTML.
 func getWorksAbstract(work *crossrefapi.Works) string {
 	if work.Message != nil && work.Message.Abstract != "" {
-		return work.Message.Abstract
+		return JATSToHTML(work.Message.Abstract)
 	}
 	return ""
 }
@@ -157,7 +174,7 @@ func getWorksISSNs(work *crossrefapi.Works) []string {
 }
 
 // getWorksFunding
-func getWorksFunding(work *crossrefapi.Works) []*simplified.Funder {
+func getWorksFunding(cfg *Config, work *crossrefapi.Works) []*simplified.Funder {
 	funding := []*simplified.Funder{}
 	suffixToROR := map[string]string{}
 	if work.Message != nil && work.Message.Funder != nil && len(work.Message.Funder) > 0 {
@@ -179,13 +196,20 @@ func getWorksFunding(work *crossrefapi.Works) []*simplified.Funder {
 					}
 				}
 			}
-			if ror == "" && funder.DOI != "" && funder.DoiAssertedBy == "publisher" {
+			if ror == "" && cfg.Ror != nil {
+				// NOTE: Funder DOI map exactly to ROR ids in the data dump,
+				// names are matched and reported if not confident.
+				if ror, ok = cfg.Ror.ResolveFundRef(funder.DOI); !ok && funder.Name != "" {
+					ror, _ = cfg.Ror.Resolve(funder.Name)
+				}
+			}
+			if ror == "" && cfg.Ror == nil && funder.DOI != "" && funder.DoiAssertedBy == "publisher" {
 				parts := strings.SplitN(funder.DOI, "/", 2)
 				if len(parts) == 2 {
 					suffix = strings.TrimSpace(parts[1])
 					ror, ok = suffixToROR[suffix]
 					if ! ok {
-						ror, ok = lookupROR(suffix, true)
+						ror, ok = lookupROR(cfg.Cache, suffix, true)
 						if ok {
 							suffixToROR[suffix] = ror
 						}
@@ -260,7 +284,13 @@ func makeSimpleTitleDetail(title string) *simplified.TitleDetail {
 	}
 }
 
-func crosswalkWorksAuthorAffiliationToCreatorAffiliation(crAffiliation *crossrefapi.Organization) *simplified.Affiliation {
+// crosswalkWorksAuthorAffiliationToCreatorAffiliation uses the publisher's
+// ROR id, otherwise the name is matched with the ROR data dump index if
+// available. Names without a confident match are kept without an id when
+// the ROR index or the people list is loaded (-people matches creators by
+// name only when they have a Caltech affiliation), otherwise they are
+// dropped.
+func crosswalkWorksAuthorAffiliationToCreatorAffiliation(cfg *Config, crAffiliation *crossrefapi.Organization) *simplified.Affiliation {
 	if crAffiliation.IDs != nil {
 		for _, id := range crAffiliation.IDs {
 			if id.IdType == "ROR" && id.AssertedBy == "publisher" {
@@ -271,10 +301,19 @@ func crosswalkWorksAuthorAffiliationToCreatorAffiliation(crAffiliation *crossref
 			}
 		}
 	}
+	if (cfg.Ror != nil || cfg.People != nil) && crAffiliation.Name != "" {
+		affiliation := new(simplified.Affiliation)
+		if ror, ok := cfg.Ror.Resolve(crAffiliation.Name); ok {
+			affiliation.ID = ror
+		} else {
+			affiliation.Name = crAffiliation.Name
+		}
+		return affiliation
+	}
 	return nil
 }
 
-func crosswalkWorksPersonToCreator(author *crossrefapi.Person, role string) *simplified.Creator {
+func crosswalkWorksPersonToCreator(cfg *Config, author *crossrefapi.Person, role string) *simplified.Creator {
 	po := new(simplified.PersonOrOrg)
 	po.FamilyName = author.Family
 	po.GivenName = author.Given
@@ -300,7 +339,7 @@ func crosswalkWorksPersonToCreator(author *crossrefapi.Person, role string) *sim
 	if author.Affiliation != nil && len(author.Affiliation) > 0 {
 		for _, crAffiliation := range author.Affiliation {
 
-			affiliation := crosswalkWorksAuthorAffiliationToCreatorAffiliation(crAffiliation)
+			affiliation := crosswalkWorksAuthorAffiliationToCreatorAffiliation(cfg, crAffiliation)
 			if affiliation != nil && creator.HasAffiliation(affiliation) == false {
 				creator.Affiliations = append(creator.Affiliations, affiliation)
 			}
@@ -346,17 +385,17 @@ func crosswalkWorksDateObjectToDateType(do *crossrefapi.DateObject, description
 	return dt
 }
 
-func getWorksCreators(work *crossrefapi.Works) []*simplified.Creator {
+func getWorksCreators(cfg *Config, work *crossrefapi.Works) []*simplified.Creator {
 	creators := []*simplified.Creator{}
 	if work.Message != nil && work.Message.Author != nil {
 		for _, person := range work.Message.Author {
-			creators = append(creators, crosswalkWorksPersonToCreator(person, ""))
+			creators = append(creators, crosswalkWorksPersonToCreator(cfg, person, ""))
 		}
 	}
 	return creators
 }
 
-func getWorksContributors(work *crossrefapi.Works) []*simplified.Creator {
+func getWorksContributors(cfg *Config, work *crossrefapi.Works) []*simplified.Creator {
 	creators := []*simplified.Creator{}
 	// NOTE: The works message object containers the related contributors as
 	// separate entries.
@@ -366,17 +405,17 @@ func getWorksContributors(work *crossrefapi.Works) []*simplified.Creator {
 	// There is a reference to .contributor and .reviewer but not sure if they really exists in the scheme.
 	if work.Message != nil && work.Message.Translator != nil {
 		for _, person := range work.Message.Translator {
-			creators = append(creators, crosswalkWorksPersonToCreator(person, "translator"))
+			creators = append(creators, crosswalkWorksPersonToCreator(cfg, person, "translator"))
 		}
 	}
 	if work.Message != nil && work.Message.Editor != nil {
 		for _, person := range work.Message.Editor {
-			creators = append(creators, crosswalkWorksPersonToCreator(person, "editor"))
+			creators = append(creators, crosswalkWorksPersonToCreator(cfg, person, "editor"))
 		}
 	}
 	if work.Message != nil && work.Message.Chair != nil {
 		for _, person := range work.Message.Chair {
-			creators = append(creators, crosswalkWorksPersonToCreator(person, "chair"))
+			creators = append(creators, crosswalkWorksPersonToCreator(cfg, person, "chair"))
 		}
 	}
 	return creators
@@ -547,12 +586,12 @@ func CrosswalkCrossRefWork(cfg *Config, work *crossrefapi.Works, options *Doi2Rd
 			return nil, err
 		}
 	}
-	if values := getWorksCreators(work); values != nil && len(values) > 0 {
+	if values := getWorksCreators(cfg, work); values != nil && len(values) > 0 {
 		if err := SetCreators(rec, values); err != nil {
 			return nil, err
 		}
 	}
-	if values := getWorksContributors(work); values != nil && len(values) > 0 {
+	if values := getWorksContributors(cfg, work); values != nil && len(values) > 0 {
 		if err := SetContributors(rec, values); err != nil {
 			return nil, err
 		}
@@ -620,7 +659,7 @@ func CrosswalkCrossRefWork(cfg *Config, work *crossrefapi.Works, options *Doi2Rd
 			}
 		}
 	}
-	if values := getWorksFunding(work); values != nil && len(values) > 0 {
+	if values := getWorksFunding(cfg, work); values != nil && len(values) > 0 {
 		if err := SetFunding(rec, values); err != nil {
 			return nil, err
 		}
@@ -668,6 +707,9 @@ func CrosswalkCrossRefWork(cfg *Config, work *crossrefapi.Works, options *Doi2Rd
 		return nil, err
 	}
 
+	// NOTE: creators are matched to the Caltech people list if loaded
+	cfg.People.EnrichCreators(rec)
+
 	// NOTE: We need to set the creation and updated time.
 	now := time.Now()
 	rec.Created = now
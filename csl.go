@@ -0,0 +1,295 @@
+package irdmtools
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSL-JSON is the input format of citeproc processors and Zotero. The
+// types below follow csl-data.json version 1.0.2,
+// <https://github.com/citation-style-language/schema/blob/master/schemas/input/csl-data.json>
+
+// CSLItem is a single CSL-JSON bibliographic item.
+type CSLItem struct {
+	ID               string     `json:"id"`
+	Type             string     `json:"type"`
+	Title            string     `json:"title,omitempty"`
+	ContainerTitle   string     `json:"container-title,omitempty"`
+	CollectionTitle  string     `json:"collection-title,omitempty"`
+	CollectionNumber string     `json:"collection-number,omitempty"`
+	Author           []*CSLName `json:"author,omitempty"`
+	Editor           []*CSLName `json:"editor,omitempty"`
+	Translator       []*CSLName `json:"translator,omitempty"`
+	Contributor      []*CSLName `json:"contributor,omitempty"`
+	Issued           *CSLDate   `json:"issued,omitempty"`
+	Accessed         *CSLDate   `json:"accessed,omitempty"`
+	Volume           string     `json:"volume,omitempty"`
+	Issue            string     `json:"issue,omitempty"`
+	Page             string     `json:"page,omitempty"`
+	Edition          string     `json:"edition,omitempty"`
+	ChapterNumber    string     `json:"chapter-number,omitempty"`
+	Number           string     `json:"number,omitempty"`
+	Genre            string     `json:"genre,omitempty"`
+	Publisher        string     `json:"publisher,omitempty"`
+	PublisherPlace   string     `json:"publisher-place,omitempty"`
+	DOI              string     `json:"DOI,omitempty"`
+	ISBN             string     `json:"ISBN,omitempty"`
+	ISSN             string     `json:"ISSN,omitempty"`
+	PMCID            string     `json:"PMCID,omitempty"`
+	URL              string     `json:"URL,omitempty"`
+	Abstract         string     `json:"abstract,omitempty"`
+}
+
+// CSLName is a CSL-JSON name, either a person (family, given, ...) or
+// an organization (literal).
+type CSLName struct {
+	Family              string `json:"family,omitempty"`
+	Given               string `json:"given,omitempty"`
+	DroppingParticle    string `json:"dropping-particle,omitempty"`
+	NonDroppingParticle string `json:"non-dropping-particle,omitempty"`
+	Suffix              string `json:"suffix,omitempty"`
+	Literal             string `json:"literal,omitempty"`
+}
+
+// CSLDate is a CSL-JSON date. DateParts holds one date or, for a range,
+// two. Raw holds dates that couldn't be parsed.
+type CSLDate struct {
+	DateParts [][]int `json:"date-parts,omitempty"`
+	Raw       string  `json:"raw,omitempty"`
+}
+
+// cslTypes maps RDM and EPrints resource types to CSL item types.
+var cslTypes = map[string]string{
+	// RDM resource types
+	"publication":                       "document",
+	"publication-article":               "article-journal",
+	"publication-book":                  "book",
+	"publication-section":               "chapter",
+	"publication-conferencepaper":       "paper-conference",
+	"conference-paper":                  "paper-conference",
+	"publication-datamanagementplan":    "document",
+	"publication-deliverable":           "report",
+	"publication-issue":                 "periodical",
+	"publication-milestone":             "report",
+	"publication-oralhistory":           "interview",
+	"publication-patent":                "patent",
+	"publication-preprint":              "article",
+	"publication-proposal":              "report",
+	"publication-report":                "report",
+	"publication-softwaredocumentation": "document",
+	"publication-technicalnote":         "report",
+	"publication-thesis":                "thesis",
+	"publication-workingpaper":          "report",
+	"poster":                            "speech",
+	"presentation":                      "speech",
+	"dataset":                           "dataset",
+	"image":                             "graphic",
+	"image-figure":                      "figure",
+	"image-map":                         "map",
+	"video":                             "motion_picture",
+	"software":                          "software",
+	"lesson":                            "document",
+	"teachingresource":                  "document",
+	"labnotebook":                       "document",
+	"interactive-resource":              "webpage",
+	"other":                             "document",
+
+	// EPrints types
+	"article":             "article-journal",
+	"journal-article":     "article-journal",
+	"book":                "book",
+	"book_section":        "chapter",
+	"conference_item":     "paper-conference",
+	"experiment":          "report",
+	"journal_issue":       "periodical",
+	"lab_notes":           "document",
+	"monograph":           "report",
+	"oral_history":        "interview",
+	"patent":              "patent",
+	"teaching_resource":   "document",
+	"thesis":              "thesis",
+	"geospatial_resource": "map",
+	"website":             "webpage",
+}
+
+// CSLType returns the CSL item type for a RDM or EPrints resource type.
+// CSL types are returned as is. Image types not otherwise mapped are
+// "graphic", anything else unknown is a "document".
+func CSLType(resourceType string) string {
+	if cslType, ok := cslTypes[resourceType]; ok {
+		return cslType
+	}
+	for _, cslType := range cslTypes {
+		if cslType == resourceType {
+			return cslType
+		}
+	}
+	if strings.HasPrefix(resourceType, "image-") {
+		return "graphic"
+	}
+	return "document"
+}
+
+// cslType returns the CSL type of the citation. The crosswalks keep the
+// resource type in .type, .resource_type is checked for citations
+// created elsewhere.
+func (cite *Citation) cslType() string {
+	if cite.Type == "" {
+		return CSLType(cite.ResourceType)
+	}
+	return CSLType(cite.Type)
+}
+
+// parseCSLDate converts a date in YYYY, YYYY-MM or YYYY-MM-DD form, or a
+// range of them separated by "/", into a CSLDate. Dates that don't parse
+// are returned as raw.
+func parseCSLDate(s string) *CSLDate {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	dt := new(CSLDate)
+	for _, part := range strings.Split(s, "/") {
+		dateParts := []int{}
+		for _, val := range strings.Split(strings.TrimSpace(part), "-") {
+			i, err := strconv.Atoi(val)
+			if err != nil {
+				return &CSLDate{Raw: s}
+			}
+			dateParts = append(dateParts, i)
+		}
+		if len(dateParts) > 3 {
+			return &CSLDate{Raw: s}
+		}
+		dt.DateParts = append(dt.DateParts, dateParts)
+	}
+	if len(dt.DateParts) > 2 {
+		return &CSLDate{Raw: s}
+	}
+	return dt
+}
+
+// cslNames converts CitationAgents to CSL names.
+func cslNames(agents []*CitationAgent) []*CSLName {
+	names := []*CSLName{}
+	for _, agent := range agents {
+		if agent == nil {
+			continue
+		}
+		name := &CSLName{
+			Family:              agent.FamilyName,
+			Given:               agent.LivedName,
+			DroppingParticle:    agent.DroppingParticle,
+			NonDroppingParticle: agent.NonDroppingParticle,
+			Suffix:              agent.Suffix,
+		}
+		if name.Family == "" && name.Given == "" {
+			name.Literal = agent.Literal
+		}
+		if name.Family == "" && name.Given == "" && name.Literal == "" {
+			continue
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	return names
+}
+
+// ToCSLItem returns the citation as a CSL-JSON item. The resource type is
+// mapped to a CSL type (see CSLType) and the publication date to "issued".
+//
+// ```
+// item := citation.ToCSLItem()
+// fmt.Printf("%s %s\n", item.Type, item.Title)
+// ```
+func (cite *Citation) ToCSLItem() *CSLItem {
+	item := &CSLItem{
+		ID:               cite.ID,
+		Title:            cite.Title,
+		ContainerTitle:   cite.Publication,
+		CollectionTitle:  cite.Series,
+		CollectionNumber: cite.SeriesNumber,
+		Author:           cslNames(cite.Author),
+		Editor:           cslNames(cite.Editor),
+		Translator:       cslNames(cite.Translator),
+		Contributor:      cslNames(cite.Contributor),
+		Issued:           parseCSLDate(cite.PublicationDate),
+		Volume:           cite.Volume,
+		Issue:            cite.Issue,
+		Page:             cite.Pages,
+		Edition:          cite.Edition,
+		ChapterNumber:    cite.Chapters,
+		Number:           cite.PatentNumber,
+		Publisher:        cite.Publisher,
+		PublisherPlace:   cite.PlaceOfPublication,
+		DOI:              strings.TrimPrefix(cite.DOI, "https://doi.org/"),
+		ISBN:             cite.ISBN,
+		ISSN:             cite.ISSN,
+		PMCID:            cite.PMCID,
+		URL:              cite.CiteUsingURL,
+		Abstract:         cite.Abstract,
+	}
+	item.Type = cite.cslType()
+	if item.Type == "chapter" && cite.BookTitle != "" {
+		item.ContainerTitle = cite.BookTitle
+	}
+	if item.Type == "thesis" {
+		item.Genre = cite.ThesisType
+		if item.Genre == "" {
+			item.Genre = cite.ThesisDegree
+		}
+		if item.Issued == nil && cite.ThesisYear != "" {
+			item.Issued = parseCSLDate(cite.ThesisYear)
+		}
+	}
+	if item.Issued == nil {
+		item.Issued = cslDate(cite.Date["issued"])
+	}
+	item.Accessed = cslDate(cite.Date["accessed"])
+	return item
+}
+
+// cslDate converts a CitationDate to a CSLDate, parsing Raw when there
+// are no date parts. Raw is kept when it doesn't parse.
+func cslDate(dt *CitationDate) *CSLDate {
+	if dt == nil {
+		return nil
+	}
+	if len(dt.DateParts) > 0 {
+		return &CSLDate{DateParts: dt.DateParts}
+	}
+	return parseCSLDate(dt.Raw)
+}
+
+// ToCSLJSON returns the citation as CSL-JSON source.
+func (cite *Citation) ToCSLJSON() ([]byte, error) {
+	return JSONMarshalIndent(cite.ToCSLItem(), "", "    ")
+}
+
+// WriteCSLJSON writes citations as a CSL-JSON array of items. If accessed
+// isn't zero it is used as the "accessed" date of items without one, e.g.
+// when the citations were harvested. It is only added to the CSL-JSON so
+// the stored citations don't change with each harvest.
+func WriteCSLJSON(out io.Writer, citations []*Citation, accessed time.Time) error {
+	items := make([]*CSLItem, 0, len(citations))
+	for _, cite := range citations {
+		item := cite.ToCSLItem()
+		if item.Accessed == nil && !accessed.IsZero() {
+			item.Accessed = &CSLDate{
+				DateParts: [][]int{{accessed.Year(), int(accessed.Month()), accessed.Day()}},
+			}
+		}
+		items = append(items, item)
+	}
+	src, err := JSONMarshalIndent(items, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "%s\n", src)
+	return err
+}
@@ -0,0 +1,106 @@
+package irdmtools
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCSLType(t *testing.T) {
+	for resourceType, expected := range map[string]string{
+		"publication-article": "article-journal",
+		"book_section":        "chapter",
+		"publication-thesis":  "thesis",
+		"image-photo":         "graphic",
+		"article-journal":     "article-journal",
+		"":                    "document",
+	} {
+		if got := CSLType(resourceType); got != expected {
+			t.Errorf("CSLType(%q), expected %q, got %q", resourceType, expected, got)
+		}
+	}
+}
+
+func TestParseCSLDate(t *testing.T) {
+	if dt := parseCSLDate("2018/2020-09"); !reflect.DeepEqual(dt.DateParts, [][]int{{2018}, {2020, 9}}) {
+		t.Errorf("expected a date range, got %+v", dt)
+	}
+	if dt := parseCSLDate("2023-07-04"); !reflect.DeepEqual(dt.DateParts, [][]int{{2023, 7, 4}}) {
+		t.Errorf("expected 2023-07-04, got %+v", dt)
+	}
+	if dt := parseCSLDate("Spring 1998"); dt.Raw != "Spring 1998" || dt.DateParts != nil {
+		t.Errorf("expected raw date, got %+v", dt)
+	}
+	if dt := parseCSLDate(""); dt != nil {
+		t.Errorf("expected nil for an empty date, got %+v", dt)
+	}
+}
+
+func TestCitationToCSLItem(t *testing.T) {
+	cite := &Citation{
+		ID:              "caltechauthors:abcde-12345",
+		Collection:      "caltechauthors",
+		CollectionID:    "abcde-12345",
+		CiteUsingURL:    "https://authors.library.caltech.edu/records/abcde-12345",
+		Type:            "publication-section",
+		Title:           "A chapter",
+		BookTitle:       "A book",
+		Publication:     "Not the container",
+		PublicationDate: "2021-03",
+		Pages:           "15-23",
+		DOI:             "https://doi.org/10.1000/182",
+		Author: []*CitationAgent{
+			{FamilyName: "Doe", LivedName: "Jane", NonDroppingParticle: "van", ORCID: "0000-0002-1825-0097"},
+			{Literal: "The Unseen University"},
+		},
+		Editor: []*CitationAgent{{FamilyName: "Roe", LivedName: "Richard"}},
+	}
+	item := cite.ToCSLItem()
+	if item.Type != "chapter" || item.ContainerTitle != "A book" {
+		t.Errorf("expected chapter in A book, got %q in %q", item.Type, item.ContainerTitle)
+	}
+	if item.DOI != "10.1000/182" || item.Page != "15-23" || item.URL != cite.CiteUsingURL {
+		t.Errorf("unexpected DOI, page or URL, %+v", item)
+	}
+	if !reflect.DeepEqual(item.Issued.DateParts, [][]int{{2021, 3}}) {
+		t.Errorf("unexpected issued, %+v", item.Issued)
+	}
+	if item.Accessed != nil {
+		t.Errorf("did not expect accessed without a harvest time, %+v", item.Accessed)
+	}
+	if len(item.Author) != 2 || item.Author[0].NonDroppingParticle != "van" || item.Author[1].Literal != "The Unseen University" {
+		t.Errorf("unexpected authors, %+v", item.Author)
+	}
+	if len(item.Editor) != 1 || item.Editor[0].Family != "Roe" {
+		t.Errorf("unexpected editors, %+v", item.Editor)
+	}
+	src, err := cite.ToCSLJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{`"container-title": "A book"`, `"date-parts": [`, `"DOI": "10.1000/182"`} {
+		if !strings.Contains(string(src), expected) {
+			t.Errorf("expected %s in %s", expected, src)
+		}
+	}
+	if strings.Contains(string(src), "orcid") {
+		t.Errorf("did not expect non-CSL fields in %s", src)
+	}
+	// The harvest time is only added to the CSL-JSON
+	out := new(bytes.Buffer)
+	if err := WriteCSLJSON(out, []*Citation{cite}, time.Date(2024, 3, 6, 12, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	items := []*CSLItem{}
+	if err := JSONUnmarshal(out.Bytes(), &items); err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Accessed == nil || !reflect.DeepEqual(items[0].Accessed.DateParts, [][]int{{2024, 3, 6}}) {
+		t.Errorf("unexpected accessed, %s", out.Bytes())
+	}
+	if _, ok := cite.Date["accessed"]; ok {
+		t.Errorf("did not expect accessed stored in the citation, %+v", cite.Date)
+	}
+}
@@ -0,0 +1,1551 @@
+package irdmtools
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CSL styles describe how a citation is rendered, see
+// <https://docs.citationstyles.org/en/stable/specification.html>. The
+// renderer below implements the parts of CSL 1.0.2 needed for a single
+// bibliography entry, e.g. APA, Chicago (author-date) and IEEE. It doesn't
+// sort, disambiguate or collapse cites.
+
+// cslNode is an element of a CSL style or locale file.
+type cslNode struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Nodes   []*cslNode `xml:",any"`
+	Text    string     `xml:",chardata"`
+}
+
+// attr returns the value of an attribute, "" if not set.
+func (node *cslNode) attr(name string) string {
+	for _, attr := range node.Attrs {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// child returns the first child element with name.
+func (node *cslNode) child(name string) *cslNode {
+	for _, child := range node.Nodes {
+		if child.XMLName.Local == name {
+			return child
+		}
+	}
+	return nil
+}
+
+// children returns the child elements with name.
+func (node *cslNode) children(name string) []*cslNode {
+	nodes := []*cslNode{}
+	for _, child := range node.Nodes {
+		if child.XMLName.Local == name {
+			nodes = append(nodes, child)
+		}
+	}
+	return nodes
+}
+
+// cslTerm is a localized term with its single and plural forms.
+type cslTerm struct {
+	Single   string
+	Multiple string
+}
+
+// CSLStyle is an independent CSL style loaded from a ".csl" file.
+type CSLStyle struct {
+	// Name is the style's file name without the ".csl" extension, e.g. "apa"
+	Name string
+
+	// Title is the style's title, e.g. "American Psychological Association 7th edition"
+	Title string
+
+	root         *cslNode
+	macros       map[string]*cslNode
+	citation     *cslNode
+	bibliography *cslNode
+	terms        map[string]*cslTerm
+	dates        map[string]*cslNode
+
+	// punctuationInQuote moves commas and periods inside closing quotes,
+	// as in American English
+	punctuationInQuote bool
+}
+
+// cslEnglishTerms are the en-US terms, see locales-en-US.xml. Keys are the
+// term name with "/form" for forms other than long.
+var cslEnglishTerms = map[string]*cslTerm{
+	"accessed":      {"accessed", ""},
+	"and":           {"and", ""},
+	"and/symbol":    {"&", ""},
+	"and others":    {"and others", ""},
+	"anonymous":     {"anonymous", ""},
+	"at":            {"at", ""},
+	"available at":  {"available at", ""},
+	"by":            {"by", ""},
+	"circa":         {"circa", ""},
+	"circa/short":   {"c.", ""},
+	"cited":         {"cited", ""},
+	"et-al":         {"et al.", ""},
+	"forthcoming":   {"forthcoming", ""},
+	"from":          {"from", ""},
+	"ibid":          {"ibid.", ""},
+	"in":            {"in", ""},
+	"in press":      {"in press", ""},
+	"internet":      {"internet", ""},
+	"no date":       {"no date", ""},
+	"no date/short": {"n.d.", ""},
+	"online":        {"online", ""},
+	"presented at":  {"presented at the", ""},
+	"retrieved":     {"retrieved", ""},
+	"version":       {"version", ""},
+
+	"chapter":         {"chapter", "chapters"},
+	"chapter/short":   {"chap.", "chaps."},
+	"edition":         {"edition", "editions"},
+	"edition/short":   {"ed.", "eds."},
+	"issue":           {"issue", "issues"},
+	"issue/short":     {"no.", "nos."},
+	"number":          {"number", "numbers"},
+	"number/short":    {"no.", "nos."},
+	"page":            {"page", "pages"},
+	"page/short":      {"p.", "pp."},
+	"volume":          {"volume", "volumes"},
+	"volume/short":    {"vol.", "vols."},
+	"reference":       {"reference", "references"},
+	"reference/short": {"ref.", "refs."},
+
+	"director":                {"director", "directors"},
+	"director/short":          {"dir.", "dirs."},
+	"director/verb":           {"directed by", ""},
+	"director/verb-short":     {"dir. by", ""},
+	"editor":                  {"editor", "editors"},
+	"editor/short":            {"ed.", "eds."},
+	"editor/verb":             {"edited by", ""},
+	"editor/verb-short":       {"ed. by", ""},
+	"editortranslator":        {"editor & translator", "editors & translators"},
+	"editortranslator/short":  {"ed. & tran.", "eds. & trans."},
+	"editortranslator/verb":   {"edited & translated by", ""},
+	"collection-editor":       {"editor", "editors"},
+	"collection-editor/short": {"ed.", "eds."},
+	"collection-editor/verb":  {"edited by", ""},
+	"container-author/verb":   {"by", ""},
+	"illustrator":             {"illustrator", "illustrators"},
+	"illustrator/short":       {"ill.", "ills."},
+	"illustrator/verb":        {"illustrated by", ""},
+	"interviewer":             {"interviewer", "interviewers"},
+	"interviewer/verb":        {"interview by", ""},
+	"translator":              {"translator", "translators"},
+	"translator/short":        {"tran.", "trans."},
+	"translator/verb":         {"translated by", ""},
+	"translator/verb-short":   {"trans. by", ""},
+
+	"month-01": {"January", ""}, "month-01/short": {"Jan.", ""},
+	"month-02": {"February", ""}, "month-02/short": {"Feb.", ""},
+	"month-03": {"March", ""}, "month-03/short": {"Mar.", ""},
+	"month-04": {"April", ""}, "month-04/short": {"Apr.", ""},
+	"month-05": {"May", ""}, "month-05/short": {"May", ""},
+	"month-06": {"June", ""}, "month-06/short": {"Jun.", ""},
+	"month-07": {"July", ""}, "month-07/short": {"Jul.", ""},
+	"month-08": {"August", ""}, "month-08/short": {"Aug.", ""},
+	"month-09": {"September", ""}, "month-09/short": {"Sep.", ""},
+	"month-10": {"October", ""}, "month-10/short": {"Oct.", ""},
+	"month-11": {"November", ""}, "month-11/short": {"Nov.", ""},
+	"month-12": {"December", ""}, "month-12/short": {"Dec.", ""},
+	"season-01": {"Spring", ""},
+	"season-02": {"Summer", ""},
+	"season-03": {"Autumn", ""},
+	"season-04": {"Winter", ""},
+
+	"ordinal":    {"th", ""},
+	"ordinal-01": {"st", ""},
+	"ordinal-02": {"nd", ""},
+	"ordinal-03": {"rd", ""},
+	"ordinal-11": {"th", ""},
+	"ordinal-12": {"th", ""},
+	"ordinal-13": {"th", ""},
+
+	"long-ordinal-01": {"first", ""},
+	"long-ordinal-02": {"second", ""},
+	"long-ordinal-03": {"third", ""},
+	"long-ordinal-04": {"fourth", ""},
+	"long-ordinal-05": {"fifth", ""},
+	"long-ordinal-06": {"sixth", ""},
+	"long-ordinal-07": {"seventh", ""},
+	"long-ordinal-08": {"eighth", ""},
+	"long-ordinal-09": {"ninth", ""},
+	"long-ordinal-10": {"tenth", ""},
+
+	"open-quote":        {"“", ""},
+	"close-quote":       {"”", ""},
+	"open-inner-quote":  {"‘", ""},
+	"close-inner-quote": {"’", ""},
+}
+
+// cslEnglishDates are the en-US localized date formats.
+const cslEnglishDates = `<locale>
+<date form="text"><date-part name="month" suffix=" "/><date-part name="day" suffix=", "/><date-part name="year"/></date>
+<date form="numeric" delimiter="/"><date-part name="month" form="numeric-leading-zeros"/><date-part name="day" form="numeric-leading-zeros"/><date-part name="year"/></date>
+</locale>`
+
+// addLocale adds the terms and date formats from a locale element.
+func (style *CSLStyle) addLocale(locale *cslNode) {
+	if terms := locale.child("terms"); terms != nil {
+		for _, node := range terms.children("term") {
+			key := node.attr("name")
+			if form := node.attr("form"); form != "" && form != "long" {
+				key += "/" + form
+			}
+			term := &cslTerm{Single: node.Text}
+			if single := node.child("single"); single != nil {
+				term.Single = single.Text
+			}
+			if multiple := node.child("multiple"); multiple != nil {
+				term.Multiple = multiple.Text
+			}
+			style.terms[key] = term
+		}
+	}
+	for _, node := range locale.children("date") {
+		style.dates[node.attr("form")] = node
+	}
+	if options := locale.child("style-options"); options != nil && options.attr("punctuation-in-quote") != "" {
+		style.punctuationInQuote = options.attr("punctuation-in-quote") == "true"
+	}
+}
+
+// ParseCSLStyle parses the source of a CSL style. Dependent styles,
+// styles that only point at a parent style, aren't supported.
+func ParseCSLStyle(src []byte) (*CSLStyle, error) {
+	root := new(cslNode)
+	if err := xml.Unmarshal(src, root); err != nil {
+		return nil, err
+	}
+	if root.XMLName.Local != "style" {
+		return nil, fmt.Errorf("not a CSL style, expected <style> found <%s>", root.XMLName.Local)
+	}
+	style := &CSLStyle{
+		root:   root,
+		macros: map[string]*cslNode{},
+		terms:  map[string]*cslTerm{},
+		dates:  map[string]*cslNode{},
+	}
+	for key, term := range cslEnglishTerms {
+		style.terms[key] = term
+	}
+	dates := new(cslNode)
+	if err := xml.Unmarshal([]byte(cslEnglishDates), dates); err != nil {
+		return nil, err
+	}
+	style.addLocale(dates)
+	style.punctuationInQuote = root.attr("default-locale") == "" || root.attr("default-locale") == "en-US"
+	if info := root.child("info"); info != nil {
+		if title := info.child("title"); title != nil {
+			style.Title = strings.TrimSpace(title.Text)
+		}
+		for _, link := range info.children("link") {
+			if link.attr("rel") == "independent-parent" {
+				return nil, fmt.Errorf("%q is a dependent style, use its parent %s", style.Title, link.attr("href"))
+			}
+		}
+	}
+	// NOTE: locales without a language apply to every language, they are
+	// added first so a language specific locale wins.
+	lang := strings.SplitN(root.attr("default-locale"), "-", 2)[0]
+	if lang == "" {
+		lang = "en"
+	}
+	for _, locale := range root.children("locale") {
+		if locale.attr("lang") == "" {
+			style.addLocale(locale)
+		}
+	}
+	for _, locale := range root.children("locale") {
+		if l := locale.attr("lang"); l != "" && strings.SplitN(l, "-", 2)[0] == lang {
+			style.addLocale(locale)
+		}
+	}
+	for _, macro := range root.children("macro") {
+		style.macros[macro.attr("name")] = macro
+	}
+	style.citation = root.child("citation")
+	style.bibliography = root.child("bibliography")
+	if style.bibliography == nil && style.citation == nil {
+		return nil, fmt.Errorf("%q has no bibliography or citation layout", style.Title)
+	}
+	return style, nil
+}
+
+// LoadCSLStyle reads a CSL style from a file. The style's name is the file
+// name without the extension, e.g. "apa" for "styles/apa.csl".
+//
+// ```
+// style, err := LoadCSLStyle("styles/apa.csl")
+// if err != nil {
+//    // ... handle error ...
+// }
+// src, err := style.Format(citation, "html")
+// ```
+func LoadCSLStyle(fName string) (*CSLStyle, error) {
+	src, err := os.ReadFile(fName)
+	if err != nil {
+		return nil, err
+	}
+	style, err := ParseCSLStyle(src)
+	if err != nil {
+		return nil, fmt.Errorf("%s, %s", fName, err)
+	}
+	style.Name = strings.TrimSuffix(path.Base(fName), path.Ext(fName))
+	return style, nil
+}
+
+// LoadLocale adds the terms and date formats from a CSL locale file
+// (e.g. "locales-de-DE.xml") to the style. The style's own locale
+// elements still take precedence.
+func (style *CSLStyle) LoadLocale(fName string) error {
+	src, err := os.ReadFile(fName)
+	if err != nil {
+		return err
+	}
+	locale := new(cslNode)
+	if err := xml.Unmarshal(src, locale); err != nil {
+		return fmt.Errorf("%s, %s", fName, err)
+	}
+	style.addLocale(locale)
+	for _, node := range style.root.children("locale") {
+		style.addLocale(node)
+	}
+	return nil
+}
+
+// term returns a term's text in the requested form falling back on the
+// long form.
+func (style *CSLStyle) term(name string, form string, plural bool) string {
+	forms := []string{form}
+	switch form {
+	case "verb-short":
+		forms = append(forms, "verb")
+	case "symbol":
+		forms = append(forms, "short")
+	}
+	forms = append(forms, "long")
+	for _, form := range forms {
+		key := name
+		if form != "" && form != "long" {
+			key += "/" + form
+		}
+		if term, ok := style.terms[key]; ok {
+			if plural && term.Multiple != "" {
+				return term.Multiple
+			}
+			return term.Single
+		}
+	}
+	return ""
+}
+
+// cslOut is the rendered output before it is written as HTML, Markdown
+// or text. Leaves hold text, branches hold parts with formatting.
+type cslOut struct {
+	text   string
+	parts  []*cslOut
+	affix  bool // prefix, suffix or delimiter
+	nocase bool // not changed by text-case
+	format map[string]string
+}
+
+// cslFormatting are the formatting attributes of CSL rendering elements.
+var cslFormatting = []string{"font-style", "font-variant", "font-weight", "text-decoration", "vertical-align", "display"}
+
+// plain returns the output's text without formatting.
+func (o *cslOut) plain() string {
+	if o == nil {
+		return ""
+	}
+	if o.parts == nil {
+		return o.text
+	}
+	var sb strings.Builder
+	for _, part := range o.parts {
+		sb.WriteString(part.plain())
+	}
+	return sb.String()
+}
+
+// leaves calls fn for each leaf that isn't an affix.
+func (o *cslOut) leaves(fn func(leaf *cslOut)) {
+	if o == nil {
+		return
+	}
+	if o.parts == nil {
+		if !o.affix {
+			fn(o)
+		}
+		return
+	}
+	for _, part := range o.parts {
+		part.leaves(fn)
+	}
+}
+
+// cslJoin joins the outputs with delimiter, nil outputs are skipped.
+func cslJoin(outs []*cslOut, delimiter string) *cslOut {
+	parts := []*cslOut{}
+	for _, o := range outs {
+		if o == nil || o.plain() == "" {
+			continue
+		}
+		if len(parts) > 0 && delimiter != "" {
+			parts = append(parts, &cslOut{text: delimiter, affix: true})
+		}
+		parts = append(parts, o)
+	}
+	if len(parts) == 0 {
+		return nil
+	}
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return &cslOut{parts: parts}
+}
+
+// cslMarkup matches the rich text markup allowed in CSL-JSON values.
+var cslMarkup = regexp.MustCompile(`(?i)<(/?)(i|b|sup|sub|span)(\s[^>]*)?>`)
+
+// cslText converts a variable's value to output. The CSL-JSON rich text
+// markup <i>, <b>, <sup>, <sub>, <span style="font-variant:small-caps;">
+// and <span class="nocase"> is kept.
+func cslText(s string) *cslOut {
+	root := &cslOut{parts: []*cslOut{}}
+	stack := []*cslOut{root}
+	pos := 0
+	for _, loc := range cslMarkup.FindAllStringSubmatchIndex(s, -1) {
+		top := stack[len(stack)-1]
+		if loc[0] > pos {
+			top.parts = append(top.parts, &cslOut{text: s[pos:loc[0]], nocase: top.nocase})
+		}
+		pos = loc[1]
+		closing, tag, attrs := s[loc[2]:loc[3]] == "/", strings.ToLower(s[loc[4]:loc[5]]), ""
+		if loc[6] >= 0 {
+			attrs = s[loc[6]:loc[7]]
+		}
+		if closing {
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+			continue
+		}
+		o := &cslOut{parts: []*cslOut{}, format: map[string]string{}, nocase: top.nocase}
+		switch {
+		case tag == "i":
+			o.format["font-style"] = "italic"
+		case tag == "b":
+			o.format["font-weight"] = "bold"
+		case tag == "sup":
+			o.format["vertical-align"] = "sup"
+		case tag == "sub":
+			o.format["vertical-align"] = "sub"
+		case strings.Contains(attrs, "nocase"):
+			o.nocase = true
+		case strings.Contains(attrs, "small-caps"):
+			o.format["font-variant"] = "small-caps"
+		}
+		top.parts = append(top.parts, o)
+		stack = append(stack, o)
+	}
+	if pos < len(s) {
+		top := stack[len(stack)-1]
+		top.parts = append(top.parts, &cslOut{text: s[pos:], nocase: top.nocase})
+	}
+	if len(root.parts) == 1 && root.parts[0].parts == nil {
+		return root.parts[0]
+	}
+	return root
+}
+
+// cslStopWords aren't capitalized by the "title" text-case.
+var cslStopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "as": true, "at": true, "but": true,
+	"by": true, "down": true, "for": true, "from": true, "in": true, "into": true,
+	"nor": true, "of": true, "on": true, "onto": true, "or": true, "over": true,
+	"so": true, "the": true, "till": true, "to": true, "up": true, "via": true,
+	"with": true, "yet": true,
+}
+
+// capitalize uppercases the first letter of a word.
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if r == utf8.RuneError {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
+// textCase applies a CSL text-case to s.
+func textCase(s string, tc string, first bool) string {
+	switch tc {
+	case "lowercase":
+		return strings.ToLower(s)
+	case "uppercase":
+		return strings.ToUpper(s)
+	case "capitalize-first", "sentence":
+		if tc == "sentence" && strings.ToUpper(s) == s {
+			s = strings.ToLower(s)
+		}
+		if first {
+			i := strings.IndexFunc(s, unicode.IsLetter)
+			if i >= 0 {
+				return s[:i] + capitalize(s[i:])
+			}
+		}
+		return s
+	case "capitalize-all", "title":
+		words := strings.Split(s, " ")
+		for i, word := range words {
+			// NOTE: words with capitals after the first letter, e.g.
+			// "iPhone" or "DNA", are kept as is.
+			if word == "" || strings.ToLower(word[1:]) != word[1:] {
+				continue
+			}
+			if tc == "title" && cslStopWords[strings.ToLower(word)] && !(first && i == 0) && i != len(words)-1 {
+				continue
+			}
+			words[i] = capitalize(word)
+		}
+		return strings.Join(words, " ")
+	}
+	return s
+}
+
+// cslContext holds the state for rendering one item.
+type cslContext struct {
+	style      *CSLStyle
+	item       *CSLItem
+	section    *cslNode
+	suppressed map[string]bool
+	called     int
+	rendered   int
+	depth      int
+
+	// substituted collects the variables rendered by a substitute element
+	substituted []string
+	substitute  int
+}
+
+// cslNameVariables are the CSL name variables the items carry.
+func (ctx *cslContext) names(variable string) []*CSLName {
+	switch variable {
+	case "author":
+		return ctx.item.Author
+	case "editor":
+		return ctx.item.Editor
+	case "translator":
+		return ctx.item.Translator
+	case "contributor":
+		return ctx.item.Contributor
+	}
+	return nil
+}
+
+// date returns a CSL date variable.
+func (ctx *cslContext) date(variable string) *CSLDate {
+	switch variable {
+	case "issued":
+		return ctx.item.Issued
+	case "accessed":
+		return ctx.item.Accessed
+	}
+	return nil
+}
+
+// variable returns a CSL standard or number variable's value.
+func (ctx *cslContext) variable(variable string) string {
+	if ctx.suppressed[variable] {
+		return ""
+	}
+	item := ctx.item
+	switch variable {
+	case "title", "title-short":
+		return item.Title
+	case "container-title", "container-title-short":
+		return item.ContainerTitle
+	case "collection-title":
+		return item.CollectionTitle
+	case "collection-number":
+		return item.CollectionNumber
+	case "volume":
+		return item.Volume
+	case "issue":
+		return item.Issue
+	case "page":
+		return item.Page
+	case "page-first":
+		return strings.TrimSpace(cslPageSeparator.Split(item.Page, 2)[0])
+	case "edition":
+		return item.Edition
+	case "chapter-number":
+		return item.ChapterNumber
+	case "number":
+		return item.Number
+	case "genre":
+		return item.Genre
+	case "publisher":
+		return item.Publisher
+	case "publisher-place":
+		return item.PublisherPlace
+	case "DOI":
+		return item.DOI
+	case "ISBN":
+		return item.ISBN
+	case "ISSN":
+		return item.ISSN
+	case "PMCID":
+		return item.PMCID
+	case "URL":
+		return item.URL
+	case "abstract":
+		return item.Abstract
+	case "citation-number":
+		return "1"
+	}
+	return ""
+}
+
+// hasVariable returns true if a variable of any kind has a value.
+func (ctx *cslContext) hasVariable(variable string) bool {
+	if ctx.suppressed[variable] {
+		return false
+	}
+	if variable == "page-first" {
+		return ctx.item.Page != ""
+	}
+	return ctx.variable(variable) != "" || len(ctx.names(variable)) > 0 || ctx.date(variable) != nil
+}
+
+// use records a variable being called by a rendering element, for group
+// suppression and substitution.
+func (ctx *cslContext) use(variable string, rendered bool) {
+	ctx.called++
+	if rendered {
+		ctx.rendered++
+		if ctx.substitute > 0 {
+			ctx.substituted = append(ctx.substituted, variable)
+		}
+	}
+}
+
+// inherited returns a name option from the element, the section
+// (citation or bibliography) or the style.
+func (ctx *cslContext) inherited(node *cslNode, name string, inheritedName string) string {
+	if node != nil {
+		if val := node.attr(name); val != "" {
+			return val
+		}
+	}
+	for _, node := range []*cslNode{ctx.section, ctx.style.root} {
+		if node != nil {
+			if val := node.attr(inheritedName); val != "" {
+				return val
+			}
+		}
+	}
+	return ""
+}
+
+// decorate applies an element's formatting, quotes, text-case and
+// affixes to its output.
+func (ctx *cslContext) decorate(node *cslNode, o *cslOut) *cslOut {
+	if o == nil || o.plain() == "" {
+		return nil
+	}
+	if tc := node.attr("text-case"); tc != "" {
+		first := true
+		o.leaves(func(leaf *cslOut) {
+			if !leaf.nocase {
+				leaf.text = textCase(leaf.text, tc, first)
+			}
+			if strings.TrimSpace(leaf.text) != "" {
+				first = false
+			}
+		})
+	}
+	if node.attr("strip-periods") == "true" {
+		o.leaves(func(leaf *cslOut) {
+			leaf.text = strings.ReplaceAll(leaf.text, ".", "")
+		})
+	}
+	if node.attr("quotes") == "true" {
+		o = &cslOut{parts: []*cslOut{
+			{text: ctx.style.term("open-quote", "", false)},
+			o,
+			{text: ctx.style.term("close-quote", "", false)},
+		}}
+	}
+	format := map[string]string{}
+	for _, name := range cslFormatting {
+		if val := node.attr(name); val != "" && name != "display" {
+			format[name] = val
+		}
+	}
+	if len(format) > 0 {
+		o = &cslOut{parts: []*cslOut{o}, format: format}
+	}
+	prefix, suffix := node.attr("prefix"), node.attr("suffix")
+	if prefix != "" || suffix != "" {
+		parts := []*cslOut{}
+		if prefix != "" {
+			parts = append(parts, &cslOut{text: prefix, affix: true})
+		}
+		parts = append(parts, o)
+		if suffix != "" {
+			parts = append(parts, &cslOut{text: suffix, affix: true})
+		}
+		o = &cslOut{parts: parts}
+	}
+	if display := node.attr("display"); display != "" {
+		o = &cslOut{parts: []*cslOut{o}, format: map[string]string{"display": display}}
+	}
+	return o
+}
+
+// renderChildren renders the child elements of node.
+func (ctx *cslContext) renderChildren(node *cslNode) []*cslOut {
+	outs := []*cslOut{}
+	for _, child := range node.Nodes {
+		if o := ctx.render(child); o != nil {
+			outs = append(outs, o)
+		}
+	}
+	return outs
+}
+
+// render renders a CSL rendering element.
+func (ctx *cslContext) render(node *cslNode) *cslOut {
+	switch node.XMLName.Local {
+	case "text":
+		return ctx.decorate(node, ctx.renderText(node))
+	case "number":
+		return ctx.decorate(node, ctx.renderNumber(node))
+	case "label":
+		val := ctx.variable(node.attr("variable"))
+		if val == "" {
+			return nil
+		}
+		return ctx.decorate(node, ctx.renderLabel(node, node.attr("variable"), cslPlural.MatchString(val)))
+	case "date":
+		return ctx.renderDate(node)
+	case "names":
+		return ctx.decorate(node, ctx.renderNames(node))
+	case "group":
+		called, rendered := ctx.called, ctx.rendered
+		o := cslJoin(ctx.renderChildren(node), node.attr("delimiter"))
+		// NOTE: a group calling variables that are all empty is suppressed
+		if ctx.called > called && ctx.rendered == rendered {
+			return nil
+		}
+		return ctx.decorate(node, o)
+	case "choose":
+		return ctx.renderChoose(node)
+	}
+	return nil
+}
+
+// renderText renders a text element's variable, macro, term or value.
+func (ctx *cslContext) renderText(node *cslNode) *cslOut {
+	switch {
+	case node.attr("variable") != "":
+		variable := node.attr("variable")
+		val := ""
+		if node.attr("form") == "short" {
+			val = ctx.variable(variable + "-short")
+		}
+		if val == "" {
+			val = ctx.variable(variable)
+		}
+		ctx.use(variable, val != "")
+		if val == "" {
+			return nil
+		}
+		if variable == "page" {
+			val = cslPageRange(val)
+		}
+		if variable == "URL" || variable == "DOI" {
+			return &cslOut{text: val, nocase: true}
+		}
+		return cslText(val)
+	case node.attr("macro") != "":
+		macro, ok := ctx.style.macros[node.attr("macro")]
+		if !ok || ctx.depth > 20 {
+			return nil
+		}
+		ctx.depth++
+		defer func() { ctx.depth-- }()
+		return cslJoin(ctx.renderChildren(macro), "")
+	case node.attr("term") != "":
+		val := ctx.style.term(node.attr("term"), node.attr("form"), node.attr("plural") == "true")
+		if val == "" {
+			return nil
+		}
+		return &cslOut{text: val}
+	case node.attr("value") != "":
+		return &cslOut{text: node.attr("value")}
+	}
+	return nil
+}
+
+// cslPageSeparator matches the separator of a page range or list.
+var cslPageSeparator = regexp.MustCompile(`\s*(-+|–|,)\s*`)
+
+// cslHyphens matches hyphens between pages.
+var cslHyphens = regexp.MustCompile(`\s*-+\s*`)
+
+// cslPageRange uses an en dash between the pages of a range.
+func cslPageRange(s string) string {
+	return cslHyphens.ReplaceAllString(s, "–")
+}
+
+// cslNumeric matches numeric values, e.g. "2", "2nd", "12-14", "3, 5 & 7"
+var cslNumeric = regexp.MustCompile(`^\s*[A-Za-z]?\d+[A-Za-z]*(\s*[-–,&]\s*[A-Za-z]?\d+[A-Za-z]*)*\s*$`)
+
+// cslPlural matches numeric values that are ranges or lists.
+var cslPlural = regexp.MustCompile(`\d\s*[-–,&]\s*\S*\d`)
+
+// ordinal returns n with its ordinal suffix, e.g. "2nd".
+func (style *CSLStyle) ordinal(n int) string {
+	suffix := style.term("ordinal", "", false)
+	if term, ok := style.terms[fmt.Sprintf("ordinal-%02d", n%100)]; ok {
+		suffix = term.Single
+	} else if term, ok := style.terms[fmt.Sprintf("ordinal-%02d", n%10)]; ok && (n%100 < 11 || n%100 > 13) {
+		suffix = term.Single
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
+
+// roman returns n as lower case roman numerals.
+func roman(n int) string {
+	if n <= 0 || n >= 4000 {
+		return strconv.Itoa(n)
+	}
+	vals := []int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+	syms := []string{"m", "cm", "d", "cd", "c", "xc", "l", "xl", "x", "ix", "v", "iv", "i"}
+	var sb strings.Builder
+	for i, val := range vals {
+		for n >= val {
+			sb.WriteString(syms[i])
+			n -= val
+		}
+	}
+	return sb.String()
+}
+
+// renderNumber renders a number element.
+func (ctx *cslContext) renderNumber(node *cslNode) *cslOut {
+	variable := node.attr("variable")
+	val := ctx.variable(variable)
+	ctx.use(variable, val != "")
+	if val == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(val))
+	if err != nil {
+		if variable == "page" {
+			val = cslPageRange(val)
+		}
+		return &cslOut{text: val}
+	}
+	switch node.attr("form") {
+	case "ordinal":
+		val = ctx.style.ordinal(n)
+	case "long-ordinal":
+		val = ctx.style.term(fmt.Sprintf("long-ordinal-%02d", n), "", false)
+		if val == "" {
+			val = ctx.style.ordinal(n)
+		}
+	case "roman":
+		val = roman(n)
+	default:
+		val = strconv.Itoa(n)
+	}
+	return &cslOut{text: val}
+}
+
+// cslLabelTerms maps number variables to the terms used by labels.
+var cslLabelTerms = map[string]string{
+	"chapter-number":    "chapter",
+	"collection-number": "number",
+}
+
+// renderLabel renders the term for a variable. plural is the contextual
+// plurality, a range of pages or more than one name.
+func (ctx *cslContext) renderLabel(node *cslNode, variable string, plural bool) *cslOut {
+	switch node.attr("plural") {
+	case "always":
+		plural = true
+	case "never":
+		plural = false
+	}
+	name := variable
+	if term, ok := cslLabelTerms[variable]; ok {
+		name = term
+	}
+	val := ctx.style.term(name, node.attr("form"), plural)
+	if val == "" {
+		return nil
+	}
+	return &cslOut{text: val}
+}
+
+// renderDate renders a localized or non-localized date element. Dates
+// without date parts render their raw value.
+func (ctx *cslContext) renderDate(node *cslNode) *cslOut {
+	variable := node.attr("variable")
+	dt := ctx.date(variable)
+	if ctx.suppressed[variable] {
+		dt = nil
+	}
+	ctx.use(variable, dt != nil)
+	if dt == nil {
+		return nil
+	}
+	if len(dt.DateParts) == 0 {
+		return ctx.decorate(node, &cslOut{text: dt.Raw})
+	}
+	format, delimiter := node, node.attr("delimiter")
+	parts := format.children("date-part")
+	if form := node.attr("form"); form != "" {
+		localized, ok := ctx.style.dates[form]
+		if !ok {
+			return nil
+		}
+		delimiter = localized.attr("delimiter")
+		show := map[string]bool{"year": true, "month": true, "day": true}
+		switch node.attr("date-parts") {
+		case "year":
+			show["month"], show["day"] = false, false
+		case "year-month":
+			show["day"] = false
+		}
+		parts = []*cslNode{}
+		for _, part := range localized.children("date-part") {
+			if !show[part.attr("name")] {
+				continue
+			}
+			// NOTE: the style can override the form and formatting of
+			// a localized date part but not its affixes.
+			merged := &cslNode{XMLName: part.XMLName, Attrs: append([]xml.Attr{}, part.Attrs...)}
+			for _, override := range node.children("date-part") {
+				if override.attr("name") != part.attr("name") {
+					continue
+				}
+				for _, attr := range override.Attrs {
+					if attr.Name.Local != "prefix" && attr.Name.Local != "suffix" {
+						merged.Attrs = append([]xml.Attr{attr}, merged.Attrs...)
+					}
+				}
+			}
+			parts = append(parts, merged)
+		}
+	}
+	ranges := []*cslOut{}
+	for _, dateParts := range dt.DateParts {
+		outs := []*cslOut{}
+		for _, part := range parts {
+			outs = append(outs, ctx.decorate(part, ctx.renderDatePart(part, dateParts)))
+		}
+		ranges = append(ranges, cslJoin(outs, delimiter))
+	}
+	o := cslJoin(ranges, "–")
+	if o == nil {
+		return nil
+	}
+	return ctx.decorate(node, o)
+}
+
+// renderDatePart renders the year, month or day of a date.
+func (ctx *cslContext) renderDatePart(part *cslNode, dateParts []int) *cslOut {
+	form := part.attr("form")
+	switch part.attr("name") {
+	case "year":
+		if len(dateParts) < 1 {
+			return nil
+		}
+		year := dateParts[0]
+		if form == "short" {
+			return &cslOut{text: fmt.Sprintf("%02d", year%100)}
+		}
+		return &cslOut{text: strconv.Itoa(year)}
+	case "month":
+		if len(dateParts) < 2 || dateParts[1] < 1 {
+			return nil
+		}
+		month := dateParts[1]
+		if month > 12 {
+			return &cslOut{text: ctx.style.term(fmt.Sprintf("season-%02d", (month-13)%4+1), "", false)}
+		}
+		switch form {
+		case "numeric":
+			return &cslOut{text: strconv.Itoa(month)}
+		case "numeric-leading-zeros":
+			return &cslOut{text: fmt.Sprintf("%02d", month)}
+		case "short":
+			return &cslOut{text: ctx.style.term(fmt.Sprintf("month-%02d", month), "short", false)}
+		}
+		return &cslOut{text: ctx.style.term(fmt.Sprintf("month-%02d", month), "", false)}
+	case "day":
+		if len(dateParts) < 3 || dateParts[2] < 1 {
+			return nil
+		}
+		day := dateParts[2]
+		switch form {
+		case "numeric-leading-zeros":
+			return &cslOut{text: fmt.Sprintf("%02d", day)}
+		case "ordinal":
+			return &cslOut{text: ctx.style.ordinal(day)}
+		}
+		return &cslOut{text: strconv.Itoa(day)}
+	}
+	return nil
+}
+
+// initials reduces given names to initials, e.g. "Jean-Paul Marie" to
+// "J.-P. M." with initialize-with ". ".
+func initials(given string, with string, hyphen bool) string {
+	var sb strings.Builder
+	for _, word := range strings.Fields(given) {
+		r, _ := utf8.DecodeRuneInString(word)
+		if unicode.IsLower(r) {
+			// NOTE: particles like "de" in a given name aren't initialized
+			sb.WriteString(word + " ")
+			continue
+		}
+		subwords := []string{}
+		for _, subword := range strings.Split(word, "-") {
+			if r, _ := utf8.DecodeRuneInString(subword); r != utf8.RuneError {
+				subwords = append(subwords, string(unicode.ToUpper(r))+strings.TrimRight(with, " "))
+			}
+		}
+		if hyphen {
+			sb.WriteString(strings.Join(subwords, "-"))
+		} else {
+			sb.WriteString(strings.Join(subwords, ""))
+		}
+		sb.WriteString(with[len(strings.TrimRight(with, " ")):])
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// renderName renders a single name. Organizations use the literal
+// name. inverted puts the family name first.
+func (ctx *cslContext) renderName(node *cslNode, name *CSLName, inverted bool) *cslOut {
+	if name.Family == "" && name.Given == "" {
+		if name.Literal == "" {
+			return nil
+		}
+		return cslText(name.Literal)
+	}
+	form := ctx.inherited(node, "form", "name-form")
+	given := name.Given
+	if with := ctx.inherited(node, "initialize-with", "initialize-with"); with != "" && ctx.inherited(node, "initialize", "initialize") != "false" {
+		given = initials(given, with, ctx.style.root.attr("initialize-with-hyphen") != "false")
+	}
+	demote := ctx.style.root.attr("demote-non-dropping-particle")
+	family := strings.TrimSpace(name.NonDroppingParticle + " " + name.Family)
+	givenParts := []string{given, name.DroppingParticle}
+	if inverted && (demote == "" || demote == "display-and-sort") {
+		family = name.Family
+		givenParts = append(givenParts, name.NonDroppingParticle)
+	}
+	givenPart := strings.Join(strings.Fields(strings.Join(givenParts, " ")), " ")
+	if !inverted {
+		givenPart = strings.Join(strings.Fields(given+" "+name.DroppingParticle), " ")
+	}
+	familyOut, givenOut := &cslOut{text: family}, &cslOut{text: givenPart}
+	for _, namePart := range node.children("name-part") {
+		switch namePart.attr("name") {
+		case "family":
+			familyOut = ctx.decorate(namePart, familyOut)
+		case "given":
+			givenOut = ctx.decorate(namePart, givenOut)
+		}
+	}
+	if form == "short" || givenPart == "" {
+		if name.Suffix != "" && form != "short" {
+			return cslJoin([]*cslOut{familyOut, {text: name.Suffix}}, " ")
+		}
+		return familyOut
+	}
+	if inverted {
+		sortSeparator := ctx.inherited(node, "sort-separator", "sort-separator")
+		if sortSeparator == "" {
+			sortSeparator = ", "
+		}
+		outs := []*cslOut{familyOut, givenOut}
+		if name.Suffix != "" {
+			outs = append(outs, &cslOut{text: name.Suffix})
+		}
+		return cslJoin(outs, sortSeparator)
+	}
+	outs := []*cslOut{givenOut, familyOut}
+	o := cslJoin(outs, " ")
+	if name.Suffix != "" {
+		o = cslJoin([]*cslOut{o, {text: name.Suffix}}, " ")
+	}
+	return o
+}
+
+// renderNameList renders the names of one variable following the name
+// element's et-al, "and" and delimiter options.
+func (ctx *cslContext) renderNameList(node *cslNode, etAlNode *cslNode, names []*CSLName) *cslOut {
+	if node == nil {
+		node = &cslNode{XMLName: xml.Name{Local: "name"}}
+	}
+	n := len(names)
+	etAlMin, _ := strconv.Atoi(ctx.inherited(node, "et-al-min", "et-al-min"))
+	useFirst, _ := strconv.Atoi(ctx.inherited(node, "et-al-use-first", "et-al-use-first"))
+	shown, etAl := names, false
+	if etAlMin > 0 && useFirst > 0 && n >= etAlMin && useFirst < n {
+		shown, etAl = names[:useFirst], true
+	}
+	if ctx.inherited(node, "form", "name-form") == "count" {
+		return &cslOut{text: strconv.Itoa(len(shown))}
+	}
+	delimiter := ctx.inherited(node, "delimiter", "name-delimiter")
+	if delimiter == "" {
+		delimiter = ", "
+	}
+	sortOrder := ctx.inherited(node, "name-as-sort-order", "name-as-sort-order")
+	outs := []*cslOut{}
+	inverted := []bool{}
+	for i, name := range shown {
+		inv := sortOrder == "all" || (sortOrder == "first" && i == 0)
+		if o := ctx.renderName(node, name, inv); o != nil {
+			outs = append(outs, o)
+			inverted = append(inverted, inv && (name.Family != "" || name.Given != ""))
+		}
+	}
+	if len(outs) == 0 {
+		return nil
+	}
+	// precedes decides if the delimiter goes before the last name or et al.
+	precedes := func(option string) bool {
+		switch option {
+		case "always":
+			return true
+		case "never":
+			return false
+		case "after-inverted-name":
+			return inverted[len(inverted)-1]
+		}
+		return len(outs) > 2
+	}
+	and := ""
+	switch ctx.inherited(node, "and", "and") {
+	case "text":
+		and = ctx.style.term("and", "", false)
+	case "symbol":
+		and = ctx.style.term("and", "symbol", false)
+	}
+	parts := []*cslOut{}
+	for i, o := range outs {
+		if i > 0 {
+			last := i == len(outs)-1 && !etAl
+			switch {
+			case last && and != "":
+				if precedes(ctx.inherited(node, "delimiter-precedes-last", "delimiter-precedes-last")) {
+					parts = append(parts, &cslOut{text: delimiter, affix: true})
+				} else {
+					parts = append(parts, &cslOut{text: " ", affix: true})
+				}
+				parts = append(parts, &cslOut{text: and + " "})
+			default:
+				parts = append(parts, &cslOut{text: delimiter, affix: true})
+			}
+		}
+		parts = append(parts, o)
+	}
+	if etAl {
+		if ctx.inherited(node, "et-al-use-last", "et-al-use-last") == "true" && n >= len(shown)+2 {
+			last := ctx.renderName(node, names[n-1], sortOrder == "all")
+			parts = append(parts, &cslOut{text: delimiter + "… ", affix: true}, last)
+		} else {
+			term := "et-al"
+			if etAlNode != nil && etAlNode.attr("term") != "" {
+				term = etAlNode.attr("term")
+			}
+			option := ctx.inherited(node, "delimiter-precedes-et-al", "delimiter-precedes-et-al")
+			if option == "" || option == "contextual" {
+				option = "contextual"
+				if len(outs) > 1 {
+					option = "always"
+				}
+			}
+			if precedes(option) {
+				parts = append(parts, &cslOut{text: delimiter, affix: true})
+			} else {
+				parts = append(parts, &cslOut{text: " ", affix: true})
+			}
+			o := &cslOut{text: ctx.style.term(term, "", false)}
+			if etAlNode != nil {
+				o = ctx.decorate(etAlNode, o)
+			}
+			parts = append(parts, o)
+		}
+	}
+	return ctx.decorate(node, &cslOut{parts: parts})
+}
+
+// renderNames renders a names element, using its substitute when the
+// name variables are empty.
+func (ctx *cslContext) renderNames(node *cslNode) *cslOut {
+	nameNode, etAlNode, labelNode := node.child("name"), node.child("et-al"), node.child("label")
+	labelFirst := false
+	for _, child := range node.Nodes {
+		if child.XMLName.Local == "name" {
+			break
+		}
+		if child.XMLName.Local == "label" {
+			labelFirst = true
+			break
+		}
+	}
+	outs := []*cslOut{}
+	variables := strings.Fields(node.attr("variable"))
+	for _, variable := range variables {
+		names := ctx.names(variable)
+		if ctx.suppressed[variable] {
+			names = nil
+		}
+		ctx.use(variable, len(names) > 0)
+		if len(names) == 0 {
+			continue
+		}
+		o := ctx.renderNameList(nameNode, etAlNode, names)
+		if o == nil {
+			continue
+		}
+		if labelNode != nil && ctx.inherited(nameNode, "form", "name-form") != "count" {
+			label := ctx.decorate(labelNode, ctx.renderLabel(labelNode, variable, len(names) > 1))
+			if labelFirst {
+				o = cslJoin([]*cslOut{label, o}, "")
+			} else {
+				o = cslJoin([]*cslOut{o, label}, "")
+			}
+		}
+		outs = append(outs, o)
+	}
+	delimiter := ctx.inherited(node, "delimiter", "names-delimiter")
+	if o := cslJoin(outs, delimiter); o != nil {
+		return o
+	}
+	substitute := node.child("substitute")
+	if substitute == nil {
+		return nil
+	}
+	for _, child := range substitute.Nodes {
+		// NOTE: a names element without children in a substitute uses
+		// the name, et-al and label elements of the parent.
+		if child.XMLName.Local == "names" && len(child.Nodes) == 0 {
+			child = &cslNode{XMLName: child.XMLName, Attrs: child.Attrs}
+			for _, parentChild := range node.Nodes {
+				if parentChild.XMLName.Local != "substitute" {
+					child.Nodes = append(child.Nodes, parentChild)
+				}
+			}
+		}
+		ctx.substitute++
+		start := len(ctx.substituted)
+		o := ctx.render(child)
+		ctx.substitute--
+		if o != nil {
+			if ctx.suppressed == nil {
+				ctx.suppressed = map[string]bool{}
+			}
+			for _, variable := range ctx.substituted[start:] {
+				ctx.suppressed[variable] = true
+			}
+			return o
+		}
+		ctx.substituted = ctx.substituted[:start]
+	}
+	return nil
+}
+
+// test evaluates one condition of an if or else-if element.
+func (ctx *cslContext) test(name string, val string) bool {
+	switch name {
+	case "type":
+		return ctx.item.Type == val
+	case "variable":
+		return ctx.hasVariable(val)
+	case "is-numeric":
+		return cslNumeric.MatchString(ctx.variable(val))
+	}
+	// NOTE: is-uncertain-date, locator, position and disambiguate
+	// don't apply to a single bibliography entry.
+	return false
+}
+
+// renderChoose renders the first matching branch of a choose element.
+func (ctx *cslContext) renderChoose(node *cslNode) *cslOut {
+	for _, branch := range node.Nodes {
+		matched := branch.XMLName.Local == "else"
+		if !matched {
+			match := branch.attr("match")
+			results := []bool{}
+			for _, attr := range branch.Attrs {
+				if attr.Name.Local == "match" {
+					continue
+				}
+				for _, val := range strings.Fields(attr.Value) {
+					results = append(results, ctx.test(attr.Name.Local, val))
+				}
+			}
+			switch match {
+			case "any":
+				for _, result := range results {
+					matched = matched || result
+				}
+			case "none":
+				matched = true
+				for _, result := range results {
+					matched = matched && !result
+				}
+			default:
+				matched = len(results) > 0
+				for _, result := range results {
+					matched = matched && result
+				}
+			}
+		}
+		if matched {
+			return cslJoin(ctx.renderChildren(branch), "")
+		}
+	}
+	return nil
+}
+
+// cslWriter writes rendered output as HTML, Markdown or text.
+type cslWriter struct {
+	sb                 strings.Builder
+	format             string
+	last               rune
+	punctuationInQuote bool
+}
+
+// markdownEscaper escapes characters that are Markdown markup.
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`, `<`, `\<`, `>`, `\>`)
+
+// tags returns the opening and closing markup for formatting.
+func (w *cslWriter) tags(name string, val string) (string, string) {
+	switch w.format {
+	case "html":
+		switch name + ":" + val {
+		case "font-style:italic", "font-style:oblique":
+			return "<i>", "</i>"
+		case "font-style:normal":
+			return `<span style="font-style:normal;">`, "</span>"
+		case "font-weight:bold":
+			return "<b>", "</b>"
+		case "font-weight:light":
+			return `<span style="font-weight:lighter;">`, "</span>"
+		case "font-variant:small-caps":
+			return `<span style="font-variant:small-caps;">`, "</span>"
+		case "text-decoration:underline":
+			return `<span style="text-decoration:underline;">`, "</span>"
+		case "vertical-align:sup":
+			return "<sup>", "</sup>"
+		case "vertical-align:sub":
+			return "<sub>", "</sub>"
+		}
+		if name == "display" {
+			return fmt.Sprintf(`<div class="csl-%s">`, val), "</div>"
+		}
+	case "markdown":
+		switch name + ":" + val {
+		case "font-style:italic", "font-style:oblique":
+			return "*", "*"
+		case "font-weight:bold":
+			return "**", "**"
+		case "vertical-align:sup":
+			return "<sup>", "</sup>"
+		case "vertical-align:sub":
+			return "<sub>", "</sub>"
+		}
+	}
+	return "", ""
+}
+
+// write writes o. Affixes starting with a period are dropped after text
+// ending in punctuation, e.g. a ". " after "Doe, J." or "Why?".
+func (w *cslWriter) write(o *cslOut) {
+	if o == nil {
+		return
+	}
+	if o.parts == nil {
+		s := o.text
+		if o.affix && strings.HasPrefix(s, ".") && strings.ContainsRune(".?!", w.last) {
+			s = s[1:]
+		}
+		if strings.HasPrefix(s, " ") && w.last == ' ' {
+			s = strings.TrimLeft(s, " ")
+		}
+		if s == "" {
+			return
+		}
+		if o.affix && w.punctuationInQuote && w.last == '”' && (s[0] == ',' || s[0] == '.') {
+			src := strings.TrimSuffix(w.sb.String(), "”")
+			w.sb.Reset()
+			w.sb.WriteString(src + s[0:1] + "”")
+			s = s[1:]
+			if s == "" {
+				return
+			}
+		}
+		switch w.format {
+		case "html":
+			w.sb.WriteString(html.EscapeString(s))
+		case "markdown":
+			w.sb.WriteString(markdownEscaper.Replace(s))
+		default:
+			w.sb.WriteString(s)
+		}
+		w.last, _ = utf8.DecodeLastRuneInString(s)
+		return
+	}
+	closing := []string{}
+	for _, name := range cslFormatting {
+		if val, ok := o.format[name]; ok {
+			open, close := w.tags(name, val)
+			w.sb.WriteString(open)
+			closing = append([]string{close}, closing...)
+		}
+	}
+	for _, part := range o.parts {
+		w.write(part)
+	}
+	for _, close := range closing {
+		w.sb.WriteString(close)
+	}
+	// NOTE: without HTML's blocks a left margin, e.g. the "[1]" of a
+	// numbered style, is separated from the entry by a space.
+	if o.format["display"] == "left-margin" && w.format != "html" {
+		w.sb.WriteString(" ")
+		w.last = ' '
+	}
+}
+
+// Format renders the citation with the style's bibliography layout, or
+// its citation layout when it has no bibliography. The format is "html",
+// "markdown" or "text".
+//
+// ```
+// src, err := style.Format(citation, "markdown")
+// if err != nil {
+//    // ... handle error ...
+// }
+// fmt.Println(src)
+// ```
+func (style *CSLStyle) Format(cite *Citation, format string) (string, error) {
+	return style.FormatItem(cite.ToCSLItem(), format)
+}
+
+// FormatItem renders a CSL item, see Format.
+func (style *CSLStyle) FormatItem(item *CSLItem, format string) (string, error) {
+	switch format {
+	case "html", "markdown", "text":
+	default:
+		return "", fmt.Errorf("%q is not a supported format, use html, markdown or text", format)
+	}
+	section := style.bibliography
+	if section == nil {
+		section = style.citation
+	}
+	layout := section.child("layout")
+	if layout == nil {
+		return "", fmt.Errorf("%q has no layout", style.Title)
+	}
+	ctx := &cslContext{style: style, item: item, section: section, suppressed: map[string]bool{}}
+	o := ctx.decorate(layout, cslJoin(ctx.renderChildren(layout), ""))
+	w := &cslWriter{format: format, punctuationInQuote: style.punctuationInQuote}
+	w.write(o)
+	s := strings.TrimSpace(w.sb.String())
+	if format == "html" && s != "" && section == style.bibliography {
+		s = `<div class="csl-entry">` + s + `</div>`
+	}
+	return s, nil
+}
+
+// CitationFormatter adds formatted citations to Citations, one for each
+// CSL style, see Citation.Formatted.
+type CitationFormatter struct {
+	Styles []*CSLStyle
+	Format string
+}
+
+// NewCitationFormatter loads a comma separated list of CSL style files
+// for rendering citations as "html", "markdown" or "text".
+//
+// ```
+// formatter, err := NewCitationFormatter("styles/apa.csl,styles/ieee.csl", "html")
+// if err != nil {
+//    // ... handle error ...
+// }
+// if err := formatter.Apply(citation); err != nil {
+//    // ... handle error ...
+// }
+// fmt.Println(citation.Formatted["apa"])
+// ```
+func NewCitationFormatter(styleFNames string, format string) (*CitationFormatter, error) {
+	if format == "" {
+		format = "html"
+	}
+	formatter := &CitationFormatter{Format: format}
+	for _, fName := range strings.Split(styleFNames, ",") {
+		if fName = strings.TrimSpace(fName); fName == "" {
+			continue
+		}
+		style, err := LoadCSLStyle(fName)
+		if err != nil {
+			return nil, err
+		}
+		formatter.Styles = append(formatter.Styles, style)
+	}
+	switch format {
+	case "html", "markdown", "text":
+	default:
+		return nil, fmt.Errorf("%q is not a supported format, use html, markdown or text", format)
+	}
+	return formatter, nil
+}
+
+// Apply renders the citation in each style setting cite.Formatted. A nil
+// formatter does nothing.
+func (formatter *CitationFormatter) Apply(cite *Citation) error {
+	if formatter == nil || len(formatter.Styles) == 0 {
+		return nil
+	}
+	item := cite.ToCSLItem()
+	for _, style := range formatter.Styles {
+		src, err := style.FormatItem(item, formatter.Format)
+		if err != nil {
+			return fmt.Errorf("%s, %s", style.Name, err)
+		}
+		if cite.Formatted == nil {
+			cite.Formatted = map[string]string{}
+		}
+		cite.Formatted[style.Name] = src
+	}
+	return nil
+}
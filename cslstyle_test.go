@@ -0,0 +1,141 @@
+package irdmtools
+
+import (
+	"path"
+	"testing"
+)
+
+func TestInitials(t *testing.T) {
+	for given, expected := range map[string]string{
+		"Jane":            "J.",
+		"Jane Marie":      "J. M.",
+		"Jean-Paul":       "J.-P.",
+		"J. R. R.":        "J. R. R.",
+		"Maria de la Paz": "M. de la P.",
+	} {
+		if got := initials(given, ". ", true); got != expected {
+			t.Errorf("initials(%q), expected %q, got %q", given, expected, got)
+		}
+	}
+	if got := initials("Jean-Paul", ".", false); got != "J.P." {
+		t.Errorf("expected J.P., got %q", got)
+	}
+}
+
+func TestCSLStyleFormat(t *testing.T) {
+	article := &Citation{
+		ID:    "authors:1",
+		Type:  "publication-article",
+		Title: "Flow of <i>E. coli</i> & friends",
+		Author: []*CitationAgent{
+			{FamilyName: "Gogh", NonDroppingParticle: "van", LivedName: "Vincent Willem"},
+			{FamilyName: "Doe", LivedName: "Jane-Marie", Suffix: "Jr."},
+			{Literal: "LIGO Scientific Collaboration"},
+		},
+		Publication:     "Nature",
+		Volume:          "600",
+		Issue:           "2",
+		Pages:           "10-12",
+		PublicationDate: "2023-07-04",
+		DOI:             "10.1000/182",
+	}
+	thesis := &Citation{
+		ID:         "thesis:1",
+		Type:       "thesis",
+		Title:      "Turbulence",
+		ThesisType: "doctoral thesis",
+		Publisher:  "California Institute of Technology",
+		Author:     []*CitationAgent{{FamilyName: "Roe", LivedName: "Richard"}},
+		Date:       map[string]*CitationDate{"issued": {Raw: "Spring 1998"}},
+	}
+	book := &Citation{
+		ID:        "book:1",
+		Type:      "book",
+		Title:     "A Book",
+		Editor:    []*CitationAgent{{FamilyName: "Smith", LivedName: "Sam"}},
+		Edition:   "2",
+		Publisher: "Pub",
+	}
+	expected := map[string][]struct {
+		cite   *Citation
+		format string
+		src    string
+	}{
+		"apa": {
+			{article, "html", `<div class="csl-entry">van Gogh, V. W., Doe, J.-M., Jr., &amp; LIGO Scientific Collaboration. (2023). Flow of <i>E. coli</i> &amp; friends. <i>Nature</i>, <i>600</i>(2), 10–12. https://doi.org/10.1000/182</div>`},
+			{article, "markdown", `van Gogh, V. W., Doe, J.-M., Jr., & LIGO Scientific Collaboration. (2023). Flow of *E. coli* & friends. *Nature*, *600*(2), 10–12. https://doi.org/10.1000/182`},
+			{thesis, "text", `Roe, R. (Spring 1998). Turbulence. [Doctoral thesis, California Institute of Technology].`},
+			{book, "text", `Smith, S. (n.d.). A Book. (2nd ed.) Pub.`},
+		},
+		"ieee": {
+			{article, "text", `[1] V. W. van Gogh, J.-M. Doe Jr., and LIGO Scientific Collaboration, “Flow of E. coli & friends,” Nature, vol. 600, no. 2, pp. 10–12, Jul. 2023, doi: 10.1000/182.`},
+			{article, "html", `<div class="csl-entry"><div class="csl-left-margin">[1]</div><div class="csl-right-inline">V. W. van Gogh, J.-M. Doe Jr., and LIGO Scientific Collaboration, “Flow of <i>E. coli</i> &amp; friends,” <i>Nature</i>, vol. 600, no. 2, pp. 10–12, Jul. 2023, doi: 10.1000/182</div>.</div>`},
+			{book, "text", `[1] S. Smith, ed., “A Book.”`},
+		},
+	}
+	for name, tests := range expected {
+		style, err := LoadCSLStyle(path.Join("testdata", "styles", name+".csl"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if style.Name != name {
+			t.Errorf("expected style name %q, got %q", name, style.Name)
+		}
+		for _, test := range tests {
+			src, err := style.Format(test.cite, test.format)
+			if err != nil {
+				t.Errorf("%s %s %s, %s", name, test.cite.ID, test.format, err)
+				continue
+			}
+			if src != test.src {
+				t.Errorf("%s %s %s, expected\n%s\ngot\n%s", name, test.cite.ID, test.format, test.src, src)
+			}
+		}
+	}
+
+	// et al. after the first author when there are 7 or more
+	style, err := LoadCSLStyle(path.Join("testdata", "styles", "ieee.csl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	many := &Citation{ID: "authors:2", Type: "article", Title: "Many"}
+	for _, family := range []string{"A", "B", "C", "D", "E", "F", "G"} {
+		many.Author = append(many.Author, &CitationAgent{FamilyName: family, LivedName: "Pat"})
+	}
+	if src, _ := style.Format(many, "markdown"); src != `\[1\] P. A *et al.*, “Many.”` {
+		t.Errorf("expected et al., got %s", src)
+	}
+	if _, err := style.Format(many, "rtf"); err == nil {
+		t.Errorf("expected an unsupported format error")
+	}
+}
+
+func TestParseCSLStyle(t *testing.T) {
+	if _, err := ParseCSLStyle([]byte(`<style xmlns="http://purl.org/net/xbiblio/csl" version="1.0"><info><title>Dependent</title><link href="http://www.zotero.org/styles/apa" rel="independent-parent"/></info></style>`)); err == nil {
+		t.Errorf("expected dependent style error")
+	}
+	if _, err := ParseCSLStyle([]byte(`<locale/>`)); err == nil {
+		t.Errorf("expected not a style error")
+	}
+}
+
+func TestCitationFormatter(t *testing.T) {
+	formatter, err := NewCitationFormatter("testdata/styles/apa.csl, testdata/styles/ieee.csl", "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cite := &Citation{ID: "authors:1", Type: "book", Title: "A Book", Author: []*CitationAgent{{FamilyName: "Doe", LivedName: "Jane"}}, PublicationDate: "2001"}
+	if err := formatter.Apply(cite); err != nil {
+		t.Fatal(err)
+	}
+	if cite.Formatted["apa"] != "Doe, J. (2001). A Book." || cite.Formatted["ieee"] != "[1] J. Doe, “A Book,” 2001." {
+		t.Errorf("unexpected formatted citations %+v", cite.Formatted)
+	}
+	var none *CitationFormatter
+	if err := none.Apply(cite); err != nil {
+		t.Errorf("expected a nil formatter to do nothing, %s", err)
+	}
+	if _, err := NewCitationFormatter("testdata/styles/apa.csl", "rtf"); err == nil {
+		t.Errorf("expected an unsupported format error")
+	}
+}
@@ -18,21 +18,32 @@ func QueryDataCiteObject(cfg *Config, doi string, options *Doi2RdmOptions) (map[
 	if err != nil {
 		return nil, err
 	}
-	objects, err := client.Dois(doi)
+	return queryDataCiteObject(client, cfg, doi)
+}
+
+// queryDataCiteObject retrieves a DOI object using an existing client, e.g.
+// when processing a batch of DOI. Responses are read from and saved to
+// cfg.Cache when set.
+func queryDataCiteObject(client *dataciteapi.DataCiteClient, cfg *Config, doi string) (map[string]interface{}, error) {
+	src, err := cfg.Cache.Fetch("datacite", doi, func() ([]byte, error) {
+		return client.DoisJSON(doi)
+	})
 	if err != nil {
 		return nil, err
 	}
-	if len(objects) == 0 {
+	m := map[string]interface{}{}
+	if len(src) > 0 {
+		if err := JSONUnmarshal(src, &m); err != nil {
+			return nil, fmt.Errorf("problem encoding/decoding DataCite object, %s", err)
+		}
+	}
+	if len(m) == 0 {
 		return nil, fmt.Errorf("no data returned for %q", doi)
 	}
-	src, _ := JSONMarshalIndent(objects, "", "    ")
 	if cfg.Debug {
+		src, _ = JSONMarshalIndent(m, "", "    ")
 		fmt.Fprintf(os.Stderr, "objects JSON:\n\n%s\n\n", src)
 	}
-	m := map[string]interface{}{}
-	if err := JSONUnmarshal(src, &m); err != nil {
-		return nil, fmt.Errorf("problem encoding/decoding DataCite object, %s", err)
-	}
 	return m, nil
 }
 
@@ -97,7 +108,7 @@ func getObjectDescription(object map[string]interface{}) string {
 			for _, item := range descriptions.([]interface{}) {
 				m := item.(map[string]interface{})
 				if values, ok := m["description"]; ok {
-					return fmt.Sprintf("%s", values)
+					return JATSToHTML(fmt.Sprintf("%s", values))
 				}
 			}
 		}
@@ -257,8 +268,10 @@ func getObjectISSNs(object map[string]interface{}) []string {
 	return issns
 }
 
-// getObjectFunding
-func getObjectFunding(object map[string]interface{}) []*simplified.Funder {
+// getObjectFunding crosswalks the funding references. Funder ROR ids are
+// used as is, Crossref Funder ids and names are matched with the ROR data
+// dump index if available.
+func getObjectFunding(cfg *Config, object map[string]interface{}) []*simplified.Funder {
 	if attrs, ok := getObjectDataAttributes(object); ok {
 		funders := []*simplified.Funder{}
 		if fundingReferences, ok := attrs["fundingReferences"].([]interface{}); ok {
@@ -269,6 +282,25 @@ func getObjectFunding(object map[string]interface{}) []*simplified.Funder {
 					funder.Funder = new(simplified.FunderIdentifier)
 					funder.Funder.Name = funderName
 				}
+				if funder.Funder != nil {
+					funderID, _ := m["funderIdentifier"].(string)
+					idType, _ := m["funderIdentifierType"].(string)
+					switch {
+					case idType == "ROR" && funderID != "":
+						funder.Funder.Identifier = strings.TrimPrefix(funderID, "https://ror.org/")
+					case cfg.Ror != nil:
+						ror, ok := "", false
+						if idType == "Crossref Funder ID" {
+							ror, ok = cfg.Ror.ResolveFundRef(funderID)
+						}
+						if !ok {
+							ror, ok = cfg.Ror.Resolve(funder.Funder.Name)
+						}
+						if ok {
+							funder.Funder.Identifier = ror
+						}
+					}
+				}
 				if awardNumber, ok := m["awardNumber"].(string); ok {
 					funder.Award = new(simplified.AwardIdentifier)
 					funder.Award.Number = awardNumber
@@ -591,7 +623,7 @@ func CrosswalkDataCiteObject(cfg *Config, object map[string]interface{}, options
 			return nil, err
 		}
 	}
-	if values := getObjectFunding(object); values != nil && len(values) > 0 {
+	if values := getObjectFunding(cfg, object); values != nil && len(values) > 0 {
 		if err := SetFunding(rec, values); err != nil {
 			return nil, err
 		}
@@ -718,6 +750,9 @@ func CrosswalkDataCiteObject(cfg *Config, object map[string]interface{}, options
 		}
 	}
 
+	// NOTE: creators are matched to the Caltech people list if loaded
+	cfg.People.EnrichCreators(rec)
+
 	// NOTE: We need to set the creation and updated time.
 	now := time.Now()
 	rec.Created = now
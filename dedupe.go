@@ -0,0 +1,427 @@
+package irdmtools
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/dataset/v2"
+)
+
+// CitationSource identifies a repository record a canonical citation was
+// merged from.
+type CitationSource struct {
+	// ID is the citation id, e.g. "caltechthesis:1234"
+	ID string `json:"id,omitempty" xml:"id,omitempty" yaml:"id,omitempty"`
+
+	// Collection is the originating collection, e.g. "caltechthesis"
+	Collection string `json:"collection,omitempty" xml:"collection,omitempty" yaml:"collection,omitempty"`
+
+	// CollectionID is the record's id in the originating collection
+	CollectionID string `json:"collection_id,omitempty" xml:"collection_id,omitempty" yaml:"collection_id,omitempty"`
+
+	// CiteUsingURL is the URL of the record in the originating collection
+	CiteUsingURL string `json:"cite_using_url,omitempty" xml:"cite_using_url,omitempty" yaml:"cite_using_url,omitempty"`
+}
+
+// CitationMatch describes a possible duplicate that needs review. Merged
+// is true if the citations were merged anyway.
+type CitationMatch struct {
+	IDs    []string `json:"ids"`
+	Reason string   `json:"reason"`
+	Merged bool     `json:"merged"`
+}
+
+// isbnRe matches ISBN-10 and ISBN-13 values with or without hyphens.
+var isbnRe = regexp.MustCompile(`(?i)(97[89][- ]?)?\d[\d -]{7,11}[\dX]`)
+
+// citationISBNs returns the ISBNs of a citation as ISBN-13 so an ISBN-10
+// matches its ISBN-13 form.
+func citationISBNs(cite *Citation) []string {
+	isbns := []string{}
+	for _, match := range isbnRe.FindAllString(cite.ISBN, -1) {
+		s := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(match))
+		switch len(s) {
+		case 10:
+			s = "978" + s[0:9]
+			sum := 0
+			for i, r := range s {
+				d := int(r - '0')
+				if i%2 == 1 {
+					d *= 3
+				}
+				sum += d
+			}
+			s += strconv.Itoa((10 - sum%10) % 10)
+		case 13:
+		default:
+			continue
+		}
+		isbns = append(isbns, s)
+	}
+	return isbns
+}
+
+// titleWords returns the folded words of a title, markup is removed.
+func titleWords(title string) []string {
+	words := []string{}
+	for _, field := range strings.Fields(cslMarkup.ReplaceAllString(title, " ")) {
+		if w := asciiWord(field); w != "" {
+			words = append(words, w)
+		}
+	}
+	return words
+}
+
+// titleSimilarity returns the share of words two titles have in common,
+// 1.0 for the same words, 0.0 for none.
+func titleSimilarity(a []string, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	set := map[string]bool{}
+	for _, w := range a {
+		set[w] = true
+	}
+	common, all := 0, len(set)
+	seen := map[string]bool{}
+	for _, w := range b {
+		if seen[w] {
+			continue
+		}
+		seen[w] = true
+		if set[w] {
+			common++
+		} else {
+			all++
+		}
+	}
+	return float64(common) / float64(all)
+}
+
+// citationFieldCount counts the citation's fields with values, used to
+// prefer the most complete citation as canonical.
+func citationFieldCount(cite *Citation) int {
+	src, err := JSONMarshal(cite)
+	if err != nil {
+		return 0
+	}
+	m := map[string]interface{}{}
+	if err := JSONUnmarshal(src, &m); err != nil {
+		return 0
+	}
+	return len(m)
+}
+
+// mergeCitations returns the canonical citation with the fields it lacks
+// filled in from the others and the sources of all of them.
+func mergeCitations(canonical *Citation, others []*Citation) (*Citation, error) {
+	src, err := JSONMarshal(canonical)
+	if err != nil {
+		return nil, err
+	}
+	merged := map[string]interface{}{}
+	if err := JSONUnmarshal(src, &merged); err != nil {
+		return nil, err
+	}
+	for _, other := range others {
+		src, err := JSONMarshal(other)
+		if err != nil {
+			return nil, err
+		}
+		m := map[string]interface{}{}
+		if err := JSONUnmarshal(src, &m); err != nil {
+			return nil, err
+		}
+		for key, val := range m {
+			// NOTE: formatted citations hold the other record's URL
+			if _, ok := merged[key]; !ok && key != "formatted" && key != "sources" {
+				merged[key] = val
+			}
+		}
+	}
+	src, err = JSONMarshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	cite := new(Citation)
+	if err := JSONUnmarshal(src, cite); err != nil {
+		return nil, err
+	}
+	cite.Sources = []*CitationSource{}
+	for _, source := range append([]*Citation{canonical}, others...) {
+		if len(source.Sources) > 0 {
+			cite.Sources = append(cite.Sources, source.Sources...)
+			continue
+		}
+		cite.Sources = append(cite.Sources, &CitationSource{
+			ID:           source.ID,
+			Collection:   source.Collection,
+			CollectionID: source.CollectionID,
+			CiteUsingURL: source.CiteUsingURL,
+		})
+	}
+	return cite, nil
+}
+
+// DedupeCitations clusters citations that describe the same work and
+// merges each cluster into a canonical citation listing its sources.
+// Citations are clustered by DOI, then ISBN, then by normalized title,
+// year and first author. The canonical citation is the one from the
+// first collection in prefer, then one with a DOI, then the most
+// complete. Matches that look doubtful are returned for review:
+//
+// - citations sharing a DOI but not their titles (merged)
+// - citations sharing an ISBN but not their titles, e.g. chapters of a book (not merged)
+// - citations with the same title, year and first author but different types (not merged)
+// - citations with the same title and first author a year apart (not merged)
+// - citations with similar titles, the same year and first author (not merged)
+//
+// ```
+// citations, err := ReadCitations("citation.ds", nil, "", "")
+// if err != nil {
+//    // ... handle error ...
+// }
+// canonical, matches, err := DedupeCitations(citations, []string{"caltechauthors"})
+// ```
+func DedupeCitations(citations []*Citation, prefer []string) ([]*Citation, []*CitationMatch, error) {
+	citations = append([]*Citation{}, citations...)
+	sort.Slice(citations, func(i, j int) bool { return citations[i].ID < citations[j].ID })
+	parent := make([]int, len(citations))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	union := func(i int, j int) {
+		if ri, rj := find(i), find(j); ri != rj {
+			parent[rj] = ri
+		}
+	}
+	matches := []*CitationMatch{}
+	reported := map[string]bool{}
+	report := func(i int, j int, reason string, merged bool) {
+		key := fmt.Sprintf("%d/%d", i, j)
+		if reported[key] {
+			return
+		}
+		reported[key] = true
+		matches = append(matches, &CitationMatch{IDs: []string{citations[i].ID, citations[j].ID}, Reason: reason, Merged: merged})
+	}
+	titles := make([][]string, len(citations))
+	years := make([]int, len(citations))
+	authors := make([]string, len(citations))
+	for i, cite := range citations {
+		titles[i] = titleWords(cite.Title)
+		years[i], _ = strconv.Atoi(citationYear(cite))
+		authors[i] = firstAgentName(cite)
+	}
+
+	// Same DOI
+	byDOI := map[string]int{}
+	for i, cite := range citations {
+		doi := strings.ToLower(normalizeDOI(cite.DOI))
+		if doi == "" {
+			continue
+		}
+		if j, ok := byDOI[doi]; ok {
+			union(j, i)
+			if titleSimilarity(titles[i], titles[j]) < 0.5 {
+				report(j, i, "same DOI, titles differ", true)
+			}
+			continue
+		}
+		byDOI[doi] = i
+	}
+
+	// Same ISBN, chapters share the ISBN of their book so titles must match
+	byISBN := map[string]int{}
+	for i, cite := range citations {
+		for _, isbn := range citationISBNs(cite) {
+			j, ok := byISBN[isbn]
+			if !ok {
+				byISBN[isbn] = i
+				continue
+			}
+			if find(i) == find(j) {
+				continue
+			}
+			if titleSimilarity(titles[i], titles[j]) >= 0.8 {
+				union(j, i)
+			} else {
+				report(j, i, "same ISBN, titles differ", false)
+			}
+		}
+	}
+
+	// Same title, year and first author
+	byTitle := map[string]int{}
+	for i, cite := range citations {
+		if len(titles[i]) == 0 || authors[i] == "" {
+			continue
+		}
+		key := fmt.Sprintf("%s|%d|%s", strings.Join(titles[i], " "), years[i], authors[i])
+		j, ok := byTitle[key]
+		if !ok {
+			byTitle[key] = i
+			continue
+		}
+		if find(i) == find(j) {
+			continue
+		}
+		if citations[j].cslType() == cite.cslType() {
+			union(j, i)
+		} else {
+			report(j, i, "same title, year and first author, types differ", false)
+		}
+	}
+
+	// Near matches, the same title and first author a year apart or
+	// similar titles with the same year and first author.
+	byTitleAuthor := map[string][]int{}
+	byAuthorYear := map[string][]int{}
+	keys := []string{}
+	for i := range citations {
+		if len(titles[i]) == 0 || authors[i] == "" {
+			continue
+		}
+		key := fmt.Sprintf("%s|%s", strings.Join(titles[i], " "), authors[i])
+		byTitleAuthor[key] = append(byTitleAuthor[key], i)
+		key = fmt.Sprintf("%s|%d", authors[i], years[i])
+		if _, ok := byAuthorYear[key]; !ok {
+			keys = append(keys, key)
+		}
+		byAuthorYear[key] = append(byAuthorYear[key], i)
+	}
+	for _, key := range keys {
+		group := byAuthorYear[key]
+		for x, i := range group {
+			for _, j := range group[x+1:] {
+				if find(i) != find(j) && titleSimilarity(titles[i], titles[j]) >= 0.8 {
+					report(i, j, "similar titles, same year and first author", false)
+				}
+			}
+		}
+	}
+	for i := range citations {
+		if len(titles[i]) == 0 || authors[i] == "" {
+			continue
+		}
+		for _, j := range byTitleAuthor[fmt.Sprintf("%s|%s", strings.Join(titles[i], " "), authors[i])] {
+			if j > i && find(i) != find(j) && (years[j]-years[i] == 1 || years[i]-years[j] == 1) {
+				report(i, j, "same title and first author, years differ", false)
+			}
+		}
+	}
+
+	// Merge the clusters
+	clusters := map[int][]*Citation{}
+	roots := []int{}
+	for i, cite := range citations {
+		root := find(i)
+		if _, ok := clusters[root]; !ok {
+			roots = append(roots, root)
+		}
+		clusters[root] = append(clusters[root], cite)
+	}
+	rank := func(cite *Citation) int {
+		for i, collection := range prefer {
+			if cite.Collection == collection {
+				return i
+			}
+		}
+		return len(prefer)
+	}
+	canonical := []*Citation{}
+	for _, root := range roots {
+		cluster := clusters[root]
+		sort.SliceStable(cluster, func(i, j int) bool {
+			a, b := cluster[i], cluster[j]
+			if rank(a) != rank(b) {
+				return rank(a) < rank(b)
+			}
+			if (a.DOI == "") != (b.DOI == "") {
+				return a.DOI != ""
+			}
+			if n, m := citationFieldCount(a), citationFieldCount(b); n != m {
+				return n > m
+			}
+			return a.ID < b.ID
+		})
+		cite, err := mergeCitations(cluster[0], cluster[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		canonical = append(canonical, cite)
+	}
+	return canonical, matches, nil
+}
+
+// RunDedupeCitations reads the citations in one dataset collection and
+// writes the canonical citations to another. The matches needing review
+// are written as JSON to reportFName ("-" for standard output).
+func RunDedupeCitations(in io.Reader, out io.Writer, eout io.Writer, args []string, prefer string, reportFName string) int {
+	if len(args) != 2 {
+		fmt.Fprintf(eout, "expected citation and canonical collection names\n")
+		return 1
+	}
+	citeCName, canonicalCName := args[0], args[1]
+	citations, err := ReadCitations(citeCName, nil, "", "")
+	if err != nil {
+		fmt.Fprintf(eout, "%s\n", err)
+		return 1
+	}
+	preferred := []string{}
+	for _, collection := range strings.Split(prefer, ",") {
+		if collection = strings.TrimSpace(collection); collection != "" {
+			preferred = append(preferred, collection)
+		}
+	}
+	canonical, matches, err := DedupeCitations(citations, preferred)
+	if err != nil {
+		fmt.Fprintf(eout, "%s\n", err)
+		return 1
+	}
+	c, err := dataset.Open(canonicalCName)
+	if err != nil {
+		fmt.Fprintf(eout, "%s\n", err)
+		return 1
+	}
+	defer c.Close()
+	for _, cite := range canonical {
+		if c.HasKey(cite.ID) {
+			err = c.UpdateObject(cite.ID, cite)
+		} else {
+			err = c.CreateObject(cite.ID, cite)
+		}
+		if err != nil {
+			fmt.Fprintf(eout, "failed to save %s, %s\n", cite.ID, err)
+			return 1
+		}
+	}
+	fmt.Fprintf(eout, "%d citations, %d canonical citations, %d matches to review\n", len(citations), len(canonical), len(matches))
+	if reportFName != "" {
+		src, err := JSONMarshalIndent(matches, "", "    ")
+		if err != nil {
+			fmt.Fprintf(eout, "%s\n", err)
+			return 1
+		}
+		if reportFName == "-" {
+			fmt.Fprintf(out, "%s\n", src)
+		} else if err := os.WriteFile(reportFName, append(src, '\n'), 0664); err != nil {
+			fmt.Fprintf(eout, "%s\n", err)
+			return 1
+		}
+	}
+	return 0 // OK
+}
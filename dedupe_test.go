@@ -0,0 +1,82 @@
+package irdmtools
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCitationISBNs(t *testing.T) {
+	cite := &Citation{ISBN: "0-306-40615-2; 978-1-4028-9462-6"}
+	expected := []string{"9780306406157", "9781402894626"}
+	if got := citationISBNs(cite); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestDedupeCitations(t *testing.T) {
+	doe := []*CitationAgent{{FamilyName: "Doe", LivedName: "Jane", CLpid: "Doe-J"}}
+	citations := []*Citation{
+		// A thesis in both CaltechAUTHORS and CaltechTHESIS
+		{ID: "caltechthesis:1", Collection: "caltechthesis", CollectionID: "1", Type: "thesis", Title: "Turbulent Flow", Author: doe, PublicationDate: "2001", ThesisDegree: "PhD"},
+		{ID: "caltechauthors:a", Collection: "caltechauthors", CollectionID: "a", Type: "thesis", Title: "Turbulent flow", Author: doe, PublicationDate: "2001-06", DOI: "10.7907/abc"},
+		// The same DOI
+		{ID: "caltechauthors:b", Collection: "caltechauthors", CollectionID: "b", Type: "article", Title: "Waves", Author: doe, PublicationDate: "2003", DOI: "https://doi.org/10.1000/XYZ"},
+		{ID: "caltechdata:c", Collection: "caltechdata", CollectionID: "c", Type: "dataset", Title: "Data for figures", Author: doe, PublicationDate: "2003", DOI: "10.1000/xyz"},
+		// A book and a chapter sharing an ISBN
+		{ID: "caltechauthors:d", Collection: "caltechauthors", CollectionID: "d", Type: "book", Title: "A Book of Flows", Author: doe, PublicationDate: "2005", ISBN: "0-306-40615-2"},
+		{ID: "caltechauthors:e", Collection: "caltechauthors", CollectionID: "e", Type: "book_section", Title: "Chapter one", Author: doe, PublicationDate: "2005", ISBN: "9780306406157"},
+		{ID: "caltechdata:f", Collection: "caltechdata", CollectionID: "f", Type: "book", Title: "A book of flows", Author: doe, PublicationDate: "2005", ISBN: "978-0-306-40615-7", Publisher: "Pub"},
+		// Near matches
+		{ID: "caltechauthors:g", Collection: "caltechauthors", CollectionID: "g", Type: "article", Title: "Vortex shedding", Author: doe, PublicationDate: "2010"},
+		{ID: "caltechauthors:h", Collection: "caltechauthors", CollectionID: "h", Type: "article", Title: "Vortex shedding", Author: doe, PublicationDate: "2011"},
+		{ID: "caltechauthors:i", Collection: "caltechauthors", CollectionID: "i", Type: "report", Title: "Vortex shedding", Author: doe, PublicationDate: "2010"},
+	}
+	canonical, matches, err := DedupeCitations(citations, []string{"caltechauthors"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources := map[string][]string{}
+	for _, cite := range canonical {
+		for _, source := range cite.Sources {
+			sources[cite.ID] = append(sources[cite.ID], source.Collection+":"+source.CollectionID)
+		}
+	}
+	expected := map[string][]string{
+		"caltechauthors:a": {"caltechauthors:a", "caltechthesis:1"},
+		"caltechauthors:b": {"caltechauthors:b", "caltechdata:c"},
+		"caltechauthors:d": {"caltechauthors:d", "caltechdata:f"},
+		"caltechauthors:e": {"caltechauthors:e"},
+		"caltechauthors:g": {"caltechauthors:g"},
+		"caltechauthors:h": {"caltechauthors:h"},
+		"caltechauthors:i": {"caltechauthors:i"},
+	}
+	if !reflect.DeepEqual(sources, expected) {
+		t.Errorf("expected canonical citations\n%+v\ngot\n%+v", expected, sources)
+	}
+	for _, cite := range canonical {
+		switch cite.ID {
+		case "caltechauthors:a":
+			if cite.ThesisDegree != "PhD" || cite.DOI != "10.7907/abc" {
+				t.Errorf("expected merged thesis fields, got %+v", cite)
+			}
+		case "caltechauthors:d":
+			if cite.Publisher != "Pub" {
+				t.Errorf("expected publisher filled in, got %+v", cite)
+			}
+		}
+	}
+	reasons := map[string]string{}
+	for _, match := range matches {
+		reasons[match.IDs[0]+" "+match.IDs[1]] = match.Reason
+	}
+	expectedReasons := map[string]string{
+		"caltechauthors:b caltechdata:c":    "same DOI, titles differ",
+		"caltechauthors:d caltechauthors:e": "same ISBN, titles differ",
+		"caltechauthors:g caltechauthors:i": "same title, year and first author, types differ",
+		"caltechauthors:g caltechauthors:h": "same title and first author, years differ",
+		"caltechauthors:h caltechauthors:i": "same title and first author, years differ",
+	}
+	if !reflect.DeepEqual(reasons, expectedReasons) {
+		t.Errorf("expected matches\n%+v\ngot\n%+v", expectedReasons, reasons)
+	}
+}
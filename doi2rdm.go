@@ -35,16 +35,27 @@
 package irdmtools
 
 import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"log"
 	"os"
+	"path"
+	"sort"
 	"strings"
+	"time"
 
 	// 3rd Party packages
 	"gopkg.in/yaml.v3"
 
 	// Caltech Library packages
 	"github.com/caltechlibrary/crossrefapi"
+	"github.com/caltechlibrary/dataciteapi"
+	"github.com/caltechlibrary/dataset/v2"
 	"github.com/caltechlibrary/simplified"
 )
 
@@ -70,6 +81,12 @@ type Doi2RdmOptions struct {
 	ISSNJournals        map[string]string `json:"issn_journals,omitempty" yaml:"issn_journals,omitempty"`
 	ISSNPublishers      map[string]string `json:"issn_publishers,omitempty" yaml:"issn_publishers,omitempty"`
 	Debug               bool              `json:"debug,omitempty" yaml:"debug,omitempty"`
+	// MergePrecedence maps record field paths to the order sources are
+	// used when merging CrossRef and DataCite records.
+	MergePrecedence map[string][]string `json:"merge_precedence,omitempty" yaml:"merge_precedence,omitempty"`
+	// EnrichPubMed adds the abstract, MeSH subjects, PMID, PMCID and
+	// grants from Europe PMC to records retrieved from CrossRef or DataCite.
+	EnrichPubMed bool `json:"enrich_pubmed,omitempty" yaml:"enrich_pubmed,omitempty"`
 }
 
 var (
@@ -126,15 +143,75 @@ resource_types:
   posted-content: publication-preprint
   DataPaper: publication-datapaper
   Text: publication-other
+  Journal Article: publication-article
+  research-article: publication-article
+  review-article: publication-article
 # Mapping DOI prefixes to Publisher names (used to normalize publisher names)
 doi_prefix_publishers:
 # Mapping ISSN prefixes to Journals (used to normalize journal titles names)
 issn_journals:
 # Mapping ISSN prefixes to Publishers (used to normalize publisher names)
 issn_publishers:
+# When merging CrossRef and DataCite records, the order the sources are
+# used for a field. Fields are paths in the RDM record, e.g.
+# metadata.description or custom_fields.journal:journal.volume. A rule
+# for custom_fields.journal:journal applies to all the journal fields.
+# Fields without a rule use the default.
+merge_precedence:
+  default: [ crossref, datacite ]
+  metadata.description: [ datacite, crossref ]
+  metadata.funding: [ crossref, datacite ]
+# Add the abstract (if missing), MeSH subjects, PMID, PMCID and grants
+# (if there is no funding) from PubMed via Europe PMC to records
+# retrieved from CrossRef or DataCite.
+enrich_pubmed: false
 `)
 )
 
+// LoadDoi2RdmOptions reads the YAML options file. If optionFName is an
+// empty string the default options are used. MailTo defaults to the
+// Caltech Library helpdesk address.
+//
+// ```
+// options, err := LoadDoi2RdmOptions("doi2rdm.yaml", false)
+// if err != nil {
+//     // ... handle error ...
+// }
+// ```
+func LoadDoi2RdmOptions(optionFName string, debug bool) (*Doi2RdmOptions, error) {
+	var (
+		err error
+		src []byte
+	)
+	src = DefaultDoi2RdmOptionsYAML
+	if optionFName != "" {
+		src, err = os.ReadFile(optionFName)
+		if err != nil {
+			return nil, err
+		}
+	}
+	options := new(Doi2RdmOptions)
+	if err := yaml.Unmarshal(src, &options); err != nil {
+		return nil, err
+	}
+	if debug {
+		options.Debug = debug
+	}
+	if options.MailTo == "" {
+		//mailTo = fmt.Sprintf("%s@%s", os.Getenv("USER"), os.Getenv("HOSTNAME"))
+		options.MailTo = "helpdesk@library.caltech.edu"
+	}
+	return options, nil
+}
+
+// optionsExitCode maps an error from LoadDoi2RdmOptions to an exit code.
+func optionsExitCode(err error) int {
+	if errors.Is(err, fs.ErrNotExist) {
+		return ENOENT
+	}
+	return ENOEXEC
+}
+
 // Configure reads the configuration file and environtment
 // initialing the Cfg attribute of a Doi2Rdm object. It returns an error
 // if problem were encounter.
@@ -176,6 +253,33 @@ func (app *Doi2Rdm) Configure(configFName string, envPrefix string, debug bool)
 	return nil
 }
 
+// OpenDB opens the Postgres connection used to read RDM records if one
+// is configured, otherwise RDM records are read with the RDM JSON API.
+func (app *Doi2Rdm) OpenDB() error {
+	if app.Cfg == nil {
+		return fmt.Errorf("application not configured")
+	}
+	if !usePostgresDB(app.Cfg) {
+		return nil
+	}
+	db, err := sql.Open("postgres", app.Cfg.MakeDSN())
+	if err != nil {
+		return err
+	}
+	app.Cfg.pgDB = db
+	return nil
+}
+
+// CloseDB closes the Postgres connection if one is open.
+func (app *Doi2Rdm) CloseDB() error {
+	if app.Cfg == nil || app.Cfg.pgDB == nil {
+		return nil
+	}
+	err := app.Cfg.pgDB.Close()
+	app.Cfg.pgDB = nil
+	return err
+}
+
 // RunCrossRefToRdm implements the doi2rdm cli behaviors using the CrossRef service.
 // With the exception of the "setup" action you should call `app.LoadConfig()` before execute
 // Run.
@@ -203,23 +307,9 @@ func (app *Doi2Rdm) RunCrossRefToRdm(in io.Reader, out io.Writer, eout io.Writer
 		err error
 		src []byte
 	)
-	src = DefaultDoi2RdmOptionsYAML
-	if optionFName != "" {
-		src, err = os.ReadFile(optionFName)
-		if err != nil {
-			return ENOENT, err
-		}
-	}
-	options := new(Doi2RdmOptions)
-	if err := yaml.Unmarshal(src, &options); err != nil {
-		return ENOEXEC, err
-	}
-	if app.Cfg.Debug {
-		options.Debug = app.Cfg.Debug
-	}
-	if options.MailTo == "" {
-		//mailTo = fmt.Sprintf("%s@%s", os.Getenv("USER"), os.Getenv("HOSTNAME"))
-		options.MailTo = "helpdesk@library.caltech.edu"
+	options, err := LoadDoi2RdmOptions(optionFName, app.Cfg.Debug)
+	if err != nil {
+		return optionsExitCode(err), err
 	}
 	var (
 		oRecord *simplified.Record
@@ -285,23 +375,9 @@ func (app *Doi2Rdm) RunDataCiteToRdm(in io.Reader, out io.Writer, eout io.Writer
 		err error
 		src []byte
 	)
-	src = DefaultDoi2RdmOptionsYAML
-	if optionFName != "" {
-		src, err = os.ReadFile(optionFName)
-		if err != nil {
-			return ENOENT, err
-		}
-	}
-	options := new(Doi2RdmOptions)
-	if err := yaml.Unmarshal(src, &options); err != nil {
-		return ENOEXEC, err
-	}
-	if app.Cfg.Debug {
-		options.Debug = app.Cfg.Debug
-	}
-	if options.MailTo == "" {
-		//mailTo = fmt.Sprintf("%s@%s", os.Getenv("USER"), os.Getenv("HOSTNAME"))
-		options.MailTo = "helpdesk@library.caltech.edu"
+	options, err := LoadDoi2RdmOptions(optionFName, app.Cfg.Debug)
+	if err != nil {
+		return optionsExitCode(err), err
 	}
 	var (
 		oRecord *simplified.Record
@@ -346,7 +422,8 @@ func (app *Doi2Rdm) RunDataCiteToRdm(in io.Reader, out io.Writer, eout io.Writer
 
 // RunDoiToRDMCombined implements the doi2rdm cli behaviors using the CrossRead and DataCite service.
 // With the exception of the "setup" action you should call `app.LoadConfig()` before execute
-// Run.
+// Run. If the options set enrich_pubmed the record is enriched from PubMed as is the saved
+// response in the diff file before comparing.
 //
 // ```
 //
@@ -366,9 +443,44 @@ func (app *Doi2Rdm) RunDataCiteToRdm(in io.Reader, out io.Writer, eout io.Writer
 //
 // ```
 func (app *Doi2Rdm) RunDoiToRdmCombined(in io.Reader, out io.Writer, eout io.Writer, optionFName, doi string, diffFName string) (int, error) {
-	// Do we have an arXiv id?
-	if strings.HasPrefix(strings.ToLower(doi), "arxiv:") {
-		return app.RunDataCiteToRdm(in, out, eout, optionFName, doi, diffFName)
+	options, err := LoadDoi2RdmOptions(optionFName, app.Cfg.Debug)
+	if err != nil {
+		return optionsExitCode(err), err
+	}
+	if options.EnrichPubMed {
+		var oRecord *simplified.Record
+		if diffFName != "" {
+			oRecord, err = readSourceRecord(app.Cfg, diffFName, options)
+			if err != nil {
+				return ENOENT, err
+			}
+		}
+		batch, err := newDoiBatch(app.Cfg, options)
+		if err != nil {
+			return ENOEXEC, err
+		}
+		nRecord, err := batch.getRecord("combined", doi)
+		if err != nil {
+			return ENOENT, err
+		}
+		// NOTE: both records are enriched so the diff only shows what
+		// changed in the source.
+		if pmRecord, err := batch.getPubMedRecord(doi); err == nil {
+			EnrichWithPubMed(nRecord, pmRecord)
+			EnrichWithPubMed(oRecord, pmRecord)
+		} else if app.Cfg.Debug {
+			log.Printf("%s not enriched from PubMed, %s", doi, err)
+		}
+		return writeRecordOrDiff(out, oRecord, nRecord)
+	}
+	// Do we have an arXiv id? Then try arXiv then DataCite.
+	if _, ok := ArxivID(doi); ok {
+		if _, axErr := app.RunArxivToRdm(in, out, eout, optionFName, doi, diffFName); axErr != nil {
+			if exitCode, dcErr := app.RunDataCiteToRdm(in, out, eout, optionFName, doi, diffFName); dcErr != nil {
+				return exitCode, fmt.Errorf("arxiv: %s, datacite: %s", axErr, dcErr)
+			}
+		}
+		return EXIT_OK, nil
 	}
 	if _, crErr := app.RunCrossRefToRdm(in, out, eout, optionFName, doi, diffFName); crErr != nil  {
 		// Then try DataCiteToRdm
@@ -378,3 +490,710 @@ func (app *Doi2Rdm) RunDoiToRdmCombined(in io.Reader, out io.Writer, eout io.Wri
 	}
 	return EXIT_OK, nil
 }
+
+// RunDoiToRdmMerged implements the doi2rdm merge mode. The DOI is
+// retrieved from both CrossRef and DataCite and the records are merged
+// field by field using the merge precedence in the options file. If
+// provenanceFName is set the source of each field is written to it as
+// JSON. If diffFName is set it holds a saved CrossRef or DataCite
+// response to compare the merged record with.
+//
+// ```
+// app := new(irdmtools.Doi2Rdm)
+// app.Cfg = new(irdmtools.Config)
+// doi := "10.22002/D1.868"
+// exitCode, err := app.RunDoiToRdmMerged(os.Stdin, os.Stdout, os.Stderr,
+//     "doi2rdm.yaml", doi, "", "provenance.json")
+// if err != nil {
+//     // ... handle error ...
+//     os.Exit(exitCode)
+// }
+// ```
+func (app *Doi2Rdm) RunDoiToRdmMerged(in io.Reader, out io.Writer, eout io.Writer, optionFName, doi string, diffFName string, provenanceFName string) (int, error) {
+	options, err := LoadDoi2RdmOptions(optionFName, app.Cfg.Debug)
+	if err != nil {
+		return optionsExitCode(err), err
+	}
+	batch, err := newDoiBatch(app.Cfg, options)
+	if err != nil {
+		return ENOEXEC, err
+	}
+	nRecord, provenance, err := batch.getMergedRecord(doi)
+	if err != nil {
+		return ENOENT, err
+	}
+	if provenanceFName != "" {
+		src, err := JSONMarshalIndent(provenance, "", "    ")
+		if err != nil {
+			return ENOEXEC, err
+		}
+		if err := os.WriteFile(provenanceFName, src, 0664); err != nil {
+			return ENOEXEC, err
+		}
+	}
+	var oRecord *simplified.Record
+	if diffFName != "" {
+		oRecord, err = readSourceRecord(app.Cfg, diffFName, options)
+		if err != nil {
+			return ENOENT, err
+		}
+		if err := batch.enrichRecord(oRecord, doi); err != nil && app.Cfg.Debug {
+			log.Printf("%s not enriched from PubMed, %s", diffFName, err)
+		}
+	}
+	return writeRecordOrDiff(out, oRecord, nRecord)
+}
+
+// writeRecordOrDiff writes the record to out or, if oRecord is not nil,
+// the difference from oRecord.
+func writeRecordOrDiff(out io.Writer, oRecord *simplified.Record, nRecord *simplified.Record) (int, error) {
+	var (
+		src []byte
+		err error
+	)
+	if oRecord != nil {
+		src, err = oRecord.DiffAsJSON(nRecord)
+	} else {
+		src, err = JSONMarshalIndent(nRecord, "", "    ")
+	}
+	if err != nil {
+		return ENOEXEC, err
+	}
+	fmt.Fprintf(out, "%s\n", src)
+	return EXIT_OK, nil
+}
+
+// readPubMedFile reads a saved Europe PMC JSON or PubMed XML response
+// returning the first article.
+func readPubMedFile(fName string) (*PubMedArticle, error) {
+	src, err := os.ReadFile(fName)
+	if err != nil {
+		return nil, err
+	}
+	var articles []*PubMedArticle
+	if bytes.HasPrefix(bytes.TrimSpace(src), []byte("<")) {
+		articles, err = ParsePubMedXML(src)
+	} else {
+		articles, err = ParseEuropePMC(src)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(articles) == 0 {
+		return nil, fmt.Errorf("no articles found in %s", fName)
+	}
+	return articles[0], nil
+}
+
+// readArxivFile reads a saved arXiv API Atom response returning the
+// first entry.
+func readArxivFile(fName string) (*ArxivEntry, error) {
+	src, err := os.ReadFile(fName)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := ParseArxivAtom(src)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no entries found in %s", fName)
+	}
+	return entries[0], nil
+}
+
+// readSourceRecord reads a saved CrossRef, DataCite, Europe PMC, PubMed
+// or arXiv API response and crosswalks it to an RDM record. It is used
+// when the source answering for a DOI isn't known in advance.
+func readSourceRecord(cfg *Config, fName string, options *Doi2RdmOptions) (*simplified.Record, error) {
+	src, err := os.ReadFile(fName)
+	if err != nil {
+		return nil, err
+	}
+	src = bytes.TrimSpace(src)
+	if bytes.HasPrefix(src, []byte("<")) {
+		if bytes.Contains(src, []byte("<feed")) {
+			entry, err := readArxivFile(fName)
+			if err != nil {
+				return nil, err
+			}
+			return CrosswalkArxivEntry(cfg, entry, options)
+		}
+		article, err := readPubMedFile(fName)
+		if err != nil {
+			return nil, err
+		}
+		return CrosswalkPubMedArticle(cfg, article, options)
+	}
+	object := map[string]interface{}{}
+	if err := JSONUnmarshal(src, &object); err != nil {
+		return nil, fmt.Errorf("%s, %s", fName, err)
+	}
+	if _, ok := object["message"]; ok {
+		work := new(crossrefapi.Works)
+		if err := JSONUnmarshal(src, &work); err != nil {
+			return nil, fmt.Errorf("%s, %s", fName, err)
+		}
+		return CrosswalkCrossRefWork(cfg, work, options)
+	}
+	if _, ok := object["data"]; ok {
+		return CrosswalkDataCiteObject(cfg, object, options)
+	}
+	if _, ok := object["resultList"]; ok {
+		article, err := readPubMedFile(fName)
+		if err != nil {
+			return nil, err
+		}
+		return CrosswalkPubMedArticle(cfg, article, options)
+	}
+	return nil, fmt.Errorf("%s is not a saved CrossRef, DataCite, Europe PMC, PubMed or arXiv response", fName)
+}
+
+// RunArxivToRdm implements the doi2rdm cli behaviors using the arXiv API.
+// The id can be an arXiv id (e.g. "arXiv:2312.07215"), arXiv DOI
+// (e.g. "10.48550/arXiv.2312.07215") or abstract URL. If diffFName is
+// set it holds a saved arXiv API Atom response to compare with.
+//
+// ```
+// app := new(irdmtools.Doi2Rdm)
+// app.Cfg = new(irdmtools.Config)
+// exitCode, err := app.RunArxivToRdm(os.Stdin, os.Stdout, os.Stderr,
+//     "doi2rdm.yaml", "arXiv:2312.07215", "")
+// if err != nil {
+//     // ... handle error ...
+//     os.Exit(exitCode)
+// }
+// ```
+func (app *Doi2Rdm) RunArxivToRdm(in io.Reader, out io.Writer, eout io.Writer, optionFName, id string, diffFName string) (int, error) {
+	options, err := LoadDoi2RdmOptions(optionFName, app.Cfg.Debug)
+	if err != nil {
+		return optionsExitCode(err), err
+	}
+	var oRecord *simplified.Record
+	if diffFName != "" {
+		oEntry, err := readArxivFile(diffFName)
+		if err != nil {
+			return ENOENT, err
+		}
+		oRecord, err = CrosswalkArxivEntry(app.Cfg, oEntry, options)
+		if err != nil {
+			return ENOEXEC, err
+		}
+	}
+	nEntry, err := QueryArxivEntry(app.Cfg, id)
+	if err != nil {
+		return ENOENT, err
+	}
+	nRecord, err := CrosswalkArxivEntry(app.Cfg, nEntry, options)
+	if err != nil {
+		return ENOEXEC, err
+	}
+	return writeRecordOrDiff(out, oRecord, nRecord)
+}
+
+// RunPubMedToRdm implements the doi2rdm cli behaviors using Europe PMC
+// for PubMed metadata. The id can be a DOI, PMID (e.g. "pmid:31452104")
+// or PMCID (e.g. "PMC6711234"). If diffFName is set it holds a saved
+// Europe PMC JSON or PubMed XML response to compare with.
+//
+// ```
+// app := new(irdmtools.Doi2Rdm)
+// app.Cfg = new(irdmtools.Config)
+// exitCode, err := app.RunPubMedToRdm(os.Stdin, os.Stdout, os.Stderr,
+//     "doi2rdm.yaml", "pmid:31452104", "")
+// if err != nil {
+//     // ... handle error ...
+//     os.Exit(exitCode)
+// }
+// ```
+func (app *Doi2Rdm) RunPubMedToRdm(in io.Reader, out io.Writer, eout io.Writer, optionFName, id string, diffFName string) (int, error) {
+	options, err := LoadDoi2RdmOptions(optionFName, app.Cfg.Debug)
+	if err != nil {
+		return optionsExitCode(err), err
+	}
+	var oRecord *simplified.Record
+	if diffFName != "" {
+		oArticle, err := readPubMedFile(diffFName)
+		if err != nil {
+			return ENOENT, err
+		}
+		oRecord, err = CrosswalkPubMedArticle(app.Cfg, oArticle, options)
+		if err != nil {
+			return ENOEXEC, err
+		}
+	}
+	nArticle, err := QueryPubMedArticle(app.Cfg, id)
+	if err != nil {
+		return ENOENT, err
+	}
+	nRecord, err := CrosswalkPubMedArticle(app.Cfg, nArticle, options)
+	if err != nil {
+		return ENOEXEC, err
+	}
+	return writeRecordOrDiff(out, oRecord, nRecord)
+}
+
+// stageRecordFields copies the selected fields of rec into draft. Fields
+// are the field paths used in merge_precedence, e.g. "metadata.description"
+// or "custom_fields.journal:journal", a parent path selects all its
+// fields. Only metadata and custom fields can be staged. It returns the
+// field paths staged.
+func stageRecordFields(draft map[string]interface{}, rec *simplified.Record, fields []string) ([]string, error) {
+	obj, err := recordObject(rec)
+	if err != nil {
+		return nil, err
+	}
+	recFields := recordFields(obj)
+	staged := []string{}
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !strings.HasPrefix(field, "metadata.") && !strings.HasPrefix(field, "custom_fields.") {
+			return nil, fmt.Errorf("can't stage %q, only metadata and custom_fields can be staged", field)
+		}
+		found := false
+		for fieldPath, val := range recFields {
+			if fieldPath == field || strings.HasPrefix(fieldPath, field+".") {
+				setRecordField(draft, fieldPath, val)
+				staged = append(staged, fieldPath)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%q not found in DOI record", field)
+		}
+	}
+	sort.Strings(staged)
+	return staged, nil
+}
+
+// commonRecordFields returns copies of the live and DOI records holding
+// only the fields the DOI record provides so their diff shows what a
+// refresh from the DOI would change.
+func commonRecordFields(live *simplified.Record, rec *simplified.Record) (*simplified.Record, *simplified.Record, error) {
+	liveObj, err := recordObject(live)
+	if err != nil {
+		return nil, nil, err
+	}
+	obj, err := recordObject(rec)
+	if err != nil {
+		return nil, nil, err
+	}
+	liveFields := recordFields(liveObj)
+	oObj, nObj := map[string]interface{}{}, map[string]interface{}{}
+	for fieldPath, val := range recordFields(obj) {
+		if !strings.HasPrefix(fieldPath, "metadata.") && !strings.HasPrefix(fieldPath, "custom_fields.") {
+			continue
+		}
+		setRecordField(nObj, fieldPath, val)
+		if liveVal, ok := liveFields[fieldPath]; ok {
+			setRecordField(oObj, fieldPath, liveVal)
+		}
+	}
+	oRecord, err := objectRecord(oObj)
+	if err != nil {
+		return nil, nil, err
+	}
+	nRecord, err := objectRecord(nObj)
+	if err != nil {
+		return nil, nil, err
+	}
+	return oRecord, nRecord, nil
+}
+
+// RunRdmDiff implements the doi2rdm RDM diff mode. It retrieves the
+// RDM record recordId, crosswalks its DOI (or doi if not empty) from
+// dataSource ("crossref", "datacite", "merge" or an empty string to try
+// CrossRef then DataCite) and writes the diff of the fields the DOI
+// record provides. If stageFields is not empty those fields are copied
+// from the DOI record into a draft of the RDM record. The record is read
+// from Postgres if the connection is open (see OpenDB) otherwise the
+// RDM JSON API is used.
+//
+// ```
+// app := new(irdmtools.Doi2Rdm)
+// if err := app.Configure("", "", false); err != nil {
+//     // ... handle error ...
+// }
+// if err := app.OpenDB(); err != nil {
+//     // ... handle error ...
+// }
+// defer app.CloseDB()
+// exitCode, err := app.RunRdmDiff(os.Stdin, os.Stdout, os.Stderr,
+//     "doi2rdm.yaml", "", "qez01-2309a", "",
+//     []string{ "metadata.funding", "custom_fields.journal:journal" })
+// if err != nil {
+//     // ... handle error ...
+//     os.Exit(exitCode)
+// }
+// ```
+func (app *Doi2Rdm) RunRdmDiff(in io.Reader, out io.Writer, eout io.Writer, optionFName string, dataSource string, recordId string, doi string, stageFields []string) (int, error) {
+	options, err := LoadDoi2RdmOptions(optionFName, app.Cfg.Debug)
+	if err != nil {
+		return optionsExitCode(err), err
+	}
+	if app.Cfg.rl == nil {
+		app.Cfg.rl = new(RateLimit)
+	}
+	live, err := getPublishedRecord(app.Cfg, recordId)
+	if err != nil {
+		return ENOENT, fmt.Errorf("failed to get %s, %s", recordId, err)
+	}
+	if doi == "" {
+		if pid, ok := live.ExternalPIDs["doi"]; ok && pid != nil {
+			doi = pid.Identifier
+		}
+		if doi == "" {
+			return ENOENT, fmt.Errorf("%s has no DOI", recordId)
+		}
+	}
+	batch, err := newDoiBatch(app.Cfg, options)
+	if err != nil {
+		return ENOEXEC, err
+	}
+	var rec *simplified.Record
+	if dataSource == "merge" {
+		rec, _, err = batch.getMergedRecord(doi)
+	} else {
+		rec, err = batch.getRecord(dataSource, doi)
+	}
+	if err != nil {
+		return ENOENT, fmt.Errorf("%s (%s), %s", doi, recordId, err)
+	}
+	oRecord, nRecord, err := commonRecordFields(live, rec)
+	if err != nil {
+		return ENOEXEC, err
+	}
+	src, err := oRecord.DiffAsJSON(nRecord)
+	if err != nil {
+		return ENOEXEC, err
+	}
+	fmt.Fprintf(out, "%s\n", src)
+	if len(stageFields) == 0 {
+		return EXIT_OK, nil
+	}
+	draft, err := NewDraft(app.Cfg, recordId)
+	if err != nil {
+		return ENOEXEC, fmt.Errorf("failed to create draft of %s, %s", recordId, err)
+	}
+	staged, err := stageRecordFields(draft, rec, stageFields)
+	if err != nil {
+		return ENOEXEC, err
+	}
+	src, err = JSONMarshal(draft)
+	if err != nil {
+		return ENOEXEC, err
+	}
+	if _, err := UpdateDraft(app.Cfg, recordId, src, app.Cfg.Debug); err != nil {
+		return ENOEXEC, fmt.Errorf("failed to update draft of %s, %s", recordId, err)
+	}
+	fmt.Fprintf(eout, "staged %s from %s into draft of %s\n", strings.Join(staged, ", "), doi, recordId)
+	return EXIT_OK, nil
+}
+
+// ReadDoiList reads a list of DOI, one per line. Blank lines and lines
+// starting with "#" are skipped. DOI in URL form are converted to their
+// canonical form.
+func ReadDoiList(in io.Reader) ([]string, error) {
+	doiList := []string{}
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		doi, err := LinkToDoi(line)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a DOI, %s", line, err)
+		}
+		doiList = append(doiList, doi)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return doiList, nil
+}
+
+// doiBatch holds the clients and options shared while processing a
+// batch of DOI.
+type doiBatch struct {
+	cfg      *Config
+	options  *Doi2RdmOptions
+	crClient *crossrefapi.CrossRefClient
+	dcClient *dataciteapi.DataCiteClient
+	rl       *RateLimit
+}
+
+// newDoiBatch creates the clients used to retrieve DOI.
+func newDoiBatch(cfg *Config, options *Doi2RdmOptions) (*doiBatch, error) {
+	var err error
+	appName := path.Base(os.Args[0])
+	batch := &doiBatch{
+		cfg:     cfg,
+		options: options,
+		// NOTE: one request per second until a service reports its limits
+		rl: &RateLimit{Limit: 1, Interval: 1},
+	}
+	batch.crClient, err = crossrefapi.NewCrossRefClient(appName, options.MailTo)
+	if err != nil {
+		return nil, err
+	}
+	batch.dcClient, err = dataciteapi.NewDataCiteClient(appName, options.MailTo)
+	if err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// getMergedRecord retrieves the DOI from both CrossRef and DataCite and
+// merges the records using the options' merge precedence. It fails
+// only if neither service has the DOI.
+func (batch *doiBatch) getMergedRecord(doi string) (*simplified.Record, *Provenance, error) {
+	records := map[string]*simplified.Record{}
+	errs := map[string]string{}
+	for _, dataSource := range []string{"crossref", "datacite"} {
+		rec, err := batch.getRecord(dataSource, doi)
+		if err != nil {
+			errs[dataSource] = err.Error()
+			continue
+		}
+		records[dataSource] = rec
+	}
+	if len(records) == 0 {
+		return nil, nil, fmt.Errorf("crossref: %s, datacite: %s", errs["crossref"], errs["datacite"])
+	}
+	rec, provenance, err := MergeRdmRecords(records, batch.options.MergePrecedence)
+	if err != nil {
+		return nil, nil, err
+	}
+	provenance.DOI = doi
+	for dataSource, msg := range errs {
+		provenance.Sources[dataSource] = msg
+	}
+	if batch.options.EnrichPubMed {
+		provenance.Sources["pubmed"] = "ok"
+		if err := batch.enrichRecord(rec, doi); err != nil {
+			provenance.Sources["pubmed"] = err.Error()
+		}
+	}
+	return rec, provenance, nil
+}
+
+// enrichRecord adds the PubMed metadata for the DOI to rec if the
+// options ask for it, see EnrichWithPubMed.
+func (batch *doiBatch) enrichRecord(rec *simplified.Record, doi string) error {
+	if !batch.options.EnrichPubMed {
+		return nil
+	}
+	pmRecord, err := batch.getPubMedRecord(doi)
+	if err != nil {
+		return err
+	}
+	EnrichWithPubMed(rec, pmRecord)
+	return nil
+}
+
+// getPubMedRecord retrieves the DOI from Europe PMC and crosswalks it to
+// an RDM record.
+func (batch *doiBatch) getPubMedRecord(doi string) (*simplified.Record, error) {
+	article, err := QueryPubMedArticle(batch.cfg, doi)
+	if err != nil {
+		return nil, err
+	}
+	return CrosswalkPubMedArticle(batch.cfg, article, batch.options)
+}
+
+// getRecord retrieves the DOI from dataSource ("crossref", "datacite",
+// "pubmed", "arxiv" or an empty string to try CrossRef then DataCite,
+// arXiv then DataCite for arXiv ids) and crosswalks it to an RDM record. With an empty string the record is enriched from
+// PubMed if the options ask for it. The rate limit is updated from the
+// last service queried.
+func (batch *doiBatch) getRecord(dataSource string, doi string) (*simplified.Record, error) {
+	if dataSource == "" {
+		rec, err := batch.getRecord("combined", doi)
+		if err == nil {
+			if err := batch.enrichRecord(rec, doi); err != nil && batch.cfg.Debug {
+				log.Printf("%s not enriched from PubMed, %s", doi, err)
+			}
+		}
+		return rec, err
+	}
+	if dataSource == "pubmed" {
+		return batch.getPubMedRecord(doi)
+	}
+	if dataSource == "arxiv" {
+		entry, err := QueryArxivEntry(batch.cfg, doi)
+		// NOTE: arXiv asks for no more than one request every three seconds
+		batch.rl.FromLimitInterval(1, 3)
+		if err != nil {
+			return nil, err
+		}
+		return CrosswalkArxivEntry(batch.cfg, entry, batch.options)
+	}
+	if _, ok := ArxivID(doi); ok && dataSource == "combined" {
+		rec, axErr := batch.getRecord("arxiv", doi)
+		if axErr == nil {
+			return rec, nil
+		}
+		rec, dcErr := batch.getRecord("datacite", doi)
+		if dcErr != nil {
+			return nil, fmt.Errorf("arxiv: %s, datacite: %s", axErr, dcErr)
+		}
+		return rec, nil
+	}
+	if dataSource == "combined" || dataSource == "crossref" {
+		work, crErr := queryCrossRefWork(batch.crClient, batch.cfg, doi)
+		batch.rl.FromLimitInterval(batch.crClient.RateLimitLimit, batch.crClient.RateLimitInterval)
+		if crErr == nil {
+			return CrosswalkCrossRefWork(batch.cfg, work, batch.options)
+		}
+		if dataSource == "crossref" {
+			return nil, crErr
+		}
+		rec, dcErr := batch.getRecord("datacite", doi)
+		if dcErr != nil {
+			return nil, fmt.Errorf("crossref: %s, datacite: %s", crErr, dcErr)
+		}
+		return rec, nil
+	}
+	object, err := queryDataCiteObject(batch.dcClient, batch.cfg, doi)
+	batch.rl.FromLimitInterval(batch.dcClient.RateLimitLimit, batch.dcClient.RateLimitInterval)
+	if err != nil {
+		return nil, err
+	}
+	return CrosswalkDataCiteObject(batch.cfg, object, batch.options)
+}
+
+// RunBatch implements the doi2rdm batch mode. It reads a list of DOI, one
+// per line, from doiFName (or from in if doiFName is "-") and retrieves
+// each from dataSource ("crossref", "datacite", "merge" or an empty string
+// to try CrossRef then DataCite). The options file is read once for the
+// batch. Records are written to out as JSON lines or, if cName is set,
+// stored in the dataset collection using the DOI as key. DOI that fail
+// are written to errorFName (or eout if errorFName is empty) as tab
+// delimited lines of DOI and error message. When merging, the provenance
+// of each record is written as JSON lines to provenanceFName if set.
+// Requests are throttled to the service's rate limits.
+//
+// ```
+// app := new(irdmtools.Doi2Rdm)
+// app.Cfg = new(irdmtools.Config)
+// exitCode, err := app.RunBatch(os.Stdin, os.Stdout, os.Stderr,
+//     "doi2rdm.yaml", "", "publications.txt", "staged.ds", "errors.log", "")
+// if err != nil {
+//     // ... handle error ...
+//     os.Exit(exitCode)
+// }
+// ```
+func (app *Doi2Rdm) RunBatch(in io.Reader, out io.Writer, eout io.Writer, optionFName string, dataSource string, doiFName string, cName string, errorFName string, provenanceFName string) (int, error) {
+	options, err := LoadDoi2RdmOptions(optionFName, app.Cfg.Debug)
+	if err != nil {
+		return optionsExitCode(err), err
+	}
+	var doiList []string
+	if doiFName == "" || doiFName == "-" {
+		doiList, err = ReadDoiList(in)
+	} else {
+		fp, oErr := os.Open(doiFName)
+		if oErr != nil {
+			return ENOENT, oErr
+		}
+		defer fp.Close()
+		doiList, err = ReadDoiList(fp)
+	}
+	if err != nil {
+		return ENOEXEC, err
+	}
+	batch, err := newDoiBatch(app.Cfg, options)
+	if err != nil {
+		return ENOEXEC, err
+	}
+	var c *dataset.Collection
+	if cName != "" {
+		c, err = dataset.Open(cName)
+		if err != nil {
+			return ENOENT, err
+		}
+		defer c.Close()
+	}
+	elog := eout
+	if errorFName != "" {
+		fp, err := os.Create(errorFName)
+		if err != nil {
+			return ENOEXEC, err
+		}
+		defer fp.Close()
+		elog = fp
+	}
+	var plog io.Writer
+	if dataSource == "merge" && provenanceFName != "" {
+		fp, err := os.Create(provenanceFName)
+		if err != nil {
+			return ENOEXEC, err
+		}
+		defer fp.Close()
+		plog = fp
+	}
+
+	tot := len(doiList)
+	errCnt := 0
+	t0 := time.Now()
+	iTime := time.Now()
+	reportProgress := false
+	log.Printf("start processing %d DOI", tot)
+	for i, doi := range doiList {
+		requests := 0
+		if app.Cfg.Cache != nil {
+			requests = app.Cfg.Cache.requests
+		}
+		var (
+			rec        *simplified.Record
+			provenance *Provenance
+		)
+		if dataSource == "merge" {
+			rec, provenance, err = batch.getMergedRecord(doi)
+		} else {
+			rec, err = batch.getRecord(dataSource, doi)
+		}
+		if err == nil && plog != nil && provenance != nil {
+			var src []byte
+			if src, err = JSONMarshal(provenance); err == nil {
+				fmt.Fprintf(plog, "%s\n", src)
+			}
+		}
+		if err == nil {
+			if c != nil {
+				key := strings.ToLower(doi)
+				if c.HasKey(key) {
+					err = c.UpdateObject(key, rec)
+				} else {
+					err = c.CreateObject(key, rec)
+				}
+			} else {
+				var src []byte
+				if src, err = JSONMarshal(rec); err == nil {
+					fmt.Fprintf(out, "%s\n", src)
+				}
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(elog, "%s\t%s\n", doi, strings.ReplaceAll(err.Error(), "\n", " "))
+			errCnt++
+		}
+		if iTime, reportProgress = CheckWaitInterval(iTime, time.Minute); reportProgress {
+			log.Printf("%d/%d DOI processed, %d errors: %s", i+1, tot, errCnt, ProgressETA(t0, i+1, tot))
+		}
+		// NOTE: responses from the cache don't count against the rate limits
+		cached := app.Cfg.Cache != nil && app.Cfg.Cache.requests == requests
+		if i < (tot-1) && !cached {
+			batch.rl.Throttle(i, tot)
+		}
+	}
+	log.Printf("%d/%d DOI processed, %d errors in %s", tot, tot, errCnt, time.Since(t0).Truncate(time.Second).String())
+	if errCnt > 0 {
+		return ENOENT, fmt.Errorf("%d of %d DOI failed", errCnt, tot)
+	}
+	return EXIT_OK, nil
+}
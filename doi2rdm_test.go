@@ -2,7 +2,8 @@ package irdmtools
 
 import (
 	//"log"
-	//"os"
+	"os"
+	"path"
 	"strings"
 	"testing"
 
@@ -675,4 +676,109 @@ func TestIssue81(t *testing.T) {
 	} else {
 		t.Errorf("failed to fund `.custom_fields` in record")
 	}
-}
\ No newline at end of file
+}
+
+func TestReadDoiList(t *testing.T) {
+	src := `# Jane Doe's publications
+10.1021/acsami.7b15651
+
+https://doi.org/10.3847/1538-3881/ad2765
+  arXiv:2312.07215  
+`
+	expected := []string{
+		"10.1021/acsami.7b15651",
+		"10.3847/1538-3881/ad2765",
+		"arXiv:2312.07215",
+	}
+	doiList, err := ReadDoiList(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doiList) != len(expected) {
+		t.Fatalf("expected %d DOI, got %d, %+v", len(expected), len(doiList), doiList)
+	}
+	for i, doi := range expected {
+		if doiList[i] != doi {
+			t.Errorf("expected %q, got %q", doi, doiList[i])
+		}
+	}
+}
+
+func TestLoadDoi2RdmOptions(t *testing.T) {
+	options, err := LoadDoi2RdmOptions("", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !options.Debug {
+		t.Errorf("expected debug to be set")
+	}
+	if options.MailTo == "" {
+		t.Errorf("expected mailto to be set")
+	}
+	if _, err := LoadDoi2RdmOptions("testdata/missing-options.yaml", false); optionsExitCode(err) != ENOENT {
+		t.Errorf("expected ENOENT for missing options file, got %d, %v", optionsExitCode(err), err)
+	}
+}
+
+func TestReadSourceRecord(t *testing.T) {
+	tmp := t.TempDir()
+	crossrefFName := path.Join(tmp, "crossref.json")
+	if err := os.WriteFile(crossrefFName, []byte(`{"status": "ok", "message-type": "work", "message": {"DOI": "10.1000/182", "type": "journal-article", "title": ["A CrossRef title"]}}`), 0664); err != nil {
+		t.Fatal(err)
+	}
+	dataciteFName := path.Join(tmp, "datacite.json")
+	if err := os.WriteFile(dataciteFName, []byte(`{"data": {"id": "10.5281/zenodo.1", "type": "dois", "attributes": {"doi": "10.5281/zenodo.1", "titles": [{"title": "A DataCite title"}]}}}`), 0664); err != nil {
+		t.Fatal(err)
+	}
+	options, err := LoadDoi2RdmOptions("", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{rl: new(RateLimit)}
+	for fName, doi := range map[string]string{
+		crossrefFName: "10.1000/182",
+		dataciteFName: "10.5281/zenodo.1",
+		path.Join("testdata", "pubmed", "pubmed-efetch.xml"):   "10.1016/j.cell.2018.06.001",
+		path.Join("testdata", "pubmed", "europepmc-core.json"): "10.1016/j.cell.2018.06.001",
+	} {
+		rec, err := readSourceRecord(cfg, fName, options)
+		if err != nil {
+			t.Errorf("%s, %s", fName, err)
+			continue
+		}
+		if pid, ok := rec.ExternalPIDs["doi"]; !ok || pid.Identifier != doi {
+			t.Errorf("%s, expected DOI %q, got %+v", fName, doi, rec.ExternalPIDs)
+		}
+	}
+	rec, err := readSourceRecord(cfg, path.Join("testdata", "arxiv", "arxiv-2312.07215.xml"), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Metadata == nil || rec.Metadata.Title == "" {
+		t.Errorf("expected an arXiv record, got %+v", rec)
+	}
+	// An RDM record isn't a saved service response
+	if _, err := readSourceRecord(cfg, path.Join("testdata", "10.5281-inveniordm.1234.json"), options); err == nil {
+		t.Errorf("expected an error for an RDM record")
+	}
+}
+
+func TestDoi2RdmOpenCloseDB(t *testing.T) {
+	app := &Doi2Rdm{Cfg: &Config{rl: new(RateLimit)}}
+	if err := app.OpenDB(); err != nil || app.Cfg.pgDB != nil {
+		t.Errorf("expected no connection without Postgres settings, got %v, %v", app.Cfg.pgDB, err)
+	}
+	app.Cfg.InvenioDbHost, app.Cfg.InvenioDbUser = "localhost", "rdm"
+	if err := app.OpenDB(); err != nil {
+		t.Fatal(err)
+	}
+	if app.Cfg.pgDB == nil {
+		t.Fatalf("expected a connection")
+	}
+	if err := app.CloseDB(); err != nil {
+		t.Error(err)
+	}
+	if app.Cfg.pgDB != nil {
+		t.Errorf("expected the closed connection to be cleared")
+	}
+}
@@ -281,6 +281,8 @@ func (app *Ep3Util) Run(in io.Reader, out io.Writer, eout io.Writer, action stri
 		flagSet.BoolVar(&all, "all", all, "harvest all records")
 		flagSet.BoolVar(&modified, "modified", modified, "harvest records between start and optional end date")
 		flagSet.BoolVar(&asCitation, "as-citation", asCitation, "harvest the records storing in citation format")
+		flagSet.IntVar(&app.Cfg.Workers, "workers", app.Cfg.Workers, "number of concurrent workers retrieving records")
+		flagSet.BoolVar(&app.Cfg.Resume, "resume", app.Cfg.Resume, "skip ids finished in the checkpoint journal")
 		flagSet.Parse(params)
 		params = flagSet.Args()
 		if (! all) && len(params) < 1 {
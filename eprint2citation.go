@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"os"
 	"path"
 	"strings"
 	"time"
@@ -35,7 +36,7 @@ func EPrintToCitation(repoName string, key string, eprint *eprinttools.EPrint, r
 
 // MigrateEPrintDatasetToCitationsDataset takes a dataset of EPrint objects and migrates the ones in the
 // id list to a citation dataset collection.
-func MigrateEPrintDatasetToCitationDataset(ep3CName string, ids []string, repoHost string, prefix string, citeCName string) error {
+func MigrateEPrintDatasetToCitationDataset(ep3CName string, ids []string, repoHost string, prefix string, citeCName string, cslOut io.Writer, formatter *CitationFormatter) error {
 	ep3, err := dataset.Open(ep3CName)
 	if err != nil {
 		return err
@@ -48,6 +49,7 @@ func MigrateEPrintDatasetToCitationDataset(ep3CName string, ids []string, repoHo
 	defer cite.Close()
 	resourceTypes := map[string]string{}
 	contributorTypes := map[string]string{}
+	cslCitations := []*Citation{}
 	tot := len(ids)
 	start := time.Now()
 	iTime := time.Now()
@@ -80,6 +82,9 @@ func MigrateEPrintDatasetToCitationDataset(ep3CName string, ids []string, repoHo
 			log.Printf("failed to convert (%d) id %s from %s to citation, %s", i, id, repoName, err)
 			continue
 		}
+		if err := formatter.Apply(citation); err != nil {
+			log.Printf("failed to format citation for %s (%d), %s", id, i, err)
+		}
 		if cite.HasKey(key) {
 			err = cite.UpdateObject(key, citation)
 		} else {
@@ -87,6 +92,8 @@ func MigrateEPrintDatasetToCitationDataset(ep3CName string, ids []string, repoHo
 		}
 		if err != nil {
 			log.Printf("failed to save citation for %s (%d), %s", id, i, err)
+		} else if cslOut != nil {
+			cslCitations = append(cslCitations, citation)
 		}
 		i++
 		if iTime, reportProgress = CheckWaitInterval(iTime, time.Minute); reportProgress || (i % 10000) == 0 {
@@ -94,12 +101,15 @@ func MigrateEPrintDatasetToCitationDataset(ep3CName string, ids []string, repoHo
 		}
 	}
 	log.Printf("%d/%d citations processed %s: completed", i, tot, time.Since(start).Truncate(time.Second).String())
+	if cslOut != nil {
+		return WriteCSLJSON(cslOut, cslCitations, start)
+	}
 	return nil
 }
 
 // RunEPrintDSToCitationDS migrates contents from an EPrint dataset collection to a citation dataset collection for
 // a give list of ids and repostiory hostname.
-func RunEPrintDSToCitationDS(in io.Reader, out io.Writer, eout io.Writer, args []string, repoHost string, prefix string, ids []string) int {
+func RunEPrintDSToCitationDS(in io.Reader, out io.Writer, eout io.Writer, args []string, repoHost string, prefix string, ids []string, cslFName string, styleFNames string, styleFormat string) int {
 	var (
 		ep3CName string
 		citeCName string
@@ -126,7 +136,30 @@ func RunEPrintDSToCitationDS(in io.Reader, out io.Writer, eout io.Writer, args [
 		fmt.Fprintf(eout, "no ids to process, aborting\n")
 		return 1
 	}
-	if err := MigrateEPrintDatasetToCitationDataset(ep3CName, keys, repoHost, prefix, citeCName); err != nil  {
+	var cslOut io.Writer
+	switch cslFName {
+	case "":
+	case "-":
+		cslOut = out
+	default:
+		fp, err := os.Create(cslFName)
+		if err != nil {
+			fmt.Fprintf(eout, "%s\n", err)
+			return 1
+		}
+		defer fp.Close()
+		cslOut = fp
+	}
+	var formatter *CitationFormatter
+	if styleFNames != "" {
+		var err error
+		formatter, err = NewCitationFormatter(styleFNames, styleFormat)
+		if err != nil {
+			fmt.Fprintf(eout, "%s\n", err)
+			return 1
+		}
+	}
+	if err := MigrateEPrintDatasetToCitationDataset(ep3CName, keys, repoHost, prefix, citeCName, cslOut, formatter); err != nil  {
 		fmt.Fprintf(eout,  "%s\n", err)
 		return 1
 	}
@@ -61,6 +61,9 @@ const (
 // EPrint2Rdm holds the configuration for rdmutil cli.
 type EPrint2Rdm struct {
 	Cfg *Config
+	// Fixups are applied to records after they are crosswalked, nil
+	// means no fixups.
+	Fixups *Fixups
 }
 
 // EPrintKeysPage holds the structure of the HTML page with the
@@ -886,7 +889,7 @@ func metadataFromEPrint(eprint *eprinttools.EPrint, rec *simplified.Record, cont
 		return err
 	}
 	if eprint.Abstract != "" {
-		rec.Metadata.Description = eprint.Abstract
+		rec.Metadata.Description = JATSToHTML(eprint.Abstract)
 	}
 
 	// Rights are scattered in several EPrints fields, they need to
@@ -1199,6 +1202,33 @@ func createdUpdatedFromEPrint(eprint *eprinttools.EPrint, rec *simplified.Record
 	return nil
 }
 
+// loadCrosswalkTypeMaps loads the resource and contributor type maps
+// used by CrosswalkEPrintToRecord. If a filename is empty the default
+// map is used.
+func loadCrosswalkTypeMaps(resourceTypesFName string, contributorTypesFName string) (map[string]string, map[string]string, error) {
+	resourceTypes := map[string]string{}
+	if resourceTypesFName != "" {
+		if err := LoadTypesMap(resourceTypesFName, resourceTypes); err != nil {
+			return nil, nil, fmt.Errorf("loading resource type map, %q, %s", resourceTypesFName, err)
+		}
+	} else {
+		for k, v := range defaultEPrintResourceTypeMap {
+			resourceTypes[k] = v
+		}
+	}
+	contributorTypes := map[string]string{}
+	if contributorTypesFName != "" {
+		if err := LoadTypesMap(contributorTypesFName, contributorTypes); err != nil {
+			return nil, nil, fmt.Errorf("loading contributor type map, %q, %s", contributorTypesFName, err)
+		}
+	} else {
+		for k, v := range defaultEPrintContributorTypeMap {
+			contributorTypes[k] = v
+		}
+	}
+	return resourceTypes, contributorTypes, nil
+}
+
 // Configure reads the configuration file and environment
 // initialing the Cfg attribute of a Eprint2Rdm object. It returns an error
 // if problem were encounter.
@@ -1298,25 +1328,9 @@ func (app *EPrint2Rdm) Run(in io.Reader, out io.Writer, eout io.Writer, username
 			}
 			defer c.Close()
 		}
-		resourceTypes := map[string]string{}
-		if resourceTypesFName != "" {
-			if err := LoadTypesMap(resourceTypesFName, resourceTypes); err != nil {
-				return fmt.Errorf("loading resource type map, %q, %s", resourceTypesFName, err)
-			}
-		} else {
-			for k, v := range defaultEPrintResourceTypeMap {
-				resourceTypes[k] = v
-			}
-		}
-		contributorTypes := map[string]string{}
-		if contributorTypesFName != "" {
-			if err := LoadTypesMap(contributorTypesFName, contributorTypes); err != nil {
-				return fmt.Errorf("loading contributor type map, %q, %s", contributorTypesFName, err)
-			}
-		} else {
-			for k, v := range defaultEPrintContributorTypeMap {
-				contributorTypes[k] = v
-			}
+		resourceTypes, contributorTypes, err := loadCrosswalkTypeMaps(resourceTypesFName, contributorTypesFName)
+		if err != nil {
+			return err
 		}
 
 		// Handle the case when you're havesting an id list.
@@ -1363,6 +1377,10 @@ func (app *EPrint2Rdm) Run(in io.Reader, out io.Writer, eout io.Writer, username
 					log.Printf("line %d, crosswalking %q, %s", i+1, eprintId, err)
 					continue
 				}
+				if err := app.Fixups.Apply(record); err != nil {
+					log.Printf("line %d, fixing up %q, %s", i+1, eprintId, err)
+					continue
+				}
 				if c.HasKey(eprintId) {
 					if err := c.UpdateObject(eprintId, record); err != nil {
 						return fmt.Errorf("error saving %q, line %d, %s", eprintId, i+1, err)
@@ -1392,6 +1410,9 @@ func (app *EPrint2Rdm) Run(in io.Reader, out io.Writer, eout io.Writer, username
 		if err := CrosswalkEPrintToRecord(eprints.EPrint[0], record, resourceTypes, contributorTypes); err != nil {
 			return err
 		}
+		if err := app.Fixups.Apply(record); err != nil {
+			return err
+		}
 		if cName != "" {
 			if c.HasKey(eprintId) {
 				if err := c.UpdateObject(eprintId, record); err != nil {
@@ -1412,3 +1433,44 @@ func (app *EPrint2Rdm) Run(in io.Reader, out io.Writer, eout io.Writer, username
 	}
 	return nil
 }
+
+// RunMigrate implements the eprint2rdm migrate mode. It migrates the
+// EPrint ids in eprintIds and those listed one per line in the file
+// idList to RDM, see Migrate.
+//
+// ```
+//
+//	app := new(irdmtools.EPrint2Rdm)
+//	if err := app.Configure("irdmtools.json", "", false); err != nil {
+//		// ... handle error ...
+//	}
+//	err := app.RunMigrate([]string{"85542"}, "", "", "", "migrated_records.csv")
+//	if err != nil {
+//		// ... handle error ...
+//	}
+//
+// ```
+func (app *EPrint2Rdm) RunMigrate(eprintIds []string, idList string, resourceTypesFName string, contributorTypesFName string, logName string) error {
+	if app.Cfg == nil {
+		return fmt.Errorf("application has no configuration set")
+	}
+	if idList != "" {
+		src, err := os.ReadFile(idList)
+		if err != nil {
+			return fmt.Errorf("read id list failed, %q, %s", idList, err)
+		}
+		for _, line := range strings.Split(string(src), "\n") {
+			if eprintId := strings.TrimSpace(line); eprintId != "" {
+				eprintIds = append(eprintIds, eprintId)
+			}
+		}
+	}
+	if len(eprintIds) == 0 {
+		return fmt.Errorf("no EPrint ids to migrate")
+	}
+	resourceTypes, contributorTypes, err := loadCrosswalkTypeMaps(resourceTypesFName, contributorTypesFName)
+	if err != nil {
+		return err
+	}
+	return app.Migrate(eprintIds, resourceTypes, contributorTypes, logName)
+}
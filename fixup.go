@@ -0,0 +1,457 @@
+package irdmtools
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	// Caltech Library Packages
+	"github.com/caltechlibrary/simplified"
+)
+
+// FixupRule is a named clean up applied to a record after it has been
+// crosswalked and before it is loaded into RDM.
+type FixupRule struct {
+	// Name is used to select the rule, e.g. on the command line
+	Name string
+	// Description explains what the rule does
+	Description string
+	// Fixup modifies the record in place. An error is returned if the
+	// record can't be fixed.
+	Fixup func(rec *simplified.Record, vocabularies Vocabularies) error
+}
+
+// fixupRules holds the registered rules in the order they are applied.
+var fixupRules = []*FixupRule{
+	{
+		Name:        "drop-empty-identifiers",
+		Description: "remove identifiers missing a scheme or value from the record, related identifiers and people",
+		Fixup:       fixupDropEmptyIdentifiers,
+	},
+	{
+		Name:        "normalize-doi",
+		Description: "trim doi.org URLs and doi: prefixes from DOI and remove related identifiers matching the record's DOI",
+		Fixup:       fixupNormalizeDOI,
+	},
+	{
+		Name:        "normalize-issn",
+		Description: "format journal and identifier ISSN as NNNN-NNNN",
+		Fixup:       fixupNormalizeISSN,
+	},
+	{
+		Name:        "person-names",
+		Description: "set a missing name to \"family, given\" for creators and contributors",
+		Fixup:       fixupPersonNames,
+	},
+	{
+		Name:        "dedupe-creators",
+		Description: "merge creators and contributors repeated with the same ORCID, clpid or name",
+		Fixup:       fixupDedupeCreators,
+	},
+	{
+		Name:        "title-types",
+		Description: "set missing additional title types to alternative-title",
+		Fixup:       fixupTitleTypes,
+	},
+	{
+		Name:        "contributor-roles",
+		Description: "map contributor roles not in the roles vocabulary to other",
+		Fixup:       fixupContributorRoles,
+	},
+	{
+		Name:        "award-titles",
+		Description: "add an empty title to awards without one",
+		Fixup:       fixupAwardTitles,
+	},
+	{
+		Name:        "vocabulary-ids",
+		Description: "check the record's vocabulary ids, e.g. resource type and roles, see ValidateRecord",
+		Fixup:       fixupVocabularyIds,
+	},
+}
+
+// RegisterFixupRule adds a rule to the end of the fixup rules. An error
+// is returned if a rule with the same name is already registered.
+func RegisterFixupRule(rule *FixupRule) error {
+	if rule == nil || rule.Name == "" || rule.Fixup == nil {
+		return fmt.Errorf("fixup rule requires a name and fixup function")
+	}
+	if GetFixupRule(rule.Name) != nil {
+		return fmt.Errorf("fixup rule %q already registered", rule.Name)
+	}
+	fixupRules = append(fixupRules, rule)
+	return nil
+}
+
+// GetFixupRule returns the named rule or nil if it is not registered.
+func GetFixupRule(name string) *FixupRule {
+	for _, rule := range fixupRules {
+		if rule.Name == name {
+			return rule
+		}
+	}
+	return nil
+}
+
+// FixupRules returns the registered rules in the order they are applied.
+func FixupRules() []*FixupRule {
+	return append([]*FixupRule{}, fixupRules...)
+}
+
+// Fixups holds the rules selected to apply to records and the
+// vocabularies they check against.
+type Fixups struct {
+	Rules        []*FixupRule
+	Vocabularies Vocabularies
+}
+
+// NewFixups takes a comma separated list of rule names, or "all", and
+// returns the selected rules using the default vocabularies. Rules
+// are applied in registration order regardless of the order listed.
+//
+// ```
+// fixups, err := NewFixups("drop-empty-identifiers,normalize-issn")
+// if err != nil {
+//    // ... handle error ...
+// }
+// if err := fixups.Apply(rec); err != nil {
+//    // ... handle error ...
+// }
+// ```
+func NewFixups(names string) (*Fixups, error) {
+	selected := map[string]bool{}
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		switch {
+		case name == "":
+			continue
+		case name == "all":
+			for _, rule := range fixupRules {
+				selected[rule.Name] = true
+			}
+		case GetFixupRule(name) == nil:
+			return nil, fmt.Errorf("unknown fixup rule %q", name)
+		default:
+			selected[name] = true
+		}
+	}
+	vocabularies, err := DefaultVocabularies()
+	if err != nil {
+		return nil, err
+	}
+	fixups := &Fixups{Vocabularies: vocabularies}
+	for _, rule := range fixupRules {
+		if selected[rule.Name] {
+			fixups.Rules = append(fixups.Rules, rule)
+		}
+	}
+	return fixups, nil
+}
+
+// Apply runs the selected rules against the record. Tombstone records
+// are left as is.
+func (fixups *Fixups) Apply(rec *simplified.Record) error {
+	if fixups == nil || rec == nil || rec.Metadata == nil || rec.Tombstone != nil {
+		return nil
+	}
+	for _, rule := range fixups.Rules {
+		if err := rule.Fixup(rec, fixups.Vocabularies); err != nil {
+			return fmt.Errorf("%s (%s), %s", rec.ID, rule.Name, err)
+		}
+	}
+	return nil
+}
+
+// FixupRecord applies the named rules (comma separated or "all") to rec
+// using the default vocabularies.
+func FixupRecord(rec *simplified.Record, names string) error {
+	fixups, err := NewFixups(names)
+	if err != nil {
+		return err
+	}
+	return fixups.Apply(rec)
+}
+
+// keepIdentifiers returns the identifiers with both a scheme and value
+// trimming the whitespace around them.
+func keepIdentifiers(identifiers []*simplified.Identifier) []*simplified.Identifier {
+	kept := []*simplified.Identifier{}
+	for _, identifier := range identifiers {
+		if identifier == nil {
+			continue
+		}
+		identifier.Scheme = strings.TrimSpace(identifier.Scheme)
+		identifier.Identifier = strings.TrimSpace(identifier.Identifier)
+		if identifier.Scheme == "" || identifier.Identifier == "" {
+			continue
+		}
+		kept = append(kept, identifier)
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	return kept
+}
+
+func fixupDropEmptyIdentifiers(rec *simplified.Record, vocabularies Vocabularies) error {
+	rec.Metadata.Identifiers = keepIdentifiers(rec.Metadata.Identifiers)
+	rec.Metadata.RelatedIdentifiers = keepIdentifiers(rec.Metadata.RelatedIdentifiers)
+	for _, people := range [][]*simplified.Creator{rec.Metadata.Creators, rec.Metadata.Contributors} {
+		for _, person := range people {
+			if person != nil && person.PersonOrOrg != nil {
+				person.PersonOrOrg.Identifiers = keepIdentifiers(person.PersonOrOrg.Identifiers)
+			}
+		}
+	}
+	for key, pid := range rec.ExternalPIDs {
+		if pid == nil || strings.TrimSpace(pid.Identifier) == "" {
+			delete(rec.ExternalPIDs, key)
+		}
+	}
+	return nil
+}
+
+// normalizeDOI trims whitespace, resolver URLs and the doi: prefix from a
+// DOI. An empty string is returned if the result isn't a DOI.
+func normalizeDOI(doi string) string {
+	doi = strings.TrimSpace(doi)
+	lower := strings.ToLower(doi)
+	for _, prefix := range []string{"https://doi.org/", "http://doi.org/", "https://dx.doi.org/", "http://dx.doi.org/", "doi.org/", "doi:"} {
+		if strings.HasPrefix(lower, prefix) {
+			doi = strings.TrimSpace(doi[len(prefix):])
+			break
+		}
+	}
+	if !strings.HasPrefix(doi, "10.") || !strings.Contains(doi, "/") {
+		return ""
+	}
+	return doi
+}
+
+func fixupNormalizeDOI(rec *simplified.Record, vocabularies Vocabularies) error {
+	recordDOI := ""
+	if pid, ok := rec.ExternalPIDs["doi"]; ok && pid != nil {
+		if doi := normalizeDOI(pid.Identifier); doi != "" {
+			pid.Identifier = doi
+			recordDOI = doi
+		}
+	}
+	for _, identifier := range rec.Metadata.Identifiers {
+		if identifier != nil && identifier.Scheme == "doi" {
+			if doi := normalizeDOI(identifier.Identifier); doi != "" {
+				identifier.Identifier = doi
+			}
+		}
+	}
+	related := []*simplified.Identifier{}
+	for _, identifier := range rec.Metadata.RelatedIdentifiers {
+		if identifier != nil && identifier.Scheme == "doi" {
+			if doi := normalizeDOI(identifier.Identifier); doi != "" {
+				if recordDOI != "" && strings.EqualFold(doi, recordDOI) {
+					continue
+				}
+				identifier.Identifier = doi
+			}
+		}
+		related = append(related, identifier)
+	}
+	if len(related) > 0 {
+		rec.Metadata.RelatedIdentifiers = related
+	} else {
+		rec.Metadata.RelatedIdentifiers = nil
+	}
+	return nil
+}
+
+var issnRe = regexp.MustCompile(`^[0-9]{7}[0-9X]$`)
+
+// normalizeISSN returns an ISSN in the form NNNN-NNNN with an upper case
+// check digit. The value is returned unchanged if it isn't an ISSN.
+func normalizeISSN(issn string) string {
+	s := strings.ToUpper(strings.TrimSpace(issn))
+	s = strings.TrimPrefix(s, "ISSN")
+	s = strings.NewReplacer("-", "", " ", "", ":", "").Replace(s)
+	if !issnRe.MatchString(s) {
+		return strings.TrimSpace(issn)
+	}
+	return s[0:4] + "-" + s[4:]
+}
+
+func fixupNormalizeISSN(rec *simplified.Record, vocabularies Vocabularies) error {
+	if journal, ok := rec.CustomFields["journal:journal"].(map[string]interface{}); ok {
+		if issn, ok := journal["issn"].(string); ok {
+			journal["issn"] = normalizeISSN(issn)
+		}
+	}
+	for _, identifiers := range [][]*simplified.Identifier{rec.Metadata.Identifiers, rec.Metadata.RelatedIdentifiers} {
+		for _, identifier := range identifiers {
+			if identifier != nil && (identifier.Scheme == "issn" || identifier.Scheme == "eissn") {
+				identifier.Identifier = normalizeISSN(identifier.Identifier)
+			}
+		}
+	}
+	return nil
+}
+
+func fixupPersonNames(rec *simplified.Record, vocabularies Vocabularies) error {
+	for _, people := range [][]*simplified.Creator{rec.Metadata.Creators, rec.Metadata.Contributors} {
+		for _, person := range people {
+			if person == nil || person.PersonOrOrg == nil || person.PersonOrOrg.Name != "" {
+				continue
+			}
+			p := person.PersonOrOrg
+			switch {
+			case p.FamilyName != "" && p.GivenName != "":
+				p.Name = fmt.Sprintf("%s, %s", p.FamilyName, p.GivenName)
+			case p.FamilyName != "":
+				p.Name = p.FamilyName
+			}
+		}
+	}
+	return nil
+}
+
+// creatorKey returns the key used to recognize a repeated creator, the
+// ORCID or clpid if available, otherwise the name.
+func creatorKey(creator *simplified.Creator) string {
+	p := creator.PersonOrOrg
+	for _, identifier := range p.Identifiers {
+		if identifier != nil && identifier.Scheme == "orcid" && identifier.Identifier != "" {
+			return "orcid:" + identifier.Identifier
+		}
+	}
+	if p.ID != "" {
+		return "clpid:" + p.ID
+	}
+	name := p.Name
+	if p.FamilyName != "" || p.GivenName != "" {
+		name = p.FamilyName + ", " + p.GivenName
+	}
+	name = strings.ToLower(strings.Join(strings.Fields(name), " "))
+	if name == "" || name == "," {
+		return ""
+	}
+	roleID := ""
+	if creator.Role != nil {
+		roleID = creator.Role.ID
+	}
+	return "name:" + name + ":" + roleID
+}
+
+// dedupeCreators merges repeated creators, keeping the first and adding
+// any identifiers and affiliations from the repeats.
+func dedupeCreators(creators []*simplified.Creator) []*simplified.Creator {
+	seen := map[string]*simplified.Creator{}
+	kept := []*simplified.Creator{}
+	for _, creator := range creators {
+		if creator == nil || creator.PersonOrOrg == nil {
+			continue
+		}
+		key := creatorKey(creator)
+		first, ok := seen[key]
+		if key == "" || !ok {
+			if key != "" {
+				seen[key] = creator
+			}
+			kept = append(kept, creator)
+			continue
+		}
+		if first.PersonOrOrg.ID == "" {
+			first.PersonOrOrg.ID = creator.PersonOrOrg.ID
+		}
+		for _, identifier := range creator.PersonOrOrg.Identifiers {
+			if !hasIdentifier(first.PersonOrOrg.Identifiers, identifier) {
+				first.PersonOrOrg.Identifiers = append(first.PersonOrOrg.Identifiers, identifier)
+			}
+		}
+		for _, affiliation := range creator.Affiliations {
+			if !hasAffiliation(first.Affiliations, affiliation) {
+				first.Affiliations = append(first.Affiliations, affiliation)
+			}
+		}
+	}
+	return kept
+}
+
+func hasIdentifier(identifiers []*simplified.Identifier, target *simplified.Identifier) bool {
+	if target == nil {
+		return true
+	}
+	for _, identifier := range identifiers {
+		if identifier != nil && identifier.Scheme == target.Scheme && identifier.Identifier == target.Identifier {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAffiliation(affiliations []*simplified.Affiliation, target *simplified.Affiliation) bool {
+	if target == nil {
+		return true
+	}
+	for _, affiliation := range affiliations {
+		if affiliation != nil && affiliation.ID == target.ID && affiliation.Name == target.Name {
+			return true
+		}
+	}
+	return false
+}
+
+func fixupDedupeCreators(rec *simplified.Record, vocabularies Vocabularies) error {
+	if rec.Metadata.Creators != nil {
+		rec.Metadata.Creators = dedupeCreators(rec.Metadata.Creators)
+	}
+	if rec.Metadata.Contributors != nil {
+		rec.Metadata.Contributors = dedupeCreators(rec.Metadata.Contributors)
+	}
+	return nil
+}
+
+func fixupTitleTypes(rec *simplified.Record, vocabularies Vocabularies) error {
+	for _, title := range rec.Metadata.AdditionalTitles {
+		if title != nil && (title.Type == nil || title.Type.ID == "") {
+			title.Type = &simplified.Type{
+				ID:    "alternative-title",
+				Title: map[string]string{"en": "Alternative Title"},
+			}
+		}
+	}
+	return nil
+}
+
+func fixupContributorRoles(rec *simplified.Record, vocabularies Vocabularies) error {
+	for _, contributor := range rec.Metadata.Contributors {
+		if contributor == nil {
+			continue
+		}
+		if contributor.Role == nil {
+			contributor.Role = new(simplified.Role)
+		}
+		if contributor.Role.ID == "" || !vocabularies.Has("contributorsroles", contributor.Role.ID) {
+			contributor.Role.ID = "other"
+		}
+	}
+	return nil
+}
+
+func fixupAwardTitles(rec *simplified.Record, vocabularies Vocabularies) error {
+	for _, funder := range rec.Metadata.Funding {
+		if funder != nil && funder.Award != nil && funder.Award.Title == nil {
+			// NOTE: RDM requires a title for an award, see irdm/fixups.py
+			funder.Award.Title = &simplified.TitleDetail{Encoding: " "}
+		}
+	}
+	return nil
+}
+
+func fixupVocabularyIds(rec *simplified.Record, vocabularies Vocabularies) error {
+	violations := ValidateRecord(rec, vocabularies)
+	if len(violations) > 0 {
+		problems := []string{}
+		for _, violation := range violations {
+			problems = append(problems, violation.String())
+		}
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
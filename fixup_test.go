@@ -0,0 +1,97 @@
+package irdmtools
+
+import (
+	"strings"
+	"testing"
+
+	// Caltech Library Packages
+	"github.com/caltechlibrary/simplified"
+)
+
+func TestNormalizeISSN(t *testing.T) {
+	for val, expected := range map[string]string{
+		"0028-0836":      "0028-0836",
+		"00280836":       "0028-0836",
+		"1234-567x":      "1234-567X",
+		"ISSN 1234 567X": "1234-567X",
+		"not an issn":    "not an issn",
+	} {
+		if got := normalizeISSN(val); got != expected {
+			t.Errorf("normalizeISSN(%q), expected %q, got %q", val, expected, got)
+		}
+	}
+}
+
+func TestFixupRecord(t *testing.T) {
+	src := []byte(`{
+    "id": "caltechauthors:1",
+    "pids": { "doi": { "identifier": "https://doi.org/10.1000/182", "provider": "external" } },
+    "custom_fields": { "journal:journal": { "issn": "1234567x" } },
+    "metadata": {
+        "resource_type": { "id": "publication-article" },
+        "title": "A title",
+        "additional_titles": [ { "title": "Another title" } ],
+        "identifiers": [
+            { "scheme": "eprintid", "identifier": "1" },
+            { "scheme": "doi", "identifier": " " }
+        ],
+        "related_identifiers": [
+            { "scheme": "doi", "identifier": "doi:10.1000/182", "relation_type": { "id": "isversionof" } },
+            { "scheme": "doi", "identifier": "10.1000/183", "relation_type": { "id": "cites" } }
+        ],
+        "creators": [
+            { "person_or_org": { "type": "personal", "family_name": "Doe", "given_name": "Jane", "identifiers": [ { "scheme": "orcid", "identifier": "0000-0002-1825-0097" } ] } },
+            { "person_or_org": { "type": "personal", "family_name": "Doe", "given_name": "J.", "clpid": "Doe-J", "identifiers": [ { "scheme": "orcid", "identifier": "0000-0002-1825-0097" } ] } },
+            { "person_or_org": { "type": "personal", "family_name": "Roe", "given_name": "Richard" } }
+        ],
+        "contributors": [
+            { "person_or_org": { "type": "personal", "family_name": "Smith", "given_name": "Sam" }, "role": { "title": { "en": "thesis_advisor" } } }
+        ],
+        "funding": [ { "funder": { "name": "NSF" }, "award": { "number": "AST-1234" } } ]
+    }
+}`)
+	rec := new(simplified.Record)
+	if err := JSONUnmarshal(src, &rec); err != nil {
+		t.Fatal(err)
+	}
+	if err := FixupRecord(rec, "all"); err != nil {
+		t.Fatal(err)
+	}
+	if doi := rec.ExternalPIDs["doi"].Identifier; doi != "10.1000/182" {
+		t.Errorf("expected normalized DOI, got %q", doi)
+	}
+	if issn := rec.CustomFields["journal:journal"].(map[string]interface{})["issn"]; issn != "1234-567X" {
+		t.Errorf("expected normalized ISSN, got %q", issn)
+	}
+	if len(rec.Metadata.Identifiers) != 1 {
+		t.Errorf("expected empty identifier dropped, got %+v", rec.Metadata.Identifiers)
+	}
+	if len(rec.Metadata.RelatedIdentifiers) != 1 || rec.Metadata.RelatedIdentifiers[0].Identifier != "10.1000/183" {
+		t.Errorf("expected record DOI removed from related identifiers, got %+v", rec.Metadata.RelatedIdentifiers)
+	}
+	if len(rec.Metadata.Creators) != 2 {
+		t.Fatalf("expected repeated creator merged, got %d creators", len(rec.Metadata.Creators))
+	}
+	if p := rec.Metadata.Creators[0].PersonOrOrg; p.ID != "Doe-J" || p.Name != "Doe, Jane" {
+		t.Errorf("expected clpid merged and name set, got %+v", p)
+	}
+	if role := rec.Metadata.Contributors[0].Role; role.ID != "other" || role.Title["en"] != "thesis_advisor" {
+		t.Errorf("expected contributor role other, got %+v", role)
+	}
+	if titleType := rec.Metadata.AdditionalTitles[0].Type; titleType == nil || titleType.ID != "alternative-title" {
+		t.Errorf("expected alternative-title type, got %+v", titleType)
+	}
+	if title := rec.Metadata.Funding[0].Award.Title; title == nil {
+		t.Errorf("expected award title to be added")
+	}
+
+	// Unknown vocabulary ids are reported
+	rec.Metadata.ResourceType["id"] = "publication-unknown"
+	err := FixupRecord(rec, "vocabulary-ids")
+	if err == nil || !strings.Contains(err.Error(), ".metadata.resource_type.id") {
+		t.Errorf("expected resource type error, got %v", err)
+	}
+	if err := FixupRecord(rec, "no-such-rule"); err == nil {
+		t.Errorf("expected unknown rule error")
+	}
+}
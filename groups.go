@@ -0,0 +1,95 @@
+package irdmtools
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// GroupTerm is a term of the local groups vocabulary, e.g. a research
+// group, lab or institute.
+type GroupTerm struct {
+	ID    string            `json:"id" yaml:"id"`
+	Title map[string]string `json:"title" yaml:"title"`
+}
+
+// mapGroupField maps a column of the groups CSV file to a group. The
+// feeds groups.csv has more columns than a vocabulary needs, they are
+// ignored.
+func mapGroupField(group *GroupTerm, key string, val string) error {
+	val = strings.TrimSpace(val)
+	if val == "" {
+		// NOTE: An empty value isn't an error, we just don't map it.
+		return nil
+	}
+	switch key {
+	case "key", "id":
+		if strings.ContainsAny(val, " \t\r\n") {
+			return fmt.Errorf("%s %q contains spaces", key, val)
+		}
+		group.ID = val
+	case "name", "title":
+		group.Title["en"] = val
+	}
+	return nil
+}
+
+// uniqueTerms adds the term to terms by id, a repeated id is an error
+// unless the terms are the same.
+func uniqueTerms(terms map[string]interface{}, id string, term interface{}) error {
+	if prev, ok := terms[id]; ok {
+		if reflect.DeepEqual(prev, term) {
+			return nil
+		}
+		return fmt.Errorf("duplicate id %q", id)
+	}
+	terms[id] = term
+	return nil
+}
+
+// GroupsToVocabulary maps the rows of a groups CSV file, or a JSON array
+// if isCSV is false, to the groups vocabulary sorted by id. A group
+// without a key gets an id made from its name. Rows that can't be mapped
+// are logged and counted, if there are any an error is returned.
+//
+// ```
+// src, _ := os.ReadFile("groups.csv")
+// groups, err := GroupsToVocabulary(src, true)
+// if err != nil {
+//    // ... handle error ...
+// }
+// ```
+func GroupsToVocabulary(src []byte, isCSV bool) ([]*GroupTerm, error) {
+	rows, err := readVocabularyRows(src, isCSV)
+	if err != nil {
+		return nil, err
+	}
+	terms := map[string]interface{}{}
+	err = mapVocabularyRows(rows, func(row *vocabularyRow) error {
+		group := &GroupTerm{Title: map[string]string{}}
+		if err := row.mapColumns(func(key string, val string) error {
+			return mapGroupField(group, key, val)
+		}); err != nil {
+			return err
+		}
+		if group.Title["en"] == "" {
+			return fmt.Errorf("missing name")
+		}
+		if group.ID == "" {
+			group.ID = vocabularyId(group.Title["en"])
+		}
+		return uniqueTerms(terms, group.ID, group)
+	})
+	if err != nil {
+		return nil, err
+	}
+	groups := []*GroupTerm{}
+	for _, term := range terms {
+		groups = append(groups, term.(*GroupTerm))
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].ID < groups[j].ID
+	})
+	return groups, nil
+}
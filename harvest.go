@@ -41,12 +41,137 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	// Caltech Library packages
 	"github.com/caltechlibrary/dataset/v2"
 )
 
+// harvestFunc retrieves the object to be stored in the dataset collection
+// for a given key.
+type harvestFunc func(key string) (interface{}, error)
+
+// harvestResult holds the outcome of a harvestFunc call made by a worker.
+type harvestResult struct {
+	key string
+	obj interface{}
+	err error
+}
+
+// saveObject creates or updates the object in the collection.
+func saveObject(c *dataset.Collection, key string, obj interface{}) error {
+	if c.HasKey(key) {
+		return c.UpdateObject(key, obj)
+	}
+	return c.CreateObject(key, obj)
+}
+
+// harvestKeys retrieves the objects for keys using a pool of workers
+// and writes them to the dataset collection. Retrieval happens concurrently
+// but writes are serialized through a single writer (the calling goroutine)
+// since a dataset collection is not safe for concurrent writes. If cp is
+// not nil the outcome for each key is recorded in the checkpoint journal.
+// It returns the count of harvested records, the count of errors and an
+// error if the harvest was stopped early.
+func harvestKeys(c *dataset.Collection, cName string, keys []string, workers int, fetch harvestFunc, cp *Checkpoint, l *log.Logger) (int, int, error) {
+	const maxErrors = 100
+	if workers < 1 {
+		workers = 1
+	}
+	tasks := make(chan string)
+	results := make(chan *harvestResult, workers)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range tasks {
+				obj, err := fetch(key)
+				select {
+				case results <- &harvestResult{key: key, obj: obj, err: err}:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		defer close(tasks)
+		for _, key := range keys {
+			select {
+			case tasks <- key:
+			case <-done:
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	eCnt, hCnt, tot := 0, 0, len(keys)
+	t0 := time.Now()
+	iTime, reportProgress := time.Now(), false
+	i := 0
+	for res := range results {
+		err := res.err
+		if err != nil {
+			l.Printf("failed to get (%d) %q, %s", i, res.key, err)
+			eCnt++
+		} else if err = saveObject(c, res.key, res.obj); err != nil {
+			l.Printf("failed to write %q to %s, %s", res.key, cName, err)
+			eCnt++
+		} else {
+			hCnt++
+		}
+		if cp != nil {
+			if err != nil {
+				err = cp.Failed(res.key, err)
+			} else {
+				err = cp.Finished(res.key)
+			}
+			if err != nil {
+				l.Printf("failed to update checkpoint %s, %s", cp.Name, err)
+			}
+		}
+		if eCnt > maxErrors {
+			// Tell the workers to stop then drain what is in flight.
+			close(done)
+			for range results {
+			}
+			return hCnt, eCnt, fmt.Errorf("Stopped, %d errors encountered", eCnt)
+		}
+		if iTime, reportProgress = CheckWaitInterval(iTime, time.Minute); reportProgress || i == 0 {
+			l.Printf("%s last id %q (%d/%d) %s: %s", cName, res.key, i, tot, time.Since(t0).Round(time.Second), ProgressETA(t0, i, tot))
+		}
+		i++
+	}
+	return hCnt, eCnt, nil
+}
+
+// openHarvestCheckpoint opens the checkpoint journal for cfg.CName. When
+// cfg.Resume is true the ids already finished are removed from keys.
+func openHarvestCheckpoint(cfg *Config, keys []string, l *log.Logger) (*Checkpoint, []string, error) {
+	cp, err := OpenCheckpoint(cfg.CName, cfg.Resume)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.Resume {
+		pending := cp.Pending(keys)
+		l.Printf("resuming from %s, %d of %d ids finished, %d pending", cp.Name, len(keys)-len(pending), len(keys), len(pending))
+		keys = pending
+	}
+	return cp, keys, nil
+}
+
+// Harvest takes a configuration and a JSON file of RDM record ids and
+// harvests the records into the dataset collection named in cfg.CName.
+// Records are retrieved from Postgres using cfg.Workers concurrent
+// workers sharing the connection pool. Progress is recorded in a
+// checkpoint journal, if cfg.Resume is true then finished ids are skipped.
 func Harvest(cfg *Config, fName string, debug bool) error {
 	cName := cfg.CName
 	if cName == "" {
@@ -69,63 +194,37 @@ func Harvest(cfg *Config, fName string, debug bool) error {
 		return err
 	}
 	l := log.New(os.Stderr, "", 1)
-	const maxErrors = 100
-	eCnt, hCnt, tot := 0, 0, len(recordIds)
+	cp, recordIds, err := openHarvestCheckpoint(cfg, recordIds, l)
+	if err != nil {
+		return err
+	}
+	defer cp.Close()
 	if debug {
-		l.Printf("%d record ids", tot)
+		l.Printf("%d record ids, %d workers", len(recordIds), cfg.harvestWorkers())
 	}
 	t0 := time.Now()
-	iTime, reportProgress := time.Now(), false
-	//fmt.Printf("DEBUG are we using the RDM REST API? %t\n", (cfg.InvenioDbHost == ""))
 	connStr := cfg.MakeDSN()
 	if connStr == "" {
 		return fmt.Errorf("ERROR: harvesting through JSON API, not supported")
-	} 
+	}
 	cfg.rl = nil
 	// Need to open our Postgres connection and defer the closing of it.
 	db, err := sql.Open("postgres", connStr)
 	if err != nil {
-		return  err
+		return err
 	}
 	defer db.Close()
+	db.SetMaxIdleConns(cfg.harvestWorkers())
 	cfg.pgDB = db
-	//fmt.Printf("DEBUG is cfg.rl nil? %t, is cfg.pgDB nil? %t\n", (cfg.rl == nil), (cfg.pgDB == nil))
-	for i, id := range recordIds {
-		rec, err := GetRecord(cfg, id, false)
-		if err != nil {
-			msg := fmt.Sprintf("%s", err)
-			if strings.HasPrefix(msg, "429 ") {
-				cfg.rl.Fprintf(os.Stderr)
-			}
-			log.Printf("failed to get (%d) %q, %s", i, id, err)
-			eCnt++
-		} else {
-			if c.HasKey(id) {
-				if err := c.UpdateObject(id, rec); err != nil {
-					log.Printf("failed to write %q to %s, %s", id, cName, err)
-					eCnt++
-				} else {
-					hCnt++
-				}
-			} else {
-				if err := c.CreateObject(id, rec); err != nil {
-					log.Printf("failed to write %q to %s, %s", id, cName, err)
-					eCnt++
-				} else {
-					hCnt++
-				}
-			}
-		}
-		if eCnt > maxErrors {
-			return fmt.Errorf("Stopped, %d errors encountered", eCnt)
-		}
-		// The rest API seems to have two rate limits, 5000 requests per hour and 500 requests per minute
-		if iTime, reportProgress = CheckWaitInterval(iTime, time.Minute); reportProgress || i == 0 {
-			log.Printf("%s last id %q (%d/%d) %s: %s", cName, id, i, tot, time.Since(t0).Round(time.Second), ProgressETA(t0, i, tot))
-		}
+	defer func() { cfg.pgDB = nil }()
+	fetch := func(id string) (interface{}, error) {
+		return GetRecord(cfg, id, false)
 	}
-	log.Printf("%d harvested, %d errors, running time %s", hCnt, eCnt, time.Since(t0).Round(time.Second))
-	cfg.pgDB = nil
+	hCnt, eCnt, err := harvestKeys(c, cName, recordIds, cfg.harvestWorkers(), fetch, cp, l)
+	if err != nil {
+		return err
+	}
+	l.Printf("%d harvested, %d errors, running time %s", hCnt, eCnt, time.Since(t0).Round(time.Second))
 	return nil
 }
 
@@ -137,7 +236,7 @@ func harvestEPrintRecordsFromMySQL(cfg *Config, recordIds []int, asCitation bool
 	}
 	defer c.Close()
 
-	var dsn string	
+	var dsn string
 	if cfg.EPrintDbHost == "localhost" {
 		dsn = fmt.Sprintf("%s:%s@/%s", cfg.EPrintDbUser, cfg.EPrintDbPassword, cfg.RepoID)
 	} else {
@@ -148,76 +247,57 @@ func harvestEPrintRecordsFromMySQL(cfg *Config, recordIds []int, asCitation bool
 		return err
 	}
 	defer db.Close()
+	db.SetMaxIdleConns(cfg.harvestWorkers())
 	l := log.New(os.Stderr, "", 1)
-	const maxErrors = 100
-	eCnt, hCnt, tot := 0, 0, len(recordIds)
+	cp, keys, err := openHarvestCheckpoint(cfg, eprintKeys(recordIds), l)
+	if err != nil {
+		return err
+	}
+	defer cp.Close()
 	if debug {
-		l.Printf("%d record ids", tot)
+		l.Printf("%d record ids, %d workers", len(keys), cfg.harvestWorkers())
 	}
 	t0 := time.Now()
-	iTime, reportProgress := time.Now(), false
-	for i, eprintid := range recordIds {
-		id := fmt.Sprintf("%d", eprintid)
+	fetch := func(id string) (interface{}, error) {
+		eprintid, err := strconv.Atoi(id)
 		if err != nil {
-			l.Printf("failed to convert %d to string, %s", eprintid, err)
-			continue
+			return nil, err
 		}
 		eprint, err := SQLReadEPrint(db, cfg.EPrintHost, eprintid)
 		if err != nil {
-			l.Printf("failed to get (%d) %d, %s", i, eprintid, err)
-			eCnt++
-		} else if asCitation {
-			// NOTE: I need to convert eprint to citation record
+			return nil, err
+		}
+		if asCitation {
 			citation := new(Citation)
 			if err := citation.CrosswalkEPrint(cName, id, cfg.EPrintBaseURL, eprint); err != nil {
-				l.Printf("failed to convert eprint %s to citation, %s", id, err)
-				eCnt++
-			} else {
-				if c.HasKey(id) {
-					if err := c.UpdateObject(id, citation); err != nil {
-						l.Printf("failed to write %d to %s, %s", eprintid, cName, err)
-						eCnt++
-					} else {
-						hCnt++
-					}
-				} else {
-					if err := c.CreateObject(id, citation); err != nil {
-						l.Printf("failed to write %d to %s, %s", eprintid, cName, err)
-						eCnt++
-					} else {
-						hCnt++
-					}
-				}
-			}
-		} else {
-			if c.HasKey(id) {
-				if err := c.UpdateObject(id, eprint); err != nil {
-					l.Printf("failed to write %d to %s, %s", eprintid, cName, err)
-					eCnt++
-				} else {
-					hCnt++
-				}
-			} else {
-				if err := c.CreateObject(id, eprint); err != nil {
-					l.Printf("failed to write %d to %s, %s", eprintid, cName, err)
-					eCnt++
-				} else {
-					hCnt++
-				}
+				return nil, fmt.Errorf("failed to convert eprint %s to citation, %s", id, err)
 			}
+			return citation, nil
 		}
-		if eCnt > maxErrors {
-			return fmt.Errorf("Stopped, %d errors encountered", eCnt)
-		}
-		// The rest API seems to have two rate limits, 5000 requests per hour and 500 requests per minute
-		if iTime, reportProgress = CheckWaitInterval(iTime, time.Minute); reportProgress || i == 0 {
-			l.Printf("%s last id %d (%d/%d) %s: %s", cName, eprintid, i, tot, time.Since(t0).Round(time.Second), ProgressETA(t0, i, tot))
-		}
+		return eprint, nil
+	}
+	hCnt, eCnt, err := harvestKeys(c, cName, keys, cfg.harvestWorkers(), fetch, cp, l)
+	if err != nil {
+		return err
 	}
 	l.Printf("%d harvested, %d errors, running time %s", hCnt, eCnt, time.Since(t0).Round(time.Second))
 	return nil
 }
 
+// eprintKeys converts a list of eprint ids into dataset keys.
+func eprintKeys(recordIds []int) []string {
+	keys := make([]string, len(recordIds))
+	for i, eprintid := range recordIds {
+		keys[i] = strconv.Itoa(eprintid)
+	}
+	return keys
+}
+
+// HarvestEPrintRecords harvests the list of eprint ids into the dataset
+// collection named in cfg.CName. If the EPrints MySQL database is configured
+// it is used with cfg.Workers concurrent workers, otherwise the rate limited
+// EPrints REST API is used one record at a time. Progress is recorded in a
+// checkpoint journal, if cfg.Resume is true then finished ids are skipped.
 func HarvestEPrintRecords(cfg *Config, recordIds []int, asCitation bool, debug bool) error {
 	// Check if we can harvest directly from EPrnits MySQL database.
 	if cfg.EPrintDbHost != "" && cfg.EPrintDbUser != "" && cfg.EPrintDbPassword != "" {
@@ -236,77 +316,51 @@ func HarvestEPrintRecords(cfg *Config, recordIds []int, asCitation bool, debug b
 	}
 	defer c.Close()
 	l := log.New(os.Stderr, "", 1)
-	const maxErrors = 100
-	eCnt, hCnt, tot := 0, 0, len(recordIds)
+	cp, keys, err := openHarvestCheckpoint(cfg, eprintKeys(recordIds), l)
+	if err != nil {
+		return err
+	}
+	defer cp.Close()
 	if debug {
-		l.Printf("%d record ids", tot)
+		l.Printf("%d record ids", len(keys))
 	}
 	t0 := time.Now()
-	iTime, reportProgress := time.Now(), false
 	cfg.rl = new(RateLimit)
 	timeout := time.Duration(timeoutSeconds)
-	for i, eprintid := range recordIds {
-		id := strconv.Itoa(eprintid)
+	fetch := func(id string) (interface{}, error) {
+		eprintid, err := strconv.Atoi(id)
+		if err != nil {
+			return nil, err
+		}
 		rec, err := GetEPrint(cfg, eprintid, timeout, 3)
 		if err != nil {
-			msg := fmt.Sprintf("%s", err)
-			if strings.HasPrefix(msg, "429 ") {
+			if strings.HasPrefix(fmt.Sprintf("%s", err), "429 ") {
 				cfg.rl.Fprintf(os.Stderr)
 			}
-			l.Printf("failed to get (%d) %q, %s", i, id, err)
-			eCnt++
-		} else if asCitation {
-			// NOTE: I need to convert rec to citation format
+			return nil, err
+		}
+		if asCitation {
 			citation := new(Citation)
 			if err := citation.CrosswalkEPrint(cName, id, cfg.EPrintBaseURL, rec.EPrint[0]); err != nil {
-					l.Printf("failed to convert EPrint %s to citation, %s", id, err)
-					eCnt++
-			} else {
-				if c.HasKey(id) {
-					if err := c.UpdateObject(id, citation); err != nil {
-						l.Printf("failed to write %q to %s, %s", id, cName, err)
-						eCnt++
-					} else {
-						hCnt++
-					}
-				} else {
-					if err := c.CreateObject(id, citation); err != nil {
-						l.Printf("failed to write %q to %s, %s", id, cName, err)
-						eCnt++
-					} else {
-						hCnt++
-					}
-				}
-			}
-		} else {
-			if c.HasKey(id) {
-				if err := c.UpdateObject(id, rec); err != nil {
-					l.Printf("failed to write %q to %s, %s", id, cName, err)
-					eCnt++
-				} else {
-					hCnt++
-				}
-			} else {
-				if err := c.CreateObject(id, rec); err != nil {
-					l.Printf("failed to write %q to %s, %s", id, cName, err)
-					eCnt++
-				} else {
-					hCnt++
-				}
+				return nil, fmt.Errorf("failed to convert EPrint %s to citation, %s", id, err)
 			}
+			return citation, nil
 		}
-		if eCnt > maxErrors {
-			return fmt.Errorf("Stopped, %d errors encountered", eCnt)
-		}
-		// The rest API seems to have two rate limits, 5000 requests per hour and 500 requests per minute
-		if iTime, reportProgress = CheckWaitInterval(iTime, time.Minute); reportProgress || i == 0 {
-			l.Printf("%s last id %q (%d/%d) %s: %s", cName, id, i, tot, time.Since(t0).Round(time.Second).String(), ProgressETA(t0, i, tot))
-		}
+		return rec, nil
+	}
+	// NOTE: The REST API is rate limited so we stick with a single worker.
+	hCnt, eCnt, err := harvestKeys(c, cName, keys, 1, fetch, cp, l)
+	if err != nil {
+		return err
 	}
 	l.Printf("%d harvested, %d errors, running time %s", hCnt, eCnt, time.Since(t0).Round(time.Second).String())
 	return nil
 }
 
+// HarvestEPrints takes a JSON file of eprint ids and harvests the records
+// into the dataset collection named in cfg.CName using the EPrints REST API.
+// Progress is recorded in a checkpoint journal, if cfg.Resume is true then
+// finished ids are skipped.
 func HarvestEPrints(cfg *Config, fName string, asCitation bool, debug bool) error {
 	cName := cfg.CName
 	if cName == "" {
@@ -329,68 +383,37 @@ func HarvestEPrints(cfg *Config, fName string, asCitation bool, debug bool) erro
 		return err
 	}
 	l := log.New(os.Stderr, "", 1)
-	const maxErrors = 100
-	eCnt, hCnt, tot := 0, 0, len(recordIds)
+	cp, keys, err := openHarvestCheckpoint(cfg, eprintKeys(recordIds), l)
+	if err != nil {
+		return err
+	}
+	defer cp.Close()
 	if debug {
-		l.Printf("%d record ids", tot)
+		l.Printf("%d record ids", len(keys))
 	}
 	t0 := time.Now()
-	iTime, reportProgress := time.Now(), false
 	cfg.rl = new(RateLimit)
 	timeout := time.Duration(timeoutSeconds)
-	for i, eprintid := range recordIds {
-		id := strconv.Itoa(eprintid)
+	fetch := func(id string) (interface{}, error) {
+		eprintid, err := strconv.Atoi(id)
+		if err != nil {
+			return nil, err
+		}
+		// FIXME: when asCitation is true rec should be converted to citation record.
 		rec, err := GetEPrint(cfg, eprintid, timeout, 3)
 		if err != nil {
-			msg := fmt.Sprintf("%s", err)
-			if strings.HasPrefix(msg, "429 ") {
+			if strings.HasPrefix(fmt.Sprintf("%s", err), "429 ") {
 				cfg.rl.Fprintf(os.Stderr)
 			}
-			log.Printf("failed to get (%d) %q, %s", i, id, err)
-			eCnt++
-		} else if asCitation {
-			// FIXME: convert rec to citation record.
-			citation := rec
-			if c.HasKey(id) {
-				if err := c.UpdateObject(id, citation); err != nil {
-					log.Printf("failed to write %q to %s, %s", id, cName, err)
-					eCnt++
-				} else {
-					hCnt++
-				}
-			} else {
-				if err := c.CreateObject(id, citation); err != nil {
-					log.Printf("failed to write %q to %s, %s", id, cName, err)
-					eCnt++
-				} else {
-					hCnt++
-				}
-			}
-	    } else {
-			if c.HasKey(id) {
-				if err := c.UpdateObject(id, rec); err != nil {
-					log.Printf("failed to write %q to %s, %s", id, cName, err)
-					eCnt++
-				} else {
-					hCnt++
-				}
-			} else {
-				if err := c.CreateObject(id, rec); err != nil {
-					log.Printf("failed to write %q to %s, %s", id, cName, err)
-					eCnt++
-				} else {
-					hCnt++
-				}
-			}
-		}
-		if eCnt > maxErrors {
-			return fmt.Errorf("Stopped, %d errors encountered", eCnt)
-		}
-		// The rest API seems to have two rate limits, 5000 requests per hour and 500 requests per minute
-		if iTime, reportProgress = CheckWaitInterval(iTime, time.Minute); reportProgress || i == 0 {
-			log.Printf("%s last id %q (%d/%d) %s: %s", cName, id, i, tot, time.Since(t0).Round(time.Second), ProgressETA(t0, i, tot))
+			return nil, err
 		}
+		return rec, nil
 	}
-	log.Printf("%d harvested, %d errors, running time %s", hCnt, eCnt, time.Since(t0).Round(time.Second))
+	// NOTE: The REST API is rate limited so we stick with a single worker.
+	hCnt, eCnt, err := harvestKeys(c, cName, keys, 1, fetch, cp, l)
+	if err != nil {
+		return err
+	}
+	l.Printf("%d harvested, %d errors, running time %s", hCnt, eCnt, time.Since(t0).Round(time.Second))
 	return nil
 }
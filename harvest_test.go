@@ -35,8 +35,16 @@
 package irdmtools
 
 import (
+	"fmt"
+	"io"
+	"log"
 	"os"
+	"path"
+	"strconv"
 	"testing"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/dataset/v2"
 )
 
 func Test03Harvest(t *testing.T) {
@@ -62,3 +70,53 @@ func Test03Harvest(t *testing.T) {
 		t.Errorf("Havest(cfg, %q), %s", idsFName, err)
 	}
 }
+
+func TestHarvestKeys(t *testing.T) {
+	cName := path.Join(t.TempDir(), "harvest_test.ds")
+	c, err := dataset.Init(cName, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	keys := []string{}
+	for i := 1; i <= 50; i++ {
+		keys = append(keys, strconv.Itoa(i))
+	}
+	fetch := func(key string) (interface{}, error) {
+		i, _ := strconv.Atoi(key)
+		if i%10 == 0 {
+			return nil, fmt.Errorf("no record for %s", key)
+		}
+		return map[string]interface{}{"id": key}, nil
+	}
+	l := log.New(io.Discard, "", 0)
+	hCnt, eCnt, err := harvestKeys(c, cName, keys, 4, fetch, nil, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hCnt != 45 || eCnt != 5 {
+		t.Errorf("expected 45 harvested and 5 errors, got %d and %d", hCnt, eCnt)
+	}
+	for _, key := range keys {
+		i, _ := strconv.Atoi(key)
+		if c.HasKey(key) == (i%10 == 0) {
+			t.Errorf("unexpected state for key %q in %s", key, cName)
+		}
+	}
+
+	// Make sure we stop once we exceed our error limit
+	failAll := func(key string) (interface{}, error) {
+		return nil, fmt.Errorf("failed %s", key)
+	}
+	keys = []string{}
+	for i := 0; i < 500; i++ {
+		keys = append(keys, strconv.Itoa(i))
+	}
+	_, eCnt, err = harvestKeys(c, cName, keys, 4, failAll, nil, l)
+	if err == nil {
+		t.Errorf("expected harvest to stop with an error")
+	}
+	if eCnt >= len(keys) {
+		t.Errorf("expected harvest to stop early, %d errors", eCnt)
+	}
+}
@@ -400,6 +400,13 @@ func deleteFile(token string, uri string, fName string, expectedStatusCode int,
 // }
 // ```
 func CheckDOI(cfg *Config, doi string) ([]map[string]interface{}, error) {
+	// ?q=pids.doi.identifier:"10.1126/science.82.2123.219"&allversions=true
+	return queryAllRecords(cfg, fmt.Sprintf("pids.doi.identifier:%q", doi))
+}
+
+// queryAllRecords returns all the versions of records matching the
+// query string, paging through the results of RDM's search API.
+func queryAllRecords(cfg *Config, queryString string) ([]map[string]interface{}, error) {
 	// Make sure we have a URL
 	u, err := url.Parse(cfg.InvenioAPI)
 	if err != nil {
@@ -407,11 +414,10 @@ func CheckDOI(cfg *Config, doi string) ([]map[string]interface{}, error) {
 	}
 	hName := u.Host
 	// Setup our query parameters, i.e. q=*
-	// ?q=pids.doi.identifier:"10.1126/science.82.2123.219"&allversions=true
 	u.Path = "/api/records"
 	
 	q := url.Values{}
-	q.Set("q", fmt.Sprintf("pids.doi.identifier:%q", doi))
+	q.Set("q", queryString)
 	q.Set("allversions", "true")
 	uri := fmt.Sprintf("%s?%s", u.String(), q.Encode())
 	tot := 0
@@ -441,7 +447,7 @@ func CheckDOI(cfg *Config, doi string) ([]map[string]interface{}, error) {
 				records = append(records, hit)
 			}
 			tot = results.Hits.Total
-			dbgPrintf(cfg, "(%d/%d) %s\n", len(records), tot, doi)
+			dbgPrintf(cfg, "(%d/%d) %s\n", len(records), tot, queryString)
 		}
 		if results.Links != nil && results.Links.Self != results.Links.Next {
 			uri = results.Links.Next
@@ -1149,7 +1155,15 @@ func PublishRecordVersion(cfg *Config, recordId string, version string, pubDate
 // fmt.Printf("%+v\n", draft)
 // ```
 func NewDraft(cfg *Config, recordId string) (map[string]interface{}, error) {
-	// FIXME: Need to figure out how to do this with direct database access
+	// NOTE: If a draft already exists we can return it from Postgres.
+	// Creating a draft from a published record means locking and linking
+	// the files bucket so that is left to the RDM API.
+	if usePgDrafts(cfg) {
+		if draft, err := getDraftFromPg(cfg.pgDB, recordId); err == nil {
+			dropEmptyDOI(draft)
+			return draft, nil
+		}
+	}
 
 	// Make sure we have a valid URL
 	u, err := url.Parse(cfg.InvenioAPI)
@@ -1188,8 +1202,6 @@ func NewDraft(cfg *Config, recordId string) (map[string]interface{}, error) {
 // fmt.Printf("%+v\n", draft)
 // ```
 func GetDraft(cfg *Config, id string) (map[string]interface{}, error) {
-	// FIXME: Need to figure out how to do this with direct database access
-
 	// Make sure we have a valid URL
 	u, err := url.Parse(cfg.InvenioAPI)
 	if err != nil {
@@ -1207,18 +1219,29 @@ func GetDraft(cfg *Config, id string) (map[string]interface{}, error) {
 	if err := JSONUnmarshal(src, &obj); err != nil {
 		return nil, err
 	}
-	// Sometimes .pids.doi comes back with missing indentifier value
-	// but scheme is doi. If this is the case removed it.
+	dropEmptyDOI(obj)
+	return obj, nil
+}
+
+// dropEmptyDOI removes .pids.doi from a draft when it has an empty
+// identifier. Sometimes .pids.doi comes back with missing indentifier
+// value but scheme is doi.
+func dropEmptyDOI(obj map[string]interface{}) {
 	if elem, ok := obj["pids"]; ok {
-		pids := elem.(map[string]interface{})
+		pids, ok := elem.(map[string]interface{})
+		if !ok {
+			return
+		}
 		if elem, ok := pids["doi"]; ok {
-			doi := elem.(map[string]interface{})
+			doi, ok := elem.(map[string]interface{})
+			if !ok {
+				return
+			}
 			if identifier, ok := doi["identifier"]; ok && identifier.(string) == "" {
 				delete(pids, "doi")
 			}
-		} 
+		}
 	}
-	return obj, nil
 }
 
 // UpdateDraft takes a configuration object and record id,
@@ -1226,7 +1249,9 @@ func GetDraft(cfg *Config, id string) (map[string]interface{}, error) {
 // and an error value.
 //
 // The configuration object must have the InvenioAPI and
-// InvenioToken attributes set.
+// InvenioToken attributes set. If a Postgres connection is open the
+// payload's vocabulary ids are checked with ValidateRecord and the
+// draft is replaced in the database, see ReindexDrafts.
 //
 // ```
 // cfg, _ := LoadConfig("config.json")
@@ -1241,8 +1266,25 @@ func GetDraft(cfg *Config, id string) (map[string]interface{}, error) {
 // fmt.Printf("%+v\n", draft)
 // ```
 func UpdateDraft(cfg *Config, recordId string, payloadSrc []byte, debug bool) (map[string]interface{}, error) {
-	// FIXME: Need to figure out how to do this with direct database access
+	if usePgDrafts(cfg) {
+		payload := map[string]interface{}{}
+		if err := JSONUnmarshal(payloadSrc, &payload); err != nil {
+			return nil, err
+		}
+		if err := validateDraftPayload(cfg, payloadSrc); err != nil {
+			return nil, fmt.Errorf("draft %s not updated, %s", recordId, err)
+		}
+		dbgPrintf(cfg, "updating draft %s in Postgres", recordId)
+		return editDraftInPg(cfg.pgDB, recordId, func(draft map[string]interface{}) error {
+			updateDraftFromPayload(draft, payload)
+			return nil
+		})
+	}
+	return putDraft(cfg, recordId, payloadSrc, debug)
+}
 
+// putDraft updates a draft with the RDM API.
+func putDraft(cfg *Config, recordId string, payloadSrc []byte, debug bool) (map[string]interface{}, error) {
 	// Make sure we have a valid URL
 	u, err := url.Parse(cfg.InvenioAPI)
 	if err != nil {
@@ -1309,8 +1351,6 @@ func DiscardDraft(cfg *Config, recordId string, debug bool) (map[string]interfac
 // }
 // ```
 func SetFilesEnable(cfg *Config, recordId string, enable bool, debug bool) (map[string]interface{}, error) {
-	// FIXME: Need to figure out how to do this with direct database access
-
 	m, err := GetDraft(cfg, recordId)
 	if err != nil {
 		return nil, err
@@ -1360,8 +1400,6 @@ func SetFilesEnable(cfg *Config, recordId string, enable bool, debug bool) (map[
 // }
 // ```
 func SetVersion(cfg *Config, recordId string, version string, debug bool) (map[string]interface{}, error) {
-	// FIXME: Need to figure out how to do this with direct database access
-
 	m, err := GetDraft(cfg, recordId)
 	if err != nil {
 		return nil, err
@@ -1395,8 +1433,6 @@ func SetVersion(cfg *Config, recordId string, version string, debug bool) (map[s
 // }
 // ```
 func SetPubDate(cfg *Config, recordId string, pubDate string, debug bool) (map[string]interface{}, error) {
-	// FIXME: Need to figure out how to do this with direct database access
-
 	m, err := GetDraft(cfg, recordId)
 	if err != nil {
 		return nil, err
@@ -1441,7 +1477,7 @@ func GetDraftFiles(cfg *Config, recordId string, debug bool) (map[string]interfa
 	if err != nil {
 		return nil, err
 	}
-	uri := fmt.Sprintf("%s/%s/draft/files", u.String(), recordId)
+	uri := fmt.Sprintf("%s/api/records/%s/draft/files", u.String(), recordId)
 	src, headers, err := getJSON(cfg.InvenioToken, uri)
 	if err != nil {
 		return nil, err
@@ -1478,7 +1514,7 @@ func GetFiles(cfg *Config, recordId string, debug bool) (map[string]interface{},
 	if err != nil {
 		return nil, err
 	}
-	uri := fmt.Sprintf("%s/%s/files", u.String(), recordId)
+	uri := fmt.Sprintf("%s/api/records/%s/files", u.String(), recordId)
 	src, headers, err := getJSON(cfg.InvenioToken, uri)
 	if err != nil {
 		return nil, err
@@ -1510,7 +1546,9 @@ func GetFiles(cfg *Config, recordId string, debug bool) (map[string]interface{},
 // fmt.Printf("%+v\n", draft)
 // ```
 func UploadFiles(cfg *Config, recordId string, filenames []string, debug bool) (map[string]interface{}, error) {
-	// FIXME: Need to figure out how to do this with direct database access
+	// NOTE: Uploads stay on the RDM API, the file content needs to be
+	// written to RDM's storage and the bucket updated which isn't something
+	// we can do from Postgres.
 
 	// Make sure we have a valid URL
 	u, err := url.Parse(cfg.InvenioAPI)
@@ -1669,7 +1707,34 @@ func SetAccess(cfg *Config, recordId string, accessType string, accessValue stri
 	var (
 		src []byte
 	)
-	// FIXME: Need to figure out how to do this with direct database access
+	// Draft access can be edited directly in Postgres.
+	if usePgDrafts(cfg) && accessType != "embargo" {
+		if _, err := getDraftFromPg(cfg.pgDB, recordId); err == nil {
+			draft, err := editDraftInPg(cfg.pgDB, recordId, func(draft map[string]interface{}) error {
+				access, ok := draft["access"].(map[string]interface{})
+				if !ok {
+					access = map[string]interface{}{
+						"files": "public",
+						"record": "public",
+					}
+				}
+				switch accessType {
+				case "files":
+					access["files"] = accessValue
+				case "record":
+					access["record"] = accessValue
+				default:
+					return fmt.Errorf("%q is not a supported access type", accessType)
+				}
+				draft["access"] = access
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			return JSONMarshalIndent(draft, "", "    ")
+		}
+	}
 		
 	// Make sure we have a URL
 	u, err := url.Parse(cfg.InvenioAPI)
@@ -1889,7 +1954,9 @@ func GetReview(cfg *Config, recordId string, debug  bool) (map[string]interface{
 // }
 // ```
 func ReviewRequest(cfg *Config, recordId string, decision string, comment string, debug bool) (map[string]interface{}, error) {
-	// FIXME: Need to figure out how to do this with direct database access
+	// NOTE: Review decisions stay on the RDM API, accepting a request
+	// publishes the draft and sends notifications which RDM's services
+	// need to do.
 	// Make sure we have a URL
 	u, err := url.Parse(cfg.InvenioAPI)
 	if err != nil {
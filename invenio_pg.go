@@ -0,0 +1,364 @@
+package irdmtools
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/simplified"
+)
+
+// Direct Postgres edits of RDM drafts.
+//
+// NOTE: Edits made directly in Postgres bypass RDM's services so the
+// search index is not updated. Each edit is done in a transaction that
+// bumps the row's version_id (exposed by RDM as the draft's revision_id)
+// and adds the draft to the irdmtools_reindex_queue table. ReindexDrafts
+// drains the queue by putting each draft back through the RDM API, which
+// validates it and updates the index. The queued ids can also be
+// retrieved with GetReindexIds. The queue table is created once with SetupReindexQueue by a database user
+// allowed to create tables, the edits only insert into it.
+
+const (
+	// reindexQueueStmt creates the table holding records needing reindexing.
+	reindexQueueStmt = `CREATE TABLE IF NOT EXISTS irdmtools_reindex_queue (
+    uuid UUID PRIMARY KEY,
+    record_id VARCHAR(255) NOT NULL,
+    record_type VARCHAR(32) NOT NULL,
+    queued TIMESTAMP NOT NULL DEFAULT (now() at time zone 'utc')
+)`
+)
+
+// usePgDrafts returns true if draft edits can go directly to Postgres.
+func usePgDrafts(cfg *Config) bool {
+	return cfg != nil && cfg.pgDB != nil
+}
+
+// getDraftFromPg returns the JSON of the active draft for recordId along
+// with its revision_id.
+func getDraftFromPg(db *sql.DB, recordId string) (map[string]interface{}, error) {
+	if db == nil {
+		return nil, fmt.Errorf("postgres connection is not open")
+	}
+	stmt := `SELECT json, version_id FROM rdm_drafts_metadata
+WHERE json->>'id' = $1 AND json IS NOT NULL LIMIT 1`
+	var (
+		src []byte
+		revisionId int
+	)
+	if err := db.QueryRow(stmt, recordId).Scan(&src, &revisionId); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("draft %q not found", recordId)
+		}
+		return nil, err
+	}
+	m := map[string]interface{}{}
+	if err := JSONUnmarshal(src, &m); err != nil {
+		return nil, err
+	}
+	m["revision_id"] = revisionId
+	return m, nil
+}
+
+// editDraftInPg applies edit to the active draft of recordId. The draft
+// row is locked for the edit, its version_id (revision_id) is bumped and
+// the draft is queued for reindexing all in one transaction. Returns the
+// updated draft.
+func editDraftInPg(db *sql.DB, recordId string, edit func(draft map[string]interface{}) error) (map[string]interface{}, error) {
+	if db == nil {
+		return nil, fmt.Errorf("postgres connection is not open")
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	// NOTE: Rollback is a no-op once the transaction is committed.
+	defer tx.Rollback()
+
+	var (
+		uuid string
+		src []byte
+		revisionId int
+	)
+	stmt := `SELECT id, json, version_id FROM rdm_drafts_metadata
+WHERE json->>'id' = $1 AND json IS NOT NULL LIMIT 1 FOR UPDATE`
+	if err := tx.QueryRow(stmt, recordId).Scan(&uuid, &src, &revisionId); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("draft %q not found", recordId)
+		}
+		return nil, err
+	}
+	draft := map[string]interface{}{}
+	if err := JSONUnmarshal(src, &draft); err != nil {
+		return nil, err
+	}
+	if err := edit(draft); err != nil {
+		return nil, err
+	}
+	// revision_id is not stored in the JSON column, it is the version_id
+	delete(draft, "revision_id")
+	src, err = JSONMarshal(draft)
+	if err != nil {
+		return nil, err
+	}
+	stmt = `UPDATE rdm_drafts_metadata
+SET json = $1, version_id = version_id + 1, updated = (now() at time zone 'utc')
+WHERE id = $2 AND version_id = $3`
+	res, err := tx.Exec(stmt, src, uuid, revisionId)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n != 1 {
+		return nil, fmt.Errorf("draft %q was modified concurrently, revision %d is stale", recordId, revisionId)
+	}
+	if err := queueReindex(tx, uuid, recordId, "draft"); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	draft["revision_id"] = revisionId + 1
+	return draft, nil
+}
+
+// reindexQueueError explains an error from a missing reindex queue.
+func reindexQueueError(err error) error {
+	if err != nil && strings.Contains(err.Error(), "does not exist") {
+		return fmt.Errorf("%s, the reindex queue needs to be created with rdmutil setup_reindex_queue", err)
+	}
+	return err
+}
+
+// queueReindex marks a record or draft as needing to be reindexed.
+func queueReindex(tx *sql.Tx, uuid string, recordId string, recordType string) error {
+	stmt := `INSERT INTO irdmtools_reindex_queue (uuid, record_id, record_type)
+VALUES ($1, $2, $3)
+ON CONFLICT (uuid) DO UPDATE SET queued = (now() at time zone 'utc')`
+	_, err := tx.Exec(stmt, uuid, recordId, recordType)
+	return reindexQueueError(err)
+}
+
+// updateDraftFromPayload replaces the fields RDM accepts when updating a
+// draft with those in payload. Like the API's PUT a field missing from
+// payload is removed from the draft. Only files.enabled of the files
+// can be changed, the rest is managed by RDM.
+func updateDraftFromPayload(draft map[string]interface{}, payload map[string]interface{}) {
+	for _, key := range []string{"metadata", "custom_fields", "access", "pids"} {
+		if val, ok := payload[key]; ok {
+			draft[key] = val
+		} else {
+			delete(draft, key)
+		}
+	}
+	if elem, ok := payload["files"]; ok {
+		if files, ok := elem.(map[string]interface{}); ok {
+			if enabled, ok := files["enabled"]; ok {
+				if draftFiles, ok := draft["files"].(map[string]interface{}); ok {
+					draftFiles["enabled"] = enabled
+				} else {
+					draft["files"] = map[string]interface{}{"enabled": enabled}
+				}
+			}
+		}
+	}
+}
+
+// validateDraftPayload checks a draft payload before it is written to
+// Postgres. RDM's schema validation is skipped by direct edits so the
+// payload must have metadata and its vocabulary ids are checked with
+// ValidateRecord.
+func validateDraftPayload(cfg *Config, payloadSrc []byte) error {
+	rec := new(simplified.Record)
+	if err := JSONUnmarshal(payloadSrc, &rec); err != nil {
+		return err
+	}
+	if rec.Metadata == nil {
+		return fmt.Errorf("missing metadata")
+	}
+	if cfg.vocabularies == nil {
+		vocabularies, err := LoadVocabularies(cfg, "")
+		if err != nil {
+			return err
+		}
+		cfg.vocabularies = vocabularies
+	}
+	violations := ValidateRecord(rec, cfg.vocabularies)
+	if len(violations) > 0 {
+		problems := []string{}
+		for _, violation := range violations {
+			problems = append(problems, violation.String())
+		}
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// GetDraftFromPg returns the draft of a record as stored in Postgres.
+// Unlike GetDraft the JSON doesn't include the links or parent added by
+// the RDM API.
+//
+// ```
+// draft, err := GetDraftFromPg(cfg, "qez01-2309a")
+// if err != nil {
+//    // ... handle error ...
+// }
+// ```
+func GetDraftFromPg(cfg *Config, recordId string) (map[string]interface{}, error) {
+	draft, err := getDraftFromPg(cfg.pgDB, recordId)
+	if err != nil {
+		return nil, err
+	}
+	dropEmptyDOI(draft)
+	return draft, nil
+}
+
+// SetupReindexQueue creates the irdmtools_reindex_queue table used to
+// queue drafts edited directly in Postgres for reindexing. It only needs
+// to be run once, by a database user allowed to create tables.
+//
+// ```
+// if err := SetupReindexQueue(cfg); err != nil {
+//    // ... handle error ...
+// }
+// ```
+func SetupReindexQueue(cfg *Config) error {
+	db := cfg.pgDB
+	if db == nil {
+		return fmt.Errorf("postgres connection not open")
+	}
+	_, err := db.Exec(reindexQueueStmt)
+	return err
+}
+
+// GetReindexIds returns the record ids queued for reindexing by direct
+// Postgres edits. If clear is true the returned ids are removed from the
+// queue.
+//
+// ```
+// ids, err := GetReindexIds(cfg, true)
+// if err != nil {
+//    // ... handle error ...
+// }
+// ```
+func GetReindexIds(cfg *Config, clear bool) ([]string, error) {
+	db := cfg.pgDB
+	if db == nil {
+		return nil, fmt.Errorf("postgres connection not open")
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	stmt := `SELECT uuid, record_id FROM irdmtools_reindex_queue ORDER BY queued FOR UPDATE`
+	rows, err := tx.Query(stmt)
+	if err != nil {
+		return nil, reindexQueueError(err)
+	}
+	ids, uuids := []string{}, []string{}
+	for rows.Next() {
+		var uuid, rdmid string
+		if err := rows.Scan(&uuid, &rdmid); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, rdmid)
+		uuids = append(uuids, uuid)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if clear {
+		for _, uuid := range uuids {
+			if _, err := tx.Exec(`DELETE FROM irdmtools_reindex_queue WHERE uuid = $1`, uuid); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// ReindexDrafts drains the reindex queue. Each queued draft is read and
+// updated unchanged through the RDM API so RDM validates it and updates
+// its search index. A draft is removed from the queue once it has been
+// put back unless it was edited again in the meantime. Drafts that fail
+// are logged and left in the queue. Returns the ids of the drafts
+// reindexed.
+//
+// ```
+// ids, err := ReindexDrafts(cfg, false)
+// if err != nil {
+//    // ... handle error ...
+// }
+// fmt.Printf("%s\n", strings.Join(ids, "\n"))
+// ```
+func ReindexDrafts(cfg *Config, debug bool) ([]string, error) {
+	db := cfg.pgDB
+	if db == nil {
+		return nil, fmt.Errorf("postgres connection not open")
+	}
+	type queued struct {
+		uuid string
+		recordId string
+		queued time.Time
+	}
+	stmt := `SELECT uuid, record_id, queued FROM irdmtools_reindex_queue
+WHERE record_type = 'draft' ORDER BY queued`
+	rows, err := db.Query(stmt)
+	if err != nil {
+		return nil, reindexQueueError(err)
+	}
+	drafts := []*queued{}
+	for rows.Next() {
+		draft := new(queued)
+		if err := rows.Scan(&draft.uuid, &draft.recordId, &draft.queued); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		drafts = append(drafts, draft)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	l := log.New(os.Stderr, "", 1)
+	ids, eCnt := []string{}, 0
+	for _, draft := range drafts {
+		err := func() error {
+			obj, err := GetDraft(cfg, draft.recordId)
+			if err != nil {
+				return err
+			}
+			src, err := JSONMarshal(obj)
+			if err != nil {
+				return err
+			}
+			if _, err := putDraft(cfg, draft.recordId, src, debug); err != nil {
+				return err
+			}
+			// NOTE: the draft stays queued if it was edited after it was read.
+			_, err = db.Exec(`DELETE FROM irdmtools_reindex_queue WHERE uuid = $1 AND queued = $2`, draft.uuid, draft.queued)
+			return err
+		}()
+		if err != nil {
+			l.Printf("failed to reindex %q, %s", draft.recordId, err)
+			eCnt++
+			continue
+		}
+		ids = append(ids, draft.recordId)
+	}
+	if eCnt > 0 {
+		return ids, fmt.Errorf("%d drafts failed to reindex", eCnt)
+	}
+	return ids, nil
+}
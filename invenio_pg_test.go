@@ -0,0 +1,93 @@
+package irdmtools
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUpdateDraftFromPayload(t *testing.T) {
+	draft := map[string]interface{}{
+		"id": "aaaaa-00001",
+		"parent": map[string]interface{}{"id": "bbbbb-00001"},
+		"metadata": map[string]interface{}{"title": "Old title"},
+		"custom_fields": map[string]interface{}{"journal:journal": map[string]interface{}{"title": "Old journal"}},
+		"files": map[string]interface{}{"enabled": true, "default_preview": "article.pdf"},
+	}
+	payload := map[string]interface{}{
+		"id": "ccccc-00001",
+		"metadata": map[string]interface{}{"title": "New title"},
+		"access": map[string]interface{}{"record": "public", "files": "restricted"},
+		"files": map[string]interface{}{"enabled": false},
+	}
+	updateDraftFromPayload(draft, payload)
+	if draft["id"] != "aaaaa-00001" {
+		t.Errorf("draft id should not change, got %v", draft["id"])
+	}
+	if _, ok := draft["parent"]; !ok {
+		t.Errorf("draft parent should be kept")
+	}
+	if title := draft["metadata"].(map[string]interface{})["title"]; title != "New title" {
+		t.Errorf("expected new title, got %v", title)
+	}
+	if _, ok := draft["access"]; !ok {
+		t.Errorf("expected access to be set")
+	}
+	if _, ok := draft["custom_fields"]; ok {
+		t.Errorf("expected custom_fields missing from the payload to be removed, got %+v", draft["custom_fields"])
+	}
+	files := draft["files"].(map[string]interface{})
+	if files["enabled"] != false || files["default_preview"] != "article.pdf" {
+		t.Errorf("expected only files.enabled to change, got %+v", files)
+	}
+}
+
+func TestValidateDraftPayload(t *testing.T) {
+	vocabularies, err := DefaultVocabularies()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{rl: new(RateLimit), vocabularies: vocabularies}
+	src := []byte(`{"metadata": {"title": "A title", "resource_type": {"id": "publication-article"}}}`)
+	if err := validateDraftPayload(cfg, src); err != nil {
+		t.Errorf("expected payload to validate, %s", err)
+	}
+	src = []byte(`{"metadata": {"title": "A title", "resource_type": {"id": "journal-article"}}}`)
+	if err := validateDraftPayload(cfg, src); err == nil {
+		t.Errorf("expected an error for an unknown resource type")
+	}
+	src = []byte(`{"access": {"record": "public", "files": "public"}}`)
+	if err := validateDraftPayload(cfg, src); err == nil {
+		t.Errorf("expected an error for a payload without metadata")
+	}
+}
+
+func TestDropEmptyDOI(t *testing.T) {
+	draft := map[string]interface{}{
+		"pids": map[string]interface{}{
+			"doi": map[string]interface{}{"identifier": "", "provider": "external"},
+			"oai": map[string]interface{}{"identifier": "oai:example:1"},
+		},
+	}
+	dropEmptyDOI(draft)
+	pids := draft["pids"].(map[string]interface{})
+	if _, ok := pids["doi"]; ok {
+		t.Errorf("expected empty doi to be removed")
+	}
+	if _, ok := pids["oai"]; !ok {
+		t.Errorf("expected oai to be kept")
+	}
+}
+
+func TestReindexQueueError(t *testing.T) {
+	if err := reindexQueueError(nil); err != nil {
+		t.Errorf("expected nil, got %s", err)
+	}
+	err := reindexQueueError(fmt.Errorf(`pq: relation "irdmtools_reindex_queue" does not exist`))
+	if err == nil || !strings.Contains(err.Error(), "setup_reindex_queue") {
+		t.Errorf("expected a hint to run setup_reindex_queue, got %v", err)
+	}
+	if err := reindexQueueError(fmt.Errorf("pq: deadlock detected")); err.Error() != "pq: deadlock detected" {
+		t.Errorf("expected the error unchanged, got %s", err)
+	}
+}
@@ -0,0 +1,475 @@
+package irdmtools
+
+import (
+	"encoding/xml"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Converting JATS abstracts to the HTML InvenioRDM accepts.
+//
+// CrossRef abstracts are JATS fragments, e.g. <jats:p> and <jats:italic>,
+// often with MathML (<mml:math>) formulas. DataCite descriptions and
+// EPrints abstracts may hold HTML. InvenioRDM only accepts a limited set
+// of HTML tags in a description so JATS elements are mapped to those
+// tags, MathML is rendered as LaTeX inside \( \) delimiters (for MathJax)
+// and other tags are removed keeping their text.
+
+// htmlAllowedTags are the tags InvenioRDM accepts in a description. The
+// attributes of allowed tags are dropped, except a link's href.
+var htmlAllowedTags = map[string]bool{
+	"a": true, "abbr": true, "acronym": true, "b": true, "blockquote": true,
+	"br": true, "code": true, "div": true, "em": true, "h1": true, "h2": true,
+	"h3": true, "h4": true, "h5": true, "h6": true, "i": true, "li": true,
+	"ol": true, "p": true, "pre": true, "span": true, "strike": true,
+	"strong": true, "sub": true, "sup": true, "table": true, "tbody": true,
+	"td": true, "th": true, "tr": true, "u": true, "ul": true,
+}
+
+// jatsTags maps JATS elements to the HTML tags replacing them.
+var jatsTags = map[string]string{
+	"italic":     "i",
+	"bold":       "b",
+	"underline":  "u",
+	"monospace":  "code",
+	"strike":     "strike",
+	"list-item":  "li",
+	"break":      "br",
+	"disp-quote": "blockquote",
+	"preformat":  "pre",
+	"ext-link":   "a",
+	"uri":        "a",
+}
+
+// jatsDropped are elements removed along with their content.
+var jatsDropped = map[string]bool{
+	"script": true, "style": true, "head": true, "object-id": true,
+}
+
+// jatsNode is an element or (if Name is empty) text of a parsed fragment.
+type jatsNode struct {
+	Name     string
+	Attrs    map[string]string
+	Text     string
+	Children []*jatsNode
+}
+
+var (
+	reSpaces     = regexp.MustCompile(`\s+`)
+	reMarkupTag  = regexp.MustCompile(`</?[a-zA-Z][\w:.-]*(\s[^<>]*)?/?>`)
+	reTeXCommand = regexp.MustCompile(`\\[a-zA-Z]+$`)
+	// reBlockSpace matches the space around block level tags
+	reBlockSpace = regexp.MustCompile(`\s*(</?(?:p|ul|ol|li|blockquote|div|table|tbody|tr|td|th|pre|h[1-6])>)\s*`)
+	// htmlText escapes text, quotes don't need escaping outside attributes
+	htmlText = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+)
+
+// parseJATS parses a JATS or HTML fragment. Namespace prefixes are
+// ignored, e.g. <jats:p> and <p> are both "p".
+func parseJATS(src string) (*jatsNode, error) {
+	decoder := xml.NewDecoder(strings.NewReader("<fragment>" + src + "</fragment>"))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+	root := &jatsNode{Name: "fragment"}
+	stack := []*jatsNode{}
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &jatsNode{Name: strings.ToLower(t.Name.Local), Attrs: map[string]string{}}
+			for _, attr := range t.Attr {
+				node.Attrs[strings.ToLower(attr.Name.Local)] = attr.Value
+			}
+			// NOTE: HTML paragraphs and list items are often left open
+			if n := len(stack); n > 1 && (node.Name == "p" || node.Name == "li") && stack[n-1].Name == node.Name {
+				stack = stack[:n-1]
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, node)
+			} else {
+				root = node
+			}
+			stack = append(stack, node)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, &jatsNode{Text: string(t)})
+			}
+		}
+	}
+	return root, nil
+}
+
+// text returns the text of the node and its children.
+func (node *jatsNode) text() string {
+	if node.Name == "" {
+		return node.Text
+	}
+	sb := strings.Builder{}
+	for _, child := range node.Children {
+		sb.WriteString(child.text())
+	}
+	return sb.String()
+}
+
+// child returns the first child element with the name.
+func (node *jatsNode) child(name string) *jatsNode {
+	for _, child := range node.Children {
+		if child.Name == name {
+			return child
+		}
+	}
+	return nil
+}
+
+// elements returns the children that are elements.
+func (node *jatsNode) elements() []*jatsNode {
+	elements := []*jatsNode{}
+	for _, child := range node.Children {
+		if child.Name != "" {
+			elements = append(elements, child)
+		}
+	}
+	return elements
+}
+
+// JATSToHTML converts a JATS or HTML abstract to the limited HTML
+// InvenioRDM accepts in a description. MathML is rendered as LaTeX in
+// \( \) delimiters, unsupported tags are removed keeping their text.
+// Text without markup is returned unchanged.
+//
+// ```
+// description := JATSToHTML(`<jats:p>The <jats:italic>E. coli</jats:italic> ...</jats:p>`)
+// // description is "<p>The <i>E. coli</i> ...</p>"
+// ```
+func JATSToHTML(src string) string {
+	if !strings.Contains(src, "<") {
+		return src
+	}
+	root, err := parseJATS(src)
+	if err != nil {
+		// NOTE: markup we can't parse is removed, only the text is kept
+		s := html.UnescapeString(reMarkupTag.ReplaceAllString(src, " "))
+		return htmlText.Replace(strings.TrimSpace(reSpaces.ReplaceAllString(s, " ")))
+	}
+	sb := strings.Builder{}
+	renderJATSChildren(&sb, root)
+	return strings.TrimSpace(reBlockSpace.ReplaceAllString(sb.String(), "$1"))
+}
+
+// renderJATSChildren writes the HTML of the node's children.
+func renderJATSChildren(sb *strings.Builder, node *jatsNode) {
+	for _, child := range node.Children {
+		renderJATS(sb, child)
+	}
+}
+
+// isAbstractTitle returns true for a title that only repeats "Abstract".
+func isAbstractTitle(title string) bool {
+	title = strings.ToLower(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(title), ":")))
+	return title == "abstract" || title == "summary"
+}
+
+// renderJATS writes the HTML of a node.
+func renderJATS(sb *strings.Builder, node *jatsNode) {
+	if node.Name == "" {
+		sb.WriteString(htmlText.Replace(reSpaces.ReplaceAllString(node.Text, " ")))
+		return
+	}
+	if jatsDropped[node.Name] {
+		return
+	}
+	switch node.Name {
+	case "math":
+		sb.WriteString(htmlText.Replace(`\(` + mathMLToLaTeX(node) + `\)`))
+		return
+	case "tex-math":
+		sb.WriteString(htmlText.Replace(`\(` + texMath(node.text()) + `\)`))
+		return
+	case "alternatives":
+		// NOTE: a formula may be given as TeX, MathML and an image
+		for _, name := range []string{"tex-math", "math"} {
+			if alt := node.child(name); alt != nil {
+				renderJATS(sb, alt)
+				return
+			}
+		}
+	case "title":
+		if title := strings.TrimSpace(reSpaces.ReplaceAllString(node.text(), " ")); title != "" && !isAbstractTitle(title) {
+			sb.WriteString("<p><b>")
+			renderJATSChildren(sb, node)
+			sb.WriteString("</b></p>")
+		}
+		return
+	case "list":
+		tag := "ul"
+		if node.Attrs["list-type"] == "order" {
+			tag = "ol"
+		}
+		sb.WriteString("<" + tag + ">")
+		renderJATSChildren(sb, node)
+		sb.WriteString("</" + tag + ">")
+		return
+	}
+	tag, ok := jatsTags[node.Name]
+	if !ok && htmlAllowedTags[node.Name] {
+		tag = node.Name
+	}
+	if tag == "" {
+		renderJATSChildren(sb, node)
+		return
+	}
+	if tag == "br" {
+		sb.WriteString("<br>")
+		return
+	}
+	if tag == "a" {
+		href := node.Attrs["href"]
+		if href == "" && node.Name == "uri" {
+			href = strings.TrimSpace(node.text())
+		}
+		lower := strings.ToLower(href)
+		if !(strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "mailto:")) {
+			renderJATSChildren(sb, node)
+			return
+		}
+		sb.WriteString(`<a href="` + html.EscapeString(href) + `">`)
+	} else {
+		sb.WriteString("<" + tag + ">")
+	}
+	renderJATSChildren(sb, node)
+	sb.WriteString("</" + tag + ">")
+}
+
+// texMath returns the formula of a JATS tex-math element, which may be
+// a complete LaTeX document.
+func texMath(src string) string {
+	if i := strings.Index(src, `\begin{document}`); i >= 0 {
+		src = src[i+len(`\begin{document}`):]
+		if j := strings.Index(src, `\end{document}`); j >= 0 {
+			src = src[:j]
+		}
+	}
+	src = strings.TrimSpace(src)
+	for _, delim := range [][2]string{{"$$", "$$"}, {"$", "$"}, {`\(`, `\)`}, {`\[`, `\]`}} {
+		if len(src) >= len(delim[0])+len(delim[1]) && strings.HasPrefix(src, delim[0]) && strings.HasSuffix(src, delim[1]) {
+			return strings.TrimSpace(src[len(delim[0]) : len(src)-len(delim[1])])
+		}
+	}
+	return src
+}
+
+// latexSymbols maps characters used in MathML to LaTeX commands.
+var latexSymbols = map[string]string{
+	"α": `\alpha`, "β": `\beta`, "γ": `\gamma`, "δ": `\delta`, "ε": `\epsilon`,
+	"ϵ": `\epsilon`, "ζ": `\zeta`, "η": `\eta`, "θ": `\theta`, "ι": `\iota`,
+	"κ": `\kappa`, "λ": `\lambda`, "μ": `\mu`, "ν": `\nu`, "ξ": `\xi`,
+	"π": `\pi`, "ρ": `\rho`, "σ": `\sigma`, "τ": `\tau`, "υ": `\upsilon`,
+	"φ": `\phi`, "ϕ": `\phi`, "χ": `\chi`, "ψ": `\psi`, "ω": `\omega`,
+	"Γ": `\Gamma`, "Δ": `\Delta`, "Θ": `\Theta`, "Λ": `\Lambda`, "Ξ": `\Xi`,
+	"Π": `\Pi`, "Σ": `\Sigma`, "Φ": `\Phi`, "Ψ": `\Psi`, "Ω": `\Omega`,
+	"×": `\times`, "·": `\cdot`, "⋅": `\cdot`, "±": `\pm`, "∓": `\mp`,
+	"÷": `\div`, "−": `-`, "≤": `\leq`, "≥": `\geq`, "≠": `\neq`,
+	"≈": `\approx`, "∼": `\sim`, "≃": `\simeq`, "≡": `\equiv`, "∝": `\propto`,
+	"≪": `\ll`, "≫": `\gg`, "∞": `\infty`, "∂": `\partial`, "∇": `\nabla`,
+	"∑": `\sum`, "∏": `\prod`, "∫": `\int`, "∮": `\oint`, "√": `\surd`,
+	"→": `\rightarrow`, "←": `\leftarrow`, "↔": `\leftrightarrow`,
+	"⇒": `\Rightarrow`, "⇌": `\rightleftharpoons`, "∈": `\in`, "∉": `\notin`,
+	"⊂": `\subset`, "⊆": `\subseteq`, "∪": `\cup`, "∩": `\cap`, "∅": `\emptyset`,
+	"∀": `\forall`, "∃": `\exists`, "°": `^{\circ}`, "′": `'`, "″": `''`,
+	"ℏ": `\hbar`, "ℓ": `\ell`, "…": `\ldots`, "⋯": `\cdots`, "∘": `\circ`,
+	"⟨": `\langle`, "⟩": `\rangle`, "{": `\{`, "}": `\}`, "%": `\%`,
+	"#": `\#`, "&": `\&`, "_": `\_`,
+	// NOTE: invisible operators, e.g. function application and times
+	"⁡": "", "⁢": "", "⁣": "", "⁤": "",
+}
+
+// latexFunctions are the function names LaTeX has commands for.
+var latexFunctions = map[string]bool{
+	"sin": true, "cos": true, "tan": true, "cot": true, "sec": true, "csc": true,
+	"sinh": true, "cosh": true, "tanh": true, "arcsin": true, "arccos": true,
+	"arctan": true, "log": true, "ln": true, "exp": true, "lim": true,
+	"max": true, "min": true, "sup": true, "inf": true, "det": true,
+	"dim": true, "ker": true, "deg": true, "arg": true, "gcd": true,
+}
+
+// latexAccents maps the mover accents to LaTeX commands.
+var latexAccents = map[string]string{
+	"¯": `\overline`, "‾": `\overline`, "-": `\overline`, "^": `\hat`,
+	"ˆ": `\hat`, "~": `\tilde`, "˜": `\tilde`, "→": `\vec`, "⃗": `\vec`,
+	"˙": `\dot`, "¨": `\ddot`,
+}
+
+// latexText converts the characters of a MathML token to LaTeX.
+func latexText(src string) string {
+	s := ""
+	for _, r := range src {
+		c := string(r)
+		if symbol, ok := latexSymbols[c]; ok {
+			c = symbol
+		}
+		s = latexJoin(s, c)
+	}
+	return s
+}
+
+// latexJoin appends s to the LaTeX in prefix adding a space when a
+// command would run into a following letter, e.g. `\alpha x`.
+func latexJoin(prefix string, s string) string {
+	if s == "" {
+		return prefix
+	}
+	r, _ := utf8.DecodeRuneInString(s)
+	if reTeXCommand.MatchString(prefix) && (r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+		return prefix + " " + s
+	}
+	return prefix + s
+}
+
+// latexGroup wraps LaTeX in braces unless it is a single character or
+// command.
+func latexGroup(s string) string {
+	if utf8.RuneCountInString(s) == 1 || (reTeXCommand.MatchString(s) && strings.LastIndex(s, `\`) == 0) {
+		return s
+	}
+	return "{" + s + "}"
+}
+
+// mathChildren returns the LaTeX of the node's element children.
+func mathChildren(node *jatsNode) []string {
+	values := []string{}
+	for _, child := range node.elements() {
+		values = append(values, mathMLToLaTeX(child))
+	}
+	return values
+}
+
+// mathJoin concatenates LaTeX fragments.
+func mathJoin(values []string) string {
+	s := ""
+	for _, value := range values {
+		s = latexJoin(s, value)
+	}
+	return s
+}
+
+// mathMLToLaTeX renders a parsed MathML element as LaTeX, e.g. for
+// display inside \( \) delimiters. Presentation MathML is supported,
+// elements it doesn't know are rendered as their children.
+func mathMLToLaTeX(node *jatsNode) string {
+	if node.Name == "" {
+		return latexText(strings.TrimSpace(node.Text))
+	}
+	args := mathChildren(node)
+	arg := func(i int) string {
+		if i < len(args) {
+			return args[i]
+		}
+		return ""
+	}
+	switch node.Name {
+	case "semantics":
+		// NOTE: a TeX annotation is used as is, otherwise the presentation MathML
+		for _, child := range node.elements() {
+			if child.Name == "annotation" && strings.Contains(strings.ToLower(child.Attrs["encoding"]), "tex") {
+				return texMath(child.text())
+			}
+		}
+		return arg(0)
+	case "annotation", "annotation-xml", "none", "mprescripts":
+		return ""
+	case "mi":
+		s := strings.TrimSpace(node.text())
+		if latexFunctions[s] {
+			return `\` + s
+		}
+		if utf8.RuneCountInString(s) > 1 {
+			return `\mathrm{` + latexText(s) + `}`
+		}
+		return latexText(s)
+	case "mn", "mo":
+		return latexText(strings.TrimSpace(node.text()))
+	case "mtext", "ms":
+		s := strings.TrimSpace(reSpaces.ReplaceAllString(node.text(), " "))
+		if s == "" {
+			return `\ `
+		}
+		return `\text{` + s + `}`
+	case "mspace":
+		return `\,`
+	case "msup":
+		return latexGroup(arg(0)) + "^" + latexGroup(arg(1))
+	case "msub":
+		return latexGroup(arg(0)) + "_" + latexGroup(arg(1))
+	case "msubsup":
+		return latexGroup(arg(0)) + "_" + latexGroup(arg(1)) + "^" + latexGroup(arg(2))
+	case "mfrac":
+		return `\frac{` + arg(0) + `}{` + arg(1) + `}`
+	case "msqrt":
+		return `\sqrt{` + mathJoin(args) + `}`
+	case "mroot":
+		return `\sqrt[` + arg(1) + `]{` + arg(0) + `}`
+	case "mover":
+		elements := node.elements()
+		if len(elements) == 2 {
+			if accent, ok := latexAccents[strings.TrimSpace(elements[1].text())]; ok && elements[1].Name == "mo" {
+				return accent + "{" + arg(0) + "}"
+			}
+		}
+		return `\overset{` + arg(1) + `}{` + arg(0) + `}`
+	case "munder":
+		if strings.HasPrefix(arg(0), `\`) {
+			return arg(0) + "_" + latexGroup(arg(1))
+		}
+		return `\underset{` + arg(1) + `}{` + arg(0) + `}`
+	case "munderover":
+		return latexGroup(arg(0)) + "_" + latexGroup(arg(1)) + "^" + latexGroup(arg(2))
+	case "mfenced":
+		open, close, separators := "(", ")", ","
+		if value, ok := node.Attrs["open"]; ok {
+			open = value
+		}
+		if value, ok := node.Attrs["close"]; ok {
+			close = value
+		}
+		if value, ok := node.Attrs["separators"]; ok {
+			separators = strings.TrimSpace(value)
+		}
+		s := latexText(open)
+		for i, value := range args {
+			if i > 0 && separators != "" {
+				sep, _ := utf8.DecodeRuneInString(separators)
+				s = latexJoin(s, latexText(string(sep)))
+			}
+			s = latexJoin(s, value)
+		}
+		return latexJoin(s, latexText(close))
+	case "mtable":
+		rows := []string{}
+		for _, row := range node.elements() {
+			cells := []string{}
+			for _, cell := range row.elements() {
+				cells = append(cells, mathMLToLaTeX(cell))
+			}
+			rows = append(rows, strings.Join(cells, " & "))
+		}
+		return `\begin{matrix}` + strings.Join(rows, ` \\ `) + `\end{matrix}`
+	}
+	// NOTE: math, mrow, mstyle, mpadded, mtd, etc. are rendered as their children
+	return mathJoin(args)
+}
@@ -0,0 +1,46 @@
+package irdmtools
+
+import (
+	"testing"
+)
+
+func TestJATSToHTML(t *testing.T) {
+	testCases := []struct {
+		src      string
+		expected string
+	}{
+		// Text without markup is unchanged
+		{"Plain text & no markup.", "Plain text & no markup."},
+		{`<jats:title>Abstract</jats:title>
+  <jats:p>The <jats:italic>E. coli</jats:italic> genome, see <jats:ext-link xmlns:xlink="http://www.w3.org/1999/xlink" ext-link-type="uri" xlink:href="https://example.edu/data">data</jats:ext-link>.</jats:p>
+  <jats:p>CO<jats:sub>2</jats:sub> &amp; H<jats:sup>+</jats:sup></jats:p>`,
+			`<p>The <i>E. coli</i> genome, see <a href="https://example.edu/data">data</a>.</p><p>CO<sub>2</sub> &amp; H<sup>+</sup></p>`},
+		{`<jats:sec><jats:title>Background</jats:title><jats:p>One</jats:p></jats:sec><jats:sec><jats:title>Results</jats:title><jats:list list-type="order"><jats:list-item><jats:p>Two</jats:p></jats:list-item></jats:list></jats:sec>`,
+			`<p><b>Background</b></p><p>One</p><p><b>Results</b></p><ol><li><p>Two</p></li></ol>`},
+		// Unsupported tags are removed, scripts with their content
+		{`<p onclick="x()">A <font color="red">red</font> <jats:sc>word</jats:sc><script>alert(1)</script></p>`,
+			`<p>A red word</p>`},
+		{`<p>A <a href="javascript:alert(1)">link</a></p>`, `<p>A link</p>`},
+		{`Line one<br>Line two`, `Line one<br>Line two`},
+		{`<p>One<p>Two &amp; R&D`, `<p>One</p><p>Two &amp; R&amp;D</p>`},
+		// MathML is rendered as LaTeX
+		{`<jats:p>Energy <mml:math xmlns:mml="http://www.w3.org/1998/Math/MathML"><mml:mi>E</mml:mi><mml:mo>=</mml:mo><mml:mi>m</mml:mi><mml:msup><mml:mi>c</mml:mi><mml:mn>2</mml:mn></mml:msup></mml:math>.</jats:p>`,
+			`<p>Energy \(E=mc^2\).</p>`},
+		{`<jats:p><jats:inline-formula><mml:math><mml:mfrac><mml:mrow><mml:mi>α</mml:mi><mml:mi>x</mml:mi></mml:mrow><mml:msqrt><mml:mn>2</mml:mn></mml:msqrt></mml:mfrac><mml:mo>≤</mml:mo><mml:msub><mml:mi>T</mml:mi><mml:mtext>eff</mml:mtext></mml:msub></mml:math></jats:inline-formula></jats:p>`,
+			`<p>\(\frac{\alpha x}{\sqrt{2}}\leq T_{\text{eff}}\)</p>`},
+		{`<mml:math><mml:munderover><mml:mo>∑</mml:mo><mml:mrow><mml:mi>i</mml:mi><mml:mo>=</mml:mo><mml:mn>1</mml:mn></mml:mrow><mml:mi>n</mml:mi></mml:munderover><mml:msub><mml:mi>x</mml:mi><mml:mi>i</mml:mi></mml:msub><mml:mo>&lt;</mml:mo><mml:mover><mml:mi>x</mml:mi><mml:mo>¯</mml:mo></mml:mover></mml:math>`,
+			`\(\sum_{i=1}^nx_i&lt;\overline{x}\)`},
+		{`<mml:math><mml:mi>sin</mml:mi><mml:mo>⁡</mml:mo><mml:mfenced><mml:mi>θ</mml:mi></mml:mfenced></mml:math>`,
+			`\(\sin(\theta)\)`},
+		// TeX alternatives are preferred to MathML
+		{`<jats:p><jats:inline-formula><jats:alternatives><jats:tex-math>\documentclass{article}\begin{document}$$\beta$$\end{document}</jats:tex-math><mml:math><mml:mi>β</mml:mi></mml:math></jats:alternatives></jats:inline-formula> decay</jats:p>`,
+			`<p>\(\beta\) decay</p>`},
+		// Markup that can't be parsed keeps its text
+		{`<p>Unclosed <b>bold</p> x < y`, `Unclosed bold x &lt; y`},
+	}
+	for i, tc := range testCases {
+		if got := JATSToHTML(tc.src); got != tc.expected {
+			t.Errorf("(%d) expected\n%s\ngot\n%s", i, tc.expected, got)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package irdmtools
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/crossrefapi"
+	"github.com/caltechlibrary/simplified"
+)
+
+// Linking preprints to their published versions.
+//
+// A preprint deposited with CrossRef (type "posted-content") is updated
+// with an "is-preprint-of" relation once the article is published. The
+// preprint records in RDM are checked for this relation and the two
+// records are linked with related identifiers, the preprint "is previous
+// version of" the article and the article "is version of" the preprint.
+
+const (
+	// PreprintFilter selects the preprint records in RDM
+	PreprintFilter = "metadata.resource_type.id = publication-preprint"
+	// PreprintRelationType relates a preprint to its published version
+	PreprintRelationType = "ispreviousversionof"
+	// PublishedRelationType relates a published article to its preprint
+	PublishedRelationType = "isversionof"
+)
+
+// VersionLink describes a related identifier to add to a record. When
+// links are proposed rather than applied Diff holds the changes to the
+// record.
+type VersionLink struct {
+	// RecordId is the RDM record to update
+	RecordId string `json:"record_id"`
+	// DOI is the DOI of the record to update
+	DOI string `json:"doi,omitempty"`
+	// RelationType is the relation of the record to RelatedDOI
+	RelationType string `json:"relation_type"`
+	// RelatedDOI is the DOI added to the related identifiers
+	RelatedDOI string `json:"related_doi"`
+	// Diff holds the DiffAsJSON of the record and the linked record
+	Diff interface{} `json:"diff,omitempty"`
+}
+
+// getWorksPreprintOf returns the DOI in the CrossRef work's
+// "is-preprint-of" relations.
+func getWorksPreprintOf(work *crossrefapi.Works) []string {
+	dois := []string{}
+	if work == nil || work.Message == nil || work.Message.Relation == nil {
+		return dois
+	}
+	for _, rel := range work.Message.Relation["is-preprint-of"] {
+		if rel == nil || !strings.EqualFold(rel.IdType, "doi") {
+			continue
+		}
+		if doi := normalizeDOI(rel.Id); doi != "" {
+			dois = append(dois, doi)
+		}
+	}
+	return dois
+}
+
+// hasRelatedDOI returns true if the DOI is already one of the record's
+// related identifiers, whatever the relation type.
+func hasRelatedDOI(rec *simplified.Record, doi string) bool {
+	if rec.Metadata == nil {
+		return false
+	}
+	for _, identifier := range rec.Metadata.RelatedIdentifiers {
+		if identifier != nil && strings.EqualFold(identifier.Scheme, "doi") &&
+			strings.EqualFold(normalizeDOI(identifier.Identifier), doi) {
+			return true
+		}
+	}
+	return false
+}
+
+// relatedDOIPatch returns a JSON Patch adding the DOI with the relation
+// type to the record's related identifiers. If the record already lists
+// the DOI nil is returned.
+func relatedDOIPatch(rec *simplified.Record, relationType string, doi string) (*Patch, error) {
+	if hasRelatedDOI(rec, doi) {
+		return nil, nil
+	}
+	linked := &simplified.Record{Metadata: new(simplified.Metadata)}
+	if rec.Metadata != nil {
+		linked.Metadata.RelatedIdentifiers = append(linked.Metadata.RelatedIdentifiers, rec.Metadata.RelatedIdentifiers...)
+	}
+	if err := AddRelatedIdentifier(linked, "doi", relationType, doi); err != nil {
+		return nil, err
+	}
+	// NOTE: the patch value must be plain JSON so the patch can be applied
+	// to a record or draft object
+	src, err := JSONMarshal(linked.Metadata.RelatedIdentifiers)
+	if err != nil {
+		return nil, err
+	}
+	identifiers := []interface{}{}
+	if err := JSONUnmarshal(src, &identifiers); err != nil {
+		return nil, err
+	}
+	return &Patch{
+		Type: JSONPatchType,
+		Operations: []*PatchOperation{
+			{Op: "add", Path: "/metadata/related_identifiers", Value: identifiers},
+		},
+	}, nil
+}
+
+// findRecordByDOI returns the id of the RDM record with the DOI, an
+// empty string if there isn't one.
+func findRecordByDOI(cfg *Config, doi string) (string, error) {
+	if cfg.pgDB != nil {
+		ids, err := QueryRecordIds(cfg, fmt.Sprintf("pids.doi.identifier = %q or pids.doi.identifier = %q", doi, strings.ToLower(doi)))
+		if err != nil {
+			return "", err
+		}
+		if len(ids) > 1 {
+			return "", fmt.Errorf("ambiguous match, records %s", strings.Join(ids, ", "))
+		}
+		if len(ids) == 1 {
+			return ids[0], nil
+		}
+		return "", nil
+	}
+	hits, err := CheckDOI(cfg, doi)
+	if err != nil {
+		return "", err
+	}
+	candidates := existingFromHits(hits)
+	if len(candidates) == 0 {
+		return "", nil
+	}
+	picked, err := pickExistingRecord(candidates)
+	if err != nil {
+		return "", err
+	}
+	return picked.ID, nil
+}
+
+// linkRecordVersion creates a draft of recordId adding the DOI to its
+// related identifiers. If publish is true the draft is published. It
+// returns false if the draft already lists the DOI.
+func linkRecordVersion(cfg *Config, recordId string, relationType string, doi string, publish bool, debug bool) (bool, error) {
+	draft, err := NewDraft(cfg, recordId)
+	if err != nil {
+		return false, err
+	}
+	rec, err := objectRecord(draft)
+	if err != nil {
+		return false, err
+	}
+	patch, err := relatedDOIPatch(rec, relationType, doi)
+	if err != nil || patch == nil {
+		return false, err
+	}
+	patched, err := patch.Apply(draft)
+	if err != nil {
+		return false, err
+	}
+	payload, err := JSONMarshalIndent(patched, "", "    ")
+	if err != nil {
+		return false, err
+	}
+	if _, err := UpdateDraft(cfg, recordId, payload, debug); err != nil {
+		return false, err
+	}
+	if publish {
+		if _, err := PublishRecordVersion(cfg, recordId, "", "", debug); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// preprintLinks returns the links needed between the preprint record
+// and the versions CrossRef reports it was published as.
+func preprintLinks(cfg *Config, client *crossrefapi.CrossRefClient, recordId string, dryRun bool) ([]*VersionLink, error) {
+	rec, err := getPublishedRecord(cfg, recordId)
+	if err != nil {
+		return nil, err
+	}
+	doi := ""
+	if pid, ok := rec.ExternalPIDs["doi"]; ok && pid != nil {
+		doi = normalizeDOI(pid.Identifier)
+	}
+	if doi == "" {
+		return nil, fmt.Errorf("no DOI")
+	}
+	work, err := queryCrossRefWork(client, cfg, doi)
+	if err != nil {
+		return nil, err
+	}
+	// NOTE: targets pairs each link with the record it updates
+	type linkTarget struct {
+		rec  *simplified.Record
+		link *VersionLink
+	}
+	targets := []*linkTarget{}
+	for _, publishedDOI := range getWorksPreprintOf(work) {
+		targets = append(targets, &linkTarget{rec, &VersionLink{
+			RecordId:     recordId,
+			DOI:          doi,
+			RelationType: PreprintRelationType,
+			RelatedDOI:   publishedDOI,
+		}})
+		publishedId, err := findRecordByDOI(cfg, publishedDOI)
+		if err != nil {
+			return nil, fmt.Errorf("%s, %s", publishedDOI, err)
+		}
+		if publishedId == "" {
+			continue
+		}
+		published, err := getPublishedRecord(cfg, publishedId)
+		if err != nil {
+			return nil, fmt.Errorf("%s, %s", publishedId, err)
+		}
+		targets = append(targets, &linkTarget{published, &VersionLink{
+			RecordId:     publishedId,
+			DOI:          publishedDOI,
+			RelationType: PublishedRelationType,
+			RelatedDOI:   doi,
+		}})
+	}
+	links := []*VersionLink{}
+	for _, target := range targets {
+		patch, err := relatedDOIPatch(target.rec, target.link.RelationType, target.link.RelatedDOI)
+		if err != nil {
+			return nil, err
+		}
+		if patch == nil {
+			continue
+		}
+		if dryRun {
+			diff, err := patchRecordDiff(target.rec, patch)
+			if err != nil {
+				return nil, err
+			}
+			target.link.Diff = map[string]interface{}{}
+			if err := JSONUnmarshal(diff, &target.link.Diff); err != nil {
+				return nil, err
+			}
+		}
+		links = append(links, target.link)
+	}
+	return links, nil
+}
+
+// LinkVersions checks each preprint record in recordIds with CrossRef
+// for "is-preprint-of" relations and links the preprint and published
+// records with related identifiers. By default the proposed links are
+// written to out as a JSON array of VersionLink. If apply is true a
+// draft of each record is updated instead, if publish is also true the
+// drafts are published. If recordIds is empty the preprint records are
+// found with QueryRecordIds. CrossRef responses are read from and saved
+// to cfg.Cache when set.
+//
+// ```
+// if err := LinkVersions(cfg, nil, false, false, os.Stdout, false); err != nil {
+//    // ... handle error ...
+// }
+// ```
+func LinkVersions(cfg *Config, recordIds []string, apply bool, publish bool, out io.Writer, debug bool) error {
+	const maxErrors = 100
+	l := log.New(os.Stderr, "", 1)
+	if len(recordIds) == 0 {
+		ids, err := QueryRecordIds(cfg, PreprintFilter)
+		if err != nil {
+			return err
+		}
+		recordIds = ids
+	}
+	client, err := crossrefapi.NewCrossRefClient(path.Base(os.Args[0]), cfg.MailTo)
+	if err != nil {
+		return err
+	}
+	// NOTE: one request per second until CrossRef reports its limits
+	rl := &RateLimit{Limit: 1, Interval: 1}
+	tot := len(recordIds)
+	lCnt, eCnt := 0, 0
+	t0 := time.Now()
+	iTime, reportProgress := time.Now(), false
+	if !apply {
+		fmt.Fprintln(out, "[")
+	}
+	for i, recordId := range recordIds {
+		requests := 0
+		if cfg.Cache != nil {
+			requests = cfg.Cache.requests
+		}
+		links, err := preprintLinks(cfg, client, recordId, !apply)
+		if err != nil {
+			l.Printf("failed to link (%d) %q, %s", i, recordId, err)
+			eCnt++
+			if eCnt > maxErrors {
+				break
+			}
+		}
+		for _, link := range links {
+			if apply {
+				ok, err := linkRecordVersion(cfg, link.RecordId, link.RelationType, link.RelatedDOI, publish, debug)
+				if err != nil {
+					l.Printf("failed to link %q to %s, %s", link.RecordId, link.RelatedDOI, err)
+					eCnt++
+					continue
+				}
+				if !ok {
+					continue
+				}
+				if debug {
+					l.Printf("linked %q (%s) %s %s", link.RecordId, link.DOI, link.RelationType, link.RelatedDOI)
+				}
+			} else {
+				src, err := JSONMarshalIndent(link, "    ", "    ")
+				if err != nil {
+					return err
+				}
+				if lCnt > 0 {
+					fmt.Fprintln(out, ",")
+				}
+				fmt.Fprintf(out, "    %s", bytes.TrimSpace(src))
+			}
+			lCnt++
+		}
+		if iTime, reportProgress = CheckWaitInterval(iTime, time.Minute); reportProgress {
+			l.Printf("last id %q (%d/%d) %s: %s", recordId, i, tot, time.Since(t0).Round(time.Second), ProgressETA(t0, i, tot))
+		}
+		// NOTE: cached responses don't need throttling
+		if cfg.Cache == nil || cfg.Cache.requests != requests {
+			rl.FromLimitInterval(client.RateLimitLimit, client.RateLimitInterval)
+			rl.Throttle(i, tot)
+		}
+	}
+	if !apply {
+		fmt.Fprintln(out, "\n]")
+	}
+	if debug || apply {
+		l.Printf("%d preprints checked, %d links, %d errors, running time %s", tot, lCnt, eCnt, time.Since(t0).Round(time.Second))
+	}
+	if eCnt > maxErrors {
+		return fmt.Errorf("Stopped, %d errors encountered", eCnt)
+	}
+	if eCnt > 0 {
+		return fmt.Errorf("%d errors linking versions", eCnt)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package irdmtools
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/crossrefapi"
+	"github.com/caltechlibrary/simplified"
+)
+
+func TestGetWorksPreprintOf(t *testing.T) {
+	work := new(crossrefapi.Works)
+	if err := JSONUnmarshal([]byte(`{
+    "status": "ok",
+    "message": {
+        "DOI": "10.1101/2023.01.01.522222",
+        "type": "posted-content",
+        "relation": {
+            "is-preprint-of": [
+                { "id-type": "doi", "id": "10.1038/s41586-023-00001-1", "asserted-by": "subject" },
+                { "id-type": "uri", "id": "https://example.edu/article", "asserted-by": "subject" },
+                { "id-type": "doi", "id": "https://doi.org/10.1126/science.abc1234", "asserted-by": "object" }
+            ],
+            "has-review": [
+                { "id-type": "doi", "id": "10.5555/review", "asserted-by": "object" }
+            ]
+        }
+    }
+}`), &work); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"10.1038/s41586-023-00001-1", "10.1126/science.abc1234"}
+	if got := getWorksPreprintOf(work); !reflect.DeepEqual(expected, got) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+	if got := getWorksPreprintOf(new(crossrefapi.Works)); len(got) != 0 {
+		t.Errorf("expected no DOI, got %+v", got)
+	}
+}
+
+func TestRelatedDOIPatch(t *testing.T) {
+	rec := new(simplified.Record)
+	if err := JSONUnmarshal([]byte(`{
+    "id": "abcde-12345",
+    "metadata": {
+        "title": "A preprint",
+        "related_identifiers": [
+            { "identifier": "10.5555/data", "scheme": "doi", "relation_type": { "id": "issupplementedby" } }
+        ]
+    }
+}`), &rec); err != nil {
+		t.Fatal(err)
+	}
+	patch, err := relatedDOIPatch(rec, PreprintRelationType, "10.1038/s41586-023-00001-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patch == nil {
+		t.Fatal("expected a patch")
+	}
+	doc, err := recordObject(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patched, err := patch.Apply(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	linked, err := objectRecord(patched)
+	if err != nil {
+		t.Fatal(err)
+	}
+	identifiers := linked.Metadata.RelatedIdentifiers
+	if len(identifiers) != 2 {
+		t.Fatalf("expected 2 related identifiers, got %d", len(identifiers))
+	}
+	if identifiers[0].Identifier != "10.5555/data" {
+		t.Errorf("expected existing identifier kept, got %+v", identifiers[0])
+	}
+	if identifiers[1].Identifier != "10.1038/s41586-023-00001-1" || identifiers[1].Scheme != "doi" ||
+		identifiers[1].RelationType == nil || identifiers[1].RelationType.ID != PreprintRelationType {
+		t.Errorf("unexpected related identifier %+v", identifiers[1])
+	}
+	if linked.Metadata.Title != "A preprint" {
+		t.Errorf("expected title unchanged, got %q", linked.Metadata.Title)
+	}
+
+	// The DOI is already listed, whatever its relation type or case
+	patch, err = relatedDOIPatch(linked, PreprintRelationType, "10.1038/S41586-023-00001-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patch != nil {
+		t.Errorf("expected no patch for a linked DOI, got %+v", patch.Operations)
+	}
+	if patch, _ = relatedDOIPatch(linked, PublishedRelationType, "10.5555/data"); patch != nil {
+		t.Errorf("expected no patch for a related DOI, got %+v", patch.Operations)
+	}
+
+	// A record without related identifiers gets the list
+	rec = new(simplified.Record)
+	rec.Metadata = &simplified.Metadata{Title: "An article"}
+	patch, err = relatedDOIPatch(rec, PublishedRelationType, "10.1101/2023.01.01.522222")
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff, err := patchRecordDiff(rec, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff) == 0 {
+		t.Errorf("expected a diff")
+	}
+}
+
+func TestGetPublishedRecordWithoutPostgres(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/records/aaaaa-00001" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"id": "aaaaa-00001", "pids": {"doi": {"identifier": "10.1101/2023.01.01.522222", "provider": "external"}}}`))
+	}))
+	defer ts.Close()
+	cfg := &Config{InvenioAPI: ts.URL, InvenioToken: "token", rl: new(RateLimit)}
+	rec, err := getPublishedRecord(cfg, "aaaaa-00001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pid, ok := rec.ExternalPIDs["doi"]; !ok || pid.Identifier != "10.1101/2023.01.01.522222" {
+		t.Errorf("unexpected record %+v", rec)
+	}
+}
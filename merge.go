@@ -0,0 +1,231 @@
+package irdmtools
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/simplified"
+)
+
+// DefaultMergePrecedence is the order sources are used for fields without
+// a merge precedence rule.
+var DefaultMergePrecedence = []string{"crossref", "datacite"}
+
+// Provenance records where the fields of a merged record came from.
+type Provenance struct {
+	// DOI of the merged record
+	DOI string `json:"doi"`
+	// Sources maps each source queried to "ok" or the error retrieving it
+	Sources map[string]string `json:"sources"`
+	// Fields maps the field path in the record, e.g. "metadata.description"
+	// or "custom_fields.journal:journal.volume", to the source used
+	Fields map[string]string `json:"fields"`
+}
+
+// isEmptyValue returns true for missing values, empty strings, lists and
+// objects.
+func isEmptyValue(val interface{}) bool {
+	switch v := val.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(v) == ""
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// recordObject returns a record as a map.
+func recordObject(rec *simplified.Record) (map[string]interface{}, error) {
+	src, err := JSONMarshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	obj := map[string]interface{}{}
+	if err := JSONUnmarshal(src, &obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// objectRecord returns a map as a record.
+func objectRecord(obj map[string]interface{}) (*simplified.Record, error) {
+	src, err := JSONMarshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	rec := new(simplified.Record)
+	if err := JSONUnmarshal(src, &rec); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// getPublishedRecord returns the published record id from Postgres if
+// the connection is open, otherwise from the RDM API.
+func getPublishedRecord(cfg *Config, id string) (*simplified.Record, error) {
+	if cfg.pgDB != nil {
+		return GetRecord(cfg, id, false)
+	}
+	obj, err := GetRawRecord(cfg, id)
+	if err != nil {
+		return nil, err
+	}
+	return objectRecord(obj)
+}
+
+// recordFields returns the mergable fields of a record as field paths
+// and values. Metadata fields are merged individually as are the fields
+// of each custom field (e.g. journal volume and issue).
+func recordFields(obj map[string]interface{}) map[string]interface{} {
+	fields := map[string]interface{}{}
+	for k, v := range obj {
+		m, isMap := v.(map[string]interface{})
+		switch {
+		case k == "metadata" && isMap:
+			for field, val := range m {
+				fields[k+"."+field] = val
+			}
+		case k == "custom_fields" && isMap:
+			for customField, val := range m {
+				if cf, ok := val.(map[string]interface{}); ok {
+					for field, fieldVal := range cf {
+						fields[k+"."+customField+"."+field] = fieldVal
+					}
+				} else {
+					fields[k+"."+customField] = val
+				}
+			}
+		default:
+			fields[k] = v
+		}
+	}
+	return fields
+}
+
+// setRecordField sets a field path returned by recordFields.
+func setRecordField(obj map[string]interface{}, fieldPath string, val interface{}) {
+	parts := strings.SplitN(fieldPath, ".", 3)
+	if len(parts) == 1 {
+		obj[fieldPath] = val
+		return
+	}
+	m, ok := obj[parts[0]].(map[string]interface{})
+	if !ok {
+		m = map[string]interface{}{}
+		obj[parts[0]] = m
+	}
+	if len(parts) == 2 {
+		m[parts[1]] = val
+		return
+	}
+	cf, ok := m[parts[1]].(map[string]interface{})
+	if !ok {
+		cf = map[string]interface{}{}
+		m[parts[1]] = cf
+	}
+	cf[parts[2]] = val
+}
+
+// mergePrecedence returns the order sources are used for a field. The
+// longest rule matching the field path or one of its parents is used,
+// e.g. "custom_fields.journal:journal" applies to the journal volume and
+// issue. Otherwise the "default" rule or DefaultMergePrecedence is used.
+func mergePrecedence(precedence map[string][]string, fieldPath string) []string {
+	best := ""
+	for rule := range precedence {
+		if (fieldPath == rule || strings.HasPrefix(fieldPath, rule+".")) && len(rule) > len(best) {
+			best = rule
+		}
+	}
+	if best != "" {
+		return precedence[best]
+	}
+	if sources, ok := precedence["default"]; ok {
+		return sources
+	}
+	return DefaultMergePrecedence
+}
+
+// MergeRdmRecords combines records retrieved from different sources
+// field by field. For each field the first source in the field's
+// precedence (see mergePrecedence) with a non-empty value is used,
+// sources not listed are used last in name order. The returned
+// provenance records the source of each field.
+//
+// ```
+// precedence := map[string][]string{
+//     "metadata.description": []string{ "datacite", "crossref" },
+//     "metadata.funding": []string{ "crossref", "datacite" },
+// }
+// rec, provenance, err := MergeRdmRecords(map[string]*simplified.Record{
+//     "crossref": crRecord,
+//     "datacite": dcRecord,
+// }, precedence)
+// ```
+func MergeRdmRecords(records map[string]*simplified.Record, precedence map[string][]string) (*simplified.Record, *Provenance, error) {
+	provenance := &Provenance{
+		Sources: map[string]string{},
+		Fields:  map[string]string{},
+	}
+	names := []string{}
+	sourceFields := map[string]map[string]interface{}{}
+	for name, rec := range records {
+		if rec == nil {
+			continue
+		}
+		obj, err := recordObject(rec)
+		if err != nil {
+			return nil, nil, err
+		}
+		names = append(names, name)
+		sourceFields[name] = recordFields(obj)
+		provenance.Sources[name] = "ok"
+	}
+	if len(names) == 0 {
+		return nil, nil, fmt.Errorf("no records to merge")
+	}
+	sort.Strings(names)
+	fieldPaths := map[string]bool{}
+	for _, fields := range sourceFields {
+		for fieldPath := range fields {
+			fieldPaths[fieldPath] = true
+		}
+	}
+	merged := map[string]interface{}{}
+	for fieldPath := range fieldPaths {
+		order := append([]string{}, mergePrecedence(precedence, fieldPath)...)
+		for _, name := range names {
+			if !inList(order, name) {
+				order = append(order, name)
+			}
+		}
+		for _, name := range order {
+			if val, ok := sourceFields[name][fieldPath]; ok && !isEmptyValue(val) {
+				setRecordField(merged, fieldPath, val)
+				provenance.Fields[fieldPath] = name
+				break
+			}
+		}
+	}
+	rec, err := objectRecord(merged)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rec, provenance, nil
+}
+
+// inList returns true if val is in the list.
+func inList(list []string, val string) bool {
+	for _, s := range list {
+		if s == val {
+			return true
+		}
+	}
+	return false
+}
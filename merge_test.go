@@ -0,0 +1,201 @@
+package irdmtools
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/simplified"
+)
+
+func TestMergePrecedence(t *testing.T) {
+	precedence := map[string][]string{
+		"default":                       {"crossref", "datacite"},
+		"metadata.description":          {"datacite", "crossref"},
+		"custom_fields.journal:journal": {"datacite"},
+	}
+	tests := map[string]string{
+		"metadata.title":                       "crossref",
+		"metadata.description":                 "datacite",
+		"custom_fields.journal:journal.volume": "datacite",
+		"custom_fields.journal:journalx.title": "crossref",
+	}
+	for fieldPath, expected := range tests {
+		if order := mergePrecedence(precedence, fieldPath); len(order) == 0 || order[0] != expected {
+			t.Errorf("expected %q to use %q first, got %+v", fieldPath, expected, order)
+		}
+	}
+	if order := mergePrecedence(nil, "metadata.title"); len(order) != 2 || order[0] != "crossref" {
+		t.Errorf("expected default merge precedence, got %+v", order)
+	}
+}
+
+func TestMergeRdmRecords(t *testing.T) {
+	crRecord, dcRecord := new(simplified.Record), new(simplified.Record)
+	crRecord.Metadata, dcRecord.Metadata = new(simplified.Metadata), new(simplified.Metadata)
+	SetDOI(crRecord, "10.1000/test.1")
+	SetTitle(crRecord, "CrossRef title")
+	SetDescription(crRecord, "CrossRef abstract")
+	SetVolume(crRecord, "12")
+	SetFunding(crRecord, []*simplified.Funder{{Award: &simplified.AwardIdentifier{Number: "PHY-1234567"}}})
+	SetTitle(dcRecord, "DataCite title")
+	SetDescription(dcRecord, "DataCite abstract")
+	SetIssue(dcRecord, "3")
+	precedence := map[string][]string{
+		"metadata.description": {"datacite", "crossref"},
+	}
+	rec, provenance, err := MergeRdmRecords(map[string]*simplified.Record{
+		"crossref": crRecord,
+		"datacite": dcRecord,
+	}, precedence)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Metadata == nil || rec.Metadata.Title != "CrossRef title" || rec.Metadata.Description != "DataCite abstract" {
+		t.Errorf("unexpected merged metadata %+v", rec.Metadata)
+	}
+	if len(rec.Metadata.Funding) != 1 {
+		t.Errorf("expected funding from CrossRef, got %+v", rec.Metadata.Funding)
+	}
+	expected := map[string]string{
+		"metadata.title":                       "crossref",
+		"metadata.description":                 "datacite",
+		"metadata.funding":                     "crossref",
+		"custom_fields.journal:journal.volume": "crossref",
+		"custom_fields.journal:journal.issue":  "datacite",
+	}
+	for fieldPath, source := range expected {
+		if provenance.Fields[fieldPath] != source {
+			t.Errorf("expected %q from %q, got %q", fieldPath, source, provenance.Fields[fieldPath])
+		}
+	}
+	journal, ok := rec.CustomFields["journal:journal"].(map[string]interface{})
+	if !ok || journal["volume"] != "12" || journal["issue"] != "3" {
+		t.Errorf("expected journal volume and issue from both sources, got %+v", rec.CustomFields["journal:journal"])
+	}
+	if _, _, err := MergeRdmRecords(map[string]*simplified.Record{}, nil); err == nil {
+		t.Errorf("expected an error merging no records")
+	}
+}
+
+func TestGetMergedRecordOffline(t *testing.T) {
+	cache, err := OpenResponseCache(path.Join(t.TempDir(), "api_cache.ds"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+	doi := "10.1000/test.1"
+	cache.Put("crossref", doi, []byte(`{
+    "status": "ok",
+    "message-type": "work",
+    "message": {
+        "DOI": "10.1000/test.1",
+        "type": "journal-article",
+        "title": [ "A merged work" ],
+        "abstract": "CrossRef abstract",
+        "funder": [ { "name": "National Science Foundation", "award": [ "PHY-1234567" ] } ]
+    }
+}`))
+	cache.Put("datacite", doi, []byte(`{
+    "data": {
+        "id": "10.1000/test.1",
+        "attributes": {
+            "doi": "10.1000/test.1",
+            "titles": [ { "title": "A merged work (DataCite)" } ],
+            "descriptions": [ { "description": "DataCite abstract", "descriptionType": "Abstract" } ],
+            "types": { "resourceTypeGeneral": "Text", "resourceType": "Article" }
+        }
+    }
+}`))
+	cache.Offline = true
+	cfg := new(Config)
+	cfg.Cache = cache
+	options, err := LoadDoi2RdmOptions("", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch, err := newDoiBatch(cfg, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, provenance, err := batch.getMergedRecord(doi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Metadata.Title != "A merged work" || rec.Metadata.Description != "DataCite abstract" {
+		t.Errorf("unexpected merged record %+v", rec.Metadata)
+	}
+	if provenance.DOI != doi || provenance.Sources["crossref"] != "ok" || provenance.Sources["datacite"] != "ok" {
+		t.Errorf("unexpected provenance %+v", provenance)
+	}
+	if provenance.Fields["metadata.funding"] != "crossref" || provenance.Fields["metadata.description"] != "datacite" {
+		t.Errorf("unexpected field provenance %+v", provenance.Fields)
+	}
+	// Neither source has the DOI
+	if _, _, err := batch.getMergedRecord("10.1000/test.2"); err == nil {
+		t.Errorf("expected an error when neither source has the DOI")
+	}
+}
+
+func TestStageRecordFields(t *testing.T) {
+	rec := new(simplified.Record)
+	rec.Metadata = new(simplified.Metadata)
+	SetTitle(rec, "DOI title")
+	SetDescription(rec, "DOI abstract")
+	SetVolume(rec, "12")
+	SetIssue(rec, "3")
+	draft := map[string]interface{}{
+		"id": "qez01-2309a",
+		"metadata": map[string]interface{}{
+			"title":       "RDM title",
+			"description": "RDM abstract",
+		},
+		"custom_fields": map[string]interface{}{
+			"journal:journal": map[string]interface{}{
+				"title": "RDM journal",
+			},
+		},
+	}
+	staged, err := stageRecordFields(draft, rec, []string{"metadata.description", " custom_fields.journal:journal"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"custom_fields.journal:journal.issue", "custom_fields.journal:journal.volume", "metadata.description"}
+	if strings.Join(staged, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected staged %q, got %q", expected, staged)
+	}
+	metadata := draft["metadata"].(map[string]interface{})
+	if metadata["title"] != "RDM title" || metadata["description"] != "DOI abstract" {
+		t.Errorf("expected only the description staged, got %+v", metadata)
+	}
+	journal := draft["custom_fields"].(map[string]interface{})["journal:journal"].(map[string]interface{})
+	if journal["title"] != "RDM journal" || journal["volume"] != "12" || journal["issue"] != "3" {
+		t.Errorf("expected volume and issue staged with the journal title kept, got %+v", journal)
+	}
+	if _, err := stageRecordFields(draft, rec, []string{"access"}); err == nil {
+		t.Errorf("expected an error staging access")
+	}
+	if _, err := stageRecordFields(draft, rec, []string{"metadata.funding"}); err == nil {
+		t.Errorf("expected an error staging a field missing from the DOI record")
+	}
+}
+
+func TestCommonRecordFields(t *testing.T) {
+	live, rec := new(simplified.Record), new(simplified.Record)
+	live.Metadata, rec.Metadata = new(simplified.Metadata), new(simplified.Metadata)
+	live.ID = "qez01-2309a"
+	SetTitle(live, "RDM title")
+	SetDescription(live, "RDM abstract")
+	SetTitle(rec, "DOI title")
+	oRecord, nRecord, err := commonRecordFields(live, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oRecord.ID != "" || oRecord.Metadata == nil || oRecord.Metadata.Title != "RDM title" || oRecord.Metadata.Description != "" {
+		t.Errorf("expected only the live title, got %+v", oRecord)
+	}
+	if nRecord.Metadata == nil || nRecord.Metadata.Title != "DOI title" {
+		t.Errorf("expected the DOI title, got %+v", nRecord.Metadata)
+	}
+}
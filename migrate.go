@@ -0,0 +1,658 @@
+package irdmtools
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	// Caltech Library Packages
+	"github.com/caltechlibrary/simplified"
+)
+
+// Migration of EPrint records to RDM.
+//
+// Each EPrint is crosswalked to a simplified record and created in RDM,
+// the files are copied from the EPrints archive and attached. EPrint
+// documents with different security settings are migrated as versions of
+// the record in the order internal, validuser, public with a metadata
+// only version when there are no public files. The first version is
+// submitted to the community and accepted, later versions are published.
+// This mirrors eprints_to_rdm.py.
+
+const (
+	// MigratedRecordsCSV is the default name of the migration log
+	MigratedRecordsCSV = "migrated_records.csv"
+
+	// MigrationMigrated is the status of a record migrated
+	MigrationMigrated = "migrated"
+	// MigrationExists is the status of an EPrint already in RDM
+	MigrationExists = "exists"
+	// MigrationDeleted is the status of an EPrint deleted in EPrints
+	MigrationDeleted = "deleted"
+	// MigrationFailed is the status of a record that failed to migrate
+	MigrationFailed = "failed"
+)
+
+var (
+	// migrationContentTypes maps EPrint document content to the label
+	// used in the file descriptions.
+	migrationContentTypes = map[string]string{
+		"accepted":     "Accepted Version",
+		"archival":     "Archival Material",
+		"bibliography": "Bibliography",
+		"coverimage":   "Cover Image",
+		"discussion":   "Discussion",
+		"draft":        "Draft",
+		"erratum":      "Erratum",
+		"inpress":      "In Press",
+		"metadata":     "Additional Metadata",
+		"other":        "Other",
+		"permission":   "Release Permission",
+		"presentation": "Presentation",
+		"reprint":      "Reprint",
+		"submitted":    "Submitted",
+		"supplemental": "Supplemental Material",
+		"updated":      "Updated",
+		"waiver":       "OA Policy Waiver",
+		"published":    "Published",
+	}
+
+	// migrationFilenameReplacer makes EPrint filenames safe for RDM
+	migrationFilenameReplacer = strings.NewReplacer(
+		"&", "_", "%", "_", "[", "_", "]", "_", " ", "_", "(", "_", ")", "_",
+	)
+)
+
+// MigrationLogEntry is a row in the migration log.
+type MigrationLogEntry struct {
+	EPrintID string
+	RdmID    string
+	Status   string
+}
+
+// migrationFile describes an EPrint file to attach to an RDM draft.
+type migrationFile struct {
+	// Filename is the name in the EPrints archive
+	Filename string
+	// Target is the name used in RDM
+	Target      string
+	URL         string
+	Pos         int
+	Content     string
+	Description string
+}
+
+// ReadMigrationLog reads the migration log returning the last status
+// of each eprint id. A missing log is not an error.
+func ReadMigrationLog(fName string) (map[string]*MigrationLogEntry, error) {
+	entries := map[string]*MigrationLogEntry{}
+	fp, err := os.Open(fName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return entries, nil
+		}
+		return nil, err
+	}
+	defer fp.Close()
+	r := csv.NewReader(fp)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(row) < 3 {
+			continue
+		}
+		entries[row[0]] = &MigrationLogEntry{EPrintID: row[0], RdmID: row[1], Status: row[2]}
+	}
+	return entries, nil
+}
+
+// appendMigrationLog appends an entry to the migration log.
+func appendMigrationLog(fName string, entry *MigrationLogEntry) error {
+	fp, err := os.OpenFile(fName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0664)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	w := csv.NewWriter(fp)
+	if err := w.Write([]string{entry.EPrintID, entry.RdmID, entry.Status}); err != nil {
+		return err
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// migrationRestrictions returns the document security settings to
+// migrate as versions in order.
+func migrationRestrictions(rec *simplified.Record) []string {
+	found := map[string]bool{}
+	if rec.Files != nil {
+		for _, entry := range rec.Files.Entries {
+			if security, ok := entry.Metadata["security"].(string); ok && security != "" {
+				found[security] = true
+			}
+		}
+	}
+	restrictions := []string{}
+	for _, security := range []string{"internal", "validuser", "public"} {
+		if found[security] {
+			restrictions = append(restrictions, security)
+		}
+	}
+	if !found["public"] {
+		restrictions = append(restrictions, "metadata_only")
+	}
+	return restrictions
+}
+
+// migrationFiles returns the files with the document security setting.
+func migrationFiles(rec *simplified.Record, security string) []*migrationFile {
+	files := []*migrationFile{}
+	if security == "metadata_only" || rec.Files == nil {
+		return files
+	}
+	for fName, entry := range rec.Files.Entries {
+		if s, _ := entry.Metadata["security"].(string); s != security {
+			continue
+		}
+		file := &migrationFile{Filename: fName, URL: entry.FileID, Pos: 1}
+		if s, ok := entry.Metadata["filename"].(string); ok && s != "" {
+			file.Filename = s
+		}
+		file.Target = migrationFilenameReplacer.Replace(file.Filename)
+		switch pos := entry.Metadata["pos"].(type) {
+		case int:
+			file.Pos = pos
+		case float64:
+			file.Pos = int(pos)
+		}
+		if content, ok := entry.Metadata["content"].(string); ok {
+			file.Content = migrationContentTypes[content]
+		}
+		file.Description, _ = entry.Metadata["format_desc"].(string)
+		files = append(files, file)
+	}
+	return files
+}
+
+// eprintDocumentPath returns the path of a file in the EPrints archive
+// from the eprint's dir, e.g. <archives>/<repo_id>/documents/disk0/00/08/55/42/01/article.pdf
+func eprintDocumentPath(archivesPath string, repoId string, dir string, pos int, fName string) string {
+	return path.Join(archivesPath, repoId, "documents", dir, fmt.Sprintf("%02d", pos), fName)
+}
+
+// getEPrintFile retrieves an EPrint file from its URL sending the
+// EPrints user and password, if configured, as basic auth.
+func getEPrintFile(cfg *Config, u string) ([]byte, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.EPrintUser != "" {
+		req.SetBasicAuth(cfg.EPrintUser, cfg.EPrintPassword)
+	}
+	appName := path.Base(os.Args[0])
+	req.Header.Set("User-Agent", fmt.Sprintf("%s %s", appName, Version))
+	client := &http.Client{
+		Timeout: 300 * time.Second,
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s for %s", res.Status, u)
+	}
+	return io.ReadAll(res.Body)
+}
+
+// retrieveEPrintFile copies an EPrint file into dName. If the EPrints
+// archive is available on the local file system it is copied, if the
+// archive path is configured but not local it is copied with scp from
+// the EPrint host, otherwise it is retrieved from the file's URL. The
+// dir is the eprint's directory in the archive (the dir column of the
+// eprint table).
+func retrieveEPrintFile(cfg *Config, dir string, file *migrationFile, dName string) (string, error) {
+	target := filepath.Join(dName, file.Target)
+	if cfg.EPrintArchivesPath != "" {
+		if cfg.RepoID == "" || dir == "" {
+			return "", fmt.Errorf("the repository id and eprint dir are needed to find %q in %s", file.Filename, cfg.EPrintArchivesPath)
+		}
+		src := eprintDocumentPath(cfg.EPrintArchivesPath, cfg.RepoID, dir, file.Pos, file.Filename)
+		if _, err := os.Stat(src); err == nil {
+			in, err := os.Open(src)
+			if err != nil {
+				return "", err
+			}
+			defer in.Close()
+			out, err := os.Create(target)
+			if err != nil {
+				return "", err
+			}
+			if _, err := io.Copy(out, in); err != nil {
+				out.Close()
+				return "", err
+			}
+			return target, out.Close()
+		}
+		cmd := exec.Command("scp", fmt.Sprintf("%s:%s", cfg.EPrintHost, src), target)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return "", fmt.Errorf("scp %s, %s, %s", src, err, strings.TrimSpace(string(out)))
+		}
+		return target, nil
+	}
+	if file.URL == "" {
+		return "", fmt.Errorf("no URL or archive path for %q", file.Filename)
+	}
+	src, err := getEPrintFile(cfg, file.URL)
+	if err != nil {
+		return "", err
+	}
+	return target, os.WriteFile(target, src, 0664)
+}
+
+// migrationPayload returns the JSON for creating the RDM record from
+// the crosswalked record.
+func migrationPayload(rec *simplified.Record) (map[string]interface{}, error) {
+	src, err := JSONMarshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]interface{}{}
+	if err := JSONUnmarshal(src, &m); err != nil {
+		return nil, err
+	}
+	payload := map[string]interface{}{
+		"access": map[string]interface{}{"record": "public", "files": "public"},
+		"files":  map[string]interface{}{"enabled": rec.Files != nil, "order": []interface{}{}},
+	}
+	for _, key := range []string{"metadata", "custom_fields", "pids"} {
+		if val, ok := m[key]; ok && val != nil {
+			payload[key] = val
+		}
+	}
+	// NOTE: the attached files description is rebuilt as files are uploaded.
+	if metadata, ok := payload["metadata"].(map[string]interface{}); ok {
+		if descriptions, ok := metadata["additional_descriptions"].([]interface{}); ok {
+			keep := []interface{}{}
+			for _, elem := range descriptions {
+				if desc, ok := elem.(map[string]interface{}); ok {
+					if t, _ := desc["type"].(map[string]interface{}); t != nil && t["id"] == "attached-files" {
+						continue
+					}
+				}
+				keep = append(keep, elem)
+			}
+			metadata["additional_descriptions"] = keep
+		}
+	}
+	return payload, nil
+}
+
+// migrationVersion attaches the files for one document security setting
+// to the draft rdmId, sets its version label and access then publishes
+// it. The first version is submitted to the community and accepted. A
+// resumed draft left by a failed migration has the files it already
+// has removed and is not submitted to the community twice.
+func migrationVersion(cfg *Config, eprintId int, dir string, rec *simplified.Record, rdmId string, security string, firstVersion bool, resumed bool, communityId string, internalNote string, pubDate string, workDir string, debug bool) error {
+	if resumed {
+		if err := clearDraftFiles(cfg, rdmId, debug); err != nil {
+			return err
+		}
+	}
+	files := migrationFiles(rec, security)
+	fileDescription, campusDescription := "", ""
+	fileTypes := []string{}
+	addType := func(t string) {
+		for _, s := range fileTypes {
+			if s == t {
+				return
+			}
+		}
+		fileTypes = append(fileTypes, t)
+	}
+	if len(files) > 0 {
+		dName := filepath.Join(workDir, fmt.Sprintf("%d", eprintId))
+		if security == "validuser" {
+			// NOTE: campus only files are staged for the campus restricted
+			// file service rather than uploaded to RDM.
+			dName = filepath.Join(workDir, "s3_uploads", rdmId)
+		}
+		if err := os.MkdirAll(dName, 0775); err != nil {
+			return err
+		}
+		uploads := []string{}
+		for _, file := range files {
+			fName, err := retrieveEPrintFile(cfg, dir, file, dName)
+			if err != nil {
+				return err
+			}
+			if file.Content != "" {
+				fileDescription += fmt.Sprintf(`<p>%s - <a href="/records/%s/files/%s?download=1">%s</a></p>`, file.Content, rdmId, file.Target, file.Target)
+				addType(file.Content)
+			}
+			if security == "validuser" {
+				campusDescription += fmt.Sprintf("     <li><a href=\"https://campus-restricted.library.caltech.edu/%s/%s\">%s</a></li>\n", rdmId, file.Target, file.Target)
+				addType("campus only")
+			} else {
+				uploads = append(uploads, fName)
+			}
+		}
+		if len(uploads) > 0 {
+			if _, err := SetFilesEnable(cfg, rdmId, true, debug); err != nil {
+				return err
+			}
+			if _, err := UploadFiles(cfg, rdmId, uploads, debug); err != nil {
+				return err
+			}
+			for _, fName := range uploads {
+				os.Remove(fName)
+			}
+		}
+	}
+	if (fileDescription != "" && security == "public") || campusDescription != "" {
+		draft, err := GetDraft(cfg, rdmId)
+		if err != nil {
+			return err
+		}
+		metadata, ok := draft["metadata"].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("missing metadata element in draft record %s", rdmId)
+		}
+		descriptions, _ := metadata["additional_descriptions"].([]interface{})
+		if fileDescription != "" && security == "public" {
+			descriptions = append(descriptions, map[string]interface{}{
+				"type":        map[string]interface{}{"id": "attached-files"},
+				"description": fileDescription,
+			})
+		}
+		if campusDescription != "" {
+			descriptions = append(descriptions, map[string]interface{}{
+				"type":        map[string]interface{}{"id": "files"},
+				"description": "The files for this record are restricted to users on the Caltech campus network:<p><ul>\n" + campusDescription,
+			})
+		}
+		metadata["additional_descriptions"] = descriptions
+		metadata["version"] = strings.Join(fileTypes, " + ")
+		payload, err := JSONMarshalIndent(draft, "", "    ")
+		if err != nil {
+			return err
+		}
+		if _, err := UpdateDraft(cfg, rdmId, payload, debug); err != nil {
+			return err
+		}
+	} else if _, err := SetVersion(cfg, rdmId, security, debug); err != nil {
+		return err
+	}
+	access := "public"
+	if security == "internal" {
+		access = "restricted"
+	}
+	if _, err := SetAccess(cfg, rdmId, "files", access, debug); err != nil {
+		return err
+	}
+	if _, err := SetAccess(cfg, rdmId, "record", access, debug); err != nil {
+		return err
+	}
+	if security == "validuser" || security == "metadata_only" {
+		if _, err := SetFilesEnable(cfg, rdmId, false, debug); err != nil {
+			return err
+		}
+	}
+	if !firstVersion {
+		_, err := PublishRecordVersion(cfg, rdmId, security, pubDate, debug)
+		return err
+	}
+	if !resumed || !reviewSubmitted(cfg, rdmId, debug) {
+		if _, err := SendToCommunity(cfg, rdmId, communityId, debug); err != nil {
+			return err
+		}
+	}
+	_, err := ReviewRequest(cfg, rdmId, "accept", internalNote, debug)
+	return err
+}
+
+// clearDraftFiles removes the files already uploaded to the draft
+// rdmId so they can be uploaded again.
+func clearDraftFiles(cfg *Config, rdmId string, debug bool) error {
+	m, err := GetDraftFiles(cfg, rdmId, debug)
+	if err != nil {
+		return err
+	}
+	entries, _ := m["entries"].([]interface{})
+	keys := []string{}
+	for _, elem := range entries {
+		if entry, ok := elem.(map[string]interface{}); ok {
+			if key, ok := entry["key"].(string); ok && key != "" {
+				keys = append(keys, key)
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err = DeleteFiles(cfg, rdmId, keys, debug)
+	return err
+}
+
+// reviewSubmitted returns true if the draft rdmId has been submitted
+// for review.
+func reviewSubmitted(cfg *Config, rdmId string, debug bool) bool {
+	review, err := GetReview(cfg, rdmId, debug)
+	if err != nil {
+		return false
+	}
+	status, _ := review["status"].(string)
+	return status == "submitted"
+}
+
+// publishedVersions returns the number of published versions of the
+// record rootId, zero if it is still a draft.
+func publishedVersions(cfg *Config, rootId string) (int, error) {
+	if _, err := GetRawRecord(cfg, rootId); err != nil {
+		// NOTE: an unpublished record must still have its draft.
+		if _, err := GetDraft(cfg, rootId); err != nil {
+			return 0, fmt.Errorf("can't resume %s, %s", rootId, err)
+		}
+		return 0, nil
+	}
+	versions, err := GetVersions(cfg, rootId)
+	if err != nil {
+		return 0, err
+	}
+	if hits, ok := versions["hits"].(map[string]interface{}); ok {
+		switch total := hits["total"].(type) {
+		case json.Number:
+			n, err := total.Int64()
+			return int(n), err
+		case float64:
+			return int(total), nil
+		}
+	}
+	return 0, fmt.Errorf("can't count the versions of %s", rootId)
+}
+
+// MigrateEPrint migrates a single EPrint to RDM returning the RDM id of
+// the record and the migration status. If the EPrint is already in RDM
+// (see findExistingRecord) it is not migrated again. The fixups, if not
+// nil, are applied to the crosswalked record. If resumeId is set it is
+// the RDM id of a record left unfinished by a failed migration, it is
+// finished rather than creating a new record. A failed migration returns
+// the RDM id of the record, if one was created, so it can be resumed.
+//
+// ```
+// rdmId, status, err := MigrateEPrint(cfg, 85542, resourceTypes, contributorTypes, fixups, cfg.InvenioCommunityID, ".", "", false)
+// if err != nil {
+//    // ... handle error ...
+// }
+// ```
+func MigrateEPrint(cfg *Config, eprintId int, resourceTypes map[string]string, contributorTypes map[string]string, fixups *Fixups, communityId string, workDir string, resumeId string, debug bool) (string, string, error) {
+	rootId := resumeId
+	eprints, err := GetEPrint(cfg, eprintId, time.Duration(timeoutSeconds), 3)
+	if err != nil {
+		return rootId, MigrationFailed, err
+	}
+	if eprints == nil || len(eprints.EPrint) == 0 {
+		return rootId, MigrationFailed, fmt.Errorf("eprint %d not found", eprintId)
+	}
+	dir := eprints.EPrint[0].Dir
+	rec := new(simplified.Record)
+	if err := CrosswalkEPrintToRecord(eprints.EPrint[0], rec, resourceTypes, contributorTypes); err != nil {
+		return rootId, MigrationFailed, err
+	}
+	if rec.Tombstone != nil {
+		return rootId, MigrationDeleted, nil
+	}
+	if err := fixups.Apply(rec); err != nil {
+		return rootId, MigrationFailed, err
+	}
+	payload, err := migrationPayload(rec)
+	if err != nil {
+		return rootId, MigrationFailed, err
+	}
+	internalNote := ""
+	if note, ok := rec.CustomFields["caltech:internal_note"].(string); ok {
+		internalNote = strings.Trim(note, "\n")
+	}
+	pubDate := time.Now().Format(datestamp)
+	if rec.Metadata != nil && rec.Metadata.PublicationDate != "" {
+		pubDate = rec.Metadata.PublicationDate
+	}
+	restrictions, next := migrationRestrictions(rec), 0
+	if rootId == "" {
+		// NOTE: a resumed record isn't looked up, findExistingRecord
+		// would find its draft.
+		existing, err := findExistingRecord(cfg, payload)
+		if err != nil {
+			return "", MigrationFailed, err
+		}
+		if existing != nil {
+			return existing.ID, MigrationExists, nil
+		}
+		src, err := JSONMarshalIndent(payload, "", "    ")
+		if err != nil {
+			return "", MigrationFailed, err
+		}
+		draft, err := NewRecord(cfg, src)
+		if err != nil {
+			return "", MigrationFailed, err
+		}
+		if rootId, _ = draft["id"].(string); rootId == "" {
+			return "", MigrationFailed, fmt.Errorf("new record for eprint %d has no id", eprintId)
+		}
+		dbgPrintf(cfg, "%d, %s, created draft", eprintId, rootId)
+	} else {
+		if next, err = publishedVersions(cfg, rootId); err != nil {
+			return rootId, MigrationFailed, err
+		}
+		dbgPrintf(cfg, "%d, %s, resuming after %d versions", eprintId, rootId, next)
+	}
+	rdmId := rootId
+	for i := next; i < len(restrictions); i++ {
+		security := restrictions[i]
+		if i > 0 {
+			// NOTE: RDM returns the draft of the next version if a
+			// failed migration left one.
+			draft, err := NewRecordVersion(cfg, rootId)
+			if err != nil {
+				return rootId, MigrationFailed, err
+			}
+			if rdmId, _ = draft["id"].(string); rdmId == "" {
+				return rootId, MigrationFailed, fmt.Errorf("new version of %s has no id", rootId)
+			}
+		}
+		resumed := resumeId != "" && i == next
+		if err := migrationVersion(cfg, eprintId, dir, rec, rdmId, security, i == 0, resumed, communityId, internalNote, pubDate, workDir, debug); err != nil {
+			return rootId, MigrationFailed, fmt.Errorf("%s version %s, %s", security, rdmId, err)
+		}
+		dbgPrintf(cfg, "%d, %s, %s", eprintId, rdmId, security)
+	}
+	return rootId, MigrationMigrated, nil
+}
+
+// Migrate migrates the EPrints in eprintIds to RDM logging the eprint id,
+// RDM id and status of each to the CSV file logName. EPrints logged as
+// migrated or existing in a previous run are skipped, those that failed
+// after their RDM record was created are resumed.
+//
+// ```
+// app := new(irdmtools.EPrint2Rdm)
+// // ... configure app ...
+// err := app.Migrate([]string{ "85542" }, resourceTypes, contributorTypes, "migrated_records.csv")
+// if err != nil {
+//    // ... handle error ...
+// }
+// ```
+func (app *EPrint2Rdm) Migrate(eprintIds []string, resourceTypes map[string]string, contributorTypes map[string]string, logName string) error {
+	cfg := app.Cfg
+	if cfg.InvenioAPI == "" || cfg.InvenioToken == "" {
+		return fmt.Errorf("RDM_URL and RDMTOK are required to migrate records")
+	}
+	if cfg.InvenioCommunityID == "" {
+		return fmt.Errorf("RDM_COMMUNITY_ID is required to migrate records")
+	}
+	if logName == "" {
+		logName = MigratedRecordsCSV
+	}
+	migrated, err := ReadMigrationLog(logName)
+	if err != nil {
+		return err
+	}
+	workDir := filepath.Dir(logName)
+	l := log.New(os.Stderr, "", 1)
+	tot := len(eprintIds)
+	mCnt, eCnt := 0, 0
+	t0 := time.Now()
+	iTime, reportProgress := time.Now(), false
+	for i, eprintId := range eprintIds {
+		if iTime, reportProgress = CheckWaitInterval(iTime, time.Minute); reportProgress || i == 0 {
+			l.Printf("processing eprint %s (%d/%d) %s", eprintId, i, tot, ProgressETA(t0, i, tot))
+		}
+		resumeId := ""
+		if entry, ok := migrated[eprintId]; ok {
+			if entry.Status == MigrationMigrated || entry.Status == MigrationExists {
+				continue
+			}
+			if entry.Status == MigrationFailed {
+				resumeId = entry.RdmID
+			}
+		}
+		id, err := strconv.Atoi(eprintId)
+		if err != nil {
+			l.Printf("skipping %q, %s", eprintId, err)
+			continue
+		}
+		rdmId, status, err := MigrateEPrint(cfg, id, resourceTypes, contributorTypes, app.Fixups, cfg.InvenioCommunityID, workDir, resumeId, cfg.Debug)
+		if err != nil {
+			l.Printf("failed (%s, %s), %s", eprintId, rdmId, err)
+			eCnt++
+		} else if status == MigrationMigrated {
+			mCnt++
+		}
+		if err := appendMigrationLog(logName, &MigrationLogEntry{EPrintID: eprintId, RdmID: rdmId, Status: status}); err != nil {
+			return err
+		}
+	}
+	l.Printf("%d migrated, %d errors, running time %s", mCnt, eCnt, time.Since(t0).Round(time.Second))
+	if eCnt > 0 {
+		return fmt.Errorf("%d eprints failed to migrate, see %s", eCnt, logName)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package irdmtools
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	// Caltech Library Packages
+	"github.com/caltechlibrary/simplified"
+)
+
+func TestMigrationRestrictions(t *testing.T) {
+	rec := new(simplified.Record)
+	if got := migrationRestrictions(rec); !reflect.DeepEqual(got, []string{"metadata_only"}) {
+		t.Errorf("expected metadata_only for a record without files, got %+v", got)
+	}
+	rec.Files = &simplified.Files{
+		Entries: map[string]*simplified.Entry{
+			"article.pdf":  {Metadata: map[string]interface{}{"security": "public", "pos": 1, "content": "published"}},
+			"notes.pdf":    {Metadata: map[string]interface{}{"security": "internal", "pos": 2}},
+			"data (1).csv": {FileID: "https://eprints.example.edu/1/3/data.csv", Metadata: map[string]interface{}{"security": "validuser", "pos": 3, "filename": "data (1).csv"}},
+		},
+	}
+	if got := migrationRestrictions(rec); !reflect.DeepEqual(got, []string{"internal", "validuser", "public"}) {
+		t.Errorf("expected internal, validuser, public, got %+v", got)
+	}
+	files := migrationFiles(rec, "public")
+	if len(files) != 1 || files[0].Target != "article.pdf" || files[0].Content != "Published" || files[0].Pos != 1 {
+		t.Errorf("unexpected public files %+v", files)
+	}
+	files = migrationFiles(rec, "validuser")
+	if len(files) != 1 || files[0].Target != "data__1_.csv" || files[0].Filename != "data (1).csv" || files[0].Pos != 3 {
+		t.Errorf("unexpected validuser files %+v", files)
+	}
+	if files := migrationFiles(rec, "metadata_only"); len(files) != 0 {
+		t.Errorf("expected no files for metadata_only, got %+v", files)
+	}
+}
+
+func TestEPrintDocumentPath(t *testing.T) {
+	expected := "/archives/caltechauthors/documents/disk0/00/08/55/42/01/article.pdf"
+	if got := eprintDocumentPath("/archives", "caltechauthors", "disk0/00/08/55/42", 1, "article.pdf"); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestGetEPrintFile(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "eprints" || password != "secret" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/85542/1/article.pdf" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("%PDF"))
+	}))
+	defer ts.Close()
+	cfg := &Config{EPrintUser: "eprints", EPrintPassword: "secret"}
+	src, err := getEPrintFile(cfg, ts.URL+"/85542/1/article.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(src) != "%PDF" {
+		t.Errorf("unexpected file %q", src)
+	}
+	_, err = getEPrintFile(cfg, ts.URL+"/85542/1/missing.pdf")
+	if err == nil {
+		t.Fatal("expected an error for a missing file")
+	}
+	if strings.Contains(err.Error(), "secret") {
+		t.Errorf("expected the password to be left out of %q", err)
+	}
+}
+
+func TestPublishedVersions(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/records/aaaaa-00001", "/api/records/bbbbb-00002/draft":
+			w.Write([]byte(`{"id": "ok"}`))
+		case "/api/records/aaaaa-00001/versions":
+			w.Write([]byte(`{"hits": {"hits": [], "total": 2}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	cfg := &Config{InvenioAPI: ts.URL, InvenioToken: "token", rl: new(RateLimit)}
+	testCases := map[string]int{
+		// Published with two versions
+		"aaaaa-00001": 2,
+		// Draft never published
+		"bbbbb-00002": 0,
+	}
+	for id, expected := range testCases {
+		n, err := publishedVersions(cfg, id)
+		if err != nil {
+			t.Errorf("%s, %s", id, err)
+		} else if n != expected {
+			t.Errorf("%s expected %d versions, got %d", id, expected, n)
+		}
+	}
+	if _, err := publishedVersions(cfg, "ccccc-00003"); err == nil {
+		t.Errorf("expected an error for a record without a draft")
+	}
+}
+
+func TestMigrationPayload(t *testing.T) {
+	rec := new(simplified.Record)
+	src := []byte(`{
+    "id": "caltechauthors:85542",
+    "access": { "record": "restricted" },
+    "metadata": {
+        "title": "A title",
+        "additional_descriptions": [
+            { "type": { "id": "attached-files" }, "description": "old files" },
+            { "type": { "id": "note" }, "description": "a note" }
+        ]
+    },
+    "files": { "enabled": true }
+}`)
+	if err := JSONUnmarshal(src, &rec); err != nil {
+		t.Fatal(err)
+	}
+	payload, err := migrationPayload(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := payload["id"]; ok {
+		t.Errorf("expected id to be removed, got %+v", payload)
+	}
+	access := payload["access"].(map[string]interface{})
+	if access["record"] != "public" || access["files"] != "public" {
+		t.Errorf("expected public access until versions are set, got %+v", access)
+	}
+	if files := payload["files"].(map[string]interface{}); files["enabled"] != true {
+		t.Errorf("expected files enabled, got %+v", files)
+	}
+	metadata := payload["metadata"].(map[string]interface{})
+	if descriptions := metadata["additional_descriptions"].([]interface{}); len(descriptions) != 1 {
+		t.Errorf("expected attached-files description removed, got %+v", descriptions)
+	}
+}
+
+func TestMigrationLog(t *testing.T) {
+	fName := filepath.Join(t.TempDir(), MigratedRecordsCSV)
+	entries, err := ReadMigrationLog(fName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected an empty log, got %+v", entries)
+	}
+	for _, entry := range []*MigrationLogEntry{
+		{EPrintID: "1", RdmID: "", Status: MigrationFailed},
+		{EPrintID: "2", RdmID: "aaaaa-00002", Status: MigrationMigrated},
+		{EPrintID: "1", RdmID: "aaaaa-00001", Status: MigrationMigrated},
+	} {
+		if err := appendMigrationLog(fName, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err = ReadMigrationLog(fName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 eprints in log, got %d", len(entries))
+	}
+	if entry := entries["1"]; entry.Status != MigrationMigrated || entry.RdmID != "aaaaa-00001" {
+		t.Errorf("expected the last entry to win, got %+v", entry)
+	}
+}
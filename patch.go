@@ -0,0 +1,524 @@
+package irdmtools
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/simplified"
+)
+
+// Batch patching of RDM records.
+//
+// A patch file holds either a JSON Patch (RFC 6902), a JSON array of
+// operations, or a JSON Merge Patch (RFC 7396), a JSON object. Paths in
+// a JSON Patch are relative to the record, e.g. "/metadata/title".
+
+const (
+	// JSONPatchType identifies an RFC 6902 JSON Patch
+	JSONPatchType = "json-patch"
+	// MergePatchType identifies an RFC 7396 JSON Merge Patch
+	MergePatchType = "merge-patch"
+)
+
+// PatchOperation is a single RFC 6902 operation.
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// Patch holds a parsed JSON Patch or JSON Merge Patch.
+type Patch struct {
+	// Type is either JSONPatchType or MergePatchType
+	Type string
+	// Operations holds the JSON Patch operations
+	Operations []*PatchOperation
+	// Merge holds the JSON Merge Patch document
+	Merge map[string]interface{}
+}
+
+// ParsePatch parses src as a JSON Patch if it is an array, otherwise as
+// a JSON Merge Patch.
+//
+// ```
+// src, _ := os.ReadFile("fix-titles.json")
+// patch, err := ParsePatch(src)
+// if err != nil {
+//    // ... handle error ...
+// }
+// ```
+func ParsePatch(src []byte) (*Patch, error) {
+	src = bytes.TrimSpace(src)
+	if len(src) == 0 {
+		return nil, fmt.Errorf("empty patch")
+	}
+	patch := new(Patch)
+	switch src[0] {
+	case '[':
+		patch.Type = JSONPatchType
+		// NOTE: we need to tell an explicit null value from a missing one.
+		ops := []map[string]interface{}{}
+		if err := JSONUnmarshal(src, &ops); err != nil {
+			return nil, err
+		}
+		for i, m := range ops {
+			op := new(PatchOperation)
+			op.Op, _ = m["op"].(string)
+			op.Path, _ = m["path"].(string)
+			op.From, _ = m["from"].(string)
+			if _, ok := m["path"]; !ok {
+				return nil, fmt.Errorf("operation %d missing path", i)
+			}
+			switch op.Op {
+			case "add", "replace", "test":
+				val, ok := m["value"]
+				if !ok {
+					return nil, fmt.Errorf("operation %d (%s) missing value", i, op.Op)
+				}
+				op.Value = val
+			case "move", "copy":
+				if _, ok := m["from"]; !ok {
+					return nil, fmt.Errorf("operation %d (%s) missing from", i, op.Op)
+				}
+			case "remove":
+			default:
+				return nil, fmt.Errorf("operation %d has unsupported op %q", i, op.Op)
+			}
+			patch.Operations = append(patch.Operations, op)
+		}
+	case '{':
+		patch.Type = MergePatchType
+		patch.Merge = map[string]interface{}{}
+		if err := JSONUnmarshal(src, &patch.Merge); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("patch must be a JSON array (RFC 6902) or object (RFC 7396)")
+	}
+	return patch, nil
+}
+
+// Apply applies the patch to doc returning the patched document. doc is
+// not modified. A JSON Patch is applied atomically, if any operation
+// fails an error is returned.
+func (patch *Patch) Apply(doc map[string]interface{}) (map[string]interface{}, error) {
+	// Work on a copy so a failed patch leaves doc unchanged.
+	var target interface{}
+	src, err := JSONMarshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	if err := JSONUnmarshal(src, &target); err != nil {
+		return nil, err
+	}
+	switch patch.Type {
+	case JSONPatchType:
+		for i, op := range patch.Operations {
+			if target, err = applyOperation(target, op); err != nil {
+				return nil, fmt.Errorf("operation %d (%s %s), %s", i, op.Op, op.Path, err)
+			}
+		}
+	case MergePatchType:
+		target = mergePatch(target, patch.Merge)
+	default:
+		return nil, fmt.Errorf("unknown patch type %q", patch.Type)
+	}
+	m, ok := target.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("patched document is not a JSON object")
+	}
+	return m, nil
+}
+
+// mergePatch implements the MergePatch function from RFC 7396.
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = mergePatch(t[k], v)
+		}
+	}
+	return t
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into its unescaped tokens.
+func parsePointer(ptr string) ([]string, error) {
+	if ptr == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(ptr, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", ptr)
+	}
+	tokens := strings.Split(ptr[1:], "/")
+	for i, tok := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(tok, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// arrayIndex converts a pointer token to an index of an array of size n.
+// If allowEnd is true "-" and n refer to the end of the array.
+func arrayIndex(tok string, n int, allowEnd bool) (int, error) {
+	if tok == "-" && allowEnd {
+		return n, nil
+	}
+	if tok == "" || (len(tok) > 1 && tok[0] == '0') {
+		return -1, fmt.Errorf("invalid array index %q", tok)
+	}
+	i, err := strconv.Atoi(tok)
+	if err != nil || i < 0 {
+		return -1, fmt.Errorf("invalid array index %q", tok)
+	}
+	if i > n || (i == n && !allowEnd) {
+		return -1, fmt.Errorf("array index %d out of range", i)
+	}
+	return i, nil
+}
+
+// getPointer returns the value at the JSON Pointer ptr.
+func getPointer(doc interface{}, ptr string) (interface{}, error) {
+	tokens, err := parsePointer(ptr)
+	if err != nil {
+		return nil, err
+	}
+	cur := doc
+	for _, tok := range tokens {
+		switch node := cur.(type) {
+		case map[string]interface{}:
+			val, ok := node[tok]
+			if !ok {
+				return nil, fmt.Errorf("%q not found", ptr)
+			}
+			cur = val
+		case []interface{}:
+			i, err := arrayIndex(tok, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			cur = node[i]
+		default:
+			return nil, fmt.Errorf("%q not found", ptr)
+		}
+	}
+	return cur, nil
+}
+
+// setPointer updates doc at the JSON Pointer ptr. The edit func is passed
+// the parent container and the last token and returns the new container.
+// Returns the updated document.
+func setPointer(doc interface{}, tokens []string, edit func(parent interface{}, tok string) (interface{}, error)) (interface{}, error) {
+	if len(tokens) == 1 {
+		return edit(doc, tokens[0])
+	}
+	tok, rest := tokens[0], tokens[1:]
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		child, ok := node[tok]
+		if !ok {
+			return nil, fmt.Errorf("path element %q not found", tok)
+		}
+		val, err := setPointer(child, rest, edit)
+		if err != nil {
+			return nil, err
+		}
+		node[tok] = val
+		return node, nil
+	case []interface{}:
+		i, err := arrayIndex(tok, len(node), false)
+		if err != nil {
+			return nil, err
+		}
+		val, err := setPointer(node[i], rest, edit)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = val
+		return node, nil
+	}
+	return nil, fmt.Errorf("path element %q not found", tok)
+}
+
+// addValue implements the RFC 6902 "add" operation.
+func addValue(doc interface{}, ptr string, value interface{}) (interface{}, error) {
+	tokens, err := parsePointer(ptr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	return setPointer(doc, tokens, func(parent interface{}, tok string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			node[tok] = value
+			return node, nil
+		case []interface{}:
+			i, err := arrayIndex(tok, len(node), true)
+			if err != nil {
+				return nil, err
+			}
+			node = append(node, nil)
+			copy(node[i+1:], node[i:])
+			node[i] = value
+			return node, nil
+		}
+		return nil, fmt.Errorf("cannot add %q to a scalar value", tok)
+	})
+}
+
+// removeValue implements the RFC 6902 "remove" operation.
+func removeValue(doc interface{}, ptr string) (interface{}, error) {
+	tokens, err := parsePointer(ptr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("cannot remove the whole document")
+	}
+	return setPointer(doc, tokens, func(parent interface{}, tok string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := node[tok]; !ok {
+				return nil, fmt.Errorf("%q not found", ptr)
+			}
+			delete(node, tok)
+			return node, nil
+		case []interface{}:
+			i, err := arrayIndex(tok, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			return append(node[:i], node[i+1:]...), nil
+		}
+		return nil, fmt.Errorf("%q not found", ptr)
+	})
+}
+
+// deepCopy returns a copy of a decoded JSON value.
+func deepCopy(val interface{}) (interface{}, error) {
+	src, err := JSONMarshal(val)
+	if err != nil {
+		return nil, err
+	}
+	var cp interface{}
+	if err := JSONUnmarshal(src, &cp); err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+// applyOperation applies a single RFC 6902 operation to doc.
+func applyOperation(doc interface{}, op *PatchOperation) (interface{}, error) {
+	switch op.Op {
+	case "add":
+		return addValue(doc, op.Path, op.Value)
+	case "remove":
+		return removeValue(doc, op.Path)
+	case "replace":
+		if _, err := getPointer(doc, op.Path); err != nil {
+			return nil, err
+		}
+		if op.Path == "" {
+			return op.Value, nil
+		}
+		doc, err := removeValue(doc, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		return addValue(doc, op.Path, op.Value)
+	case "move":
+		if strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, fmt.Errorf("cannot move %q into one of its children", op.From)
+		}
+		val, err := getPointer(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		if doc, err = removeValue(doc, op.From); err != nil {
+			return nil, err
+		}
+		return addValue(doc, op.Path, val)
+	case "copy":
+		val, err := getPointer(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		if val, err = deepCopy(val); err != nil {
+			return nil, err
+		}
+		return addValue(doc, op.Path, val)
+	case "test":
+		val, err := getPointer(doc, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(val, op.Value) {
+			return nil, fmt.Errorf("test failed")
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("unsupported op %q", op.Op)
+}
+
+// patchRecordDiff applies the patch to a simplified record and returns
+// the DiffAsJSON of the original and patched record.
+func patchRecordDiff(rec *simplified.Record, patch *Patch) ([]byte, error) {
+	src, err := JSONMarshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]interface{}{}
+	if err := JSONUnmarshal(src, &m); err != nil {
+		return nil, err
+	}
+	patched, err := patch.Apply(m)
+	if err != nil {
+		return nil, err
+	}
+	if src, err = JSONMarshal(patched); err != nil {
+		return nil, err
+	}
+	newRec := new(simplified.Record)
+	if err := JSONUnmarshal(src, &newRec); err != nil {
+		return nil, err
+	}
+	return rec.DiffAsJSON(newRec)
+}
+
+// getPatchSource returns the record PatchRecord would patch without
+// creating a draft. NewDraft returns a record's pending draft if it has
+// one, otherwise the draft is a copy of the published record.
+func getPatchSource(cfg *Config, recordId string) (*simplified.Record, error) {
+	var (
+		draft map[string]interface{}
+		err error
+	)
+	if usePgDrafts(cfg) {
+		draft, err = getDraftFromPg(cfg.pgDB, recordId)
+	} else {
+		draft, err = GetDraft(cfg, recordId)
+	}
+	if err != nil {
+		return getPublishedRecord(cfg, recordId)
+	}
+	return objectRecord(draft)
+}
+
+// PatchRecord creates a draft of recordId, applies the patch and updates
+// the draft. If publish is true the draft is published.
+//
+// ```
+// draft, err := PatchRecord(cfg, "bq3se-47g50", patch, true, false)
+// if err != nil {
+//    // ... handle error ...
+// }
+// ```
+func PatchRecord(cfg *Config, recordId string, patch *Patch, publish bool, debug bool) (map[string]interface{}, error) {
+	draft, err := NewDraft(cfg, recordId)
+	if err != nil {
+		return nil, err
+	}
+	patched, err := patch.Apply(draft)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := JSONMarshalIndent(patched, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	draft, err = UpdateDraft(cfg, recordId, payload, debug)
+	if err != nil {
+		return nil, err
+	}
+	if publish {
+		return PublishRecordVersion(cfg, recordId, "", "", debug)
+	}
+	return draft, nil
+}
+
+// PatchRecords applies a patch to each record in recordIds. If dryRun is
+// true no drafts are created, instead the DiffAsJSON of each record, or
+// its pending draft if it has one, and its patched version is written to out as a JSON array of record id
+// and diff. Failed records are logged and counted, the run stops after
+// 100 failures.
+//
+// ```
+// src, _ := os.ReadFile("fix-titles.json")
+// patch, _ := ParsePatch(src)
+// if err := PatchRecords(cfg, ids, patch, false, true, os.Stdout, false); err != nil {
+//    // ... handle error ...
+// }
+// ```
+func PatchRecords(cfg *Config, recordIds []string, patch *Patch, publish bool, dryRun bool, out io.Writer, debug bool) error {
+	const maxErrors = 100
+	l := log.New(os.Stderr, "", 1)
+	tot := len(recordIds)
+	pCnt, eCnt := 0, 0
+	t0 := time.Now()
+	iTime, reportProgress := time.Now(), false
+	if dryRun {
+		fmt.Fprintln(out, "[")
+	}
+	for i, recordId := range recordIds {
+		var err error
+		if dryRun {
+			var (
+				rec  *simplified.Record
+				diff []byte
+			)
+			if rec, err = getPatchSource(cfg, recordId); err == nil {
+				if diff, err = patchRecordDiff(rec, patch); err == nil {
+					if pCnt > 0 {
+						fmt.Fprintln(out, ",")
+					}
+					fmt.Fprintf(out, "    { %q: %s }", recordId, bytes.TrimSpace(diff))
+				}
+			}
+		} else {
+			_, err = PatchRecord(cfg, recordId, patch, publish, debug)
+		}
+		if err != nil {
+			l.Printf("failed to patch (%d) %q, %s", i, recordId, err)
+			eCnt++
+			if eCnt > maxErrors {
+				break
+			}
+		} else {
+			pCnt++
+		}
+		if iTime, reportProgress = CheckWaitInterval(iTime, time.Minute); reportProgress {
+			l.Printf("last id %q (%d/%d) %s: %s", recordId, i, tot, time.Since(t0).Round(time.Second), ProgressETA(t0, i, tot))
+		}
+	}
+	if dryRun {
+		fmt.Fprintln(out, "\n]")
+	}
+	if debug || !dryRun {
+		l.Printf("%d patched, %d errors, running time %s", pCnt, eCnt, time.Since(t0).Round(time.Second))
+	}
+	if eCnt > maxErrors {
+		return fmt.Errorf("Stopped, %d errors encountered", eCnt)
+	}
+	if eCnt > 0 {
+		return fmt.Errorf("%d records failed to patch", eCnt)
+	}
+	return nil
+}
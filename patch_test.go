@@ -0,0 +1,169 @@
+package irdmtools
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONPatch(t *testing.T) {
+	doc := map[string]interface{}{}
+	if err := JSONUnmarshal([]byte(`{
+    "metadata": {
+        "title": "Old title",
+        "subjects": [ { "subject": "one" }, { "subject": "two" } ],
+        "version": "v1"
+    }
+}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+	patch, err := ParsePatch([]byte(`[
+    { "op": "test", "path": "/metadata/title", "value": "Old title" },
+    { "op": "replace", "path": "/metadata/title", "value": "New title" },
+    { "op": "add", "path": "/metadata/subjects/-", "value": { "subject": "three" } },
+    { "op": "remove", "path": "/metadata/subjects/0" },
+    { "op": "copy", "from": "/metadata/version", "path": "/metadata/edition" },
+    { "op": "move", "from": "/metadata/edition", "path": "/metadata/description" }
+]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patch.Type != JSONPatchType {
+		t.Fatalf("expected %q, got %q", JSONPatchType, patch.Type)
+	}
+	patched, err := patch.Apply(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{}
+	if err := JSONUnmarshal([]byte(`{
+    "metadata": {
+        "title": "New title",
+        "subjects": [ { "subject": "two" }, { "subject": "three" } ],
+        "version": "v1",
+        "description": "v1"
+    }
+}`), &expected); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, patched) {
+		t.Errorf("expected %+v, got %+v", expected, patched)
+	}
+	// doc should not be modified
+	if title := doc["metadata"].(map[string]interface{})["title"]; title != "Old title" {
+		t.Errorf("expected original doc unchanged, got title %q", title)
+	}
+
+	// A failed test op should fail the whole patch
+	patch, err = ParsePatch([]byte(`[
+    { "op": "replace", "path": "/metadata/title", "value": "New title" },
+    { "op": "test", "path": "/metadata/version", "value": "v2" }
+]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := patch.Apply(doc); err == nil {
+		t.Errorf("expected failed test op to return an error")
+	}
+	for _, src := range []string{
+		`[ { "op": "remove", "path": "/metadata/missing" } ]`,
+		`[ { "op": "add", "path": "/metadata/subjects/5", "value": 1 } ]`,
+		`[ { "op": "move", "from": "/metadata", "path": "/metadata/child" } ]`,
+	} {
+		patch, err := ParsePatch([]byte(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := patch.Apply(doc); err == nil {
+			t.Errorf("expected an error for %s", src)
+		}
+	}
+	for _, src := range []string{
+		`[ { "op": "replace", "path": "/metadata/title" } ]`,
+		`[ { "op": "frobnicate", "path": "/metadata/title" } ]`,
+		`"not a patch"`,
+	} {
+		if _, err := ParsePatch([]byte(src)); err == nil {
+			t.Errorf("expected parse error for %s", src)
+		}
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	doc := map[string]interface{}{}
+	if err := JSONUnmarshal([]byte(`{
+    "access": { "record": "restricted", "files": "restricted" },
+    "metadata": { "title": "Old title", "version": "v1" }
+}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+	patch, err := ParsePatch([]byte(`{
+    "access": { "record": "public" },
+    "metadata": { "version": null, "publisher": "CaltechAUTHORS" }
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patch.Type != MergePatchType {
+		t.Fatalf("expected %q, got %q", MergePatchType, patch.Type)
+	}
+	patched, err := patch.Apply(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"access": map[string]interface{}{
+			"record": "public",
+			"files":  "restricted",
+		},
+		"metadata": map[string]interface{}{
+			"title":     "Old title",
+			"publisher": "CaltechAUTHORS",
+		},
+	}
+	if !reflect.DeepEqual(expected, patched) {
+		t.Errorf("expected %+v, got %+v", expected, patched)
+	}
+}
+
+func TestPatchRecordsDryRun(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/records/aaaaa-00001/draft":
+			w.Write([]byte(`{"id": "aaaaa-00001", "metadata": {"title": "Draft title"}}`))
+		case "/api/records/aaaaa-00001", "/api/records/bbbbb-00001":
+			w.Write([]byte(`{"id": "published", "metadata": {"title": "Published title"}}`))
+		default:
+			if r.Method != http.MethodGet {
+				t.Errorf("dry run should not %s %s", r.Method, r.URL.Path)
+			}
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	cfg := &Config{InvenioAPI: ts.URL, InvenioToken: "token", rl: new(RateLimit)}
+	patch, err := ParsePatch([]byte(`{"metadata": {"title": "New title"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := new(bytes.Buffer)
+	if err := PatchRecords(cfg, []string{"aaaaa-00001", "bbbbb-00001"}, patch, false, true, out, false); err != nil {
+		t.Fatal(err)
+	}
+	diffs := []map[string]interface{}{}
+	if err := JSONUnmarshal(out.Bytes(), &diffs); err != nil {
+		t.Fatalf("%s, %s", err, out.Bytes())
+	}
+	if len(diffs) != 2 {
+		t.Fatalf("expected two diffs, got %s", out.Bytes())
+	}
+	for i, expected := range map[int]string{0: "Draft title", 1: "Published title"} {
+		src, _ := JSONMarshal(diffs[i])
+		if !strings.Contains(string(src), expected) || !strings.Contains(string(src), "New title") {
+			t.Errorf("expected diff %d from %q, got %s", i, expected, src)
+		}
+	}
+}
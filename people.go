@@ -0,0 +1,543 @@
+package irdmtools
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/simplified"
+)
+
+// Enriching creators from the Caltech people list.
+//
+// The people list is the CSV file people2vocabulary converts to the RDM
+// names vocabulary. Creators are matched to people by ORCID or, for
+// creators with a Caltech affiliation, by family and given name. A match
+// adds the person's clpid and ORCID to the creator. Optionally records
+// from the ORCID public data file (the summaries, one XML file per
+// ORCID) are used to check a creator's ORCID shows Caltech employment.
+// Matches that aren't certain are reported for review and left alone.
+
+const (
+	// CaltechROR is Caltech's ROR id
+	CaltechROR = "05dxps055"
+	// JPLROR is JPL's ROR id
+	JPLROR = "027k65916"
+)
+
+// mapPeopleField maps a column of the people CSV file to a person.
+func mapPeopleField(person *simplified.Person, key string, val string) error {
+	if val == "" {
+		// NOTE: An empty value isn't an error, we just don't map it.
+		return nil
+	}
+	switch key {
+	case "family_name":
+		person.Family = val
+	case "given_name":
+		person.Given = val
+	case "clpid":
+		identifier := new(simplified.Identifier)
+		identifier.Scheme = "clpid"
+		identifier.Identifier = val
+		person.Identifiers = append(person.Identifiers, identifier)
+	case "cl_people_id":
+		identifier := new(simplified.Identifier)
+		identifier.Scheme = "clpid"
+		identifier.Identifier = val
+		person.Identifiers = append(person.Identifiers, identifier)
+	case "thesis_id":
+	case "advisor_id":
+	case "authors_id":
+	case "archivesspace_id":
+	case "directory_id":
+	case "viaf_id":
+	case "lcnaf":
+	case "isni":
+		identifier := new(simplified.Identifier)
+		identifier.Scheme = key
+		identifier.Identifier = val
+		person.Identifiers = append(person.Identifiers, identifier)
+	case "wikidata":
+	case "snac":
+	case "orcid":
+		identifier := new(simplified.Identifier)
+		identifier.Scheme = key
+		identifier.Identifier = val
+		person.Identifiers = append(person.Identifiers, identifier)
+	case "image":
+	case "educated_at":
+	case "caltech":
+		affiliation := new(simplified.Affiliation)
+		affiliation.ID = CaltechROR
+		affiliation.Name = "Caltech"
+		person.Affiliations = append(person.Affiliations, affiliation)
+	case "jpl":
+		affiliation := new(simplified.Affiliation)
+		affiliation.ID = JPLROR
+		affiliation.Name = "JPL"
+		person.Affiliations = append(person.Affiliations, affiliation)
+	case "faculty":
+	case "alumn":
+	case "status":
+	case "directory_person_type":
+	case "title":
+	case "bio":
+	case "division":
+	case "authors_count":
+	case "thesis_count":
+	case "data_count":
+	case "advisor_count":
+	case "editor_count":
+	case "updated":
+	default:
+		return fmt.Errorf("not know how to map %q <- %q", key, val)
+	}
+	if person.Name == "" && person.Given != "" && person.Family != "" {
+		person.Name = fmt.Sprintf("%s, %s", person.Family, person.Given)
+	}
+	return nil
+}
+
+// ReadPeopleCSV reads the people CSV file, the first row holds the
+// column names. With clRules each person is given the Caltech
+// affiliation. Rows that can't be mapped are logged and counted, if
+// there are any an error is returned with the people read.
+//
+// ```
+// fp, _ := os.Open("people.csv")
+// defer fp.Close()
+// people, err := ReadPeopleCSV(fp, true)
+// if err != nil {
+//    // ... handle error ...
+// }
+// ```
+func ReadPeopleCSV(in io.Reader, clRules bool) ([]*simplified.Person, error) {
+	src, err := io.ReadAll(in)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := readVocabularyRows(src, true)
+	if err != nil {
+		return nil, err
+	}
+	// NOTE:This is the Caltech affiliation, needed by clsRules
+	// where this list of of Caltech people (from feeds).
+	caltech := new(simplified.Affiliation)
+	caltech.ID = CaltechROR
+	caltech.Name = "Caltech"
+	peopleList := []*simplified.Person{}
+	err = mapVocabularyRows(rows, func(row *vocabularyRow) error {
+		person := new(simplified.Person)
+		err := row.mapColumns(func(key string, val string) error {
+			return mapPeopleField(person, key, val)
+		})
+		if clRules {
+			// Check if Caltech affiliation is asserted
+			if !person.HasAffiliation(caltech) {
+				person.Affiliations = append(person.Affiliations, caltech)
+			}
+		}
+		peopleList = append(peopleList, person)
+		return err
+	})
+	return peopleList, err
+}
+
+// OrcidRecord holds the parts of an ORCID public data file record used
+// to check a creator's ORCID.
+type OrcidRecord struct {
+	ORCID      string `json:"orcid"`
+	GivenNames string `json:"given_names,omitempty"`
+	FamilyName string `json:"family_name,omitempty"`
+	// Caltech is true if the record lists Caltech or JPL employment
+	Caltech bool `json:"caltech,omitempty"`
+}
+
+// orcidSummary is the XML of a record in the ORCID public data file's
+// summaries, namespaces are ignored.
+type orcidSummary struct {
+	XMLName    xml.Name `xml:"record"`
+	Path       string   `xml:"orcid-identifier>path"`
+	GivenNames string   `xml:"person>name>given-names"`
+	FamilyName string   `xml:"person>name>family-name"`
+	Employers  []struct {
+		Name       string `xml:"name"`
+		Identifier string `xml:"disambiguated-organization>disambiguated-organization-identifier"`
+	} `xml:"activities-summary>employments>affiliation-group>employment-summary>organization"`
+}
+
+// ParseOrcidRecord parses a record from the ORCID public data file.
+func ParseOrcidRecord(src []byte) (*OrcidRecord, error) {
+	summary := new(orcidSummary)
+	if err := xml.Unmarshal(src, &summary); err != nil {
+		return nil, err
+	}
+	if summary.Path == "" {
+		return nil, fmt.Errorf("missing ORCID")
+	}
+	rec := &OrcidRecord{
+		ORCID:      normalizeORCID(summary.Path),
+		GivenNames: strings.TrimSpace(summary.GivenNames),
+		FamilyName: strings.TrimSpace(summary.FamilyName),
+	}
+	for _, employer := range summary.Employers {
+		id := strings.TrimPrefix(strings.TrimSpace(employer.Identifier), "https://ror.org/")
+		if id == CaltechROR || id == JPLROR || isCaltechName(employer.Name) {
+			rec.Caltech = true
+		}
+	}
+	return rec, nil
+}
+
+// isCaltechName returns true for the names Caltech and JPL go by.
+func isCaltechName(name string) bool {
+	name = foldName(name)
+	switch name {
+	case "caltech", "california institute of technology", "jpl", "jet propulsion laboratory":
+		return true
+	}
+	return strings.Contains(name, "california institute of technology") ||
+		strings.Contains(name, "jet propulsion laboratory")
+}
+
+// nameFolds maps accented letters to the letters used to compare names.
+var nameFolds = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ä", "a", "ã", "a", "å", "a", "ā", "a",
+	"ç", "c", "č", "c", "ć", "c", "é", "e", "è", "e", "ê", "e", "ë", "e",
+	"ē", "e", "ě", "e", "í", "i", "ì", "i", "î", "i", "ï", "i", "ñ", "n",
+	"ń", "n", "ó", "o", "ò", "o", "ô", "o", "ö", "o", "õ", "o", "ø", "o",
+	"ō", "o", "ř", "r", "š", "s", "ś", "s", "ú", "u", "ù", "u", "û", "u",
+	"ü", "u", "ū", "u", "ý", "y", "ž", "z", "ź", "z", "ł", "l", "ß", "ss",
+	".", " ", ",", " ", "-", " ", "‐", " ", "'", "", "’", "",
+)
+
+// foldName returns a name lower cased without accents or punctuation.
+func foldName(name string) string {
+	return strings.Join(strings.Fields(nameFolds.Replace(strings.ToLower(name))), " ")
+}
+
+// givenNamesMatch compares given names. It returns "exact" if they are
+// the same, "initials" if they only agree on initials, e.g. "J. A." and
+// "Jane Ann", otherwise an empty string.
+func givenNamesMatch(a string, b string) string {
+	a, b = foldName(a), foldName(b)
+	if a == "" || b == "" {
+		return ""
+	}
+	if a == b {
+		return "exact"
+	}
+	aNames, bNames := strings.Fields(a), strings.Fields(b)
+	n := len(aNames)
+	if len(bNames) < n {
+		n = len(bNames)
+	}
+	for i := 0; i < n; i++ {
+		x, y := aNames[i], bNames[i]
+		if len(x) > 1 && len(y) > 1 {
+			if x != y {
+				return ""
+			}
+		} else if x[0] != y[0] {
+			return ""
+		}
+	}
+	return "initials"
+}
+
+// PeopleMatch is a creator that needs review, e.g. matching more than
+// one person.
+type PeopleMatch struct {
+	// Record is the DOI of the record holding the creator
+	Record string `json:"record,omitempty"`
+	// Creator is the creator's name and ORCID, if any
+	Creator string `json:"creator"`
+	ORCID   string `json:"orcid,omitempty"`
+	// MatchedOn is how the candidates matched, e.g. orcid, name or initials
+	MatchedOn string `json:"matched_on"`
+	// Candidates are the clpid of the people matched
+	Candidates []string `json:"candidates,omitempty"`
+	// Note explains why the match wasn't used
+	Note string `json:"note,omitempty"`
+}
+
+// PeopleIndex matches creators to the people list. Uncertain matches
+// are added to Reported.
+type PeopleIndex struct {
+	// Reported holds the creators that need review
+	Reported []*PeopleMatch
+
+	people   []*simplified.Person
+	orcids   map[string][]int
+	family   map[string][]int
+	records  map[string]*OrcidRecord
+	reported map[string]bool
+}
+
+// NewPeopleIndex creates an index of the people list.
+func NewPeopleIndex(people []*simplified.Person) *PeopleIndex {
+	idx := &PeopleIndex{
+		orcids:   map[string][]int{},
+		family:   map[string][]int{},
+		records:  map[string]*OrcidRecord{},
+		reported: map[string]bool{},
+	}
+	for _, person := range people {
+		if person.GetIdentifier("clpid") == "" {
+			continue
+		}
+		i := len(idx.people)
+		idx.people = append(idx.people, person)
+		if orcid := normalizeORCID(person.GetIdentifier("orcid")); orcid != "" {
+			idx.orcids[orcid] = append(idx.orcids[orcid], i)
+		}
+		if family := foldName(person.Family); family != "" {
+			idx.family[family] = append(idx.family[family], i)
+		}
+	}
+	return idx
+}
+
+// Len returns the number of people in the index.
+func (idx *PeopleIndex) Len() int {
+	if idx == nil {
+		return 0
+	}
+	return len(idx.people)
+}
+
+// AddOrcidRecords adds records from the ORCID public data file.
+func (idx *PeopleIndex) AddOrcidRecords(records []*OrcidRecord) {
+	for _, rec := range records {
+		idx.records[rec.ORCID] = rec
+	}
+}
+
+// LoadPeopleCSV loads the people CSV file into a PeopleIndex.
+//
+// ```
+// idx, err := LoadPeopleCSV("people.csv")
+// if err != nil {
+//    // ... handle error ...
+// }
+// app.Cfg.People = idx
+// ```
+func LoadPeopleCSV(fName string) (*PeopleIndex, error) {
+	fp, err := os.Open(fName)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	people, err := ReadPeopleCSV(fp, false)
+	if err != nil {
+		return nil, fmt.Errorf("%s, %s", fName, err)
+	}
+	return NewPeopleIndex(people), nil
+}
+
+// LoadOrcidRecords reads the ORCID public data file records, either a
+// single XML file or a directory of them, e.g. the unpacked summaries.
+// Only the records with Caltech employment are kept, they are the ones
+// used to match creators, the public data file holds millions of
+// records. Files that aren't ORCID records are skipped.
+func LoadOrcidRecords(fName string) ([]*OrcidRecord, error) {
+	records := []*OrcidRecord{}
+	err := filepath.WalkDir(fName, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(strings.ToLower(p), ".xml") {
+			return nil
+		}
+		src, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if rec, err := ParseOrcidRecord(src); err == nil && rec.Caltech {
+			records = append(records, rec)
+		}
+		return nil
+	})
+	return records, err
+}
+
+// clpids returns the clpid of the people.
+func (idx *PeopleIndex) clpids(people []int) []string {
+	ids := []string{}
+	for _, i := range people {
+		ids = append(ids, idx.people[i].GetIdentifier("clpid"))
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// matchName returns the people with the family name whose given names
+// match, the exact matches if there are any.
+func (idx *PeopleIndex) matchName(family string, given string) ([]int, string) {
+	exact, initials := []int{}, []int{}
+	for _, i := range idx.family[foldName(family)] {
+		switch givenNamesMatch(given, idx.people[i].Given) {
+		case "exact":
+			exact = append(exact, i)
+		case "initials":
+			initials = append(initials, i)
+		}
+	}
+	if len(exact) > 0 {
+		return exact, "name"
+	}
+	return initials, "initials"
+}
+
+// hasCaltechAffiliation returns true if the creator is affiliated with
+// Caltech or JPL.
+func hasCaltechAffiliation(creator *simplified.Creator) bool {
+	for _, affiliation := range creator.Affiliations {
+		if affiliation == nil {
+			continue
+		}
+		if affiliation.ID == CaltechROR || affiliation.ID == JPLROR || isCaltechName(affiliation.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// getCreatorIdentifier returns the identifier of the creator with the
+// scheme.
+func getCreatorIdentifier(po *simplified.PersonOrOrg, scheme string) string {
+	for _, identifier := range po.Identifiers {
+		if identifier != nil && identifier.Scheme == scheme {
+			return identifier.Identifier
+		}
+	}
+	return ""
+}
+
+// setCreatorIdentifier adds the identifier to the creator unless it has
+// one with the scheme.
+func setCreatorIdentifier(po *simplified.PersonOrOrg, scheme string, value string) {
+	if value != "" && getCreatorIdentifier(po, scheme) == "" {
+		po.Identifiers = append(po.Identifiers, mkSimpleIdentifier(scheme, value))
+	}
+}
+
+// matchCreator returns the person matching the creator. Uncertain
+// matches are returned as a PeopleMatch for review.
+func (idx *PeopleIndex) matchCreator(creator *simplified.Creator) (*simplified.Person, *PeopleMatch) {
+	po := creator.PersonOrOrg
+	orcid := normalizeORCID(getCreatorIdentifier(po, "orcid"))
+	review := &PeopleMatch{
+		Creator: strings.TrimSpace(strings.Trim(fmt.Sprintf("%s, %s", po.FamilyName, po.GivenName), ", ")),
+		ORCID:   orcid,
+	}
+	if orcid != "" {
+		if people, ok := idx.orcids[orcid]; ok {
+			if len(people) == 1 {
+				return idx.people[people[0]], nil
+			}
+			review.MatchedOn, review.Candidates = "orcid", idx.clpids(people)
+			review.Note = "ORCID belongs to more than one person"
+			return nil, review
+		}
+		// NOTE: the ORCID record shows if the creator worked at Caltech
+		// and gives the name they use
+		if orcidRec, ok := idx.records[orcid]; ok && orcidRec.Caltech {
+			people, matchedOn := idx.matchName(orcidRec.FamilyName, orcidRec.GivenNames)
+			return idx.pickPerson(people, "orcid-record "+matchedOn, orcid, review)
+		}
+	}
+	if !hasCaltechAffiliation(creator) {
+		return nil, nil
+	}
+	people, matchedOn := idx.matchName(po.FamilyName, po.GivenName)
+	return idx.pickPerson(people, matchedOn, orcid, review)
+}
+
+// pickPerson returns the person if there is a single exact name match
+// without a conflicting ORCID, otherwise the candidates are returned
+// for review.
+func (idx *PeopleIndex) pickPerson(people []int, matchedOn string, orcid string, review *PeopleMatch) (*simplified.Person, *PeopleMatch) {
+	if len(people) == 0 {
+		return nil, nil
+	}
+	review.MatchedOn, review.Candidates = matchedOn, idx.clpids(people)
+	switch {
+	case len(people) > 1:
+		review.Note = "more than one person matches"
+	case !strings.HasSuffix(matchedOn, "name"):
+		review.Note = "only the initials match"
+	case orcid != "" && normalizeORCID(idx.people[people[0]].GetIdentifier("orcid")) != "":
+		review.Note = "person has a different ORCID"
+	default:
+		return idx.people[people[0]], nil
+	}
+	return nil, review
+}
+
+// report adds a creator needing review to Reported once per record,
+// e.g. when the CrossRef and DataCite records are merged.
+func (idx *PeopleIndex) report(m *PeopleMatch) {
+	key := strings.Join([]string{m.Record, m.Creator, m.ORCID}, "\t")
+	if idx.reported[key] {
+		return
+	}
+	idx.reported[key] = true
+	idx.Reported = append(idx.Reported, m)
+}
+
+// EnrichCreators adds the clpid and ORCID of the people matching the
+// record's creators and contributors. Creators needing review are added
+// to Reported. It returns the number of creators enriched.
+//
+// ```
+// n := cfg.People.EnrichCreators(rec)
+// ```
+func (idx *PeopleIndex) EnrichCreators(rec *simplified.Record) int {
+	if idx == nil || rec == nil || rec.Metadata == nil {
+		return 0
+	}
+	doi := ""
+	if pid, ok := rec.ExternalPIDs["doi"]; ok && pid != nil {
+		doi = pid.Identifier
+	}
+	n := 0
+	for _, creators := range [][]*simplified.Creator{rec.Metadata.Creators, rec.Metadata.Contributors} {
+		for _, creator := range creators {
+			if creator == nil || creator.PersonOrOrg == nil || creator.PersonOrOrg.Type != "personal" {
+				continue
+			}
+			person, review := idx.matchCreator(creator)
+			if review != nil {
+				review.Record = doi
+				idx.report(review)
+				continue
+			}
+			if person == nil {
+				continue
+			}
+			setCreatorIdentifier(creator.PersonOrOrg, "clpid", person.GetIdentifier("clpid"))
+			setCreatorIdentifier(creator.PersonOrOrg, "orcid", normalizeORCID(person.GetIdentifier("orcid")))
+			n++
+		}
+	}
+	return n
+}
+
+// WritePeopleReport writes the creators needing review as tab delimited
+// lines of record DOI, creator, ORCID, how they matched, the candidate
+// clpids and a note.
+func WritePeopleReport(out io.Writer, matches []*PeopleMatch) error {
+	for _, m := range matches {
+		if _, err := fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s\t%s\n", m.Record, m.Creator, m.ORCID, m.MatchedOn, strings.Join(m.Candidates, ", "), m.Note); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package irdmtools
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/crossrefapi"
+	"github.com/caltechlibrary/simplified"
+)
+
+// testCreator returns a personal creator with an optional ORCID and
+// affiliation.
+func testCreator(family string, given string, orcid string, affiliation *simplified.Affiliation) *simplified.Creator {
+	creator := &simplified.Creator{
+		PersonOrOrg: &simplified.PersonOrOrg{
+			Type:       "personal",
+			FamilyName: family,
+			GivenName:  given,
+			Name:       family + ", " + given,
+		},
+	}
+	if orcid != "" {
+		creator.PersonOrOrg.Identifiers = append(creator.PersonOrOrg.Identifiers, mkSimpleIdentifier("orcid", orcid))
+	}
+	if affiliation != nil {
+		creator.Affiliations = append(creator.Affiliations, affiliation)
+	}
+	return creator
+}
+
+func TestReadPeopleCSV(t *testing.T) {
+	src, err := os.ReadFile(path.Join("testdata", "people", "people.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	people, err := ReadPeopleCSV(bytes.NewReader(src), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(people) != 6 {
+		t.Fatalf("expected 6 people, got %d", len(people))
+	}
+	person := people[0]
+	if person.Name != "Doe, Jane" || person.GetIdentifier("clpid") != "Doe-J" || person.GetIdentifier("orcid") != "0000-0001-2345-6789" {
+		t.Errorf("unexpected person %+v", person)
+	}
+	if len(person.Affiliations) != 1 || person.Affiliations[0].ID != CaltechROR {
+		t.Errorf("expected a single Caltech affiliation, got %+v", person.Affiliations)
+	}
+	if _, err := ReadPeopleCSV(strings.NewReader("clpid,shoe_size\nDoe-J,9\n"), false); err == nil {
+		t.Errorf("expected an error for an unknown column")
+	}
+}
+
+func TestGivenNamesMatch(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		expected string
+	}{
+		{"Jane", "jane", "exact"},
+		{"José", "Jose", "exact"},
+		{"J.", "Jane", "initials"},
+		{"J. A.", "John Adam", "initials"},
+		{"John A.", "John Adam", "initials"},
+		{"John", "Jane", ""},
+		{"J", "Karl", ""},
+		{"", "Jane", ""},
+	}
+	for _, tc := range testCases {
+		if got := givenNamesMatch(tc.a, tc.b); got != tc.expected {
+			t.Errorf("givenNamesMatch(%q, %q) expected %q, got %q", tc.a, tc.b, tc.expected, got)
+		}
+	}
+}
+
+func TestParseOrcidRecord(t *testing.T) {
+	records, err := LoadOrcidRecords(path.Join("testdata", "people", "orcid"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// NOTE: only the records with Caltech employment are kept
+	if len(records) != 1 {
+		t.Fatalf("expected 1 ORCID record, got %d", len(records))
+	}
+	rec := records[0]
+	if rec.ORCID != "0000-0002-1825-0097" || rec.FamilyName != "Carberry" || rec.GivenNames != "Josiah" || !rec.Caltech {
+		t.Errorf("unexpected ORCID record %+v", rec)
+	}
+	src, err := os.ReadFile(path.Join("testdata", "people", "orcid", "0000-0003-0000-0001.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err = ParseOrcidRecord(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.ORCID != "0000-0003-0000-0001" || rec.Caltech {
+		t.Errorf("expected no Caltech employment, got %+v", rec)
+	}
+}
+
+func TestEnrichCreators(t *testing.T) {
+	idx, err := LoadPeopleCSV(path.Join("testdata", "people", "people.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := LoadOrcidRecords(path.Join("testdata", "people", "orcid"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx.AddOrcidRecords(records)
+	caltech := &simplified.Affiliation{ID: CaltechROR}
+	rec := new(simplified.Record)
+	rec.ExternalPIDs = map[string]*simplified.PersistentIdentifier{
+		"doi": {Identifier: "10.5555/example"},
+	}
+	rec.Metadata = &simplified.Metadata{
+		Creators: []*simplified.Creator{
+			// ORCID in the people list
+			testCreator("Doe", "J.", "https://orcid.org/0000-0001-2345-6789", nil),
+			// Name and Caltech affiliation
+			testCreator("Roe", "Richard", "", caltech),
+			// Name without a Caltech affiliation
+			testCreator("Roe", "Richard", "", &simplified.Affiliation{Name: "Example University"}),
+			// Only the initials match
+			testCreator("Smith", "J. A.", "", &simplified.Affiliation{Name: "California Institute of Technology"}),
+			// Accents are ignored
+			testCreator("Muller", "Karl", "", caltech),
+			// ORCID record with Caltech employment
+			testCreator("Carberry", "J.", "0000-0002-1825-0097", nil),
+			// ORCID record without Caltech employment
+			testCreator("Roe", "R.", "0000-0003-0000-0001", nil),
+		},
+		Contributors: []*simplified.Creator{
+			// More than one person matches
+			testCreator("Smith", "J.", "", caltech),
+		},
+	}
+	if n := idx.EnrichCreators(rec); n != 4 {
+		t.Errorf("expected 4 creators enriched, got %d", n)
+	}
+	expected := []struct {
+		clpid string
+		orcid string
+	}{
+		{"Doe-J", "https://orcid.org/0000-0001-2345-6789"},
+		{"Roe-R", ""},
+		{"", ""},
+		{"", ""},
+		{"Muller-K", ""},
+		{"Carberry-J", "0000-0002-1825-0097"},
+		{"", "0000-0003-0000-0001"},
+	}
+	for i, creator := range rec.Metadata.Creators {
+		po := creator.PersonOrOrg
+		if got := getCreatorIdentifier(po, "clpid"); got != expected[i].clpid {
+			t.Errorf("(%d) %s expected clpid %q, got %q", i, po.Name, expected[i].clpid, got)
+		}
+		if got := getCreatorIdentifier(po, "orcid"); got != expected[i].orcid {
+			t.Errorf("(%d) %s expected ORCID %q, got %q", i, po.Name, expected[i].orcid, got)
+		}
+	}
+	if len(idx.Reported) != 2 {
+		t.Fatalf("expected 2 creators for review, got %+v", idx.Reported)
+	}
+	if m := idx.Reported[0]; m.Record != "10.5555/example" || m.Creator != "Smith, J. A." || m.MatchedOn != "initials" || strings.Join(m.Candidates, " ") != "Smith-J-A" {
+		t.Errorf("unexpected review %+v", m)
+	}
+	if m := idx.Reported[1]; m.MatchedOn != "initials" || strings.Join(m.Candidates, " ") != "Smith-J-A Smith-J-B" {
+		t.Errorf("unexpected review %+v", m)
+	}
+
+	// Enriching again doesn't duplicate identifiers or reports
+	idx.EnrichCreators(rec)
+	if got := len(rec.Metadata.Creators[1].PersonOrOrg.Identifiers); got != 1 {
+		t.Errorf("expected 1 identifier, got %d", got)
+	}
+	if len(idx.Reported) != 2 {
+		t.Errorf("expected 2 creators for review, got %d", len(idx.Reported))
+	}
+	out := new(bytes.Buffer)
+	if err := WritePeopleReport(out, idx.Reported); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "10.5555/example\tSmith, J. A.\t\tinitials\tSmith-J-A\tonly the initials match\n") {
+		t.Errorf("unexpected report %q", out.String())
+	}
+
+	// A nil index leaves the record alone
+	var none *PeopleIndex
+	if n := none.EnrichCreators(rec); n != 0 {
+		t.Errorf("expected nil index to enrich nothing, got %d", n)
+	}
+}
+
+func TestEnrichCrossRefCreatorWithoutRorDump(t *testing.T) {
+	idx, err := LoadPeopleCSV(path.Join("testdata", "people", "people.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	person := &crossrefapi.Person{
+		Family:      "Roe",
+		Given:       "Richard",
+		Affiliation: []*crossrefapi.Organization{{Name: "California Institute of Technology"}},
+	}
+	// NOTE: without the ROR index or people list the affiliation name is dropped
+	creator := crosswalkWorksPersonToCreator(new(Config), person, "")
+	if len(creator.Affiliations) != 0 {
+		t.Errorf("expected no affiliations by default, got %+v", creator.Affiliations)
+	}
+	// With the people list but no ROR index the name is kept
+	cfg := &Config{People: idx}
+	creator = crosswalkWorksPersonToCreator(cfg, person, "")
+	if len(creator.Affiliations) != 1 || creator.Affiliations[0].Name != "California Institute of Technology" {
+		t.Fatalf("expected the affiliation name, got %+v", creator.Affiliations)
+	}
+	rec := new(simplified.Record)
+	rec.Metadata = &simplified.Metadata{Creators: []*simplified.Creator{creator}}
+	if n := idx.EnrichCreators(rec); n != 1 {
+		t.Errorf("expected 1 creator enriched, got %d", n)
+	}
+	if got := getCreatorIdentifier(creator.PersonOrOrg, "clpid"); got != "Roe-R" {
+		t.Errorf("expected clpid Roe-R, got %q", got)
+	}
+}
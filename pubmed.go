@@ -0,0 +1,777 @@
+package irdmtools
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	// Caltech Library Packages
+	"github.com/caltechlibrary/simplified"
+)
+
+// PubMedAuthor is an author of a PubMed article.
+type PubMedAuthor struct {
+	FamilyName   string   `json:"family_name,omitempty"`
+	GivenName    string   `json:"given_name,omitempty"`
+	Initials     string   `json:"initials,omitempty"`
+	ORCID        string   `json:"orcid,omitempty"`
+	Affiliations []string `json:"affiliations,omitempty"`
+}
+
+// PubMedMeSH is a MeSH heading, e.g. "Neoplasms" qualified by "genetics".
+type PubMedMeSH struct {
+	// Descriptor is the MeSH descriptor name
+	Descriptor string `json:"descriptor,omitempty"`
+	// UI is the MeSH unique identifier, e.g. "D009369"
+	UI string `json:"ui,omitempty"`
+	// Major is true when the heading is a major topic of the article
+	Major      bool     `json:"major,omitempty"`
+	Qualifiers []string `json:"qualifiers,omitempty"`
+}
+
+// PubMedGrant is a grant listed for a PubMed article.
+type PubMedGrant struct {
+	GrantID string `json:"grant_id,omitempty"`
+	Agency  string `json:"agency,omitempty"`
+	Acronym string `json:"acronym,omitempty"`
+	Country string `json:"country,omitempty"`
+}
+
+// PubMedArticle holds the metadata of an article retrieved from PubMed
+// (efetch XML) or Europe PMC (REST API JSON) in a common form for
+// crosswalking to RDM.
+type PubMedArticle struct {
+	PMID             string          `json:"pmid,omitempty"`
+	PMCID            string          `json:"pmcid,omitempty"`
+	DOI              string          `json:"doi,omitempty"`
+	Title            string          `json:"title,omitempty"`
+	Abstract         string          `json:"abstract,omitempty"`
+	Journal          string          `json:"journal,omitempty"`
+	ISSNs            []string        `json:"issns,omitempty"`
+	Volume           string          `json:"volume,omitempty"`
+	Issue            string          `json:"issue,omitempty"`
+	Pages            string          `json:"pages,omitempty"`
+	PublicationDate  string          `json:"publication_date,omitempty"`
+	Language         string          `json:"language,omitempty"`
+	PublicationTypes []string        `json:"publication_types,omitempty"`
+	Authors          []*PubMedAuthor `json:"authors,omitempty"`
+	MeSH             []*PubMedMeSH   `json:"mesh,omitempty"`
+	Keywords         []string        `json:"keywords,omitempty"`
+	Grants           []*PubMedGrant  `json:"grants,omitempty"`
+}
+
+//
+// PubMed efetch XML, see https://www.nlm.nih.gov/bsd/licensee/elements_descriptions.html
+//
+
+type pmArticleSet struct {
+	XMLName  xml.Name     `xml:"PubmedArticleSet"`
+	Articles []*pmArticle `xml:"PubmedArticle"`
+}
+
+type pmArticle struct {
+	MedlineCitation struct {
+		PMID    string `xml:"PMID"`
+		Article struct {
+			Journal struct {
+				ISSN         []string `xml:"ISSN"`
+				JournalIssue struct {
+					Volume  string    `xml:"Volume"`
+					Issue   string    `xml:"Issue"`
+					PubDate pmPubDate `xml:"PubDate"`
+				} `xml:"JournalIssue"`
+				Title string `xml:"Title"`
+			} `xml:"Journal"`
+			ArticleTitle pmText `xml:"ArticleTitle"`
+			Pagination   struct {
+				MedlinePgn string `xml:"MedlinePgn"`
+			} `xml:"Pagination"`
+			Abstract struct {
+				AbstractText []pmAbstractText `xml:"AbstractText"`
+			} `xml:"Abstract"`
+			AuthorList struct {
+				Authors []struct {
+					LastName    string `xml:"LastName"`
+					ForeName    string `xml:"ForeName"`
+					Initials    string `xml:"Initials"`
+					Identifiers []struct {
+						Source string `xml:"Source,attr"`
+						Value  string `xml:",chardata"`
+					} `xml:"Identifier"`
+					AffiliationInfo []struct {
+						Affiliation string `xml:"Affiliation"`
+					} `xml:"AffiliationInfo"`
+				} `xml:"Author"`
+			} `xml:"AuthorList"`
+			Language  []string `xml:"Language"`
+			GrantList struct {
+				Grants []struct {
+					GrantID string `xml:"GrantID"`
+					Acronym string `xml:"Acronym"`
+					Agency  string `xml:"Agency"`
+					Country string `xml:"Country"`
+				} `xml:"Grant"`
+			} `xml:"GrantList"`
+			PublicationTypes []string    `xml:"PublicationTypeList>PublicationType"`
+			ArticleDates     []pmPubDate `xml:"ArticleDate"`
+		} `xml:"Article"`
+		MeshHeadings []struct {
+			Descriptor struct {
+				UI    string `xml:"UI,attr"`
+				Major string `xml:"MajorTopicYN,attr"`
+				Name  string `xml:",chardata"`
+			} `xml:"DescriptorName"`
+			Qualifiers []struct {
+				Major string `xml:"MajorTopicYN,attr"`
+				Name  string `xml:",chardata"`
+			} `xml:"QualifierName"`
+		} `xml:"MeshHeadingList>MeshHeading"`
+		Keywords []string `xml:"KeywordList>Keyword"`
+	} `xml:"MedlineCitation"`
+	PubmedData struct {
+		ArticleIds []struct {
+			IdType string `xml:"IdType,attr"`
+			Value  string `xml:",chardata"`
+		} `xml:"ArticleIdList>ArticleId"`
+	} `xml:"PubmedData"`
+}
+
+// pmText holds element text which may include inline markup,
+// e.g. <i>, <sup>.
+type pmText struct {
+	Inner string `xml:",innerxml"`
+}
+
+type pmAbstractText struct {
+	Label string `xml:"Label,attr"`
+	pmText
+}
+
+type pmPubDate struct {
+	Year        string `xml:"Year"`
+	Month       string `xml:"Month"`
+	Day         string `xml:"Day"`
+	MedlineDate string `xml:"MedlineDate"`
+}
+
+var (
+	reMarkup = regexp.MustCompile(`<[^>]+>`)
+	months   = map[string]string{
+		"jan": "01", "feb": "02", "mar": "03", "apr": "04",
+		"may": "05", "jun": "06", "jul": "07", "aug": "08",
+		"sep": "09", "oct": "10", "nov": "11", "dec": "12",
+	}
+)
+
+// text returns the element's text without inline markup.
+func (t pmText) text() string {
+	s := html.UnescapeString(reMarkup.ReplaceAllString(t.Inner, ""))
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// date returns the date as YYYY, YYYY-MM or YYYY-MM-DD.
+func (d pmPubDate) date() string {
+	year := d.Year
+	if year == "" && len(d.MedlineDate) >= 4 {
+		// NOTE: MedlineDate are free text, e.g. "1998 Dec-1999 Jan"
+		year = d.MedlineDate[0:4]
+	}
+	if year == "" {
+		return ""
+	}
+	month := d.Month
+	if m, ok := months[strings.ToLower(month)]; ok {
+		month = m
+	}
+	if len(month) == 1 {
+		month = "0" + month
+	}
+	if len(month) != 2 {
+		return year
+	}
+	day := d.Day
+	if len(day) == 1 {
+		day = "0" + day
+	}
+	if len(day) != 2 {
+		return year + "-" + month
+	}
+	return year + "-" + month + "-" + day
+}
+
+// ParsePubMedXML reads PubMed efetch XML, e.g. a saved response from
+// https://eutils.ncbi.nlm.nih.gov/entrez/eutils/efetch.fcgi?db=pubmed&retmode=xml&id=PMID,
+// returning the articles it contains.
+//
+// ```
+// src, _ := os.ReadFile("pubmed-31452104.xml")
+// articles, err := ParsePubMedXML(src)
+// if err != nil {
+//     // ... handle error ...
+// }
+// rec, err := CrosswalkPubMedArticle(cfg, articles[0], options)
+// ```
+func ParsePubMedXML(src []byte) ([]*PubMedArticle, error) {
+	set := new(pmArticleSet)
+	if err := xml.Unmarshal(src, set); err != nil {
+		return nil, err
+	}
+	articles := []*PubMedArticle{}
+	for _, item := range set.Articles {
+		citation := item.MedlineCitation
+		journal := citation.Article.Journal
+		article := &PubMedArticle{
+			PMID:             strings.TrimSpace(citation.PMID),
+			Title:            citation.Article.ArticleTitle.text(),
+			Journal:          journal.Title,
+			ISSNs:            journal.ISSN,
+			Volume:           journal.JournalIssue.Volume,
+			Issue:            journal.JournalIssue.Issue,
+			Pages:            citation.Article.Pagination.MedlinePgn,
+			PublicationDate:  journal.JournalIssue.PubDate.date(),
+			PublicationTypes: citation.Article.PublicationTypes,
+			Keywords:         citation.Keywords,
+		}
+		// NOTE: The electronic publication date is complete, the
+		// journal issue date is often only a year and month.
+		for _, d := range citation.Article.ArticleDates {
+			if val := d.date(); len(val) > len(article.PublicationDate) {
+				article.PublicationDate = val
+			}
+		}
+		if len(citation.Article.Language) > 0 {
+			article.Language = citation.Article.Language[0]
+		}
+		parts := []string{}
+		for _, abstract := range citation.Article.Abstract.AbstractText {
+			if val := abstract.text(); val != "" {
+				if abstract.Label != "" {
+					val = abstract.Label + ": " + val
+				}
+				parts = append(parts, val)
+			}
+		}
+		article.Abstract = strings.Join(parts, "\n\n")
+		for _, author := range citation.Article.AuthorList.Authors {
+			if author.LastName == "" {
+				// NOTE: collective names (e.g. consortia) aren't people
+				continue
+			}
+			person := &PubMedAuthor{
+				FamilyName: author.LastName,
+				GivenName:  author.ForeName,
+				Initials:   author.Initials,
+			}
+			for _, identifier := range author.Identifiers {
+				if strings.EqualFold(identifier.Source, "ORCID") {
+					person.ORCID = normalizeORCID(identifier.Value)
+				}
+			}
+			for _, info := range author.AffiliationInfo {
+				if info.Affiliation != "" {
+					person.Affiliations = append(person.Affiliations, info.Affiliation)
+				}
+			}
+			article.Authors = append(article.Authors, person)
+		}
+		for _, heading := range citation.MeshHeadings {
+			mesh := &PubMedMeSH{
+				Descriptor: heading.Descriptor.Name,
+				UI:         heading.Descriptor.UI,
+				Major:      heading.Descriptor.Major == "Y",
+			}
+			for _, qualifier := range heading.Qualifiers {
+				mesh.Qualifiers = append(mesh.Qualifiers, qualifier.Name)
+				if qualifier.Major == "Y" {
+					mesh.Major = true
+				}
+			}
+			article.MeSH = append(article.MeSH, mesh)
+		}
+		for _, grant := range citation.Article.GrantList.Grants {
+			article.Grants = append(article.Grants, &PubMedGrant{
+				GrantID: grant.GrantID,
+				Agency:  grant.Agency,
+				Acronym: grant.Acronym,
+				Country: grant.Country,
+			})
+		}
+		for _, id := range item.PubmedData.ArticleIds {
+			switch id.IdType {
+			case "doi":
+				article.DOI = strings.TrimSpace(id.Value)
+			case "pmc":
+				article.PMCID = strings.ToUpper(strings.TrimSpace(id.Value))
+			}
+		}
+		articles = append(articles, article)
+	}
+	return articles, nil
+}
+
+//
+// Europe PMC REST API JSON, see https://europepmc.org/RestfulWebService
+//
+
+type epmcSearch struct {
+	HitCount   int `json:"hitCount"`
+	ResultList struct {
+		Results []*epmcResult `json:"result"`
+	} `json:"resultList"`
+}
+
+type epmcResult struct {
+	Source               string `json:"source"`
+	PMID                 string `json:"pmid"`
+	PMCID                string `json:"pmcid"`
+	DOI                  string `json:"doi"`
+	Title                string `json:"title"`
+	AbstractText         string `json:"abstractText"`
+	Language             string `json:"language"`
+	PageInfo             string `json:"pageInfo"`
+	FirstPublicationDate string `json:"firstPublicationDate"`
+	JournalInfo          struct {
+		Volume               string `json:"volume"`
+		Issue                string `json:"issue"`
+		PrintPublicationDate string `json:"printPublicationDate"`
+		Journal              struct {
+			Title string `json:"title"`
+			ISSN  string `json:"issn"`
+			ESSN  string `json:"essn"`
+		} `json:"journal"`
+	} `json:"journalInfo"`
+	AuthorList struct {
+		Authors []struct {
+			FirstName string `json:"firstName"`
+			LastName  string `json:"lastName"`
+			Initials  string `json:"initials"`
+			AuthorId  struct {
+				Type  string `json:"type"`
+				Value string `json:"value"`
+			} `json:"authorId"`
+			AffiliationDetails struct {
+				Affiliations []struct {
+					Affiliation string `json:"affiliation"`
+				} `json:"authorAffiliation"`
+			} `json:"authorAffiliationDetailsList"`
+		} `json:"author"`
+	} `json:"authorList"`
+	PubTypeList struct {
+		PubTypes []string `json:"pubType"`
+	} `json:"pubTypeList"`
+	MeshHeadingList struct {
+		MeshHeadings []struct {
+			MajorTopic     string `json:"majorTopic_YN"`
+			DescriptorName string `json:"descriptorName"`
+			QualifierList  struct {
+				Qualifiers []struct {
+					QualifierName string `json:"qualifierName"`
+					MajorTopic    string `json:"majorTopic_YN"`
+				} `json:"meshQualifier"`
+			} `json:"meshQualifierList"`
+		} `json:"meshHeading"`
+	} `json:"meshHeadingList"`
+	KeywordList struct {
+		Keywords []string `json:"keyword"`
+	} `json:"keywordList"`
+	GrantsList struct {
+		Grants []struct {
+			GrantID string `json:"grantId"`
+			Agency  string `json:"agency"`
+			Acronym string `json:"acronym"`
+		} `json:"grant"`
+	} `json:"grantsList"`
+}
+
+// ParseEuropePMC reads a Europe PMC search response retrieved with
+// "resultType=core" and "format=json", returning the articles it contains.
+//
+// ```
+// src, _ := os.ReadFile("europepmc-31452104.json")
+// articles, err := ParseEuropePMC(src)
+// if err != nil {
+//     // ... handle error ...
+// }
+// ```
+func ParseEuropePMC(src []byte) ([]*PubMedArticle, error) {
+	search := new(epmcSearch)
+	if err := JSONUnmarshal(src, search); err != nil {
+		return nil, err
+	}
+	articles := []*PubMedArticle{}
+	for _, result := range search.ResultList.Results {
+		info := result.JournalInfo
+		article := &PubMedArticle{
+			PMID:             result.PMID,
+			PMCID:            strings.ToUpper(result.PMCID),
+			DOI:              result.DOI,
+			Title:            pmText{Inner: result.Title}.text(),
+			Abstract:         pmText{Inner: result.AbstractText}.text(),
+			Journal:          info.Journal.Title,
+			Volume:           info.Volume,
+			Issue:            info.Issue,
+			Pages:            result.PageInfo,
+			Language:         result.Language,
+			PublicationTypes: result.PubTypeList.PubTypes,
+			Keywords:         result.KeywordList.Keywords,
+		}
+		for _, issn := range []string{info.Journal.ISSN, info.Journal.ESSN} {
+			if issn != "" {
+				article.ISSNs = append(article.ISSNs, issn)
+			}
+		}
+		switch {
+		case result.FirstPublicationDate != "":
+			article.PublicationDate = result.FirstPublicationDate
+		case info.PrintPublicationDate != "":
+			article.PublicationDate = info.PrintPublicationDate
+		}
+		for _, author := range result.AuthorList.Authors {
+			if author.LastName == "" {
+				continue
+			}
+			person := &PubMedAuthor{
+				FamilyName: author.LastName,
+				GivenName:  author.FirstName,
+				Initials:   author.Initials,
+			}
+			if strings.EqualFold(author.AuthorId.Type, "ORCID") {
+				person.ORCID = normalizeORCID(author.AuthorId.Value)
+			}
+			for _, affiliation := range author.AffiliationDetails.Affiliations {
+				if affiliation.Affiliation != "" {
+					person.Affiliations = append(person.Affiliations, affiliation.Affiliation)
+				}
+			}
+			article.Authors = append(article.Authors, person)
+		}
+		for _, heading := range result.MeshHeadingList.MeshHeadings {
+			mesh := &PubMedMeSH{
+				Descriptor: heading.DescriptorName,
+				Major:      heading.MajorTopic == "Y",
+			}
+			for _, qualifier := range heading.QualifierList.Qualifiers {
+				mesh.Qualifiers = append(mesh.Qualifiers, qualifier.QualifierName)
+				if qualifier.MajorTopic == "Y" {
+					mesh.Major = true
+				}
+			}
+			article.MeSH = append(article.MeSH, mesh)
+		}
+		for _, grant := range result.GrantsList.Grants {
+			article.Grants = append(article.Grants, &PubMedGrant{
+				GrantID: grant.GrantID,
+				Agency:  grant.Agency,
+				Acronym: grant.Acronym,
+			})
+		}
+		articles = append(articles, article)
+	}
+	return articles, nil
+}
+
+// normalizeORCID returns the ORCID without the https://orcid.org/ prefix.
+func normalizeORCID(orcid string) string {
+	orcid = strings.TrimSpace(orcid)
+	for _, prefix := range []string{"https://orcid.org/", "http://orcid.org/"} {
+		orcid = strings.TrimPrefix(orcid, prefix)
+	}
+	return orcid
+}
+
+// europePMCQuery returns the Europe PMC query for a DOI, PMID
+// (e.g. "pmid:31452104") or PMCID (e.g. "PMC6711234").
+func europePMCQuery(id string) string {
+	id = strings.TrimSpace(id)
+	lower := strings.ToLower(id)
+	switch {
+	case strings.HasPrefix(lower, "pmid:"):
+		return fmt.Sprintf("EXT_ID:%s AND SRC:MED", strings.TrimSpace(id[5:]))
+	case strings.HasPrefix(lower, "pmc") && !strings.Contains(id, "/"):
+		return fmt.Sprintf("PMCID:%s", strings.ToUpper(id))
+	case strings.Trim(id, "0123456789") == "":
+		return fmt.Sprintf("EXT_ID:%s AND SRC:MED", id)
+	}
+	return fmt.Sprintf("DOI:%q", id)
+}
+
+// queryEuropePMC calls the Europe PMC search API.
+func queryEuropePMC(query string) ([]byte, error) {
+	searchAPI := "https://www.ebi.ac.uk/europepmc/webservices/rest/search"
+	client := &http.Client{}
+	req, err := http.NewRequest("GET", searchAPI, nil)
+	if err != nil {
+		return nil, err
+	}
+	q := req.URL.Query()
+	q.Set("query", query)
+	q.Set("resultType", "core")
+	q.Set("format", "json")
+	q.Set("pageSize", "1")
+	req.URL.RawQuery = q.Encode()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s", searchAPI, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// QueryPubMedArticle retrieves the article for a DOI, PMID (e.g.
+// "pmid:31452104") or PMCID (e.g. "PMC6711234") from Europe PMC.
+// Responses are read from and saved to cfg.Cache when set.
+//
+// ```
+// article, err := QueryPubMedArticle(cfg, "10.1038/s41586-019-1507-6")
+// if err != nil {
+//     // ... handle error ...
+// }
+// rec, err := CrosswalkPubMedArticle(cfg, article, options)
+// ```
+func QueryPubMedArticle(cfg *Config, id string) (*PubMedArticle, error) {
+	query := europePMCQuery(id)
+	src, err := cfg.Cache.Fetch("europepmc", query, func() ([]byte, error) {
+		return queryEuropePMC(query)
+	})
+	if err != nil {
+		return nil, err
+	}
+	articles, err := ParseEuropePMC(src)
+	if err != nil {
+		return nil, err
+	}
+	if len(articles) == 0 {
+		return nil, fmt.Errorf("%q not found in Europe PMC", id)
+	}
+	if cfg.Debug {
+		src, _ := JSONMarshalIndent(articles[0], "", "    ")
+		fmt.Fprintf(os.Stderr, "pubmed article JSON:\n\n%s\n\n", src)
+	}
+	return articles[0], nil
+}
+
+// getArticleResourceType returns the first publication type mapped in
+// resourceTypes, e.g. "Journal Article".
+func getArticleResourceType(article *PubMedArticle, resourceTypes map[string]string) string {
+	for _, pubType := range article.PublicationTypes {
+		if _, ok := resourceTypes[pubType]; ok {
+			return pubType
+		}
+	}
+	return ""
+}
+
+// getArticleCreators maps the authors, matching affiliations with the
+// ROR data dump index when available.
+func getArticleCreators(cfg *Config, article *PubMedArticle) []*simplified.Creator {
+	creators := []*simplified.Creator{}
+	for _, author := range article.Authors {
+		creator := &simplified.Creator{
+			PersonOrOrg: &simplified.PersonOrOrg{
+				Type:       "personal",
+				FamilyName: author.FamilyName,
+				GivenName:  author.GivenName,
+				Name:       fmt.Sprintf("%s, %s", author.FamilyName, author.GivenName),
+			},
+			Role: makeSimpleRole("author"),
+		}
+		if author.ORCID != "" {
+			creator.PersonOrOrg.Identifiers = append(creator.PersonOrOrg.Identifiers, mkSimpleIdentifier("orcid", author.ORCID))
+		}
+		for _, name := range author.Affiliations {
+			affiliation := &simplified.Affiliation{Name: name}
+			if cfg.Ror != nil {
+				if ror, ok := cfg.Ror.Resolve(name); ok {
+					affiliation = &simplified.Affiliation{ID: ror}
+				}
+			}
+			if !creator.HasAffiliation(affiliation) {
+				creator.Affiliations = append(creator.Affiliations, affiliation)
+			}
+		}
+		creators = append(creators, creator)
+	}
+	return creators
+}
+
+// getArticleSubjects returns the MeSH headings and keywords as subjects.
+// Qualified headings are written as "Descriptor/qualifier".
+func getArticleSubjects(article *PubMedArticle) []*simplified.Subject {
+	subjects := []*simplified.Subject{}
+	add := func(s string) {
+		subject := &simplified.Subject{Subject: strings.TrimSpace(s)}
+		if subject.Subject != "" && !isDuplicateSubject(subject, subjects) {
+			subjects = append(subjects, subject)
+		}
+	}
+	for _, mesh := range article.MeSH {
+		if len(mesh.Qualifiers) == 0 {
+			add(mesh.Descriptor)
+		}
+		for _, qualifier := range mesh.Qualifiers {
+			add(mesh.Descriptor + "/" + qualifier)
+		}
+	}
+	for _, keyword := range article.Keywords {
+		add(keyword)
+	}
+	return subjects
+}
+
+// getArticleFunding returns the grants as funding. Agencies are matched
+// with the ROR data dump index when available.
+func getArticleFunding(cfg *Config, article *PubMedArticle) []*simplified.Funder {
+	funding := []*simplified.Funder{}
+	for _, grant := range article.Grants {
+		funder := new(simplified.Funder)
+		if grant.Agency != "" {
+			funder.Funder = &simplified.FunderIdentifier{Name: grant.Agency}
+			if cfg.Ror != nil {
+				if ror, ok := cfg.Ror.Resolve(grant.Agency); ok {
+					funder.Funder.Identifier = ror
+				}
+			}
+		}
+		if grant.GrantID != "" {
+			funder.Award = &simplified.AwardIdentifier{Number: grant.GrantID}
+		}
+		if funder.Funder != nil || funder.Award != nil {
+			funding = append(funding, funder)
+		}
+	}
+	return funding
+}
+
+// CrosswalkPubMedArticle takes an article from PubMed or Europe PMC and
+// maps the fields into a simplified Record including the MeSH headings
+// as subjects, the PMID and PMCID as identifiers and the grants as
+// funding.
+func CrosswalkPubMedArticle(cfg *Config, article *PubMedArticle, options *Doi2RdmOptions) (*simplified.Record, error) {
+	if article == nil {
+		return nil, fmt.Errorf("pubmed article not populated")
+	}
+	rec := new(simplified.Record)
+	rec.Metadata = new(simplified.Metadata)
+	if value := getArticleResourceType(article, options.ResourceTypes); value != "" {
+		if err := SetResourceType(rec, value, options.ResourceTypes); err != nil {
+			return nil, err
+		}
+	}
+	if article.DOI != "" {
+		if err := SetDOI(rec, article.DOI); err != nil {
+			return nil, err
+		}
+	}
+	if article.Title != "" {
+		if err := SetTitle(rec, article.Title); err != nil {
+			return nil, err
+		}
+	}
+	if article.Abstract != "" {
+		if err := SetDescription(rec, article.Abstract); err != nil {
+			return nil, err
+		}
+	}
+	if values := getArticleCreators(cfg, article); len(values) > 0 {
+		if err := SetCreators(rec, values); err != nil {
+			return nil, err
+		}
+	}
+	if article.Journal != "" {
+		if err := SetPublication(rec, article.Journal); err != nil {
+			return nil, err
+		}
+	}
+	if article.Volume != "" {
+		if err := SetVolume(rec, article.Volume); err != nil {
+			return nil, err
+		}
+	}
+	if article.Issue != "" {
+		if err := SetIssue(rec, article.Issue); err != nil {
+			return nil, err
+		}
+	}
+	if article.Pages != "" {
+		if err := SetPageRange(rec, article.Pages); err != nil {
+			return nil, err
+		}
+	}
+	if len(article.ISSNs) > 0 {
+		if err := SetJournalField(rec, "issn", article.ISSNs[0]); err != nil {
+			return nil, err
+		}
+		for _, issn := range article.ISSNs[1:] {
+			AddIdentifier(rec, "issn", issn)
+		}
+	}
+	if article.PMID != "" {
+		AddIdentifier(rec, "pmid", article.PMID)
+	}
+	if article.PMCID != "" {
+		AddIdentifier(rec, "pmcid", article.PMCID)
+	}
+	if values := getArticleSubjects(article); len(values) > 0 {
+		if err := AddSubjects(rec, values); err != nil {
+			return nil, err
+		}
+	}
+	if values := getArticleFunding(cfg, article); len(values) > 0 {
+		if err := SetFunding(rec, values); err != nil {
+			return nil, err
+		}
+	}
+	if article.PublicationDate != "" {
+		if err := SetPublicationDate(rec, article.PublicationDate); err != nil {
+			return nil, err
+		}
+	}
+	cfg.People.EnrichCreators(rec)
+	return rec, nil
+}
+
+// EnrichWithPubMed adds what PubMed knows and rec lacks: the abstract
+// if rec has none, MeSH subjects, PMID and PMCID identifiers and the
+// grants if rec has no funding.
+func EnrichWithPubMed(rec *simplified.Record, pmRecord *simplified.Record) {
+	if rec == nil || pmRecord == nil || pmRecord.Metadata == nil {
+		return
+	}
+	if rec.Metadata == nil {
+		rec.Metadata = new(simplified.Metadata)
+	}
+	if strings.TrimSpace(rec.Metadata.Description) == "" {
+		rec.Metadata.Description = pmRecord.Metadata.Description
+	}
+	for _, subject := range pmRecord.Metadata.Subjects {
+		if !isDuplicateSubject(subject, rec.Metadata.Subjects) {
+			rec.Metadata.Subjects = append(rec.Metadata.Subjects, subject)
+		}
+	}
+	for _, identifier := range pmRecord.Metadata.Identifiers {
+		if identifier.Scheme != "pmid" && identifier.Scheme != "pmcid" {
+			continue
+		}
+		found := false
+		for _, item := range rec.Metadata.Identifiers {
+			if item.Scheme == identifier.Scheme && item.Identifier == identifier.Identifier {
+				found = true
+				break
+			}
+		}
+		if !found {
+			rec.Metadata.Identifiers = append(rec.Metadata.Identifiers, identifier)
+		}
+	}
+	if len(rec.Metadata.Funding) == 0 {
+		rec.Metadata.Funding = pmRecord.Metadata.Funding
+	}
+}
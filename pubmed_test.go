@@ -0,0 +1,242 @@
+package irdmtools
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/simplified"
+)
+
+func checkPubMedArticle(t *testing.T, source string, article *PubMedArticle) {
+	t.Helper()
+	expected := map[string]string{
+		"pmid":    "30000001",
+		"pmcid":   "PMC6100001",
+		"doi":     "10.1016/j.cell.2018.06.001",
+		"title":   "Neural circuits controlling thirst in Drosophila.",
+		"journal": "Cell",
+		"volume":  "174",
+		"issue":   "3",
+		"pages":   "730-742",
+		"date":    "2018-06-28",
+	}
+	got := map[string]string{
+		"pmid":    article.PMID,
+		"pmcid":   article.PMCID,
+		"doi":     article.DOI,
+		"title":   article.Title,
+		"journal": article.Journal,
+		"volume":  article.Volume,
+		"issue":   article.Issue,
+		"pages":   article.Pages,
+		"date":    article.PublicationDate,
+	}
+	for k, v := range expected {
+		if got[k] != v {
+			t.Errorf("%s: expected %s %q, got %q", source, k, v, got[k])
+		}
+	}
+	if !strings.Contains(article.Abstract, "water") || !strings.Contains(article.Abstract, "& satiety") {
+		t.Errorf("%s: unexpected abstract %q", source, article.Abstract)
+	}
+	if len(article.Authors) != 2 || article.Authors[0].ORCID != "0000-0002-1825-0097" || len(article.Authors[1].Affiliations) != 1 {
+		t.Errorf("%s: unexpected authors %+v", source, article.Authors)
+	}
+	if len(article.MeSH) != 3 || article.MeSH[1].Descriptor != "Drosophila" || !article.MeSH[1].Major || article.MeSH[1].Qualifiers[0] != "physiology" {
+		t.Errorf("%s: unexpected MeSH %+v", source, article.MeSH)
+	}
+	if len(article.Grants) != 2 || article.Grants[0].GrantID != "R01 DK123456" || article.Grants[1].Agency != "National Science Foundation" {
+		t.Errorf("%s: unexpected grants %+v", source, article.Grants)
+	}
+}
+
+func TestParsePubMedXML(t *testing.T) {
+	src, err := os.ReadFile(path.Join("testdata", "pubmed", "pubmed-efetch.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	articles, err := ParsePubMedXML(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(articles) != 1 {
+		t.Fatalf("expected one article, got %d", len(articles))
+	}
+	checkPubMedArticle(t, "pubmed", articles[0])
+	if articles[0].MeSH[0].UI != "D000818" {
+		t.Errorf("expected MeSH UI D000818, got %q", articles[0].MeSH[0].UI)
+	}
+	if !strings.HasPrefix(articles[0].Abstract, "BACKGROUND: ") {
+		t.Errorf("expected labeled abstract, got %q", articles[0].Abstract)
+	}
+}
+
+func TestParseEuropePMC(t *testing.T) {
+	src, err := os.ReadFile(path.Join("testdata", "pubmed", "europepmc-core.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	articles, err := ParseEuropePMC(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(articles) != 1 {
+		t.Fatalf("expected one article, got %d", len(articles))
+	}
+	checkPubMedArticle(t, "europepmc", articles[0])
+	if strings.Join(articles[0].ISSNs, ",") != "0092-8674,1097-4172" {
+		t.Errorf("unexpected ISSNs %q", articles[0].ISSNs)
+	}
+	if articles, err := ParseEuropePMC([]byte(`{"hitCount": 0, "resultList": {"result": []}}`)); err != nil || len(articles) != 0 {
+		t.Errorf("expected no articles, got %d, %v", len(articles), err)
+	}
+}
+
+func TestCrosswalkPubMedArticle(t *testing.T) {
+	article, err := readPubMedFile(path.Join("testdata", "pubmed", "pubmed-efetch.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := LoadRorDump(path.Join("testdata", "ror", "ror-data.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{Ror: idx}
+	options, err := LoadDoi2RdmOptions("", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := CrosswalkPubMedArticle(cfg, article, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Metadata.ResourceType["id"] != "publication-article" {
+		t.Errorf("expected publication-article, got %+v", rec.Metadata.ResourceType)
+	}
+	if rec.ExternalPIDs["doi"] == nil || rec.ExternalPIDs["doi"].Identifier != "10.1016/j.cell.2018.06.001" {
+		t.Errorf("unexpected DOI %+v", rec.ExternalPIDs)
+	}
+	identifiers := map[string]string{}
+	for _, identifier := range rec.Metadata.Identifiers {
+		identifiers[identifier.Scheme] = identifier.Identifier
+	}
+	if identifiers["pmid"] != "30000001" || identifiers["pmcid"] != "PMC6100001" {
+		t.Errorf("expected PMID and PMCID identifiers, got %+v", identifiers)
+	}
+	subjects := []string{}
+	for _, subject := range rec.Metadata.Subjects {
+		subjects = append(subjects, subject.Subject)
+	}
+	if strings.Join(subjects, "; ") != "Animals; Drosophila/physiology; Thirst; neural circuits" {
+		t.Errorf("unexpected subjects %q", subjects)
+	}
+	if len(rec.Metadata.Funding) != 2 {
+		t.Fatalf("expected two grants, got %+v", rec.Metadata.Funding)
+	}
+	nsf := rec.Metadata.Funding[1]
+	if nsf.Funder == nil || nsf.Funder.Identifier != "021nxhr62" || nsf.Award == nil || nsf.Award.Number != "IOS-1456789" {
+		t.Errorf("expected NSF grant with ROR id, got %+v", nsf)
+	}
+	creators := rec.Metadata.Creators
+	if len(creators) != 2 || creators[1].Affiliations[0].ID != "05dxps055" {
+		t.Errorf("expected Caltech affiliation, got %+v", creators)
+	}
+	if _, err := CrosswalkPubMedArticle(cfg, nil, options); err == nil {
+		t.Errorf("expected an error for a missing article")
+	}
+}
+
+func TestEnrichWithPubMed(t *testing.T) {
+	article, err := readPubMedFile(path.Join("testdata", "pubmed", "europepmc-core.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	options, _ := LoadDoi2RdmOptions("", false)
+	pmRecord, err := CrosswalkPubMedArticle(new(Config), article, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := new(simplified.Record)
+	rec.Metadata = new(simplified.Metadata)
+	SetTitle(rec, "CrossRef title")
+	AddSubject(rec, "Thirst")
+	AddIdentifier(rec, "pmid", "30000001")
+	SetFunding(rec, []*simplified.Funder{{Award: &simplified.AwardIdentifier{Number: "PHY-1234567"}}})
+	EnrichWithPubMed(rec, pmRecord)
+	if rec.Metadata.Title != "CrossRef title" || !strings.HasPrefix(rec.Metadata.Description, "BACKGROUND:") {
+		t.Errorf("expected title kept and abstract added, got %+v", rec.Metadata)
+	}
+	if len(rec.Metadata.Subjects) != 4 {
+		t.Errorf("expected MeSH subjects added without duplicates, got %d", len(rec.Metadata.Subjects))
+	}
+	if len(rec.Metadata.Identifiers) != 2 {
+		t.Errorf("expected PMCID added without duplicating PMID, got %+v", rec.Metadata.Identifiers)
+	}
+	if len(rec.Metadata.Funding) != 1 {
+		t.Errorf("expected existing funding kept, got %+v", rec.Metadata.Funding)
+	}
+}
+
+func TestPubMedOffline(t *testing.T) {
+	for id, query := range map[string]string{
+		"pmid:30000001":              "EXT_ID:30000001 AND SRC:MED",
+		"30000001":                   "EXT_ID:30000001 AND SRC:MED",
+		"pmc6100001":                 "PMCID:PMC6100001",
+		"10.1016/j.cell.2018.06.001": `DOI:"10.1016/j.cell.2018.06.001"`,
+	} {
+		if got := europePMCQuery(id); got != query {
+			t.Errorf("expected query %q for %q, got %q", query, id, got)
+		}
+	}
+	cache, err := OpenResponseCache(path.Join(t.TempDir(), "api_cache.ds"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+	src, err := os.ReadFile(path.Join("testdata", "pubmed", "europepmc-core.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doi := "10.1016/j.cell.2018.06.001"
+	if err := cache.Put("europepmc", europePMCQuery(doi), src); err != nil {
+		t.Fatal(err)
+	}
+	cache.Offline = true
+	cfg := &Config{Cache: cache}
+	options, _ := LoadDoi2RdmOptions("", false)
+	batch, err := newDoiBatch(cfg, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := batch.getRecord("pubmed", doi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Metadata.Title != "Neural circuits controlling thirst in Drosophila." {
+		t.Errorf("unexpected title %q", rec.Metadata.Title)
+	}
+	if _, err := batch.getRecord("pubmed", "pmid:1"); err == nil {
+		t.Errorf("expected an error for an uncached PMID offline")
+	}
+	// Combined mode enriches the CrossRef record
+	cache.Put("crossref", doi, []byte(`{
+    "status": "ok",
+    "message-type": "work",
+    "message": {
+        "DOI": "10.1016/j.cell.2018.06.001",
+        "type": "journal-article",
+        "title": [ "Neural circuits controlling thirst in Drosophila" ]
+    }
+}`))
+	options.EnrichPubMed = true
+	rec, err = batch.getRecord("", doi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Metadata.Description == "" || len(rec.Metadata.Subjects) != 4 || len(rec.Metadata.Funding) != 2 {
+		t.Errorf("expected CrossRef record enriched from PubMed, got %+v", rec.Metadata)
+	}
+}
@@ -0,0 +1,284 @@
+package irdmtools
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// existingRecord is a candidate match for a record being put into RDM.
+type existingRecord struct {
+	// ID is the RDM record (or draft) id
+	ID string
+	// Parent is the id shared by all versions of a record
+	Parent string
+	// Draft is true if the record has never been published
+	Draft bool
+}
+
+// putRecordSchemes are the EPrints identifiers, in order of preference,
+// used to find a migrated record.
+var putRecordSchemes = []string{"resolverid", "eprintid"}
+
+// recordIdentifier returns the identifier in .metadata.identifiers with
+// the given scheme.
+func recordIdentifier(rec map[string]interface{}, scheme string) string {
+	metadata, ok := rec["metadata"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	identifiers, ok := metadata["identifiers"].([]interface{})
+	if !ok {
+		return ""
+	}
+	for _, elem := range identifiers {
+		if m, ok := elem.(map[string]interface{}); ok {
+			if s, _ := m["scheme"].(string); s == scheme {
+				id, _ := m["identifier"].(string)
+				return strings.TrimSpace(id)
+			}
+		}
+	}
+	return ""
+}
+
+// recordDOI returns the record's DOI from .pids.doi or, failing that,
+// .metadata.identifiers.
+func recordDOI(rec map[string]interface{}) string {
+	if pids, ok := rec["pids"].(map[string]interface{}); ok {
+		if doi, ok := pids["doi"].(map[string]interface{}); ok {
+			if id, ok := doi["identifier"].(string); ok && id != "" {
+				return strings.TrimSpace(id)
+			}
+		}
+	}
+	return recordIdentifier(rec, "doi")
+}
+
+// findRecordsByIdentifierFromPg returns the latest versions of records
+// and the unpublished drafts with the identifier in .metadata.identifiers.
+func findRecordsByIdentifierFromPg(db *sql.DB, scheme string, identifier string) ([]*existingRecord, error) {
+	if db == nil {
+		return nil, fmt.Errorf("postgres connection not open")
+	}
+	jsonPath := `$."metadata"."identifiers"[*] ? (@."scheme" == $s && @."identifier" == $i)`
+	vars, err := JSONMarshal(map[string]string{"s": scheme, "i": identifier})
+	if err != nil {
+		return nil, err
+	}
+	stmt := `SELECT r.json->>'id', r.parent_id::text, false
+  FROM rdm_records_metadata AS r
+  JOIN rdm_versions_state AS v ON (r.id = v.latest_id)
+ WHERE r.json IS NOT NULL
+   AND COALESCE(r.deletion_status, 'P') = 'P'
+   AND jsonb_path_exists(r.json, $1::jsonpath, $2::jsonb)
+UNION
+SELECT d.json->>'id', d.parent_id::text, true
+  FROM rdm_drafts_metadata AS d
+ WHERE d.json IS NOT NULL
+   AND NOT EXISTS (SELECT 1 FROM rdm_records_metadata AS p WHERE p.id = d.id)
+   AND jsonb_path_exists(d.json, $1::jsonpath, $2::jsonb)`
+	rows, err := db.Query(stmt, jsonPath, string(vars))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	records := []*existingRecord{}
+	for rows.Next() {
+		rec := new(existingRecord)
+		if err := rows.Scan(&rec.ID, &rec.Parent, &rec.Draft); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	err = rows.Err()
+	return records, err
+}
+
+// existingFromHits returns the latest version records in search hits.
+func existingFromHits(hits []map[string]interface{}) []*existingRecord {
+	records := []*existingRecord{}
+	for _, hit := range hits {
+		if versions, ok := hit["versions"].(map[string]interface{}); ok {
+			if isLatest, ok := versions["is_latest"].(bool); ok && !isLatest {
+				continue
+			}
+		}
+		rec := new(existingRecord)
+		rec.ID, _ = hit["id"].(string)
+		if parent, ok := hit["parent"].(map[string]interface{}); ok {
+			rec.Parent, _ = parent["id"].(string)
+		}
+		if rec.Parent == "" {
+			rec.Parent = rec.ID
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+// findRecordsByIdentifier returns the records with the identifier in
+// .metadata.identifiers. Postgres is used when available so unpublished
+// drafts are found too.
+func findRecordsByIdentifier(cfg *Config, scheme string, identifier string) ([]*existingRecord, error) {
+	if cfg.pgDB != nil {
+		return findRecordsByIdentifierFromPg(cfg.pgDB, scheme, identifier)
+	}
+	hits, err := queryAllRecords(cfg, fmt.Sprintf("metadata.identifiers.identifier:%q", identifier))
+	if err != nil {
+		return nil, err
+	}
+	// NOTE: The search matches the identifier regardless of scheme.
+	matches := []map[string]interface{}{}
+	for _, hit := range hits {
+		if recordIdentifier(hit, scheme) == identifier {
+			matches = append(matches, hit)
+		}
+	}
+	return existingFromHits(matches), nil
+}
+
+// pickExistingRecord reduces the candidates to a single record. The
+// versions of a record share a parent so more than one parent means the
+// match is ambiguous. A published record is preferred over a draft.
+func pickExistingRecord(candidates []*existingRecord) (*existingRecord, error) {
+	var picked *existingRecord
+	parents := map[string]bool{}
+	for _, rec := range candidates {
+		if picked == nil || (picked.Draft && !rec.Draft) {
+			picked = rec
+		}
+		parents[rec.Parent] = true
+	}
+	if len(parents) > 1 {
+		ids := []string{}
+		for _, rec := range candidates {
+			ids = append(ids, rec.ID)
+		}
+		return nil, fmt.Errorf("ambiguous match, records %s", strings.Join(ids, ", "))
+	}
+	return picked, nil
+}
+
+// findExistingRecord looks for an existing RDM record matching rec. The
+// EPrints resolver id (or eprint id) in .metadata.identifiers is checked
+// first. If none match the DOI is checked, e.g. for a record created by
+// doi2rdm. Since publishers sometimes reuse a DOI for different works a
+// DOI match with different EPrints identifiers isn't the same record.
+// Returns nil if no record is found and an error if more than one is.
+//
+// ```
+// existing, err := findExistingRecord(cfg, rec)
+// if err != nil {
+//    // ... handle error ...
+// }
+// if existing == nil {
+//    // ... create a new record ...
+// }
+// ```
+func findExistingRecord(cfg *Config, rec map[string]interface{}) (*existingRecord, error) {
+	schemes := []string{}
+	for _, scheme := range putRecordSchemes {
+		if identifier := recordIdentifier(rec, scheme); identifier != "" {
+			candidates, err := findRecordsByIdentifier(cfg, scheme, identifier)
+			if err != nil {
+				return nil, err
+			}
+			if len(candidates) > 0 {
+				return pickExistingRecord(candidates)
+			}
+			schemes = append(schemes, scheme)
+		}
+	}
+	if doi := recordDOI(rec); doi != "" {
+		hits, err := CheckDOI(cfg, doi)
+		if err != nil {
+			return nil, err
+		}
+		matches := []map[string]interface{}{}
+		for _, hit := range hits {
+			conflict := false
+			for _, scheme := range schemes {
+				// NOTE: the identifier didn't match so any value differs
+				if recordIdentifier(hit, scheme) != "" {
+					conflict = true
+				}
+			}
+			if !conflict {
+				matches = append(matches, hit)
+			}
+		}
+		if candidates := existingFromHits(matches); len(candidates) > 0 {
+			return pickExistingRecord(candidates)
+		}
+	}
+	return nil, nil
+}
+
+// mergeIntoDraft merges the metadata, custom fields, access and pids of
+// rec into draft. Objects are merged (RFC 7396), other values replace
+// the ones in the draft.
+func mergeIntoDraft(draft map[string]interface{}, rec map[string]interface{}) (map[string]interface{}, error) {
+	patch := &Patch{Type: MergePatchType, Merge: map[string]interface{}{}}
+	for _, key := range []string{"metadata", "custom_fields", "access", "pids"} {
+		if val, ok := rec[key]; ok && val != nil {
+			patch.Merge[key] = val
+		}
+	}
+	return patch.Apply(draft)
+}
+
+// PutRecord creates or updates a record from JSON source. If an existing
+// record is found (see findExistingRecord) a draft is created, the new
+// metadata is merged into it and the draft is published. An unpublished
+// draft is updated but not published. Otherwise a new record is created
+// as with NewRecord. Re-running a migration with PutRecord does not
+// create duplicate records.
+//
+// ```
+// src, _ := os.ReadFile("record.json")
+// record, err := PutRecord(cfg, src, false)
+// if err != nil {
+//    // ... handle error ...
+// }
+// ```
+func PutRecord(cfg *Config, src []byte, debug bool) (map[string]interface{}, error) {
+	rec := map[string]interface{}{}
+	if err := JSONUnmarshal(src, &rec); err != nil {
+		return nil, err
+	}
+	existing, err := findExistingRecord(cfg, rec)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		dbgPrintf(cfg, "creating new record")
+		return NewRecord(cfg, src)
+	}
+	dbgPrintf(cfg, "updating existing record %s (draft %t)", existing.ID, existing.Draft)
+	var draft map[string]interface{}
+	if existing.Draft {
+		draft, err = GetDraft(cfg, existing.ID)
+	} else {
+		draft, err = NewDraft(cfg, existing.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	merged, err := mergeIntoDraft(draft, rec)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := JSONMarshalIndent(merged, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	draft, err = UpdateDraft(cfg, existing.ID, payload, debug)
+	if err != nil {
+		return nil, err
+	}
+	if existing.Draft {
+		return draft, nil
+	}
+	return PublishRecordVersion(cfg, existing.ID, "", "", debug)
+}
@@ -0,0 +1,160 @@
+package irdmtools
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecordIdentifiers(t *testing.T) {
+	rec := map[string]interface{}{}
+	if err := JSONUnmarshal([]byte(`{
+    "metadata": {
+        "identifiers": [
+            { "scheme": "eprintid", "identifier": "1234" },
+            { "scheme": "resolverid", "identifier": "Doe2023a" },
+            { "scheme": "doi", "identifier": "10.1000/182" }
+        ]
+    }
+}`), &rec); err != nil {
+		t.Fatal(err)
+	}
+	if got := recordIdentifier(rec, "resolverid"); got != "Doe2023a" {
+		t.Errorf("expected resolverid Doe2023a, got %q", got)
+	}
+	if got := recordIdentifier(rec, "pmid"); got != "" {
+		t.Errorf("expected no pmid, got %q", got)
+	}
+	if got := recordDOI(rec); got != "10.1000/182" {
+		t.Errorf("expected DOI from identifiers, got %q", got)
+	}
+	rec["pids"] = map[string]interface{}{
+		"doi": map[string]interface{}{"identifier": "10.1000/183", "provider": "external"},
+	}
+	if got := recordDOI(rec); got != "10.1000/183" {
+		t.Errorf("expected DOI from pids, got %q", got)
+	}
+}
+
+func TestPickExistingRecord(t *testing.T) {
+	hits := []map[string]interface{}{}
+	if err := JSONUnmarshal([]byte(`[
+    { "id": "aaaaa-00001", "parent": { "id": "ppppp-00001" }, "versions": { "is_latest": false } },
+    { "id": "aaaaa-00002", "parent": { "id": "ppppp-00001" }, "versions": { "is_latest": true } }
+]`), &hits); err != nil {
+		t.Fatal(err)
+	}
+	candidates := existingFromHits(hits)
+	if len(candidates) != 1 {
+		t.Fatalf("expected only the latest version, got %d", len(candidates))
+	}
+	rec, err := pickExistingRecord(candidates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.ID != "aaaaa-00002" {
+		t.Errorf("expected aaaaa-00002, got %q", rec.ID)
+	}
+
+	// A published record is preferred to a draft of a new version
+	rec, err = pickExistingRecord([]*existingRecord{
+		{ID: "bbbbb-00002", Parent: "ppppp-00002", Draft: true},
+		{ID: "bbbbb-00001", Parent: "ppppp-00002"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.ID != "bbbbb-00001" || rec.Draft {
+		t.Errorf("expected published bbbbb-00001, got %+v", rec)
+	}
+
+	// Different works, e.g. sharing a DOI, are ambiguous
+	if _, err := pickExistingRecord([]*existingRecord{
+		{ID: "ccccc-00001", Parent: "ppppp-00003"},
+		{ID: "ddddd-00001", Parent: "ppppp-00004"},
+	}); err == nil {
+		t.Errorf("expected ambiguous match error")
+	}
+}
+
+func TestMergeIntoDraft(t *testing.T) {
+	draft, rec := map[string]interface{}{}, map[string]interface{}{}
+	if err := JSONUnmarshal([]byte(`{
+    "id": "aaaaa-00001",
+    "files": { "enabled": true },
+    "metadata": { "title": "Old title", "publisher": "CaltechAUTHORS" }
+}`), &draft); err != nil {
+		t.Fatal(err)
+	}
+	if err := JSONUnmarshal([]byte(`{
+    "files": { "enabled": false },
+    "metadata": { "title": "New title" }
+}`), &rec); err != nil {
+		t.Fatal(err)
+	}
+	merged, err := mergeIntoDraft(draft, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metadata := merged["metadata"].(map[string]interface{})
+	if metadata["title"] != "New title" || metadata["publisher"] != "CaltechAUTHORS" {
+		t.Errorf("expected merged metadata, got %+v", metadata)
+	}
+	if merged["id"] != "aaaaa-00001" {
+		t.Errorf("expected draft id to be kept, got %+v", merged["id"])
+	}
+	if files := merged["files"].(map[string]interface{}); files["enabled"] != true {
+		t.Errorf("expected draft files to be kept, got %+v", files)
+	}
+}
+
+func TestFindExistingRecordByDOI(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query().Get("q")
+		switch {
+		case strings.Contains(q, "10.1000/doi2rdm"):
+			// Created by doi2rdm, no EPrints identifiers
+			w.Write([]byte(`{"hits": {"total": 1, "hits": [
+    {"id": "aaaaa-00001", "parent": {"id": "aaaaa-00000"}, "versions": {"is_latest": true},
+     "pids": {"doi": {"identifier": "10.1000/doi2rdm"}}}
+]}}`))
+		case strings.Contains(q, "10.1000/reused"):
+			// A different EPrint with the same DOI
+			w.Write([]byte(`{"hits": {"total": 1, "hits": [
+    {"id": "bbbbb-00001", "parent": {"id": "bbbbb-00000"}, "versions": {"is_latest": true},
+     "metadata": {"identifiers": [{"scheme": "eprintid", "identifier": "999"}]}}
+]}}`))
+		default:
+			w.Write([]byte(`{"hits": {"total": 0, "hits": []}}`))
+		}
+	}))
+	defer ts.Close()
+	cfg := &Config{InvenioAPI: ts.URL, InvenioToken: "token", rl: new(RateLimit)}
+	testCases := map[string]string{
+		"10.1000/doi2rdm": "aaaaa-00001",
+		"10.1000/reused":  "",
+		"10.1000/new":     "",
+	}
+	for doi, expected := range testCases {
+		rec := map[string]interface{}{}
+		if err := JSONUnmarshal([]byte(`{
+    "metadata": {"identifiers": [{"scheme": "eprintid", "identifier": "1234"}]},
+    "pids": {"doi": {"identifier": "`+doi+`", "provider": "external"}}
+}`), &rec); err != nil {
+			t.Fatal(err)
+		}
+		existing, err := findExistingRecord(cfg, rec)
+		if err != nil {
+			t.Errorf("%s, %s", doi, err)
+			continue
+		}
+		got := ""
+		if existing != nil {
+			got = existing.ID
+		}
+		if got != expected {
+			t.Errorf("%s expected %q, got %q", doi, expected, got)
+		}
+	}
+}
@@ -0,0 +1,385 @@
+package irdmtools
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Query filters select records by the values of their JSON fields.
+//
+// A filter is one or more comparisons joined with "and", "or", "not"
+// and parentheses, e.g.
+//
+//	metadata.resource_type.id = publication-article and
+//	  metadata.publication_date >= "2020" and metadata.publication_date < "2021"
+//
+// A field is a dotted path into the record JSON, custom field names keep
+// their colon, e.g. `custom_fields.journal:journal.issn`. Arrays along the
+// path are searched, so `metadata.identifiers.scheme = pmid` matches a
+// record with any PMID identifier. The operators are =, !=, <, <=, >, >=,
+// ~ (case insensitive regular expression) and "exists" which takes no
+// value. Values are strings, quoted if they contain spaces or operator
+// characters, or true, false and null. A != comparison matches records
+// where no value of the field equals the value.
+//
+// Each comparison is compiled to a Postgres SQL/JSON path test against
+// the json column of rdm_records_metadata.
+
+// filterNode is a node in a parsed query filter.
+type filterNode struct {
+	// Op is "and", "or", "not", "exists" or a comparison operator
+	Op       string
+	Path     []string
+	Value    interface{}
+	Children []*filterNode
+}
+
+// filterToken is a lexical token of a query filter.
+type filterToken struct {
+	// Kind is "word", "string", "op", "(" or ")"
+	Kind string
+	Text string
+	Pos  int
+}
+
+// isFilterOpChar returns true for the characters forming operators.
+func isFilterOpChar(r rune) bool {
+	return strings.ContainsRune("=!<>~", r)
+}
+
+// tokenizeFilter splits a query filter into tokens.
+func tokenizeFilter(expr string) ([]*filterToken, error) {
+	tokens := []*filterToken{}
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			tokens = append(tokens, &filterToken{Kind: string(r), Text: string(r), Pos: i})
+			i++
+		case r == '"' || r == '\'':
+			quote, start := r, i
+			sb := strings.Builder{}
+			i++
+			for ; i < len(runes) && runes[i] != quote; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				sb.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated string at position %d", start)
+			}
+			i++
+			tokens = append(tokens, &filterToken{Kind: "string", Text: sb.String(), Pos: start})
+		case isFilterOpChar(r):
+			start := i
+			for i < len(runes) && isFilterOpChar(runes[i]) {
+				i++
+			}
+			op := string(runes[start:i])
+			switch op {
+			case "=", "==", "!=", "<>", "<", "<=", ">", ">=", "~":
+			default:
+				return nil, fmt.Errorf("unknown operator %q at position %d", op, start)
+			}
+			tokens = append(tokens, &filterToken{Kind: "op", Text: op, Pos: start})
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !isFilterOpChar(runes[i]) &&
+				runes[i] != '(' && runes[i] != ')' && runes[i] != '"' && runes[i] != '\'' {
+				i++
+			}
+			tokens = append(tokens, &filterToken{Kind: "word", Text: string(runes[start:i]), Pos: start})
+		}
+	}
+	return tokens, nil
+}
+
+// filterParser is a recursive descent parser for query filters.
+type filterParser struct {
+	tokens []*filterToken
+	pos    int
+}
+
+func (p *filterParser) peek() *filterToken {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return nil
+}
+
+func (p *filterParser) next() *filterToken {
+	tok := p.peek()
+	if tok != nil {
+		p.pos++
+	}
+	return tok
+}
+
+// isKeyword checks if tok is the (case insensitive) keyword kw.
+func isKeyword(tok *filterToken, kw string) bool {
+	return tok != nil && tok.Kind == "word" && strings.EqualFold(tok.Text, kw)
+}
+
+// parseOr handles `term ("or" term)*`
+func (p *filterParser) parseOr() (*filterNode, error) {
+	node, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for isKeyword(p.peek(), "or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		node = &filterNode{Op: "or", Children: []*filterNode{node, right}}
+	}
+	return node, nil
+}
+
+// parseAnd handles `factor ("and" factor)*`
+func (p *filterParser) parseAnd() (*filterNode, error) {
+	node, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+	for isKeyword(p.peek(), "and") {
+		p.next()
+		right, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		node = &filterNode{Op: "and", Children: []*filterNode{node, right}}
+	}
+	return node, nil
+}
+
+// parseFactor handles negation, parenthesis and comparisons.
+func (p *filterParser) parseFactor() (*filterNode, error) {
+	tok := p.next()
+	if tok == nil {
+		return nil, fmt.Errorf("unexpected end of filter")
+	}
+	if isKeyword(tok, "not") {
+		child, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		return &filterNode{Op: "not", Children: []*filterNode{child}}, nil
+	}
+	if tok.Kind == "(" {
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing == nil || closing.Kind != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis for position %d", tok.Pos)
+		}
+		return node, nil
+	}
+	if tok.Kind != "word" {
+		return nil, fmt.Errorf("expected a field at position %d, found %q", tok.Pos, tok.Text)
+	}
+	path, err := parseFilterPath(tok.Text)
+	if err != nil {
+		return nil, fmt.Errorf("%s at position %d", err, tok.Pos)
+	}
+	opTok := p.next()
+	if isKeyword(opTok, "exists") {
+		return &filterNode{Op: "exists", Path: path}, nil
+	}
+	if opTok == nil || opTok.Kind != "op" {
+		return nil, fmt.Errorf("expected an operator after %q", tok.Text)
+	}
+	valTok := p.next()
+	if valTok == nil || (valTok.Kind != "word" && valTok.Kind != "string") {
+		return nil, fmt.Errorf("expected a value after %q %s", tok.Text, opTok.Text)
+	}
+	var val interface{} = valTok.Text
+	if valTok.Kind == "word" {
+		switch valTok.Text {
+		case "true":
+			val = true
+		case "false":
+			val = false
+		case "null":
+			val = nil
+		}
+	}
+	op := opTok.Text
+	switch op {
+	case "==":
+		op = "="
+	case "<>":
+		op = "!="
+	}
+	if op == "~" {
+		if _, ok := val.(string); !ok {
+			return nil, fmt.Errorf("%s ~ requires a regular expression string", tok.Text)
+		}
+	}
+	return &filterNode{Op: op, Path: path, Value: val}, nil
+}
+
+// parseFilterPath splits a dotted field path into its keys.
+func parseFilterPath(s string) ([]string, error) {
+	path := strings.Split(s, ".")
+	for _, key := range path {
+		if key == "" {
+			return nil, fmt.Errorf("invalid field %q", s)
+		}
+	}
+	return path, nil
+}
+
+// parseQueryFilter parses a query filter expression.
+func parseQueryFilter(expr string) (*filterNode, error) {
+	tokens, err := tokenizeFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty filter")
+	}
+	p := &filterParser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok != nil {
+		return nil, fmt.Errorf("unexpected %q at position %d", tok.Text, tok.Pos)
+	}
+	return node, nil
+}
+
+// jsonPathString quotes s as an SQL/JSON path string literal.
+func jsonPathString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// jsonPathOf returns the SQL/JSON path for a field.
+func jsonPathOf(path []string) string {
+	parts := []string{"$"}
+	for _, key := range path {
+		parts = append(parts, jsonPathString(key))
+	}
+	return strings.Join(parts, ".")
+}
+
+// compile renders the node as an SQL boolean expression appending the
+// query parameters to params.
+func (node *filterNode) compile(params []interface{}) (string, []interface{}, error) {
+	switch node.Op {
+	case "and", "or":
+		clauses := []string{}
+		for _, child := range node.Children {
+			clause, p, err := child.compile(params)
+			if err != nil {
+				return "", nil, err
+			}
+			params = p
+			clauses = append(clauses, clause)
+		}
+		return "(" + strings.Join(clauses, " "+strings.ToUpper(node.Op)+" ") + ")", params, nil
+	case "not":
+		clause, p, err := node.Children[0].compile(params)
+		if err != nil {
+			return "", nil, err
+		}
+		return "(NOT " + clause + ")", p, nil
+	case "exists":
+		params = append(params, jsonPathOf(node.Path))
+		return fmt.Sprintf("jsonb_path_exists(json, $%d::jsonpath)", len(params)), params, nil
+	case "~":
+		pattern := jsonPathOf(node.Path) + ` ? (@ like_regex ` + jsonPathString(node.Value.(string)) + ` flag "i")`
+		params = append(params, pattern)
+		return fmt.Sprintf("jsonb_path_exists(json, $%d::jsonpath)", len(params)), params, nil
+	case "=", "!=", "<", "<=", ">", ">=":
+		op := node.Op
+		if op == "=" || op == "!=" {
+			op = "=="
+		}
+		vars, err := JSONMarshal(map[string]interface{}{"v": node.Value})
+		if err != nil {
+			return "", nil, err
+		}
+		params = append(params, fmt.Sprintf("%s ? (@ %s $v)", jsonPathOf(node.Path), op), string(vars))
+		clause := fmt.Sprintf("jsonb_path_exists(json, $%d::jsonpath, $%d::jsonb)", len(params)-1, len(params))
+		if node.Op == "!=" {
+			clause = "(NOT " + clause + ")"
+		}
+		return clause, params, nil
+	}
+	return "", nil, fmt.Errorf("unsupported filter operation %q", node.Op)
+}
+
+// compileQueryFilter compiles a query filter into the SQL query for
+// matching record ids and its parameters.
+func compileQueryFilter(expr string) (string, []interface{}, error) {
+	node, err := parseQueryFilter(expr)
+	if err != nil {
+		return "", nil, err
+	}
+	clause, params, err := node.compile([]interface{}{})
+	if err != nil {
+		return "", nil, err
+	}
+	stmt := `SELECT json->>'id' AS rdmid
+ FROM rdm_records_metadata
+ JOIN rdm_versions_state
+   ON (rdm_records_metadata.id = rdm_versions_state.latest_id)
+WHERE json IS NOT NULL
+  AND COALESCE(deletion_status, 'P') = 'P'
+  AND ` + clause + `
+ORDER BY json->>'id'`
+	return stmt, params, nil
+}
+
+// queryRecordIdsFromPg returns the ids of the latest version of records
+// matching the query filter.
+func queryRecordIdsFromPg(db *sql.DB, filter string) ([]string, error) {
+	if db == nil {
+		return nil, fmt.Errorf("postgres connection not open")
+	}
+	stmt, params, err := compileQueryFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(stmt, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := []string{}
+	for rows.Next() {
+		var rdmid string
+		if err := rows.Scan(&rdmid); err != nil {
+			return nil, err
+		}
+		keys = append(keys, rdmid)
+	}
+	err = rows.Err()
+	return keys, err
+}
+
+// QueryRecordIds takes a configuration object and a query filter and
+// returns the ids of the latest version of the records matching the
+// filter. It requires Postgres access. Records that have been deleted
+// are not included, restricted records are unless the filter excludes
+// them, e.g. `access.record = public`.
+//
+// ```
+// ids, err := QueryRecordIds(cfg, `custom_fields.journal:journal.issn = "0035-8711"`)
+// if err != nil {
+//    // ... handle error ...
+// }
+// ```
+func QueryRecordIds(cfg *Config, filter string) ([]string, error) {
+	return queryRecordIdsFromPg(cfg.pgDB, filter)
+}
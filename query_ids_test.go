@@ -0,0 +1,80 @@
+package irdmtools
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompileQueryFilter(t *testing.T) {
+	testData := []struct {
+		filter string
+		clause string
+		params []interface{}
+	}{
+		{
+			filter: `metadata.resource_type.id = publication-article`,
+			clause: `jsonb_path_exists(json, $1::jsonpath, $2::jsonb)`,
+			params: []interface{}{
+				`$."metadata"."resource_type"."id" ? (@ == $v)`,
+				`{"v":"publication-article"}`,
+			},
+		},
+		{
+			filter: `custom_fields.journal:journal.issn = "0035-8711"`,
+			clause: `jsonb_path_exists(json, $1::jsonpath, $2::jsonb)`,
+			params: []interface{}{
+				`$."custom_fields"."journal:journal"."issn" ? (@ == $v)`,
+				`{"v":"0035-8711"}`,
+			},
+		},
+		{
+			filter: `metadata.publication_date >= 2020 AND metadata.publication_date<"2021"`,
+			clause: `(jsonb_path_exists(json, $1::jsonpath, $2::jsonb) AND jsonb_path_exists(json, $3::jsonpath, $4::jsonb))`,
+			params: []interface{}{
+				`$."metadata"."publication_date" ? (@ >= $v)`,
+				`{"v":"2020"}`,
+				`$."metadata"."publication_date" ? (@ < $v)`,
+				`{"v":"2021"}`,
+			},
+		},
+		{
+			filter: `not (access.record != public or metadata.title ~ "black hole") and pids.doi exists`,
+			clause: `((NOT ((NOT jsonb_path_exists(json, $1::jsonpath, $2::jsonb)) OR jsonb_path_exists(json, $3::jsonpath))) AND jsonb_path_exists(json, $4::jsonpath))`,
+			params: []interface{}{
+				`$."access"."record" ? (@ == $v)`,
+				`{"v":"public"}`,
+				`$."metadata"."title" ? (@ like_regex "black hole" flag "i")`,
+				`$."pids"."doi"`,
+			},
+		},
+	}
+	for _, td := range testData {
+		stmt, params, err := compileQueryFilter(td.filter)
+		if err != nil {
+			t.Errorf("%s: %s", td.filter, err)
+			continue
+		}
+		if !strings.Contains(stmt, "AND "+td.clause+"\n") {
+			t.Errorf("%s: expected clause %s, got\n%s", td.filter, td.clause, stmt)
+		}
+		if !reflect.DeepEqual(td.params, params) {
+			t.Errorf("%s: expected params %+v, got %+v", td.filter, td.params, params)
+		}
+	}
+	for _, filter := range []string{
+		``,
+		`metadata.title`,
+		`metadata.title =`,
+		`metadata..title = x`,
+		`(metadata.title = x`,
+		`metadata.title = x y`,
+		`metadata.title => x`,
+		`metadata.title = "unterminated`,
+		`metadata.title ~ true`,
+	} {
+		if _, _, err := compileQueryFilter(filter); err == nil {
+			t.Errorf("expected an error for %q", filter)
+		}
+	}
+}
@@ -20,6 +20,10 @@ type RateLimit struct {
 	Remaining int `json:"remaining,omitempty"`
 	// Reset maps to X-RateLimit-Reset
 	Reset int `json:"reset,omitempty"`
+	// Interval is the number of seconds Limit applies to, it maps to
+	// CrossRef's X-Rate-Limit-Interval. APIs like CrossRef don't report
+	// the remaining requests so Throttle spaces requests evenly.
+	Interval int `json:"interval,omitempty"`
 }
 
 // FromResponse takes an http.Response struct and extracts
@@ -96,6 +100,23 @@ func (rl *RateLimit) FromHeader(header http.Header) {
 	}
 }
 
+// FromLimitInterval sets the rate limit from a request limit per interval
+// in seconds, e.g. the CrossRef and DataCite client's RateLimitLimit and
+// RateLimitInterval. Values less than one are ignored.
+//
+// ```
+// rl := new(RateLimit)
+// rl.FromLimitInterval(client.RateLimitLimit, client.RateLimitInterval)
+// ```
+func (rl *RateLimit) FromLimitInterval(limit int, interval int) {
+	if limit > 0 {
+		rl.Limit = limit
+	}
+	if interval > 0 {
+		rl.Interval = interval
+	}
+}
+
 func (rl *RateLimit) ResetString() string {
 	var s string
 	if rl.Reset > 0 {
@@ -145,6 +166,11 @@ func (rl *RateLimit) TimeToReset() (time.Duration, time.Time) {
 // ```
 func (rl *RateLimit) Throttle(i int, tot int) {
 	var speedBump time.Duration
+	if rl.Interval > 0 && rl.Limit > 0 {
+		// NOTE: Limit requests per Interval seconds, e.g. CrossRef's polite pool
+		time.Sleep(time.Duration(int64(rl.Interval) * int64(time.Second) / int64(rl.Limit)))
+		return
+	}
 	// NOTE: 5000 per hour rate from some RDM API
 	// 500 per minutes for others. We need to throttle accordingly
 	// An hout == 3600 seconds, a minute is 60 seconds.
@@ -37,7 +37,7 @@ func RdmToCitation(prefix string, record *simplified.Record, repoHost string) (*
 
 // MigrateRdmDatasetToCitationsDataset takes a dataset of RDM objects and migrates the ones in the
 // id list to a citation dataset collection.
-func MigrateRdmDatasetToCitationDataset(rdmCName string, ids []string, repoHost string, prefix string, citeCName string) error {
+func MigrateRdmDatasetToCitationDataset(rdmCName string, ids []string, repoHost string, prefix string, citeCName string, cslOut io.Writer, formatter *CitationFormatter) error {
 	rdm, err := dataset.Open(rdmCName)
 	if err != nil {
 		return err
@@ -48,6 +48,7 @@ func MigrateRdmDatasetToCitationDataset(rdmCName string, ids []string, repoHost
 		return err
 	}
 	defer cite.Close()
+	cslCitations := []*Citation{}
 	tot := len(ids)
 	start := time.Now()
 	iTime := time.Now()
@@ -74,6 +75,9 @@ func MigrateRdmDatasetToCitationDataset(rdmCName string, ids []string, repoHost
 			log.Printf("failed to convert (%d) id %s from %s to citation, %s", i, id, repoName, err)
 			continue
 		}
+		if err := formatter.Apply(citation); err != nil {
+			log.Printf("failed to format citation for %s (%d), %s", id, i, err)
+		}
 		if cite.HasKey(key) {
 			err = cite.UpdateObject(key, citation)
 		} else {
@@ -81,6 +85,8 @@ func MigrateRdmDatasetToCitationDataset(rdmCName string, ids []string, repoHost
 		}
 		if err != nil {
 			log.Printf("failed to save citation for %s (%d), %s", id, i, err)
+		} else if cslOut != nil {
+			cslCitations = append(cslCitations, citation)
 		}
 		i++
 		if iTime, reportProgress = CheckWaitInterval(iTime, time.Minute); reportProgress || (i % 10000) == 0 {
@@ -88,12 +94,15 @@ func MigrateRdmDatasetToCitationDataset(rdmCName string, ids []string, repoHost
 		}
 	}
 	log.Printf("%d/%d citations processed %s: completed", i, tot, time.Since(start).Truncate(time.Second).String())
+	if cslOut != nil {
+		return WriteCSLJSON(cslOut, cslCitations, start)
+	}
 	return nil
 }
 
 // RunRdmDSToCitationDS migrates contents from an RDM dataset collection to a citation dataset collection for
 // a give list of ids and repostiory hostname.
-func RunRdmDSToCitationDS(in io.Reader, out io.Writer, eout io.Writer, args []string, repoHost string, prefix string, ids []string) int {
+func RunRdmDSToCitationDS(in io.Reader, out io.Writer, eout io.Writer, args []string, repoHost string, prefix string, ids []string, cslFName string, styleFNames string, styleFormat string) int {
 	var (
 		rdmCName string
 		citeCName string
@@ -128,7 +137,30 @@ func RunRdmDSToCitationDS(in io.Reader, out io.Writer, eout io.Writer, args []st
 			}
 		}
 	}
-	if err := MigrateRdmDatasetToCitationDataset(rdmCName, keys, repoHost, prefix, citeCName); err != nil  {
+	var cslOut io.Writer
+	switch cslFName {
+	case "":
+	case "-":
+		cslOut = out
+	default:
+		fp, err := os.Create(cslFName)
+		if err != nil {
+			fmt.Fprintf(eout, "%s\n", err)
+			return 1
+		}
+		defer fp.Close()
+		cslOut = fp
+	}
+	var formatter *CitationFormatter
+	if styleFNames != "" {
+		var err error
+		formatter, err = NewCitationFormatter(styleFNames, styleFormat)
+		if err != nil {
+			fmt.Fprintf(eout, "%s\n", err)
+			return 1
+		}
+	}
+	if err := MigrateRdmDatasetToCitationDataset(rdmCName, keys, repoHost, prefix, citeCName, cslOut, formatter); err != nil  {
 		fmt.Fprintf(eout,  "%s\n", err)
 		return 1
 	}
@@ -729,6 +729,18 @@ func (app *Rdm2EPrint) RunHarvest(in io.Reader, out io.Writer, eout io.Writer, c
 		app.Cfg.pgDB = db
 	}
 
+	// Record our progress so an interrupted run can be resumed.
+	cp, err := OpenCheckpoint(cName, app.Cfg.Resume)
+	if err != nil {
+		return err
+	}
+	defer cp.Close()
+	if app.Cfg.Resume {
+		pending := cp.Pending(rdmids)
+		log.Printf("resuming from %s, %d of %d ids finished, %d pending", cp.Name, len(rdmids)-len(pending), len(rdmids), len(pending))
+		rdmids = pending
+	}
+
 	eCnt, cCnt, tot := 0, 0, len(rdmids)
 	t0 := time.Now()
 	rptTime := time.Now()
@@ -738,33 +750,37 @@ func (app *Rdm2EPrint) RunHarvest(in io.Reader, out io.Writer, eout io.Writer, c
 		rec, err := GetRecord(app.Cfg, rdmid, false)
 		if err != nil {
 			log.Printf("Aborting, failed to get record (%d) %s, %s", i, rdmid, err)
+			cp.Failed(rdmid, err)
 			return err
 		}
 		if latestVersions {
-			if rec.Versions == nil {
-				continue
-			} 
-			if ! rec.Versions.IsLatest {
+			if rec.Versions == nil || ! rec.Versions.IsLatest {
+				cp.Finished(rdmid)
 				continue
 			}
 		}
 		eprint := new(eprinttools.EPrint)
 		if err := CrosswalkRdmToEPrint(app.Cfg, rec, eprint); err != nil {
 			log.Printf("Aborting, failed to crosswalk record (%d) %s, %s", i, rdmid, err)
+			cp.Failed(rdmid, err)
 			return err
 		}
 		if ds.HasKey(rec.ID) {
 			if err := ds.UpdateObject(rec.ID, eprint); err != nil {
 				log.Printf("error (update): %q, %s", rec.ID, err)
+				cp.Failed(rdmid, err)
 				eCnt++
 			} else {
+				cp.Finished(rdmid)
 				cCnt++
 			}
 		} else {
 			if err := ds.CreateObject(rec.ID, eprint); err != nil {
 				log.Printf("error (create): %q, %s", rec.ID, err)
+				cp.Failed(rdmid, err)
 				eCnt++
 			} else {
+				cp.Finished(rdmid)
 				cCnt++
 			}
 		}
@@ -37,9 +37,15 @@ package irdmtools
 import (
 	"bytes"
 	"database/sql"
+	"flag"
 	"fmt"
 	"io"
 	"os"
+	"strings"
+	"time"
+
+	// Caltech Library Packages
+	"github.com/caltechlibrary/simplified"
 )
 
 // RdmUtil holds the configuration for rdmutil cli.
@@ -206,6 +212,107 @@ func (app *RdmUtil) GetRecordIds() ([]byte, error) {
 	return src, nil
 }
 
+// QueryIds returns a byte slice for a JSON encoded list of the latest
+// version record ids matching a query filter, see QueryRecordIds for
+// the filter syntax.
+//
+// ```
+//
+//	app := new(irdmtools.RdmUtil)
+//	if err := app.LoadConfig("irdmtools.json"); err != nil {
+//	   // ... handle error ...
+//	}
+//	src, err := app.QueryIds(`metadata.resource_type.id = publication-article`)
+//	if err != nil {
+//	    // ... handle error ...
+//	}
+//	fmt.Printf("%s\n", src)
+//
+// ```
+func (app *RdmUtil) QueryIds(filter string) ([]byte, error) {
+	ids, err := QueryRecordIds(app.Cfg, filter)
+	if err != nil {
+		return nil, err
+	}
+	src, err := JSONMarshalIndent(ids, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return src, nil
+}
+
+// SetupReindexQueue creates the table queuing drafts edited directly in
+// Postgres for reindexing. It is run once before editing drafts in
+// Postgres.
+//
+// ```
+//
+//	app := new(irdmtools.RdmUtil)
+//	if err := app.LoadConfig("irdmtools.json"); err != nil {
+//	   // ... handle error ...
+//	}
+//	if err := app.SetupReindexQueue(); err != nil {
+//	    // ... handle error ...
+//	}
+//
+// ```
+func (app *RdmUtil) SetupReindexQueue() error {
+	return SetupReindexQueue(app.Cfg)
+}
+
+// GetReindexIds returns a byte slice for a JSON encoded list of record
+// ids edited directly in Postgres that need to be reindexed by RDM. If
+// clear is true the ids are removed from the reindex queue.
+//
+// ```
+//
+//	app := new(irdmtools.RdmUtil)
+//	if err := app.LoadConfig("irdmtools.json"); err != nil {
+//	   // ... handle error ...
+//	}
+//	src, err := app.GetReindexIds(false)
+//	if err != nil {
+//	    // ... handle error ...
+//	}
+//	fmt.Printf("%s\n", src)
+//
+// ```
+func (app *RdmUtil) GetReindexIds(clear bool) ([]byte, error) {
+	ids, err := GetReindexIds(app.Cfg, clear)
+	if err != nil {
+		return nil, err
+	}
+	return JSONMarshalIndent(ids, "", "    ")
+}
+
+// ReindexDrafts puts the drafts queued by direct Postgres edits back
+// through the RDM API so they are validated and reindexed. Returns a byte
+// slice for a JSON encoded list of the record ids reindexed.
+//
+// ```
+//
+//	app := new(irdmtools.RdmUtil)
+//	if err := app.LoadConfig("irdmtools.json"); err != nil {
+//	   // ... handle error ...
+//	}
+//	src, err := app.ReindexDrafts()
+//	if err != nil {
+//	    // ... handle error ...
+//	}
+//	fmt.Printf("%s\n", src)
+//
+// ```
+func (app *RdmUtil) ReindexDrafts() ([]byte, error) {
+	ids, err := ReindexDrafts(app.Cfg, app.Debug)
+	if ids == nil {
+		return nil, err
+	}
+	src, jErr := JSONMarshalIndent(ids, "", "    ")
+	if jErr != nil {
+		return nil, jErr
+	}
+	return src, err
+}
 
 // GetRecordStaleIds returns a byte slice for a JSON encode list
 // of record ids or an error. The record ids are for the stale
@@ -508,6 +615,70 @@ func (app *RdmUtil) NewRecord(src []byte) ([]byte, error) {
 	return JSONMarshalIndent(data, "", "    ")
 }
 
+// PutRecord creates or updates a record from JSON source. If a record
+// with the same EPrints resolver id, eprint id or DOI exists it is
+// updated and published, otherwise a new record is created. It returns
+// the record.
+//
+// ```
+//
+// app := new(irdmtools.RdmUtil)
+// if err := app.LoadConfig("irdmtools.json"); err != nil {
+//   // ... handle error ...
+// }
+// jsonSrc, _ := os.ReadFile("record.json")
+// src, err := app.PutRecord(jsonSrc)
+// if err != nil {
+//   // ... handle error ...
+// }
+// fmt.Printf("%s\n", src)
+//
+// ```
+func (app *RdmUtil) PutRecord(src []byte) ([]byte, error) {
+	data, err := PutRecord(app.Cfg, src, app.Cfg.Debug)
+	if err != nil {
+		return nil, err
+	}
+	return JSONMarshalIndent(data, "", "    ")
+}
+
+// ValidateRecord checks the vocabulary ids in a record against the RDM
+// instance's vocabularies. The vocabularies are read from vocabularyFName
+// if it isn't empty, otherwise from Postgres if configured. It returns
+// the violations as a JSON array and an error if there were any.
+//
+// ```
+// app := new(irdmtools.RdmUtil)
+// if err := app.LoadConfig("irdmtools.json"); err != nil {
+//   // ... handle error ...
+// }
+// jsonSrc, _ := os.ReadFile("record.json")
+// src, err := app.ValidateRecord(jsonSrc, "vocabularies.yaml")
+// fmt.Printf("%s\n", src)
+// if err != nil {
+//   // ... handle error ...
+// }
+// ```
+func (app *RdmUtil) ValidateRecord(src []byte, vocabularyFName string) ([]byte, error) {
+	rec := new(simplified.Record)
+	if err := JSONUnmarshal(src, &rec); err != nil {
+		return nil, err
+	}
+	vocabularies, err := LoadVocabularies(app.Cfg, vocabularyFName)
+	if err != nil {
+		return nil, err
+	}
+	violations := ValidateRecord(rec, vocabularies)
+	src, err = JSONMarshalIndent(violations, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	if len(violations) > 0 {
+		return src, fmt.Errorf("%d vocabulary violation(s)", len(violations))
+	}
+	return src, nil
+}
+
 // NewRecordVersion create a new record version using record id.
 // It returns a created record including a record id.
 //
@@ -609,6 +780,16 @@ func (app *RdmUtil) GetDraft(id string) ([]byte, error) {
 	return src, nil
 }
 
+// GetDraftFromPg takes a record id and returns the draft as stored in
+// Postgres, see GetDraftFromPg.
+func (app *RdmUtil) GetDraftFromPg(id string) ([]byte, error) {
+	obj, err := GetDraftFromPg(app.Cfg, id)
+	if err != nil {
+		return nil, err
+	}
+	return JSONMarshalIndent(obj, "", "    ")
+}
+
 // UpdateDraft returns takes a record id and returns a draft record.
 //
 // ```
@@ -920,11 +1101,72 @@ func (app *RdmUtil) DeleteEndpoint(p string) ([]byte, error) {
 // Harvest takes a JSON file contianing a list of record ids and
 // harvests them into a dataset v2 collection. The dataset collection
 // must exist and be configured in either the environment or
-// configuration file.
+// configuration file. The number of concurrent workers is taken from
+// app.Cfg.Workers.
 func (app *RdmUtil) Harvest(fName string) error {
 	return Harvest(app.Cfg, fName, app.Cfg.Debug)
 }
 
+// Sync updates the dataset collection with the records modified since the
+// collection's last sync. If start is not an empty string it is used
+// instead of the stored high-water mark. Deleted, tombstoned and restricted
+// records are removed from the collection unless markDeleted is true, then
+// they are kept with a tombstone.
+func (app *RdmUtil) Sync(start string, markDeleted bool) error {
+	return Sync(app.Cfg, start, markDeleted, app.Cfg.Debug)
+}
+
+// PatchRecords reads a JSON file containing a list of record ids and a
+// patch file holding a JSON Patch (RFC 6902) or JSON Merge Patch
+// (RFC 7396). Each record gets a new draft with the patch applied, if
+// publish is true the draft is then published. If dryRun is true the
+// records are not changed, the diff of each record is written to out.
+func (app *RdmUtil) PatchRecords(idsName string, patchName string, publish bool, dryRun bool, out io.Writer) error {
+	src, err := os.ReadFile(idsName)
+	if err != nil {
+		return err
+	}
+	recordIds := []string{}
+	if err := JSONUnmarshal(src, &recordIds); err != nil {
+		return err
+	}
+	src, err = os.ReadFile(patchName)
+	if err != nil {
+		return err
+	}
+	patch, err := ParsePatch(src)
+	if err != nil {
+		return err
+	}
+	return PatchRecords(app.Cfg, recordIds, patch, publish, dryRun, out, app.Cfg.Debug)
+}
+
+// LinkVersions checks preprint records with CrossRef for the versions
+// they were published as and links the preprint and published records
+// with related identifiers. If idsName is not an empty string it is a
+// JSON file listing the preprint record ids, otherwise the preprint
+// records are found in Postgres. Unless apply is true the proposed links
+// are written to out. If publish is true the updated drafts are
+// published.
+func (app *RdmUtil) LinkVersions(idsName string, apply bool, publish bool, out io.Writer) error {
+	recordIds := []string{}
+	if idsName != "" {
+		src, err := os.ReadFile(idsName)
+		if err != nil {
+			return err
+		}
+		if err := JSONUnmarshal(src, &recordIds); err != nil {
+			return err
+		}
+		if len(recordIds) == 0 {
+			return fmt.Errorf("no record ids found in %s", idsName)
+		}
+	} else if app.Cfg.pgDB == nil {
+		return fmt.Errorf("a JSON identifier file is required without Postgres access")
+	}
+	return LinkVersions(app.Cfg, recordIds, apply, publish, out, app.Cfg.Debug)
+}
+
 // getRecordParams parse the command parameters for record id oriented
 // actions.
 func getRecordParams(params []string, requireRecordId bool, requireInName bool, requireOutName bool) (string, string, string, error) {
@@ -1153,6 +1395,17 @@ func (app *RdmUtil) Run(in io.Reader, out io.Writer, eout io.Writer, action stri
 		version string
 		pubDate string
 	)
+	// Draft metadata edits go directly to Postgres when it is configured.
+	switch action {
+	case "new_draft", "update_draft", "set_files_enable", "put_record",
+		"set_version", "set_publication_date", "set_access":
+		if usePostgresDB(app.Cfg) {
+			if err := app.OpenDB(); err != nil {
+				return err
+			}
+			defer app.CloseDB()
+		}
+	}
 	switch action {
 	case "setup":
 		if len(params) == 0 {
@@ -1184,6 +1437,39 @@ func (app *RdmUtil) Run(in io.Reader, out io.Writer, eout io.Writer, action stri
 		}
 		defer app.CloseDB()
 		src, err = app.GetRecordIds()
+	case "query_ids":
+		if len(params) == 0 {
+			return fmt.Errorf("missing filter expression")
+		}
+		if err := app.OpenDB(); err != nil {
+			return err
+		}
+		defer app.CloseDB()
+		src, err = app.QueryIds(strings.Join(params, " "))
+	case "setup_reindex_queue":
+		if err := app.OpenDB(); err != nil {
+			return err
+		}
+		defer app.CloseDB()
+		err = app.SetupReindexQueue()
+	case "get_reindex_ids":
+		clear := false
+		flagSet := flag.NewFlagSet("get_reindex_ids", flag.ContinueOnError)
+		flagSet.BoolVar(&clear, "clear", clear, "remove the returned ids from the reindex queue")
+		if err := flagSet.Parse(params); err != nil {
+			return err
+		}
+		if err := app.OpenDB(); err != nil {
+			return err
+		}
+		defer app.CloseDB()
+		src, err = app.GetReindexIds(clear)
+	case "reindex_drafts":
+		if err := app.OpenDB(); err != nil {
+			return err
+		}
+		defer app.CloseDB()
+		src, err = app.ReindexDrafts()
 	case "get_all_stale_ids":
 		if err := app.OpenDB(); err != nil {
 			return err
@@ -1285,6 +1571,56 @@ func (app *RdmUtil) Run(in io.Reader, out io.Writer, eout io.Writer, action stri
 			}
 			return nil
 		}
+	case "put_record":
+		inName, outName, err = getIOParams(params, false, false)
+		if inName != "" && inName != "-" {
+			src, err = os.ReadFile(inName)
+		} else {
+			src, err = io.ReadAll(in)
+		}
+		if err != nil {
+			return err
+		}
+		src, err = app.PutRecord(src)
+		if err != nil {
+			return err
+		}
+		if outName != "" && outName != "-" {
+			if err := os.WriteFile(outName, src, 0664); err != nil {
+				return err
+			}
+			return nil
+		}
+	case "validate":
+		vocabularyFName := ""
+		flagSet := flag.NewFlagSet("validate", flag.ContinueOnError)
+		flagSet.StringVar(&vocabularyFName, "vocabularies", vocabularyFName, "read vocabularies from a YAML file")
+		if err := flagSet.Parse(params); err != nil {
+			return err
+		}
+		inName, _, err = getIOParams(flagSet.Args(), false, false)
+		if err != nil {
+			return err
+		}
+		if inName != "" && inName != "-" {
+			src, err = os.ReadFile(inName)
+		} else {
+			src, err = io.ReadAll(in)
+		}
+		if err != nil {
+			return err
+		}
+		if vocabularyFName == "" && usePostgresDB(app.Cfg) {
+			if err := app.OpenDB(); err != nil {
+				return err
+			}
+			defer app.CloseDB()
+		}
+		src, err = app.ValidateRecord(src, vocabularyFName)
+		if src != nil {
+			fmt.Fprintf(out, "%s\n", bytes.TrimSpace(src))
+		}
+		return err
 	case "new_version":
 		recordId, _, _, err = getRecordParams(params, true, false, false)
 		if err != nil {
@@ -1304,11 +1640,25 @@ func (app *RdmUtil) Run(in io.Reader, out io.Writer, eout io.Writer, action stri
 		}
 		src, err = app.NewDraft(recordId)
 	case "get_draft":
-		recordId, _, _, err = getRecordParams(params, true, false, false)
+		fromPg := false
+		flagSet := flag.NewFlagSet("get_draft", flag.ContinueOnError)
+		flagSet.BoolVar(&fromPg, "pg", fromPg, "read the draft from Postgres")
+		if err := flagSet.Parse(params); err != nil {
+			return err
+		}
+		recordId, _, _, err = getRecordParams(flagSet.Args(), true, false, false)
 		if err !=nil {
 			return err
 		}
-		src, err = app.GetDraft(recordId)
+		if fromPg {
+			if err := app.OpenDB(); err != nil {
+				return err
+			}
+			defer app.CloseDB()
+			src, err = app.GetDraftFromPg(recordId)
+		} else {
+			src, err = app.GetDraft(recordId)
+		}
 	case "update_draft":
 		recordId, inName, _, err = getRecordParams(params, true, false, false)
 		if err != nil {
@@ -1446,9 +1796,18 @@ func (app *RdmUtil) Run(in io.Reader, out io.Writer, eout io.Writer, action stri
 		}
 		src, err = app.DeleteEndpoint(p)
 	case "harvest":
+		workers, resume := app.Cfg.Workers, false
+		flagSet := flag.NewFlagSet("harvest", flag.ContinueOnError)
+		flagSet.IntVar(&workers, "workers", workers, "number of concurrent workers retrieving records")
+		flagSet.BoolVar(&resume, "resume", resume, "skip ids finished in the checkpoint journal")
+		if err := flagSet.Parse(params); err != nil {
+			return err
+		}
+		params = flagSet.Args()
 		if len(params) != 1 {
 			return fmt.Errorf("JSON Identifier file required")
 		}
+		app.Cfg.Workers, app.Cfg.Resume = workers, resume
 		if err := app.OpenDB(); err != nil {
 			return err
 		}
@@ -1456,6 +1815,81 @@ func (app *RdmUtil) Run(in io.Reader, out io.Writer, eout io.Writer, action stri
 		if err := app.Harvest(params[0]); err != nil {
 			return err
 		}
+	case "sync":
+		markDeleted := false
+		flagSet := flag.NewFlagSet("sync", flag.ContinueOnError)
+		flagSet.BoolVar(&markDeleted, "mark-deleted", markDeleted, "keep removed records adding a tombstone instead of deleting them")
+		if err := flagSet.Parse(params); err != nil {
+			return err
+		}
+		params = flagSet.Args()
+		start := ""
+		if len(params) > 0 {
+			start = params[0]
+		}
+		if err := app.Sync(start, markDeleted); err != nil {
+			return err
+		}
+	case "patch_records":
+		publish, dryRun := false, false
+		flagSet := flag.NewFlagSet("patch_records", flag.ContinueOnError)
+		flagSet.BoolVar(&publish, "publish", publish, "publish each draft after it is patched")
+		flagSet.BoolVar(&dryRun, "dry-run", dryRun, "show the changes without creating drafts")
+		if err := flagSet.Parse(params); err != nil {
+			return err
+		}
+		params = flagSet.Args()
+		if len(params) != 2 {
+			return fmt.Errorf("JSON identifier file and patch file required")
+		}
+		if usePostgresDB(app.Cfg) {
+			if err := app.OpenDB(); err != nil {
+				return err
+			}
+			defer app.CloseDB()
+		}
+		if err := app.PatchRecords(params[0], params[1], publish, dryRun, out); err != nil {
+			return err
+		}
+
+	case "link_versions":
+		apply, publish, cacheName, cacheTTL := false, false, "", 720*time.Hour
+		flagSet := flag.NewFlagSet("link_versions", flag.ContinueOnError)
+		flagSet.BoolVar(&apply, "apply", apply, "update the records instead of listing the proposed links")
+		flagSet.BoolVar(&publish, "publish", publish, "publish each draft after it is linked, requires -apply")
+		flagSet.StringVar(&cacheName, "cache", cacheName, "save and reuse CrossRef responses in a dataset collection")
+		flagSet.DurationVar(&cacheTTL, "cache-ttl", cacheTTL, "how long cached responses are used, zero means forever")
+		if err := flagSet.Parse(params); err != nil {
+			return err
+		}
+		params = flagSet.Args()
+		if len(params) > 1 {
+			return fmt.Errorf("expected an optional JSON identifier file")
+		}
+		if publish && !apply {
+			return fmt.Errorf("-publish requires -apply")
+		}
+		idsName := ""
+		if len(params) == 1 {
+			idsName = params[0]
+		}
+		if cacheName != "" {
+			cache, err := OpenResponseCache(cacheName, cacheTTL)
+			if err != nil {
+				return err
+			}
+			defer cache.Close()
+			app.Cfg.Cache = cache
+		}
+		if usePostgresDB(app.Cfg) {
+			if err := app.OpenDB(); err != nil {
+				return err
+			}
+			defer app.CloseDB()
+		}
+		if err := app.LinkVersions(idsName, apply, publish, out); err != nil {
+			return err
+		}
 
 	default:
 		err = fmt.Errorf("%q action is not supported", action)
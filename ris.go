@@ -0,0 +1,161 @@
+package irdmtools
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// risTypes maps CSL types (see CSLType) to RIS reference types.
+var risTypes = map[string]string{
+	"article-journal":  "JOUR",
+	"article":          "UNPB",
+	"book":             "BOOK",
+	"chapter":          "CHAP",
+	"paper-conference": "CPAPER",
+	"report":           "RPRT",
+	"thesis":           "THES",
+	"patent":           "PAT",
+	"dataset":          "DATA",
+	"software":         "COMP",
+	"motion_picture":   "VIDEO",
+	"graphic":          "FIGURE",
+	"figure":           "FIGURE",
+	"map":              "MAP",
+	"webpage":          "ELEC",
+	"periodical":       "JFULL",
+	"speech":           "SLIDE",
+}
+
+// risNames returns the RIS name values for agents, "Family, Given" for
+// people and the literal name for organizations.
+func risNames(agents []*CitationAgent) []string {
+	names := []string{}
+	for _, agent := range agents {
+		if agent == nil {
+			continue
+		}
+		switch {
+		case agent.FamilyName != "":
+			family := agent.FamilyName
+			if agent.NonDroppingParticle != "" {
+				family = agent.NonDroppingParticle + " " + family
+			}
+			name := family
+			if agent.LivedName != "" {
+				name += ", " + agent.LivedName
+			}
+			if agent.Suffix != "" {
+				name += ", " + agent.Suffix
+			}
+			names = append(names, name)
+		case agent.Literal != "":
+			names = append(names, agent.Literal)
+		}
+	}
+	return names
+}
+
+// risDate returns a publication date in RIS's YYYY/MM/DD/ form.
+func risDate(s string) string {
+	dt := parseCSLDate(s)
+	if dt == nil || len(dt.DateParts) == 0 {
+		return ""
+	}
+	parts := []string{"", "", "", ""}
+	for i, val := range dt.DateParts[0] {
+		if i == 0 {
+			parts[i] = fmt.Sprintf("%04d", val)
+		} else {
+			parts[i] = fmt.Sprintf("%02d", val)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// ToRIS returns the citation as a RIS record.
+//
+// ```
+// fmt.Printf("%s", cite.ToRIS())
+// ```
+func (cite *Citation) ToRIS() string {
+	risType, ok := risTypes[cite.cslType()]
+	if !ok {
+		risType = "GEN"
+	}
+	var sb strings.Builder
+	add := func(tag string, val string) {
+		// NOTE: RIS is line oriented, line breaks are folded into spaces
+		val = strings.Join(strings.Fields(val), " ")
+		if val != "" {
+			fmt.Fprintf(&sb, "%s  - %s\r\n", tag, val)
+		}
+	}
+	add("TY", risType)
+	add("ID", cite.ID)
+	for _, name := range risNames(cite.Author) {
+		add("AU", name)
+	}
+	for _, name := range risNames(cite.Editor) {
+		add("A2", name)
+	}
+	for _, name := range risNames(cite.Translator) {
+		add("A4", name)
+	}
+	add("TI", cite.Title)
+	switch risType {
+	case "CHAP", "CPAPER":
+		if cite.BookTitle != "" {
+			add("T2", cite.BookTitle)
+		} else {
+			add("T2", cite.Publication)
+		}
+	default:
+		add("T2", cite.Publication)
+	}
+	add("T3", cite.Series)
+	add("PY", citationYear(cite))
+	add("DA", risDate(cite.PublicationDate))
+	add("VL", cite.Volume)
+	switch risType {
+	case "PAT":
+		add("IS", cite.PatentNumber)
+		add("PB", cite.PatentAssignee)
+		add("M1", cite.PatentApplication)
+	case "THES":
+		add("PB", cite.Publisher)
+		add("M3", cite.ThesisType)
+		add("M1", cite.ThesisDegree)
+	case "RPRT":
+		add("PB", cite.Publisher)
+		add("IS", cite.SeriesNumber)
+	default:
+		add("IS", cite.Issue)
+		add("PB", cite.Publisher)
+	}
+	if pages := strings.SplitN(strings.ReplaceAll(cite.Pages, "--", "-"), "-", 2); pages[0] != "" {
+		add("SP", pages[0])
+		if len(pages) > 1 {
+			add("EP", pages[1])
+		}
+	}
+	add("ET", cite.Edition)
+	add("CY", cite.PlaceOfPublication)
+	add("SN", cite.ISBN)
+	add("SN", cite.ISSN)
+	add("DO", strings.TrimPrefix(cite.DOI, "https://doi.org/"))
+	add("UR", cite.CiteUsingURL)
+	add("AB", cite.Abstract)
+	sb.WriteString("ER  - \r\n")
+	return sb.String()
+}
+
+// WriteRIS writes the citations as RIS records.
+func WriteRIS(out io.Writer, citations []*Citation) error {
+	for _, cite := range citations {
+		if _, err := fmt.Fprintf(out, "%s\r\n", cite.ToRIS()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package irdmtools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCitationToRIS(t *testing.T) {
+	cite := &Citation{
+		ID:              "authors:1",
+		Type:            "publication-article",
+		Title:           "A\ntitle",
+		Author:          []*CitationAgent{{FamilyName: "Doe", LivedName: "Jane"}, {Literal: "LIGO"}},
+		Publication:     "Nature",
+		PublicationDate: "2023-07-04",
+		Volume:          "600",
+		Issue:           "2",
+		Pages:           "10-12",
+		ISSN:            "0028-0836",
+		DOI:             "10.1000/182",
+	}
+	src := cite.ToRIS()
+	expected := strings.Join([]string{
+		"TY  - JOUR",
+		"ID  - authors:1",
+		"AU  - Doe, Jane",
+		"AU  - LIGO",
+		"TI  - A title",
+		"T2  - Nature",
+		"PY  - 2023",
+		"DA  - 2023/07/04/",
+		"VL  - 600",
+		"IS  - 2",
+		"SP  - 10",
+		"EP  - 12",
+		"SN  - 0028-0836",
+		"DO  - 10.1000/182",
+		"ER  - ",
+		"",
+	}, "\r\n")
+	if src != expected {
+		t.Errorf("expected\n%q\ngot\n%q", expected, src)
+	}
+
+	cite = &Citation{ID: "patent:1", Type: "patent", PatentNumber: "US 1234567", PatentAssignee: "Caltech"}
+	src = cite.ToRIS()
+	for _, expected := range []string{"TY  - PAT\r\n", "IS  - US 1234567\r\n", "PB  - Caltech\r\n"} {
+		if !strings.Contains(src, expected) {
+			t.Errorf("expected %q in %q", expected, src)
+		}
+	}
+}
+
+func TestCitationHasAgent(t *testing.T) {
+	cite := &Citation{
+		Author: []*CitationAgent{{FamilyName: "Doe", CLpid: "Doe-J"}},
+		Editor: []*CitationAgent{{FamilyName: "Roe", ORCID: "https://orcid.org/0000-0002-1825-0097"}},
+	}
+	if !cite.HasAgent("Doe-J", "") {
+		t.Errorf("expected author clpid to match")
+	}
+	if !cite.HasAgent("", "0000-0002-1825-0097") {
+		t.Errorf("expected editor ORCID to match")
+	}
+	if cite.HasAgent("Roe-R", "") || cite.HasAgent("", "") {
+		t.Errorf("expected no match")
+	}
+}
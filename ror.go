@@ -1,10 +1,16 @@
 package irdmtools
 
 import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"os"
 	"strings"
 	"time"
+	"unicode"
 )
 
 type RorOrgAPIResponse struct {
@@ -13,26 +19,38 @@ type RorOrgAPIResponse struct {
 	Items []map[string]interface{} `json:"items,omitempty"`
 }
 
-// lookupROR
-func lookupROR(doiSuffix string, trimPrefix bool) (string, bool) {
+// queryROR retrieves the ROR organizations API response for a query.
+func queryROR(query string) ([]byte, error) {
 	// Call: https://api.ror.org/organizations?query={doiPrefix}
 	orgAPI := "https://api.ror.org/organizations"
 	client := &http.Client{}
 	req, err := http.NewRequest("GET", orgAPI, nil)
 	if err != nil {
-		return "", false
+		return nil, err
 	}
 	// Add our query using the DOI prefix
 	q := req.URL.Query()
-	q.Set("query", doiSuffix)
+	q.Set("query", query)
 	req.URL.RawQuery = q.Encode()
-    resp, err := client.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
-		return "", false
+		return nil, err
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s", orgAPI, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// lookupROR returns the ROR of the organization matching the DOI suffix,
+// e.g. of a funder DOI. Responses are read from and saved to cache when
+// it isn't nil.
+func lookupROR(cache *ResponseCache, doiSuffix string, trimPrefix bool) (string, bool) {
+	body, err := cache.Fetch("ror", doiSuffix, func() ([]byte, error) {
+		return queryROR(doiSuffix)
+	})
+	if err == nil {
 		result := new(RorOrgAPIResponse)
 		if err := JSONUnmarshal(body, &result); err != nil {
 			return "", false
@@ -52,3 +70,482 @@ func lookupROR(doiSuffix string, trimPrefix bool) (string, bool) {
 	return "", false
 }
 
+
+// DefaultRorThreshold is the confidence a match from a ROR data dump
+// needs before its ROR id is used.
+const DefaultRorThreshold = 0.85
+
+// RorOrg is an organization from the ROR data dump.
+type RorOrg struct {
+	// ID is the ROR id without the "https://ror.org/" prefix
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Aliases  []string `json:"aliases,omitempty"`
+	Acronyms []string `json:"acronyms,omitempty"`
+	Labels   []string `json:"labels,omitempty"`
+	// FundRef holds the Crossref Funder ids, e.g. "100000001"
+	FundRef []string `json:"fundref,omitempty"`
+	Status  string   `json:"status,omitempty"`
+}
+
+// RorMatch is the result of matching a name against a RorIndex.
+type RorMatch struct {
+	// Query is the name that was matched
+	Query string `json:"query"`
+	// ID and Name are the best matching organization, if any
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+	// MatchedOn is how the organization matched, e.g. name, label, alias,
+	// acronym, fundref or, for partial matches, words
+	MatchedOn string `json:"matched_on,omitempty"`
+	// Confidence is between 0.0 and 1.0
+	Confidence float64 `json:"confidence"`
+}
+
+// rorEntry is a name of an organization in the index.
+type rorEntry struct {
+	org  int
+	kind string
+	key  string
+}
+
+// rorKindWeight is the confidence of an exact match of a name by kind.
+var rorKindWeight = map[string]float64{
+	"name":    1.0,
+	"label":   0.95,
+	"alias":   0.9,
+	"acronym": 0.75,
+}
+
+// RorIndex is a local index of the ROR data dump used to match
+// affiliation and funder names to ROR ids without calling the ROR API.
+// Matches below Threshold are added to Reported instead of being used.
+type RorIndex struct {
+	Threshold float64
+	// Reported holds the matches that were below Threshold
+	Reported []*RorMatch
+
+	orgs     []*RorOrg
+	keys     map[string][]*rorEntry
+	words    map[string][]*rorEntry
+	fundRef  map[string]int
+	reported map[string]bool
+}
+
+// rorStopWords aren't used to match names.
+var rorStopWords = map[string]bool{
+	"the": true, "of": true, "and": true, "for": true, "at": true, "in": true,
+}
+
+// rorWords returns the lower cased words in a name. Latin letters are
+// folded to ASCII, punctuation and stop words are dropped.
+func rorWords(name string) []string {
+	var (
+		words []string
+		sb    strings.Builder
+	)
+	flush := func() {
+		if w := sb.String(); w != "" && !rorStopWords[w] {
+			words = append(words, w)
+		}
+		sb.Reset()
+	}
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			sb.WriteRune(r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if folded, ok := latinFold[r]; ok {
+				sb.WriteString(folded)
+			} else {
+				sb.WriteRune(r)
+			}
+		case r == '\'' || r == '’':
+			// NOTE: keep possessives together, e.g. "Women's"
+		default:
+			flush()
+		}
+	}
+	flush()
+	return words
+}
+
+// NewRorIndex creates an index of organizations.
+func NewRorIndex(orgs []*RorOrg) *RorIndex {
+	idx := &RorIndex{
+		Threshold: DefaultRorThreshold,
+		keys:      map[string][]*rorEntry{},
+		words:     map[string][]*rorEntry{},
+		fundRef:   map[string]int{},
+		reported:  map[string]bool{},
+	}
+	for _, org := range orgs {
+		idx.add(org)
+	}
+	return idx
+}
+
+// add adds an organization to the index.
+func (idx *RorIndex) add(org *RorOrg) {
+	i := len(idx.orgs)
+	idx.orgs = append(idx.orgs, org)
+	addName := func(kind string, name string) {
+		words := rorWords(name)
+		if len(words) == 0 {
+			return
+		}
+		entry := &rorEntry{org: i, kind: kind, key: strings.Join(words, " ")}
+		idx.keys[entry.key] = append(idx.keys[entry.key], entry)
+		if kind != "acronym" {
+			for _, w := range words {
+				idx.words[w] = append(idx.words[w], entry)
+			}
+		}
+	}
+	addName("name", org.Name)
+	for _, label := range org.Labels {
+		addName("label", label)
+	}
+	for _, alias := range org.Aliases {
+		addName("alias", alias)
+	}
+	for _, acronym := range org.Acronyms {
+		addName("acronym", acronym)
+	}
+	for _, id := range org.FundRef {
+		idx.fundRef[id] = i
+	}
+}
+
+// Len returns the number of organizations in the index.
+func (idx *RorIndex) Len() int {
+	if idx == nil {
+		return 0
+	}
+	return len(idx.orgs)
+}
+
+// orgWeight lowers the confidence for withdrawn or inactive organizations.
+func (idx *RorIndex) orgWeight(i int) float64 {
+	if status := idx.orgs[i].Status; status != "" && status != "active" {
+		return 0.9
+	}
+	return 1.0
+}
+
+// matchExact matches the name's words to the index. When the name
+// matches more than one organization the confidence is the best
+// match's share of the matches.
+func (idx *RorIndex) matchExact(query string, key string) *RorMatch {
+	best := map[int]*rorEntry{}
+	weights := map[int]float64{}
+	for _, entry := range idx.keys[key] {
+		w := rorKindWeight[entry.kind] * idx.orgWeight(entry.org)
+		if w > weights[entry.org] {
+			best[entry.org], weights[entry.org] = entry, w
+		}
+	}
+	if len(best) == 0 {
+		return nil
+	}
+	total, bestOrg := 0.0, -1
+	for i, w := range weights {
+		total += w
+		if bestOrg < 0 || w > weights[bestOrg] || (w == weights[bestOrg] && i < bestOrg) {
+			bestOrg = i
+		}
+	}
+	org := idx.orgs[bestOrg]
+	return &RorMatch{
+		Query:      query,
+		ID:         org.ID,
+		Name:       org.Name,
+		MatchedOn:  best[bestOrg].kind,
+		Confidence: weights[bestOrg] * weights[bestOrg] / total,
+	}
+}
+
+// matchWords finds the organization name sharing the most words with the
+// query. Partial matches are never more than 0.8 confidence.
+func (idx *RorIndex) matchWords(query string, words []string) *RorMatch {
+	// NOTE: common words like "university" are only used when the
+	// query has nothing more specific.
+	const maxPostings = 2000
+	candidates := map[*rorEntry]bool{}
+	var rarest []*rorEntry
+	for _, w := range words {
+		postings := idx.words[w]
+		if rarest == nil || (len(postings) > 0 && len(postings) < len(rarest)) {
+			rarest = postings
+		}
+		if len(postings) <= maxPostings {
+			for _, entry := range postings {
+				candidates[entry] = true
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		for _, entry := range rarest {
+			candidates[entry] = true
+		}
+	}
+	var (
+		best     *rorEntry
+		bestConf float64
+	)
+	for entry := range candidates {
+		conf := titleSimilarity(words, strings.Fields(entry.key)) * rorKindWeight[entry.kind] * idx.orgWeight(entry.org) * 0.8
+		if conf > bestConf || (conf == bestConf && best != nil && entry.org < best.org) {
+			best, bestConf = entry, conf
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	org := idx.orgs[best.org]
+	return &RorMatch{
+		Query:      query,
+		ID:         org.ID,
+		Name:       org.Name,
+		MatchedOn:  "words",
+		Confidence: bestConf,
+	}
+}
+
+// Match returns the best matching organization for a name with its
+// confidence. Affiliations like "Division of Physics, Caltech, Pasadena"
+// are also matched a part at a time. Match returns nil if nothing
+// matches.
+//
+// ```
+// m := idx.Match("California Institute of Technology")
+// if m != nil && m.Confidence >= idx.Threshold {
+//     fmt.Printf("%s %s\n", m.ID, m.Name)
+// }
+// ```
+func (idx *RorIndex) Match(name string) *RorMatch {
+	if idx == nil {
+		return nil
+	}
+	words := rorWords(name)
+	if len(words) == 0 {
+		return nil
+	}
+	if m := idx.matchExact(name, strings.Join(words, " ")); m != nil {
+		return m
+	}
+	var best *RorMatch
+	if parts := strings.Split(name, ","); len(parts) > 1 {
+		for _, part := range parts {
+			if m := idx.matchExact(name, strings.Join(rorWords(part), " ")); m != nil {
+				m.Confidence = m.Confidence * 0.9
+				if best == nil || m.Confidence > best.Confidence {
+					best = m
+				}
+			}
+		}
+	}
+	if m := idx.matchWords(name, words); m != nil && (best == nil || m.Confidence > best.Confidence) {
+		best = m
+	}
+	return best
+}
+
+// report adds a below threshold match to Reported once per query.
+func (idx *RorIndex) report(m *RorMatch) {
+	if idx.reported[m.Query] {
+		return
+	}
+	idx.reported[m.Query] = true
+	idx.Reported = append(idx.Reported, m)
+}
+
+// Resolve returns the ROR id for a name if the match is at least
+// Threshold confidence. Other matches are reported, see Reported.
+func (idx *RorIndex) Resolve(name string) (string, bool) {
+	if idx == nil || strings.TrimSpace(name) == "" {
+		return "", false
+	}
+	m := idx.Match(name)
+	if m == nil {
+		idx.report(&RorMatch{Query: name})
+		return "", false
+	}
+	if m.Confidence < idx.Threshold {
+		idx.report(m)
+		return "", false
+	}
+	return m.ID, true
+}
+
+// ResolveFundRef returns the ROR id for a Crossref Funder id, e.g.
+// "100000001" or "10.13039/100000001".
+func (idx *RorIndex) ResolveFundRef(funderID string) (string, bool) {
+	if idx == nil {
+		return "", false
+	}
+	funderID = strings.TrimPrefix(strings.TrimSpace(funderID), "https://doi.org/")
+	funderID = strings.TrimPrefix(funderID, "10.13039/")
+	if i, ok := idx.fundRef[funderID]; ok {
+		return idx.orgs[i].ID, true
+	}
+	return "", false
+}
+
+// WriteRorReport writes the matches as tab delimited lines of query,
+// confidence, ROR id and name.
+func WriteRorReport(out io.Writer, matches []*RorMatch) error {
+	for _, m := range matches {
+		if _, err := fmt.Fprintf(out, "%s\t%.2f\t%s\t%s\n", m.Query, m.Confidence, m.ID, m.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rorDumpRecord holds the parts of a ROR data dump record we use, it
+// supports both the v1 and v2 schema.
+type rorDumpRecord struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	// Schema v1
+	Name     string   `json:"name"`
+	Aliases  []string `json:"aliases"`
+	Acronyms []string `json:"acronyms"`
+	Labels   []struct {
+		Label string `json:"label"`
+	} `json:"labels"`
+	// Schema v2
+	Names []struct {
+		Value string   `json:"value"`
+		Types []string `json:"types"`
+	} `json:"names"`
+	// ExternalIDs is an object in v1 and a list in v2
+	ExternalIDs json.RawMessage `json:"external_ids"`
+}
+
+// toRorOrg converts a dump record to a RorOrg.
+func (rec *rorDumpRecord) toRorOrg() *RorOrg {
+	org := &RorOrg{
+		ID:       strings.TrimPrefix(rec.ID, "https://ror.org/"),
+		Name:     rec.Name,
+		Aliases:  rec.Aliases,
+		Acronyms: rec.Acronyms,
+		Status:   rec.Status,
+	}
+	for _, label := range rec.Labels {
+		org.Labels = append(org.Labels, label.Label)
+	}
+	for _, name := range rec.Names {
+		for _, nameType := range name.Types {
+			switch nameType {
+			case "ror_display":
+				org.Name = name.Value
+			case "label":
+				org.Labels = append(org.Labels, name.Value)
+			case "alias":
+				org.Aliases = append(org.Aliases, name.Value)
+			case "acronym":
+				org.Acronyms = append(org.Acronyms, name.Value)
+			}
+		}
+	}
+	// NOTE: "all" is a list of ids except for GRID ids in v1
+	type externalID struct {
+		Type string          `json:"type"`
+		All  json.RawMessage `json:"all"`
+	}
+	v1 := map[string]*externalID{}
+	v2 := []*externalID{}
+	if err := json.Unmarshal(rec.ExternalIDs, &v2); err != nil {
+		if err := json.Unmarshal(rec.ExternalIDs, &v1); err == nil {
+			for idType, ids := range v1 {
+				if ids != nil {
+					ids.Type = idType
+					v2 = append(v2, ids)
+				}
+			}
+		}
+	}
+	for _, ids := range v2 {
+		if strings.ToLower(ids.Type) == "fundref" {
+			all := []string{}
+			if err := json.Unmarshal(ids.All, &all); err == nil {
+				org.FundRef = append(org.FundRef, all...)
+			}
+		}
+	}
+	return org
+}
+
+// readRorDump reads the organizations in a ROR data dump's JSON array.
+func readRorDump(in io.Reader) ([]*RorOrg, error) {
+	dec := json.NewDecoder(in)
+	if t, err := dec.Token(); err != nil {
+		return nil, err
+	} else if delim, ok := t.(json.Delim); !ok || delim != '[' {
+		return nil, fmt.Errorf("expected a JSON array of organizations")
+	}
+	orgs := []*RorOrg{}
+	for dec.More() {
+		rec := new(rorDumpRecord)
+		if err := dec.Decode(rec); err != nil {
+			return nil, err
+		}
+		if rec.ID != "" {
+			orgs = append(orgs, rec.toRorOrg())
+		}
+	}
+	return orgs, nil
+}
+
+// LoadRorDump loads a ROR data dump, either the JSON file or the zip file
+// it is distributed in, see <https://ror.readme.io/docs/data-dump>. Both
+// the v1 and v2 schema are supported.
+//
+// ```
+// idx, err := LoadRorDump("v1.50-2024-07-29-ror-data.zip")
+// if err != nil {
+//     // ... handle error ...
+// }
+// app.Cfg.Ror = idx
+// ```
+func LoadRorDump(fName string) (*RorIndex, error) {
+	if !strings.HasSuffix(strings.ToLower(fName), ".zip") {
+		fp, err := os.Open(fName)
+		if err != nil {
+			return nil, err
+		}
+		defer fp.Close()
+		orgs, err := readRorDump(fp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %q, %s", fName, err)
+		}
+		return NewRorIndex(orgs), nil
+	}
+	z, err := zip.OpenReader(fName)
+	if err != nil {
+		return nil, err
+	}
+	defer z.Close()
+	// NOTE: dumps may have v1 and v2 schema JSON files, prefer v2
+	var dump *zip.File
+	for _, f := range z.File {
+		if strings.HasSuffix(f.Name, ".json") && (dump == nil || strings.Contains(f.Name, "schema_v2")) {
+			dump = f
+		}
+	}
+	if dump == nil {
+		return nil, fmt.Errorf("no JSON file found in %q", fName)
+	}
+	fp, err := dump.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	orgs, err := readRorDump(fp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q in %q, %s", dump.Name, fName, err)
+	}
+	return NewRorIndex(orgs), nil
+}
@@ -1,13 +1,19 @@
 package irdmtools
 
 import (
+	"archive/zip"
+	"os"
+	"path"
 	"testing"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/crossrefapi"
 )
 
 func TestLookupROR(t *testing.T) {
 	doiSuffix := "100000025"
 	expectedROR := "https://ror.org/04xeg9z08"
-	ror, ok := lookupROR(doiSuffix, false)
+	ror, ok := lookupROR(nil, doiSuffix, false)
 	if ! ok {
 		t.Errorf("expected lookupROR to return OK, failed")
 	}
@@ -15,3 +21,149 @@ func TestLookupROR(t *testing.T) {
 		t.Errorf("expected ror %q, got %q", expectedROR, ror)
 	}
 }
+
+func TestRorIndex(t *testing.T) {
+	idx, err := LoadRorDump(path.Join("testdata", "ror", "ror-data.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx.Len() != 4 {
+		t.Fatalf("expected 4 organizations, got %d", idx.Len())
+	}
+	testMatches := []struct {
+		name      string
+		id        string
+		matchedOn string
+		resolved  bool
+	}{
+		{"California Institute of Technology", "05dxps055", "name", true},
+		{"the national science foundation", "021nxhr62", "name", true},
+		{"Fundacion Nacional para la Ciencia", "021nxhr62", "label", true},
+		{"Division of Physics, California Institute of Technology, Pasadena, CA 91125, USA", "05dxps055", "name", true},
+		// Acronyms are reported, "NSF" is ambiguous
+		{"Caltech", "05dxps055", "acronym", false},
+		{"NSF", "021nxhr62", "acronym", false},
+		// Partial matches are reported
+		{"Caltech Jet Propulsion Lab", "027k65916", "words", false},
+	}
+	for _, test := range testMatches {
+		m := idx.Match(test.name)
+		if m == nil {
+			t.Errorf("expected a match for %q", test.name)
+			continue
+		}
+		if m.ID != test.id || m.MatchedOn != test.matchedOn {
+			t.Errorf("expected %q to match %s on %s, got %+v", test.name, test.id, test.matchedOn, m)
+		}
+		ror, ok := idx.Resolve(test.name)
+		if ok != test.resolved {
+			t.Errorf("expected %q resolved %t, got %t (%.2f)", test.name, test.resolved, ok, m.Confidence)
+		}
+		if ok && ror != test.id {
+			t.Errorf("expected %q to resolve to %s, got %s", test.name, test.id, ror)
+		}
+	}
+	if m := idx.Match("Nowhere Museum"); m != nil {
+		t.Errorf("expected no match, got %+v", m)
+	}
+	if _, ok := idx.Resolve("Unknown Institute of Nowhere"); ok {
+		t.Errorf("expected a weak partial match to not resolve")
+	}
+	if len(idx.Reported) != 4 {
+		t.Errorf("expected 4 reported matches, got %d", len(idx.Reported))
+	}
+	for _, funderID := range []string{"100000001", "10.13039/100000001", "https://doi.org/10.13039/100000001"} {
+		if ror, ok := idx.ResolveFundRef(funderID); !ok || ror != "021nxhr62" {
+			t.Errorf("expected %q to resolve to 021nxhr62, got %q", funderID, ror)
+		}
+	}
+}
+
+func TestLoadRorDumpZip(t *testing.T) {
+	src, err := os.ReadFile(path.Join("testdata", "ror", "ror-data.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fName := path.Join(t.TempDir(), "v1.50-2024-07-29-ror-data.zip")
+	fp, err := os.Create(fName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	z := zip.NewWriter(fp)
+	for _, name := range []string{"v1.50-2024-07-29-ror-data.json", "v1.50-2024-07-29-ror-data_schema_v2.json"} {
+		w, err := z.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(src)
+	}
+	z.Close()
+	fp.Close()
+	idx, err := LoadRorDump(fName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx.Len() != 4 {
+		t.Errorf("expected 4 organizations, got %d", idx.Len())
+	}
+}
+
+func TestRorCrosswalks(t *testing.T) {
+	idx, err := LoadRorDump(path.Join("testdata", "ror", "ror-data.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := new(Config)
+	cfg.Ror = idx
+	affiliation := crosswalkWorksAuthorAffiliationToCreatorAffiliation(cfg, &crossrefapi.Organization{
+		Name: "California Institute of Technology",
+	})
+	if affiliation == nil || affiliation.ID != "05dxps055" {
+		t.Errorf("expected affiliation 05dxps055, got %+v", affiliation)
+	}
+	affiliation = crosswalkWorksAuthorAffiliationToCreatorAffiliation(cfg, &crossrefapi.Organization{
+		Name: "Caltech",
+	})
+	if affiliation == nil || affiliation.ID != "" || affiliation.Name != "Caltech" {
+		t.Errorf("expected affiliation name without id, got %+v", affiliation)
+	}
+
+	work := &crossrefapi.Works{
+		Message: &crossrefapi.Message{
+			Funder: []*crossrefapi.Funder{
+				{Name: "National Science Foundation", DOI: "10.13039/100000001", DoiAssertedBy: "crossref", Award: []string{"PHY-1234567"}},
+				{Name: "Jet Propulsion Laboratory"},
+			},
+		},
+	}
+	funding := getWorksFunding(cfg, work)
+	if len(funding) != 2 {
+		t.Fatalf("expected two funders, got %d", len(funding))
+	}
+	for i, expected := range []string{"021nxhr62", "027k65916"} {
+		if funding[i].Funder == nil || funding[i].Funder.Identifier != expected {
+			t.Errorf("expected funder %d to be %s, got %+v", i, expected, funding[i].Funder)
+		}
+	}
+
+	object := map[string]interface{}{
+		"data": map[string]interface{}{
+			"attributes": map[string]interface{}{
+				"fundingReferences": []interface{}{
+					map[string]interface{}{"funderName": "NSF", "funderIdentifier": "https://doi.org/10.13039/100000001", "funderIdentifierType": "Crossref Funder ID"},
+					map[string]interface{}{"funderName": "Caltech", "funderIdentifier": "https://ror.org/05dxps055", "funderIdentifierType": "ROR"},
+					map[string]interface{}{"funderName": "Swiss National Science Foundation"},
+				},
+			},
+		},
+	}
+	funders := getObjectFunding(cfg, object)
+	if len(funders) != 3 {
+		t.Fatalf("expected three funders, got %d", len(funders))
+	}
+	for i, expected := range []string{"021nxhr62", "05dxps055", "00yjd3n13"} {
+		if funders[i].Funder == nil || funders[i].Funder.Identifier != expected {
+			t.Errorf("expected funder %d to be %s, got %+v", i, expected, funders[i].Funder)
+		}
+	}
+}
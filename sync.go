@@ -0,0 +1,245 @@
+package irdmtools
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/dataset/v2"
+)
+
+// SyncState holds the high-water mark for a dataset collection kept in
+// sync with RDM. It is stored next to the collection, e.g. "authors.ds"
+// has the state file "authors.ds.sync.json".
+type SyncState struct {
+	// CName is the dataset collection being synced
+	CName string `json:"c_name"`
+	// LastUpdated is the last `updated` value successfully processed
+	LastUpdated string `json:"last_updated,omitempty"`
+	// LastRun is when the sync last completed
+	LastRun string `json:"last_run,omitempty"`
+}
+
+// syncChange describes a record modified in RDM since the high-water mark.
+type syncChange struct {
+	ID      string
+	Updated string
+	// Removed holds the reason the record should no longer be in the
+	// collection (e.g. "deleted", "tombstone", "restricted"), empty otherwise.
+	Removed string
+}
+
+// SyncStateName returns the name of the sync state file for a collection.
+func SyncStateName(cName string) string {
+	return strings.TrimSuffix(cName, "/") + ".sync.json"
+}
+
+// ReadSyncState reads the sync state for a collection. If no state has
+// been saved an empty state is returned.
+func ReadSyncState(cName string) (*SyncState, error) {
+	state := &SyncState{CName: cName}
+	src, err := os.ReadFile(SyncStateName(cName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, err
+	}
+	if err := JSONUnmarshal(src, &state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// WriteSyncState saves the sync state for a collection.
+func WriteSyncState(state *SyncState) error {
+	src, err := JSONMarshalIndent(state, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(SyncStateName(state.CName), src, 0664)
+}
+
+// getChangedRecordsFromPg returns the records updated after since ordered
+// by their updated timestamp. Unlike getModifiedRecordIdsFromPg it includes
+// records that were deleted, tombstoned or restricted so they can be
+// removed from a collection.
+func getChangedRecordsFromPg(db *sql.DB, since string) ([]*syncChange, error) {
+	if db == nil {
+		return nil, fmt.Errorf("postgres connection not open")
+	}
+	// NOTE: A hard deleted record has a NULL json column so we get the
+	// record id from the pidstore.
+	stmt := `SELECT COALESCE(r.json->>'id', p.pid_value) AS rdmid,
+       to_char(r.updated, 'YYYY-MM-DD"T"HH24:MI:SS.US') AS updated,
+       (CASE
+           WHEN r.json IS NULL THEN 'deleted'
+           WHEN COALESCE(r.deletion_status, 'P') <> 'P' THEN 'deleted'
+           WHEN r.json ? 'tombstone' THEN 'tombstone'
+           WHEN COALESCE(r.json->'access'->>'record', '') <> 'public' THEN 'restricted'
+           ELSE ''
+       END) AS removed
+FROM rdm_records_metadata AS r
+LEFT JOIN pidstore_pid AS p
+  ON (p.object_uuid = r.id AND p.pid_type = 'recid')
+WHERE r.updated > $1
+ORDER BY r.updated`
+	rows, err := db.Query(stmt, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	changes := []*syncChange{}
+	for rows.Next() {
+		var (
+			rdmid   sql.NullString
+			updated string
+			removed string
+		)
+		if err := rows.Scan(&rdmid, &updated, &removed); err != nil {
+			return nil, err
+		}
+		if !rdmid.Valid {
+			continue
+		}
+		changes = append(changes, &syncChange{ID: rdmid.String, Updated: updated, Removed: removed})
+	}
+	err = rows.Err()
+	return changes, err
+}
+
+// applySyncChanges updates the collection with the changed records. Removed
+// records are deleted from the collection or, if markDeleted is true, kept
+// with a tombstone added. It returns the new high-water mark (the updated
+// value of the last change where it and all changes before it succeeded),
+// the counts of updated, removed and failed records.
+func applySyncChanges(c *dataset.Collection, cName string, changes []*syncChange, fetch harvestFunc, markDeleted bool, mark string, l *log.Logger) (string, int, int, int) {
+	uCnt, rCnt, eCnt, tot := 0, 0, 0, len(changes)
+	t0 := time.Now()
+	iTime, reportProgress := time.Now(), false
+	ok := true
+	for i, change := range changes {
+		var err error
+		if change.Removed != "" {
+			if c.HasKey(change.ID) {
+				if markDeleted {
+					err = markRemoved(c, change)
+				} else {
+					err = c.Delete(change.ID)
+				}
+			}
+			if err == nil {
+				rCnt++
+			}
+		} else {
+			var obj interface{}
+			obj, err = fetch(change.ID)
+			if err == nil {
+				err = saveObject(c, change.ID, obj)
+			}
+			if err == nil {
+				uCnt++
+			}
+		}
+		if err != nil {
+			l.Printf("failed to sync (%d) %q, %s", i, change.ID, err)
+			eCnt++
+			ok = false
+		} else if ok {
+			mark = change.Updated
+		}
+		if iTime, reportProgress = CheckWaitInterval(iTime, time.Minute); reportProgress || i == 0 {
+			l.Printf("%s last id %q (%d/%d) %s: %s", cName, change.ID, i, tot, time.Since(t0).Round(time.Second), ProgressETA(t0, i, tot))
+		}
+	}
+	return mark, uCnt, rCnt, eCnt
+}
+
+// markRemoved adds a tombstone to the object held in the collection.
+func markRemoved(c *dataset.Collection, change *syncChange) error {
+	obj := map[string]interface{}{}
+	if err := c.Read(change.ID, obj); err != nil {
+		return err
+	}
+	if _, ok := obj["tombstone"]; !ok {
+		obj["tombstone"] = map[string]interface{}{
+			"reason":    change.Removed,
+			"timestamp": change.Updated,
+		}
+	}
+	return c.UpdateObject(change.ID, obj)
+}
+
+// Sync brings the dataset collection named in cfg.CName up to date with
+// RDM. It retrieves the records updated since the collection's high-water
+// mark, or since start if provided, and removes (or marks if markDeleted
+// is true) the records that were deleted, tombstoned or restricted. The
+// high-water mark is saved when the run completes.
+//
+// ```
+// if err := Sync(cfg, "", false, false); err != nil {
+//    // ... handle error ...
+// }
+// ```
+func Sync(cfg *Config, start string, markDeleted bool, debug bool) error {
+	cName := cfg.CName
+	if cName == "" {
+		return fmt.Errorf("dataset collection not configured")
+	}
+	c, err := dataset.Open(cName)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	state, err := ReadSyncState(cName)
+	if err != nil {
+		return err
+	}
+	since := state.LastUpdated
+	if start != "" {
+		since = start
+	}
+	if since == "" {
+		// NOTE: without a high-water mark we sync everything.
+		since = "1970-01-01"
+	}
+	connStr := cfg.MakeDSN()
+	if connStr == "" {
+		return fmt.Errorf("ERROR: sync requires Postgres access")
+	}
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	cfg.pgDB = db
+	defer func() { cfg.pgDB = nil }()
+
+	l := log.New(os.Stderr, "", 1)
+	t0 := time.Now()
+	changes, err := getChangedRecordsFromPg(db, since)
+	if err != nil {
+		return err
+	}
+	if debug {
+		l.Printf("%d records changed since %s", len(changes), since)
+	}
+	fetch := func(id string) (interface{}, error) {
+		return GetRecord(cfg, id, false)
+	}
+	mark, uCnt, rCnt, eCnt := applySyncChanges(c, cName, changes, fetch, markDeleted, since, l)
+	state.LastUpdated = mark
+	state.LastRun = time.Now().Format(time.RFC3339)
+	if err := WriteSyncState(state); err != nil {
+		return err
+	}
+	l.Printf("%d updated, %d removed, %d errors, high-water mark %s, running time %s", uCnt, rCnt, eCnt, mark, time.Since(t0).Round(time.Second))
+	if eCnt > 0 {
+		return fmt.Errorf("%d records failed to sync, they will be retried on the next run", eCnt)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package irdmtools
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"testing"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/dataset/v2"
+)
+
+func TestSyncState(t *testing.T) {
+	cName := path.Join(t.TempDir(), "sync_test.ds")
+	state, err := ReadSyncState(cName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.LastUpdated != "" {
+		t.Errorf("expected an empty high-water mark, got %q", state.LastUpdated)
+	}
+	state.LastUpdated = "2023-10-01T12:30:00.000001"
+	if err := WriteSyncState(state); err != nil {
+		t.Fatal(err)
+	}
+	state, err = ReadSyncState(cName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.LastUpdated != "2023-10-01T12:30:00.000001" {
+		t.Errorf("expected high-water mark to be saved, got %q", state.LastUpdated)
+	}
+}
+
+func TestApplySyncChanges(t *testing.T) {
+	cName := path.Join(t.TempDir(), "sync_test.ds")
+	c, err := dataset.Init(cName, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for _, key := range []string{"aaaaa-00001", "aaaaa-00002", "aaaaa-00003"} {
+		if err := c.CreateObject(key, map[string]interface{}{"id": key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	changes := []*syncChange{
+		{ID: "aaaaa-00001", Updated: "2023-10-01T00:00:01.000000"},
+		{ID: "aaaaa-00002", Updated: "2023-10-01T00:00:02.000000", Removed: "deleted"},
+		{ID: "aaaaa-00004", Updated: "2023-10-01T00:00:03.000000"},
+		{ID: "aaaaa-00005", Updated: "2023-10-01T00:00:04.000000"},
+		{ID: "aaaaa-00003", Updated: "2023-10-01T00:00:05.000000", Removed: "restricted"},
+	}
+	fetch := func(id string) (interface{}, error) {
+		if id == "aaaaa-00005" {
+			return nil, fmt.Errorf("%s not found", id)
+		}
+		return map[string]interface{}{"id": id, "synced": true}, nil
+	}
+	l := log.New(io.Discard, "", 0)
+	mark, uCnt, rCnt, eCnt := applySyncChanges(c, cName, changes, fetch, false, "2023-09-30", l)
+	if uCnt != 2 || rCnt != 2 || eCnt != 1 {
+		t.Errorf("expected 2 updated, 2 removed, 1 error, got %d, %d, %d", uCnt, rCnt, eCnt)
+	}
+	// The high-water mark must stop before the first failure
+	if mark != "2023-10-01T00:00:03.000000" {
+		t.Errorf("unexpected high-water mark %q", mark)
+	}
+	if c.HasKey("aaaaa-00002") || c.HasKey("aaaaa-00003") {
+		t.Errorf("expected removed records to be deleted from %s", cName)
+	}
+	if !c.HasKey("aaaaa-00004") {
+		t.Errorf("expected aaaaa-00004 to be added to %s", cName)
+	}
+
+	// Marking keeps the record and adds a tombstone
+	changes = []*syncChange{
+		{ID: "aaaaa-00001", Updated: "2023-10-02T00:00:01.000000", Removed: "tombstone"},
+	}
+	mark, _, rCnt, eCnt = applySyncChanges(c, cName, changes, fetch, true, mark, l)
+	if rCnt != 1 || eCnt != 0 || mark != "2023-10-02T00:00:01.000000" {
+		t.Errorf("expected one marked record, got %d removed, %d errors, mark %q", rCnt, eCnt, mark)
+	}
+	obj := map[string]interface{}{}
+	if err := c.Read("aaaaa-00001", obj); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := obj["tombstone"]; !ok {
+		t.Errorf("expected aaaaa-00001 to have a tombstone, %+v", obj)
+	}
+}
@@ -0,0 +1,383 @@
+package irdmtools
+
+import (
+	"bytes"
+	"database/sql"
+	"embed"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	// Caltech Library Packages
+	"github.com/caltechlibrary/simplified"
+
+	// 3rd Party packages
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed irdm/vocabularies/*.yaml irdm/vocabularies/licenses.csv
+var vocabularyFiles embed.FS
+
+// Vocabularies maps an RDM vocabulary name (e.g. "resourcetypes",
+// "contributorsroles") to the set of ids it allows.
+type Vocabularies map[string]map[string]bool
+
+// VocabularyViolation describes a vocabulary id in a record that is not
+// in the RDM instance's vocabulary.
+type VocabularyViolation struct {
+	// Path is the JSON path to the value, e.g. .metadata.resource_type.id
+	Path string `json:"path"`
+	// Vocabulary is the vocabulary name, e.g. resourcetypes
+	Vocabulary string `json:"vocabulary"`
+	// Value is the id not found in the vocabulary
+	Value string `json:"value"`
+}
+
+// String returns a human readable description of the violation.
+func (violation *VocabularyViolation) String() string {
+	if violation.Value == "" {
+		return fmt.Sprintf("%s is required by %s", violation.Path, violation.Vocabulary)
+	}
+	return fmt.Sprintf("%s %q not in %s", violation.Path, violation.Value, violation.Vocabulary)
+}
+
+// defaultVocabularyFiles maps the RDM vocabulary names to the data files
+// in irdm/vocabularies (see irdm/vocabularies.yaml).
+var defaultVocabularyFiles = map[string]string{
+	"creatorsroles":     "roles.yaml",
+	"contributorsroles": "roles.yaml",
+	"resourcetypes":     "resource_types.yaml",
+	"descriptiontypes":  "description_types.yaml",
+	"datetypes":         "date_types.yaml",
+	"relationtypes":     "relation_types.yaml",
+	"titletypes":        "title_types.yaml",
+	"identifiertypes":   "identifier_types.yaml",
+}
+
+// Add adds ids to the named vocabulary.
+func (v Vocabularies) Add(name string, ids ...string) {
+	if _, ok := v[name]; !ok {
+		v[name] = map[string]bool{}
+	}
+	for _, id := range ids {
+		v[name][id] = true
+	}
+}
+
+// Has returns true if the named vocabulary is known and includes id.
+// Unknown vocabularies are not checked so Has returns true for them.
+func (v Vocabularies) Has(name string, id string) bool {
+	ids, ok := v[name]
+	if !ok {
+		return true
+	}
+	return ids[id]
+}
+
+// vocabularyIds returns the ids from a list of vocabulary terms. A term
+// is either an id or an object with an id attribute.
+func vocabularyIds(terms []interface{}) []string {
+	ids := []string{}
+	for _, term := range terms {
+		switch t := term.(type) {
+		case string:
+			if t != "" {
+				ids = append(ids, t)
+			}
+		case map[string]interface{}:
+			if id, ok := t["id"].(string); ok && id != "" {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// parseVocabularyYAML returns the ids in a vocabulary data file, a YAML
+// list of objects with an id attribute.
+func parseVocabularyYAML(src []byte) ([]string, error) {
+	terms := []interface{}{}
+	if err := yaml.Unmarshal(src, &terms); err != nil {
+		return nil, err
+	}
+	return vocabularyIds(terms), nil
+}
+
+// parseVocabularyCSV returns the ids in an RDM vocabulary CSV file, e.g.
+// licenses.csv. The file is semicolon delimited with an id column.
+func parseVocabularyCSV(src []byte) ([]string, error) {
+	r := csv.NewReader(bytes.NewReader(src))
+	r.Comma = ';'
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for i, row := range rows {
+		if i == 0 || len(row) == 0 || row[0] == "" {
+			continue
+		}
+		ids = append(ids, row[0])
+	}
+	return ids, nil
+}
+
+// DefaultVocabularies returns the RDM vocabularies shipped with irdmtools
+// in irdm/vocabularies along with the resource types used by the
+// EPrints crosswalk.
+func DefaultVocabularies() (Vocabularies, error) {
+	vocabularies := Vocabularies{}
+	for name, fName := range defaultVocabularyFiles {
+		src, err := vocabularyFiles.ReadFile(path.Join("irdm/vocabularies", fName))
+		if err != nil {
+			return nil, err
+		}
+		ids, err := parseVocabularyYAML(src)
+		if err != nil {
+			return nil, fmt.Errorf("%s, %s", fName, err)
+		}
+		vocabularies.Add(name, ids...)
+	}
+	src, err := vocabularyFiles.ReadFile("irdm/vocabularies/licenses.csv")
+	if err != nil {
+		return nil, err
+	}
+	ids, err := parseVocabularyCSV(src)
+	if err != nil {
+		return nil, fmt.Errorf("licenses.csv, %s", err)
+	}
+	vocabularies.Add("licenses", ids...)
+	for _, id := range defaultEPrintResourceTypeMap {
+		vocabularies.Add("resourcetypes", id)
+	}
+	return vocabularies, nil
+}
+
+// LoadVocabulariesYAML reads vocabularies from a YAML file mapping the
+// vocabulary names to their terms. The terms are either listed or, as in
+// irdm/vocabularies.yaml, read from a "data-file" relative to the YAML
+// file.
+//
+// ```
+// resourcetypes:
+//   - publication-article
+//   - id: publication-book
+// contributorsroles:
+//   data-file: vocabularies/roles.yaml
+// ```
+func LoadVocabulariesYAML(fName string) (Vocabularies, error) {
+	src, err := os.ReadFile(fName)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]interface{}{}
+	if err := yaml.Unmarshal(src, &m); err != nil {
+		return nil, fmt.Errorf("%s, %s", fName, err)
+	}
+	vocabularies := Vocabularies{}
+	for name, val := range m {
+		switch v := val.(type) {
+		case []interface{}:
+			vocabularies.Add(name, vocabularyIds(v)...)
+		case map[string]interface{}:
+			dataFile, ok := v["data-file"].(string)
+			if !ok {
+				return nil, fmt.Errorf("%s, %s is missing data-file", fName, name)
+			}
+			if !filepath.IsAbs(dataFile) {
+				dataFile = filepath.Join(filepath.Dir(fName), dataFile)
+			}
+			data, err := os.ReadFile(dataFile)
+			if err != nil {
+				return nil, err
+			}
+			var ids []string
+			if filepath.Ext(dataFile) == ".csv" {
+				ids, err = parseVocabularyCSV(data)
+			} else {
+				ids, err = parseVocabularyYAML(data)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s, %s", dataFile, err)
+			}
+			vocabularies.Add(name, ids...)
+		default:
+			return nil, fmt.Errorf("%s, unexpected value for %s", fName, name)
+		}
+	}
+	return vocabularies, nil
+}
+
+// vocabulariesFromPg reads the vocabularies from an RDM instance's
+// vocabularies_metadata table. Subjects are read from subject_metadata
+// when the table exists.
+func vocabulariesFromPg(db *sql.DB) (Vocabularies, error) {
+	if db == nil {
+		return nil, fmt.Errorf("postgres connection not open")
+	}
+	vocabularies := Vocabularies{}
+	stmt := `SELECT COALESCE(json->'type'->>'id', json->>'type'), json->>'id'
+  FROM vocabularies_metadata
+ WHERE json IS NOT NULL AND json->>'id' IS NOT NULL`
+	rows, err := db.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, id sql.NullString
+		if err := rows.Scan(&name, &id); err != nil {
+			return nil, err
+		}
+		if name.Valid && id.Valid {
+			vocabularies.Add(name.String, id.String)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// NOTE: subject_metadata is only present if subjects vocabularies,
+	// e.g. MeSH, have been loaded.
+	var hasSubjects bool
+	if err := db.QueryRow(`SELECT to_regclass('subject_metadata') IS NOT NULL`).Scan(&hasSubjects); err != nil {
+		return nil, err
+	}
+	if hasSubjects {
+		subjects, err := db.Query(`SELECT json->>'id' FROM subject_metadata WHERE json IS NOT NULL AND json->>'id' IS NOT NULL`)
+		if err != nil {
+			return nil, err
+		}
+		defer subjects.Close()
+		for subjects.Next() {
+			var id string
+			if err := subjects.Scan(&id); err != nil {
+				return nil, err
+			}
+			vocabularies.Add("subjects", id)
+		}
+		if err := subjects.Err(); err != nil {
+			return nil, err
+		}
+	}
+	// NOTE: creator and contributor roles share the roles vocabulary
+	// in RDM's default configuration.
+	if roles, ok := vocabularies["roles"]; ok {
+		for _, name := range []string{"creatorsroles", "contributorsroles"} {
+			if _, ok := vocabularies[name]; !ok {
+				vocabularies[name] = roles
+			}
+		}
+	}
+	return vocabularies, nil
+}
+
+// LoadVocabularies returns the vocabularies to validate records with. If
+// fName is not empty the vocabularies are read from the YAML file (see
+// LoadVocabulariesYAML), otherwise they are read from Postgres if the
+// connection is open, otherwise the defaults are used.
+//
+// ```
+// vocabularies, err := LoadVocabularies(cfg, "")
+// if err != nil {
+//    // ... handle error ...
+// }
+// for _, violation := range ValidateRecord(rec, vocabularies) {
+//    fmt.Printf("%s\n", violation)
+// }
+// ```
+func LoadVocabularies(cfg *Config, fName string) (Vocabularies, error) {
+	if fName != "" {
+		return LoadVocabulariesYAML(fName)
+	}
+	if cfg != nil && cfg.pgDB != nil {
+		return vocabulariesFromPg(cfg.pgDB)
+	}
+	return DefaultVocabularies()
+}
+
+// ValidateRecord checks the vocabulary ids in a record, e.g. resource
+// type, creator and contributor roles, licenses and subjects, and returns
+// a violation for each id that is not in the vocabularies. Vocabularies
+// not included in vocabularies are not checked.
+func ValidateRecord(rec *simplified.Record, vocabularies Vocabularies) []*VocabularyViolation {
+	violations := []*VocabularyViolation{}
+	if rec == nil || rec.Metadata == nil {
+		return violations
+	}
+	check := func(name string, id string, path string, args ...interface{}) {
+		if id != "" && !vocabularies.Has(name, id) {
+			violations = append(violations, &VocabularyViolation{
+				Path:       fmt.Sprintf(path, args...),
+				Vocabulary: name,
+				Value:      id,
+			})
+		}
+	}
+	metadata := rec.Metadata
+	if id, ok := metadata.ResourceType["id"].(string); ok {
+		check("resourcetypes", id, ".metadata.resource_type.id")
+	} else {
+		violations = append(violations, &VocabularyViolation{
+			Path:       ".metadata.resource_type.id",
+			Vocabulary: "resourcetypes",
+		})
+	}
+	for i, creator := range metadata.Creators {
+		if creator != nil && creator.Role != nil {
+			check("creatorsroles", creator.Role.ID, ".metadata.creators[%d].role.id", i)
+		}
+	}
+	for i, contributor := range metadata.Contributors {
+		if contributor == nil || contributor.Role == nil || contributor.Role.ID == "" {
+			// NOTE: RDM requires a role for contributors
+			violations = append(violations, &VocabularyViolation{
+				Path:       fmt.Sprintf(".metadata.contributors[%d].role.id", i),
+				Vocabulary: "contributorsroles",
+			})
+			continue
+		}
+		check("contributorsroles", contributor.Role.ID, ".metadata.contributors[%d].role.id", i)
+	}
+	for i, title := range metadata.AdditionalTitles {
+		if title != nil && title.Type != nil {
+			check("titletypes", title.Type.ID, ".metadata.additional_titles[%d].type.id", i)
+		}
+	}
+	for i, description := range metadata.AdditionalDescriptions {
+		if description != nil && description.Type != nil {
+			check("descriptiontypes", description.Type.ID, ".metadata.additional_descriptions[%d].type.id", i)
+		}
+	}
+	for i, dt := range metadata.Dates {
+		if dt != nil && dt.Type != nil {
+			check("datetypes", dt.Type.ID, ".metadata.dates[%d].type.id", i)
+		}
+	}
+	for i, identifier := range metadata.RelatedIdentifiers {
+		if identifier != nil && identifier.RelationType != nil {
+			check("relationtypes", identifier.RelationType.ID, ".metadata.related_identifiers[%d].relation_type.id", i)
+		}
+		if identifier != nil && identifier.ResourceType != nil {
+			check("resourcetypes", identifier.ResourceType.ID, ".metadata.related_identifiers[%d].resource_type.id", i)
+		}
+	}
+	for i, right := range metadata.Rights {
+		if right != nil {
+			check("licenses", right.ID, ".metadata.rights[%d].id", i)
+		}
+	}
+	for i, subject := range metadata.Subjects {
+		if subject != nil {
+			check("subjects", subject.ID, ".metadata.subjects[%d].id", i)
+		}
+	}
+	for i, language := range metadata.Languages {
+		if id, ok := language["id"].(string); ok {
+			check("languages", id, ".metadata.languages[%d].id", i)
+		}
+	}
+	return violations
+}
@@ -0,0 +1,117 @@
+package irdmtools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	// Caltech Library Packages
+	"github.com/caltechlibrary/simplified"
+)
+
+func TestDefaultVocabularies(t *testing.T) {
+	vocabularies, err := DefaultVocabularies()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, id := range map[string]string{
+		"resourcetypes":     "publication-article",
+		"contributorsroles": "editor",
+		"titletypes":        "alternative-title",
+		"relationtypes":     "iscitedby",
+		"licenses":          "cc-by-4.0",
+	} {
+		if !vocabularies.Has(name, id) {
+			t.Errorf("expected %q in %s", id, name)
+		}
+	}
+	// Resource types used by the EPrints crosswalk are included
+	if !vocabularies.Has("resourcetypes", "publication-oralhistory") {
+		t.Errorf("expected crosswalk resource types in resourcetypes")
+	}
+	if vocabularies.Has("contributorsroles", "thesis_advisor") {
+		t.Errorf("did not expect thesis_advisor in contributorsroles")
+	}
+}
+
+func TestLoadVocabulariesYAML(t *testing.T) {
+	vocabularies, err := LoadVocabulariesYAML(filepath.Join("irdm", "vocabularies.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !vocabularies.Has("creatorsroles", "editor") || vocabularies.Has("datetypes", "published") {
+		t.Errorf("unexpected vocabularies from data files, %+v", vocabularies["datetypes"])
+	}
+	fName := filepath.Join(t.TempDir(), "vocabularies.yaml")
+	if err := os.WriteFile(fName, []byte(`resourcetypes:
+  - publication-article
+  - id: publication-book
+subjects: []
+`), 0664); err != nil {
+		t.Fatal(err)
+	}
+	vocabularies, err = LoadVocabulariesYAML(fName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !vocabularies.Has("resourcetypes", "publication-book") || vocabularies.Has("resourcetypes", "dataset") {
+		t.Errorf("unexpected resourcetypes %+v", vocabularies["resourcetypes"])
+	}
+	if vocabularies.Has("subjects", "Astronomy") {
+		t.Errorf("expected an empty subjects vocabulary")
+	}
+	if !vocabularies.Has("licenses", "anything") {
+		t.Errorf("expected vocabularies not listed to be unchecked")
+	}
+}
+
+func TestValidateRecord(t *testing.T) {
+	vocabularies, err := DefaultVocabularies()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vocabularies.Add("subjects", "http://id.loc.gov/authorities/subjects/sh85009003")
+	src := []byte(`{
+    "metadata": {
+        "resource_type": { "id": "journal-article" },
+        "title": "A title",
+        "creators": [
+            { "person_or_org": { "type": "personal", "family_name": "Doe" }, "role": { "id": "author" } }
+        ],
+        "contributors": [
+            { "person_or_org": { "type": "personal", "family_name": "Roe" }, "role": { "id": "editor" } },
+            { "person_or_org": { "type": "personal", "family_name": "Smith" } }
+        ],
+        "rights": [ { "id": "cc-by-4.0" }, { "id": "cc-by-9.0" } ],
+        "subjects": [
+            { "subject": "Astronomy" },
+            { "id": "http://id.loc.gov/authorities/subjects/sh85009003" },
+            { "id": "http://id.loc.gov/authorities/subjects/sh00000000" }
+        ],
+        "related_identifiers": [
+            { "scheme": "doi", "identifier": "10.1000/182", "relation_type": { "id": "ispreprintof" } }
+        ]
+    }
+}`)
+	rec := new(simplified.Record)
+	if err := JSONUnmarshal(src, &rec); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		".metadata.resource_type.id":                        "journal-article",
+		".metadata.creators[0].role.id":                     "author",
+		".metadata.contributors[1].role.id":                 "",
+		".metadata.rights[1].id":                            "cc-by-9.0",
+		".metadata.subjects[2].id":                          "http://id.loc.gov/authorities/subjects/sh00000000",
+		".metadata.related_identifiers[0].relation_type.id": "ispreprintof",
+	}
+	violations := ValidateRecord(rec, vocabularies)
+	if len(violations) != len(expected) {
+		t.Errorf("expected %d violations, got %d, %+v", len(expected), len(violations), violations)
+	}
+	for _, violation := range violations {
+		if val, ok := expected[violation.Path]; !ok || val != violation.Value {
+			t.Errorf("unexpected violation %s", violation)
+		}
+	}
+}
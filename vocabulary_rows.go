@@ -0,0 +1,166 @@
+package irdmtools
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Mapping CSV and JSON sources to RDM vocabularies.
+//
+// people2vocabulary, groups2vocabulary and awards2vocabulary read a CSV
+// file, the first row holding the column names, or a JSON array of
+// objects. Each row is mapped to a vocabulary term one column at a time
+// by a mapField function, e.g. mapPeopleField. Rows that fail to map are
+// logged and counted. The terms are sorted by id so re-running a
+// conversion only changes the terms that changed.
+
+// vocabularyRow is a row of a vocabulary source.
+type vocabularyRow struct {
+	// No is the row number, the CSV header is row zero
+	No int
+	// Keys holds the column names in order
+	Keys []string
+	// Values maps the column names to their values
+	Values map[string]string
+}
+
+// readVocabularyRows reads the rows of a CSV file, or a JSON array of
+// objects if isCSV is false. JSON values that aren't strings are
+// converted to strings, object keys are sorted.
+func readVocabularyRows(src []byte, isCSV bool) ([]*vocabularyRow, error) {
+	rows := []*vocabularyRow{}
+	if !isCSV {
+		objects := []map[string]interface{}{}
+		if err := JSONUnmarshal(src, &objects); err != nil {
+			return nil, err
+		}
+		for i, obj := range objects {
+			row := &vocabularyRow{No: i + 1, Values: map[string]string{}}
+			for key, val := range obj {
+				row.Keys = append(row.Keys, key)
+				switch v := val.(type) {
+				case nil:
+					row.Values[key] = ""
+				case string:
+					row.Values[key] = v
+				default:
+					row.Values[key] = fmt.Sprintf("%v", v)
+				}
+			}
+			sort.Strings(row.Keys)
+			rows = append(rows, row)
+		}
+		return rows, nil
+	}
+	r := csv.NewReader(bytes.NewReader(src))
+	fields := []string{}
+	for rowNo := 0; ; rowNo++ {
+		cells, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("row %d error, %s", rowNo, err)
+		}
+		if rowNo == 0 {
+			for _, field := range cells {
+				// NOTE: spreadsheets often save a byte order mark
+				fields = append(fields, strings.TrimSpace(strings.TrimPrefix(field, "\ufeff")))
+			}
+			continue
+		}
+		row := &vocabularyRow{No: rowNo, Keys: fields, Values: map[string]string{}}
+		for colNo, val := range cells {
+			row.Values[fields[colNo]] = val
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// mapColumns calls mapField for each column of the row in order. All
+// the columns are mapped, the errors are returned together.
+func (row *vocabularyRow) mapColumns(mapField func(key string, val string) error) error {
+	errs := []string{}
+	for _, key := range row.Keys {
+		if err := mapField(key, row.Values[key]); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// mapVocabularyRows calls mapRow for each row. Failed rows are logged
+// and counted, if any fail an error is returned after all the rows are
+// mapped.
+func mapVocabularyRows(rows []*vocabularyRow, mapRow func(row *vocabularyRow) error) error {
+	e := 0
+	for _, row := range rows {
+		if err := mapRow(row); err != nil {
+			log.Printf("row %d error, %s", row.No, err)
+			e += 1
+		}
+	}
+	if e > 0 {
+		return fmt.Errorf("%d rows failed to map", e)
+	}
+	return nil
+}
+
+// vocabularyId returns a stable vocabulary id for a name, it is lower
+// case with runs of other characters than letters and digits replaced by
+// a dash, e.g. "Division of Biology" becomes "division-of-biology".
+func vocabularyId(name string) string {
+	sb := strings.Builder{}
+	dash := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && sb.Len() > 0 {
+				sb.WriteRune('-')
+			}
+			sb.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return sb.String()
+}
+
+// rorAlphabet is the Crockford base32 alphabet used by ROR ids.
+const rorAlphabet = "0123456789abcdefghjkmnpqrstvwxyz"
+
+// ValidROR returns true if id is a well formed ROR id with a correct
+// checksum, e.g. "05dxps055". The "https://ror.org/" prefix is allowed.
+func ValidROR(id string) bool {
+	id = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(id), "https://ror.org/"))
+	if len(id) != 9 || id[0] != '0' {
+		return false
+	}
+	n := 0
+	for _, c := range id[1:7] {
+		i := strings.IndexRune(rorAlphabet, c)
+		if i < 0 {
+			return false
+		}
+		n = n*32 + i
+	}
+	checksum := 0
+	for _, c := range id[7:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+		checksum = checksum*10 + int(c-'0')
+	}
+	// NOTE: the checksum is ISO/IEC 7064 MOD 97-10
+	return checksum == 98-((n*100)%97)
+}
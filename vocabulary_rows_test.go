@@ -0,0 +1,148 @@
+package irdmtools
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestValidROR(t *testing.T) {
+	for _, id := range []string{CaltechROR, JPLROR, "021nxhr62", "https://ror.org/027ka1x80", "01CWQZE88"} {
+		if !ValidROR(id) {
+			t.Errorf("expected %q to be a valid ROR id", id)
+		}
+	}
+	for _, id := range []string{"", "05dxps056", "15dxps055", "05dxps05", "05dxpu055", "NSF"} {
+		if ValidROR(id) {
+			t.Errorf("expected %q to be an invalid ROR id", id)
+		}
+	}
+	for name, id := range DefaultFunderNames {
+		if !ValidROR(id) {
+			t.Errorf("%q has an invalid ROR id %q", name, id)
+		}
+	}
+}
+
+func TestVocabularyId(t *testing.T) {
+	testCases := map[string]string{
+		"Division of Biology":     "division-of-biology",
+		"  Lab (Jet Propulsion) ": "lab-jet-propulsion",
+		"Ångström Lab":            "ångström-lab",
+		"--":                      "",
+	}
+	for src, expected := range testCases {
+		if got := vocabularyId(src); got != expected {
+			t.Errorf("vocabularyId(%q) expected %q, got %q", src, expected, got)
+		}
+	}
+}
+
+func TestGroupsToVocabulary(t *testing.T) {
+	src, err := os.ReadFile(path.Join("testdata", "vocabularies", "groups.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups, err := GroupsToVocabulary(src, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, group := range groups {
+		ids = append(ids, group.ID)
+	}
+	expected := "Caltech-Center-for-Environmental-Microbial-Interactions-(CEMI) Tectonics-Observatory division-of-biology"
+	if got := strings.Join(ids, " "); got != expected {
+		t.Errorf("expected ids %q, got %q", expected, got)
+	}
+	if groups[2].Title["en"] != "Division of Biology" {
+		t.Errorf("unexpected group %+v", groups[2])
+	}
+
+	src, err = os.ReadFile(path.Join("testdata", "vocabularies", "groups.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups, err = GroupsToVocabulary(src, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 2 || groups[0].ID != "Tectonics-Observatory" || groups[1].ID != "division-of-biology" {
+		t.Errorf("unexpected groups %+v", groups)
+	}
+
+	for _, src := range []string{
+		"key,name\nA,Alpha\nA,Another Alpha\n",
+		"key,name\nB,\n",
+		"key,name\nNot a key,Beta\n",
+	} {
+		if _, err := GroupsToVocabulary([]byte(src), true); err == nil {
+			t.Errorf("expected an error for %q", src)
+		}
+	}
+}
+
+func TestAwardsToVocabulary(t *testing.T) {
+	src, err := os.ReadFile(path.Join("testdata", "vocabularies", "awards.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	awards, err := AwardsToVocabulary(src, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(awards) != 2 {
+		t.Fatalf("expected 2 awards, got %d", len(awards))
+	}
+	// The prime funding source is used if there is one
+	if award := awards[0]; award.ID != "AWD-000001" || award.Number != "80NSSC20K0001" || award.Funder["id"] != "027ka1x80" || award.Title["en"] != "Ocean Models" {
+		t.Errorf("unexpected award %+v", award)
+	}
+	if award := awards[1]; award.ID != "AWD-000002" || award.Number != "PHY-1234567" || award.Funder["id"] != "021nxhr62" {
+		t.Errorf("unexpected award %+v", award)
+	}
+
+	src, err = os.ReadFile(path.Join("testdata", "vocabularies", "funders.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	funders, err := ReadFunderNames(src, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if funders["Example Foundation"] != CaltechROR {
+		t.Errorf("unexpected funders %+v", funders)
+	}
+	awards, err = AwardsToVocabulary([]byte("Award #,Oracle Funding Source Name,Funding Src Award #\n,Example Foundation,EF 42\n"), true, funders)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(awards) != 1 || awards[0].ID != CaltechROR+"::EF 42" {
+		t.Errorf("unexpected awards %+v", awards)
+	}
+
+	// Generic columns with a ROR funder
+	awards, err = AwardsToVocabulary([]byte(`[{"number": "N00014-21-1-2000", "funder": "https://ror.org/00rk2pe57", "acronym": "ONR"}]`), false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(awards) != 1 || awards[0].ID != "00rk2pe57::N00014-21-1-2000" || awards[0].Acronym != "ONR" || awards[0].Title != nil {
+		t.Errorf("unexpected awards %+v", awards)
+	}
+
+	for _, src := range []string{
+		// No funder mapping
+		"Award #,Oracle Funding Source Name,Funding Src Award #\nA1,Example Foundation,42\n",
+		// Bad ROR checksum
+		"id,number,funder\nA2,42,021nxhr63\n",
+		// Number without a letter or digit
+		"id,number,funder\nA3,--,021nxhr62\n",
+		// Conflicting ids
+		"id,number,funder\nA4,42,021nxhr62\nA4,43,021nxhr62\n",
+	} {
+		if _, err := AwardsToVocabulary([]byte(src), true, nil); err == nil {
+			t.Errorf("expected an error for %q", src)
+		}
+	}
+}